@@ -0,0 +1,291 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/mounter"
+
+	"golang.org/x/sys/unix"
+)
+
+// benchmarks run on a file of this size, unless set by --size
+const defaultBenchSizeMB = 256
+
+type benchResult struct {
+	name       string
+	bytes      int64
+	operations int
+	duration   time.Duration
+}
+
+// runBenchCommand runs a short sequential and random read/write benchmark through a mount - an existing one, or
+// a temporary mount of a container - for comparing reports of slow storage against a standard measurement
+func runBenchCommand(args []string) int {
+	flagSet := flag.NewFlagSet("bench", flag.ContinueOnError)
+	target := flagSet.String("target", "", "Directory in an existing mount to benchmark in")
+	container := flagSet.String("container", "", "Container to mount temporarily when --target isn't set")
+	cluster := flagSet.String("cluster", "", "Cluster of --container, defaults to the configured default")
+	accessKey := flagSet.String("access-key", os.Getenv("V3IO_ACCESS_KEY"), "Access key of the temporary mount (default $V3IO_ACCESS_KEY)")
+	sizeMB := flagSet.Int("size", defaultBenchSizeMB, "Size of the benchmark file in MB")
+	blockSize := flagSet.Int("block-size", 1024*1024, "Block size of the sequential benchmarks in bytes")
+	randomBlockSize := flagSet.Int("random-block-size", 4096, "Block size of the random benchmarks in bytes")
+	randomDuration := flagSet.Duration("random-duration", 10*time.Second, "Duration of each random benchmark")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	if *sizeMB <= 0 || *blockSize <= 0 || *randomBlockSize <= 0 || *randomBlockSize > *sizeMB*1024*1024 {
+		fmt.Fprintln(os.Stderr, "Invalid --size, --block-size or --random-block-size")
+		return 2
+	}
+
+	benchDir := *target
+	if benchDir == "" {
+		if *container == "" || *accessKey == "" {
+			fmt.Fprintln(os.Stderr, "Either --target, or --container and --access-key must be set")
+			return 2
+		}
+
+		targetPath, unmount, err := mountForBench(*cluster, *container, *accessKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to mount %s: %s\n", *container, err)
+			return 1
+		}
+
+		defer unmount()
+		benchDir = targetPath
+	}
+
+	benchFile, err := ioutil.TempFile(benchDir, ".flex-fuse-bench-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create benchmark file in %s: %s\n", benchDir, err)
+		return 1
+	}
+
+	defer os.Remove(benchFile.Name()) // nolint: errcheck
+	defer benchFile.Close()           // nolint: errcheck
+
+	size := int64(*sizeMB) * 1024 * 1024
+
+	fmt.Printf("Benchmarking %s (%d MB file)\n", benchDir, *sizeMB)
+
+	for _, benchmark := range []func() (*benchResult, error){
+		func() (*benchResult, error) { return benchSequentialWrite(benchFile, size, *blockSize) },
+		func() (*benchResult, error) { return benchSequentialRead(benchFile, size, *blockSize) },
+		func() (*benchResult, error) {
+			return benchRandom(benchFile, size, *randomBlockSize, *randomDuration, false)
+		},
+		func() (*benchResult, error) {
+			return benchRandom(benchFile, size, *randomBlockSize, *randomDuration, true)
+		},
+	} {
+		result, err := benchmark()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Benchmark failed: %s\n", err)
+			return 1
+		}
+
+		seconds := result.duration.Seconds()
+		fmt.Printf("%-20s %10.1f MB/s %10.0f IOPS\n",
+			result.name,
+			float64(result.bytes)/1024/1024/seconds,
+			float64(result.operations)/seconds)
+	}
+
+	return 0
+}
+
+// mountForBench mounts a container on a temporary target path, returning it and a function unmounting it
+func mountForBench(cluster string, container string, accessKey string) (string, func(), error) {
+	benchConfig, err := config.New()
+	if err != nil {
+		return "", nil, err
+	}
+
+	benchMounter := mounter.New(benchConfig)
+	cri.SetTempDir(benchConfig.TempDir, int64(benchConfig.TempDirMinFreeMB)<<20)
+
+	workDir, err := cri.CreateTempDir("flex-fuse-bench-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	// the target path is laid out as kubelet's, which names the container
+	podID := fmt.Sprintf("bench%d", time.Now().Unix())
+	targetPath := path.Join(workDir, "pods", podID, "volumes", "v3io~fuse", "bench")
+
+	if err := os.MkdirAll(targetPath, 0750); err != nil {
+		os.RemoveAll(workDir) // nolint: errcheck
+		return "", nil, err
+	}
+
+	if err := benchMounter.Mount(context.Background(), &mounter.MountRequest{
+		TargetPath: targetPath,
+		AccessKey:  accessKey,
+		Cluster:    cluster,
+		Container:  container,
+	}); err != nil {
+		os.RemoveAll(workDir) // nolint: errcheck
+		return "", nil, err
+	}
+
+	return targetPath, func() {
+		if err := benchMounter.Unmount(context.Background(), targetPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to unmount %s: %s\n", targetPath, err)
+			return
+		}
+
+		os.RemoveAll(workDir) // nolint: errcheck
+	}, nil
+}
+
+func benchSequentialWrite(benchFile *os.File, size int64, blockSize int) (*benchResult, error) {
+	block := make([]byte, blockSize)
+	rand.Read(block) // nolint: errcheck
+
+	result := benchResult{name: "sequential write"}
+	startedAt := time.Now()
+
+	for result.bytes < size {
+		written, err := benchFile.WriteAt(block[:minInt64(int64(blockSize), size-result.bytes)], result.bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		result.bytes += int64(written)
+		result.operations++
+	}
+
+	// the data must reach the mount, not just the page cache
+	if err := benchFile.Sync(); err != nil {
+		return nil, err
+	}
+
+	result.duration = time.Since(startedAt)
+
+	return &result, nil
+}
+
+func benchSequentialRead(benchFile *os.File, size int64, blockSize int) (*benchResult, error) {
+	dropPageCache(benchFile)
+
+	block := make([]byte, blockSize)
+
+	result := benchResult{name: "sequential read"}
+	startedAt := time.Now()
+
+	for result.bytes < size {
+		read, err := benchFile.ReadAt(block, result.bytes)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if read == 0 {
+			break
+		}
+
+		result.bytes += int64(read)
+		result.operations++
+	}
+
+	result.duration = time.Since(startedAt)
+
+	return &result, nil
+}
+
+// benchRandom reads or writes blocks at random offsets of the file for a duration
+func benchRandom(benchFile *os.File,
+	size int64,
+	blockSize int,
+	duration time.Duration,
+	write bool) (*benchResult, error) {
+
+	block := make([]byte, blockSize)
+	rand.Read(block) // nolint: errcheck
+
+	blocks := size / int64(blockSize)
+
+	if !write {
+		dropPageCache(benchFile)
+	}
+
+	result := benchResult{name: "random read"}
+	if write {
+		result.name = "random write"
+	}
+
+	startedAt := time.Now()
+
+	for time.Since(startedAt) < duration {
+		offset := rand.Int63n(blocks) * int64(blockSize)
+
+		var transferred int
+		var err error
+
+		if write {
+			transferred, err = benchFile.WriteAt(block, offset)
+		} else {
+			transferred, err = benchFile.ReadAt(block, offset)
+		}
+
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		result.bytes += int64(transferred)
+		result.operations++
+	}
+
+	if write {
+		if err := benchFile.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	result.duration = time.Since(startedAt)
+
+	return &result, nil
+}
+
+// dropPageCache evicts the file's cached pages (best effort), so reads measure the mount rather than memory
+func dropPageCache(benchFile *os.File) {
+	unix.Fadvise(int(benchFile.Fd()), 0, 0, unix.FADV_DONTNEED) // nolint: errcheck
+}
+
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+)
+
+// runConfigCommand handles "config validate [path]", printing the effective configuration
+func runConfigCommand(args []string) int {
+	if len(args) < 1 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "Usage: fuse config validate [path]")
+		return 2
+	}
+
+	configPath := config.Path()
+	if len(args) > 1 {
+		configPath = args[1]
+	}
+
+	effectiveConfig, err := config.NewFromFile(configPath, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration %s: %s\n", configPath, err)
+		return 1
+	}
+
+	for _, warning := range effectiveConfig.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	fmt.Println(effectiveConfig.String())
+
+	return 0
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// how long the driver waits to connect to the daemon before handling the action itself
+const daemonDialTimeout = time.Second
+
+// how long the daemon waits for a forwarded request to be sent, and for its response to be received
+var daemonConnectionTimeout = 10 * time.Second
+
+// daemonCRI is the runtime connection the daemon shares between requests
+var daemonCRI cri.CRI
+
+// actions handled by the daemon when it's running
+var daemonActions = map[string]bool{
+	"mount":         true,
+	"unmount":       true,
+	"attach":        true,
+	"detach":        true,
+	"waitforattach": true,
+	"isattached":    true,
+	"mountdevice":   true,
+	"unmountdevice": true,
+}
+
+// daemonRequest is a kubelet invocation forwarded to the daemon
+type daemonRequest struct {
+	Args    []string `json:"args"`
+	TraceID string   `json:"traceId"`
+}
+
+// runDaemonCommand serves kubelet invocations forwarded by the driver over a unix socket, with a persistent
+// runtime connection, until interrupted
+func runDaemonCommand(args []string) int {
+	daemonConfig, err := config.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read configuration: %s\n", err)
+		return 1
+	}
+
+	flagSet := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	socketPath := flagSet.String("socket", daemonConfig.DaemonSocket, "Unix socket to serve on")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	// target paths are mounted and unmounted in the daemon's mount namespace
+	if err := flex.CheckMountNamespace(cri.GetNodeLayout().KubeletRootDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Can't serve kubelet invocations: %s\n", err)
+		return 1
+	}
+
+	daemonCRI, err = cri.New(daemonConfig.RuntimeBackend, daemonConfig.RuntimeEndpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create CRI: %s\n", err)
+		return 1
+	}
+
+	defer daemonCRI.Close() // nolint: errcheck
+
+	if err := os.MkdirAll(path.Dir(*socketPath), 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create socket directory: %s\n", err)
+		return 1
+	}
+
+	// a previous daemon's socket is left behind if it was killed
+	os.Remove(*socketPath) // nolint: errcheck
+
+	listener, err := listenDaemonSocket(*socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to listen on %s: %s\n", *socketPath, err)
+		return 1
+	}
+
+	defer os.Remove(*socketPath) // nolint: errcheck
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close() // nolint: errcheck
+	}()
+
+	journal.Info("Serving kubelet invocations", "socket", *socketPath)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0
+			}
+
+			fmt.Fprintf(os.Stderr, "Failed to accept connection: %s\n", err)
+			return 1
+		}
+
+		go serveDaemonConnection(conn)
+	}
+}
+
+// listenDaemonSocket listens on a unix socket only root can connect to, as requests carry access keys and trigger
+// mounts. The socket is created with a restrictive umask, so that it's never accessible to others - not even
+// between its creation and a chmod
+func listenDaemonSocket(socketPath string) (net.Listener, error) {
+	previousUmask := syscall.Umask(0077)
+	listener, err := net.Listen("unix", socketPath)
+	syscall.Umask(previousUmask)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close() // nolint: errcheck
+		return nil, fmt.Errorf("Failed to restrict socket permissions: %s", err)
+	}
+
+	return listener, nil
+}
+
+func serveDaemonConnection(conn net.Conn) {
+	defer conn.Close() // nolint: errcheck
+
+	// a client that never sends its request (or never reads the response) would hold the goroutine forever. The
+	// action itself isn't bounded, as mounts may take minutes
+	if err := conn.SetDeadline(time.Now().Add(daemonConnectionTimeout)); err != nil {
+		journal.Warn("Failed to set daemon connection deadline", "err", err.Error())
+		return
+	}
+
+	request := daemonRequest{}
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		journal.Warn("Failed to decode daemon request", "err", err.Error())
+		return
+	}
+
+	// requests are handled concurrently, so each logs with the trace ID of the invocation it was forwarded by,
+	// rather than the daemon's
+	if request.TraceID == "" {
+		request.TraceID = journal.NewTraceID()
+	}
+
+	logger := journal.NewLogger(request.TraceID)
+	logger.Info("Handling forwarded invocation", "action", getAction(request.Args))
+
+	var response *flex.Response
+	if len(request.Args) == 0 || !daemonActions[request.Args[0]] {
+		response = getArgumentFailResponse(request.Args, "The daemon doesn't handle this action")
+	} else {
+		closeOperationLog := openOperationLog(request.Args, logger)
+		response = handleAction(request.Args, logger)
+		closeOperationLog()
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(daemonConnectionTimeout)); err != nil {
+		logger.Warn("Failed to set daemon connection deadline", "err", err.Error())
+	}
+
+	if err := json.NewEncoder(conn).Encode(response); err != nil {
+		logger.Warn("Failed to send daemon response", "err", err.Error())
+	}
+}
+
+// forwardToDaemon has the daemon handle an invocation if it's running, returning whether it did
+func forwardToDaemon(args []string) (*flex.Response, bool) {
+	if len(args) == 0 || !daemonActions[args[0]] {
+		return nil, false
+	}
+
+	driverConfig, err := config.New()
+	if err != nil || driverConfig.DaemonSocket == "" {
+		return nil, false
+	}
+
+	if _, err := os.Stat(driverConfig.DaemonSocket); err != nil {
+		return nil, false
+	}
+
+	conn, err := net.DialTimeout("unix", driverConfig.DaemonSocket, daemonDialTimeout)
+	if err != nil {
+		journal.Debug("Daemon is unreachable, handling the action", "err", err.Error())
+		return nil, false
+	}
+
+	defer conn.Close() // nolint: errcheck
+
+	if err := json.NewEncoder(conn).Encode(&daemonRequest{
+		Args:    args,
+		TraceID: journal.TraceID(),
+	}); err != nil {
+		journal.Debug("Failed to forward to daemon, handling the action", "err", err.Error())
+		return nil, false
+	}
+
+	// the request was sent, so it's not retried locally - it may be in progress
+	response := flex.Response{}
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return flex.NewFailResponse("Failed to get the daemon's response", err), true
+	}
+
+	return &response, true
+}
+
+func getAction(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	return args[0]
+}
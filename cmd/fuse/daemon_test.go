@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+func TestListenDaemonSocket(t *testing.T) {
+	socketPath := path.Join(t.TempDir(), "daemon.sock")
+
+	// the socket must not be accessible to others even with a permissive umask
+	previousUmask := syscall.Umask(0)
+	defer syscall.Umask(previousUmask)
+
+	listener, err := listenDaemonSocket(socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	defer listener.Close() // nolint: errcheck
+
+	if umask := syscall.Umask(0); umask != 0 {
+		t.Errorf("Expected the umask to be restored, got %o", umask)
+	}
+
+	socketInfo, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %s", err)
+	}
+
+	if socketInfo.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket permissions 0600, got %o", socketInfo.Mode().Perm())
+	}
+}
+
+func TestServeDaemonConnectionTimeout(t *testing.T) {
+	previousTimeout := daemonConnectionTimeout
+	daemonConnectionTimeout = 50 * time.Millisecond
+	defer func() {
+		daemonConnectionTimeout = previousTimeout
+	}()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close() // nolint: errcheck
+
+	served := make(chan struct{})
+	go func() {
+		serveDaemonConnection(serverConn)
+		close(served)
+	}()
+
+	// the client never sends its request
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the connection to be closed once its deadline passed")
+	}
+}
+
+func TestServeDaemonConnectionUnhandledAction(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close() // nolint: errcheck
+
+	go serveDaemonConnection(serverConn)
+
+	if err := clientConn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("Failed to set deadline: %s", err)
+	}
+
+	if err := json.NewEncoder(clientConn).Encode(&daemonRequest{Args: []string{"version"}}); err != nil {
+		t.Fatalf("Failed to send request: %s", err)
+	}
+
+	response := flex.Response{}
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatalf("Failed to receive response: %s", err)
+	}
+
+	if response.Status != "Failure" {
+		t.Errorf("Expected the daemon to refuse the action, got %s: %s", response.Status, response.Message)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+// runDoctorCommand diagnoses the node's mounts and their prerequisites, failing if any check fails. With --fix, the
+// failed checks it knows how to remediate are fixed and checked again. With --dry-run as well, the changes are only
+// printed
+func runDoctorCommand(args []string) int {
+	flagSet := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fix := flagSet.Bool("fix", false, "Remediate failed checks, e.g. create missing directories and load the fuse module")
+	dryRun := flagSet.Bool("dry-run", false, "With --fix, print what would change without changing anything")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	if flagSet.NArg() != 0 || (*dryRun && !*fix) {
+		fmt.Fprintln(os.Stderr, "Usage: fuse doctor [--fix [--dry-run]]")
+		return 2
+	}
+
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %s\n", err)
+		return 1
+	}
+
+	checks := mounter.RunChecks()
+	if *fix {
+		if fixedChecks := applyFixes(checks, *dryRun); fixedChecks > 0 && !*dryRun {
+			fmt.Printf("Fixed %d checks, checking again\n", fixedChecks)
+			checks = mounter.RunChecks()
+		}
+	}
+
+	failedChecks := 0
+	for _, check := range checks {
+		result := "OK"
+		if !check.Passed {
+			result = "FAIL"
+			failedChecks++
+		}
+
+		fmt.Printf("[%s] %s: %s\n", result, check.Name, check.Message)
+	}
+
+	if failedChecks > 0 {
+		fmt.Fprintf(os.Stderr, "%d checks failed\n", failedChecks)
+		return 1
+	}
+
+	return 0
+}
+
+// applyFixes remediates the failed checks that have a fix, printing each fix's changes. A dry run only prints them.
+// Returns the number of fixes applied
+func applyFixes(checks []*flex.Check, dryRun bool) int {
+	fixedChecks := 0
+	for _, check := range checks {
+		if check.Passed || check.Fix == nil {
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("Would fix %s:\n", check.Name)
+		} else {
+			fmt.Printf("Fixing %s:\n", check.Name)
+		}
+
+		for _, change := range check.Fix.Changes {
+			fmt.Printf("  %s\n", change)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		if err := check.Fix.Apply(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to fix %s: %s\n", check.Name, err)
+			continue
+		}
+
+		fixedChecks++
+	}
+
+	return fixedChecks
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+// runDrainCommand prepares the node for maintenance - new mounts are refused, active mounts are flushed and
+// their unmounting is awaited
+func runDrainCommand(args []string) int {
+	flagSet := flag.NewFlagSet("drain", flag.ContinueOnError)
+	timeout := flagSet.Duration("timeout", 10*time.Minute, "How long to wait for mounts to be unmounted, 0 to not wait")
+	cancel := flagSet.Bool("cancel", false, "Stop draining, allowing new mounts")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create mounter: %s\n", err)
+		return 1
+	}
+
+	if *cancel {
+		if err := mounter.Undrain(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to stop draining: %s\n", err)
+			return 1
+		}
+
+		fmt.Println("Node is no longer draining")
+		return 0
+	}
+
+	failedSyncs, err := mounter.Drain()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to drain: %s\n", err)
+		return 1
+	}
+
+	fmt.Println("Node is draining, new mounts are refused")
+
+	if failedSyncs > 0 {
+		fmt.Fprintf(os.Stderr, "Failed to flush %d mounts\n", failedSyncs)
+	}
+
+	deadline := time.Now().Add(*timeout)
+
+	for {
+		mountRecords, err := mounter.ListMountRecords()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list mounts: %s\n", err)
+			return 1
+		}
+
+		if len(mountRecords) == 0 {
+			fmt.Println("All mounts are unmounted")
+			return 0
+		}
+
+		if *timeout == 0 {
+			fmt.Printf("%d mounts remain\n", len(mountRecords))
+			return 0
+		}
+
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "Timed out waiting for %d mounts to be unmounted:\n", len(mountRecords))
+			for _, mountRecord := range mountRecords {
+				fmt.Fprintf(os.Stderr, "  %s\n", mountRecord.TargetPath)
+			}
+
+			return 1
+		}
+
+		fmt.Printf("Waiting for %d mounts to be unmounted\n", len(mountRecords))
+		time.Sleep(5 * time.Second)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// implemented by CRIs whose containers have snapshots (containerd)
+type snapshotChecker interface {
+	HasSnapshot(string) (bool, error)
+}
+
+type e2eRun struct {
+	criInstance   cri.CRI
+	mounter       *flex.Mounter
+	targetPath    string
+	containerName string
+	failed        bool
+}
+
+// runE2ECommand mounts and unmounts a volume through the container runtime with a stand-in of the FUSE image
+// (hack/e2e), verifying the container, its snapshot, task and logs are created and cleaned up
+func runE2ECommand(args []string) int {
+	flagSet := flag.NewFlagSet("e2e", flag.ContinueOnError)
+	image := flagSet.String("image", "flex-fuse-e2e:latest", "Stand-in image of the FUSE container (see hack/e2e)")
+	runtimeEndpoint := flagSet.String("runtime-endpoint", "", "Container runtime endpoint, defaults to the configured one")
+	timeout := flagSet.Duration("timeout", time.Minute, "Mount timeout")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	e2eConfig, err := config.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read configuration: %s\n", err)
+		return 1
+	}
+
+	cri.SetTempDir(e2eConfig.TempDir, int64(e2eConfig.TempDirMinFreeMB)<<20)
+
+	workDir, err := cri.CreateTempDir("flex-fuse-e2e-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create work directory: %s\n", err)
+		return 1
+	}
+
+	defer os.RemoveAll(workDir) // nolint: errcheck
+
+	// a plain FUSE container mount with the stand-in image, keeping state apart from the node's
+	imageRepository, imageTag := splitImage(*image)
+	e2eConfig.ImageRepository = imageRepository
+	e2eConfig.ImageTag = imageTag
+	e2eConfig.Type = "os"
+	e2eConfig.Attach = false
+	e2eConfig.MountTimeoutSeconds = int(timeout.Seconds())
+	e2eConfig.StateDir = path.Join(workDir, "state")
+	if *runtimeEndpoint != "" {
+		e2eConfig.RuntimeEndpoint = *runtimeEndpoint
+	}
+
+	criInstance, err := cri.New(e2eConfig.RuntimeBackend, e2eConfig.RuntimeEndpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create CRI: %s\n", err)
+		return 1
+	}
+
+	defer criInstance.Close() // nolint: errcheck
+
+	// the target path is laid out as kubelet's, which names the container
+	podID := fmt.Sprintf("e2e%d", time.Now().Unix())
+
+	run := e2eRun{
+		criInstance:   criInstance,
+		mounter:       flex.NewMounterFromConfig(e2eConfig),
+		targetPath:    path.Join(workDir, "pods", podID, "volumes", "v3io~fuse", "e2e"),
+		containerName: flex.ContainerNamePrefix + podID + "-e2e",
+	}
+
+	run.mountAndVerify()
+	run.unmountAndVerify()
+
+	if run.failed {
+		fmt.Println("FAIL")
+		return 1
+	}
+
+	fmt.Println("PASS")
+	return 0
+}
+
+func (r *e2eRun) mountAndVerify() {
+	if err := os.MkdirAll(r.targetPath, 0750); err != nil {
+		r.check("create target path", err)
+		return
+	}
+
+	spec, _ := json.Marshal(map[string]string{"accessKey": "e2e"}) // nolint: errcheck
+
+	response := r.mounter.Mount(r.targetPath, string(spec))
+	r.check("mount", responseError(response))
+	if response.Status != "Success" {
+		return
+	}
+
+	r.check("target path is mounted", checkMounted(r.targetPath, true))
+
+	containerNames, err := r.criInstance.ListContainers(r.containerName)
+	if err == nil && len(containerNames) == 0 {
+		err = fmt.Errorf("Container %s not found", r.containerName)
+	}
+	r.check("container exists", err)
+
+	_, err = r.criInstance.GetContainerPid(r.containerName)
+	r.check("task is running", err)
+
+	// containerd specific - the snapshot and the task's log file
+	if checker, ok := r.criInstance.(snapshotChecker); ok {
+		r.check("snapshot exists", checkSnapshot(checker, r.containerName, true))
+		r.check("container logs", checkLogs(r.containerName))
+	}
+
+	// the stand-in mounts a tmpfs, which must be writable through the target path
+	r.check("write through mount", ioutil.WriteFile(path.Join(r.targetPath, "e2e"), []byte("e2e"), 0600))
+}
+
+func (r *e2eRun) unmountAndVerify() {
+	r.check("unmount", responseError(r.mounter.Unmount(r.targetPath)))
+	r.check("target path is unmounted", checkMounted(r.targetPath, false))
+
+	containerNames, err := r.criInstance.ListContainers(r.containerName)
+	if err == nil && len(containerNames) > 0 {
+		err = fmt.Errorf("Container %s still exists", r.containerName)
+	}
+	r.check("container removed", err)
+
+	if checker, ok := r.criInstance.(snapshotChecker); ok {
+		r.check("snapshot removed", checkSnapshot(checker, r.containerName, false))
+	}
+}
+
+func (r *e2eRun) check(name string, err error) {
+	if err != nil {
+		r.failed = true
+		fmt.Printf("[FAIL] %s: %s\n", name, err)
+		journal.Warn("E2E check failed", "check", name, "err", err.Error())
+		return
+	}
+
+	fmt.Printf("[ OK ] %s\n", name)
+}
+
+func responseError(response *flex.Response) error {
+	if response.Status != "Success" {
+		return fmt.Errorf("%s: %s", response.Status, response.Message)
+	}
+
+	return nil
+}
+
+func checkMounted(targetPath string, expected bool) error {
+	output, err := exec.Command("mount").Output()
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(string(output), " "+targetPath+" ") != expected {
+		return fmt.Errorf("Expected mounted to be %v", expected)
+	}
+
+	return nil
+}
+
+func checkSnapshot(checker snapshotChecker, containerName string, expected bool) error {
+	exists, err := checker.HasSnapshot(containerName)
+	if err != nil {
+		return err
+	}
+
+	if exists != expected {
+		return fmt.Errorf("Expected snapshot to exist to be %v", expected)
+	}
+
+	return nil
+}
+
+// checkLogs verifies the stand-in's output reached the container's log
+func checkLogs(containerName string) error {
+	logPaths, err := filepath.Glob(path.Join(cri.GetTempDir(), containerName+"-*"))
+	if err != nil {
+		return err
+	}
+
+	for _, logPath := range logPaths {
+		content, err := ioutil.ReadFile(logPath)
+		if err == nil && strings.Contains(string(content), "stand-in mounted") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Output of the stand-in not found in %d logs", len(logPaths))
+}
+
+func splitImage(image string) (string, string) {
+	tagIdx := strings.LastIndex(image, ":")
+	if tagIdx == -1 || strings.Contains(image[tagIdx:], "/") {
+		return image, "latest"
+	}
+
+	return image[:tagIdx], image[tagIdx+1:]
+}
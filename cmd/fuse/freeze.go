@@ -0,0 +1,57 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+// runFreezeCommand handles "freeze <target path>", blocking I/O through a mount until thawed
+func runFreezeCommand(args []string) int {
+	return runFreezeOrThaw("freeze", args, (*flex.Mounter).Freeze)
+}
+
+// runThawCommand handles "thaw <target path>"
+func runThawCommand(args []string) int {
+	return runFreezeOrThaw("thaw", args, (*flex.Mounter).Thaw)
+}
+
+func runFreezeOrThaw(command string, args []string, handler func(*flex.Mounter, string) error) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: fuse %s <target path>\n", command)
+		return 2
+	}
+
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create mounter: %s\n", err)
+		return 1
+	}
+
+	if err := handler(mounter, args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to %s %s: %s\n", command, args[0], err)
+		return 1
+	}
+
+	return 0
+}
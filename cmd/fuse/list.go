@@ -0,0 +1,94 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+// runListCommand lists the node's mounts with the state of their FUSE containers
+func runListCommand(args []string) int {
+	flagSet := flag.NewFlagSet("list", flag.ContinueOnError)
+	jsonOutput := flagSet.Bool("json", false, "Print the mounts as JSON")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create mounter: %s\n", err)
+		return 1
+	}
+
+	mountStatuses, err := mounter.ListMountStatuses()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list mounts: %s\n", err)
+		return 1
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(mountStatuses); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode mounts: %s\n", err)
+			return 1
+		}
+
+		return 0
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TARGET PATH\tCONTAINER\tMOUNTED\tSTATE\tPID\tSTARTED")
+
+	for _, mountStatus := range mountStatuses {
+		state := mountStatus.ContainerState
+		if mountStatus.Error != "" {
+			state = "unknown"
+		} else if state == "stopped" {
+			state = fmt.Sprintf("exited (%d)", mountStatus.ExitCode)
+		}
+
+		started := "-"
+		if !mountStatus.StartedAt.IsZero() {
+			started = mountStatus.StartedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%t\t%s\t%d\t%s\n",
+			mountStatus.TargetPath,
+			mountStatus.ContainerName,
+			mountStatus.Mounted,
+			state,
+			mountStatus.Pid,
+			started)
+	}
+
+	writer.Flush() // nolint: errcheck
+
+	return 0
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/containerd/containerd/runtime/v2/logging"
+	"github.com/v3io/flex-fuse/pkg/cri"
+)
+
+// runLogForwardCommand handles "log-forward <fluentd address>", run by the containerd shim as the logging binary
+// of FUSE containers with the fluentd log driver. It forwards the container's output until it's closed
+func runLogForwardCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: fuse %s <fluentd address>\n", cri.LogForwardCommand)
+		return 2
+	}
+
+	logging.Run(func(ctx context.Context, config *logging.Config, ready func() error) error {
+		if err := ready(); err != nil {
+			return err
+		}
+
+		return cri.ForwardLogs(ctx, args[0], config.ID, map[string]io.Reader{
+			"stdout": config.Stdout,
+			"stderr": config.Stderr,
+		})
+	})
+
+	return 0
+}
@@ -20,64 +20,300 @@ such restriction.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
+	"github.com/v3io/flex-fuse/pkg/config"
 	"github.com/v3io/flex-fuse/pkg/flex"
 	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/version"
 )
 
-func handleAction() *flex.Response {
-	journal.Debug("Handling action", os.Args)
+// commands are invoked by users rather than kubelet, and print their own output
+var commands = map[string]func([]string) int{
+	"bench":         runBenchCommand,
+	"config":        runConfigCommand,
+	"controller":    runControllerCommand,
+	"daemon":        runDaemonCommand,
+	"doctor":        runDoctorCommand,
+	"drain":         runDrainCommand,
+	"e2e":           runE2ECommand,
+	"freeze":        runFreezeCommand,
+	"list":          runListCommand,
+	"log-forward":   runLogForwardCommand,
+	"monitor":       runMonitorCommand,
+	"shutdown-hook": runShutdownHookCommand,
+	"thaw":          runThawCommand,
+	"unmount-all":   runUnmountAllCommand,
+	"upgrade":       runUpgradeCommand,
+}
+
+// handleAction handles a kubelet invocation - the action and its arguments - logging with a logger
+func handleAction(args []string, logger *journal.Logger) *flex.Response {
+	logger.Debug("Handling action", args)
 
-	if len(os.Args) < 2 {
-		return getArgumentFailResponse("Fuse requires at least an action argument")
+	if len(args) < 1 {
+		return getArgumentFailResponse(args, "Fuse requires at least an action argument")
 	}
 
-	switch action := os.Args[1]; action {
+	switch action := args[0]; action {
 	case "init":
-		result := flex.NewSuccessResponse("No initialization required")
-		result.Capabilities = map[string]interface{}{
-			"attach": false,
-		}
+		result := flex.NewSuccessResponse(initialize())
+		result.Capabilities = getInitCapabilities()
+		result.Version = version.Get()
+
+		return result
+
+	case "version":
+		result := flex.NewSuccessResponse(version.Get().String())
+		result.Version = version.Get()
 
 		return result
 
 	case "mount":
-		if len(os.Args) != 4 {
-			return getArgumentFailResponse("Mount requires 2 exactly arguments")
-		}
+		return handleMounterAction(args, logger, 2, func(mounter *flex.Mounter, args []string) *flex.Response {
+			return mounter.Mount(args[0], args[1])
+		})
 
-		mounter, err := flex.NewMounter()
-		if err != nil {
-			return flex.NewFailResponse("Failed to create mounter", err)
+	case "unmount":
+		if len(args) > 1 && args[1] == "--resume" {
+			return resumeUnmounts(args[2:], logger)
 		}
 
-		return mounter.Mount(os.Args[2], os.Args[3])
+		return handleMounterAction(args, logger, 1, func(mounter *flex.Mounter, args []string) *flex.Response {
+			return mounter.Unmount(args[0])
+		})
 
-	case "unmount":
-		if len(os.Args) != 3 {
-			return getArgumentFailResponse("Mount requires 1 exactly argument")
+	case "attach":
+		return handleMounterAction(args, logger, 2, func(mounter *flex.Mounter, args []string) *flex.Response {
+			return mounter.Attach(args[0], args[1])
+		})
+
+	case "detach":
+		return handleMounterAction(args, logger, 2, func(mounter *flex.Mounter, args []string) *flex.Response {
+			return mounter.Detach(args[0], args[1])
+		})
+
+	case "waitforattach":
+		return handleMounterAction(args, logger, 2, func(mounter *flex.Mounter, args []string) *flex.Response {
+			return mounter.WaitForAttach(args[0])
+		})
+
+	case "isattached":
+		return handleMounterAction(args, logger, 2, func(mounter *flex.Mounter, args []string) *flex.Response {
+			return mounter.IsAttached(args[1])
+		})
+
+	case "mountdevice":
+		return handleMounterAction(args, logger, 3, func(mounter *flex.Mounter, args []string) *flex.Response {
+			return mounter.MountDevice(args[0], args[2])
+		})
+
+	case "unmountdevice":
+		return handleMounterAction(args, logger, 1, func(mounter *flex.Mounter, args []string) *flex.Response {
+			return mounter.UnmountDevice(args[0])
+		})
+
+	case "getvolumename":
+		return flex.NewNotSupportedResponse("getvolumename is not supported")
+
+	default:
+		return getArgumentFailResponse(args, fmt.Sprintf("Received (%s) action is not supported", action))
+	}
+}
+
+// handleMounterAction verifies the number of action arguments and invokes the handler with a mounter
+func handleMounterAction(args []string,
+	logger *journal.Logger,
+	numArgs int,
+	handler func(mounter *flex.Mounter, args []string) *flex.Response) *flex.Response {
+
+	if len(args) != numArgs+1 {
+		return getArgumentFailResponse(args, fmt.Sprintf("%s requires exactly %d arguments", args[0], numArgs))
+	}
+
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		return flex.NewFailResponse("Failed to create mounter", err)
+	}
+
+	mounter.SetLogger(logger)
+
+	// the daemon shares its runtime connection between requests
+	if daemonCRI != nil {
+		mounter.SetCRI(daemonCRI)
+	}
+
+	return handler(mounter, args[1:])
+}
+
+// resumeUnmounts completes the unmounts that failed midway - of a target path if given, or all of them
+func resumeUnmounts(args []string, logger *journal.Logger) *flex.Response {
+	if len(args) > 1 {
+		return getArgumentFailResponse(args, "unmount --resume accepts at most a target path")
+	}
+
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		return flex.NewFailResponse("Failed to create mounter", err)
+	}
+
+	mounter.SetLogger(logger)
+
+	if daemonCRI != nil {
+		mounter.SetCRI(daemonCRI)
+	}
+
+	if len(args) == 1 {
+		return mounter.Unmount(args[0])
+	}
+
+	responses, err := mounter.ResumeUnmounts()
+	if err != nil {
+		return flex.NewFailResponse("Failed to list pending unmounts", err)
+	}
+
+	var failures []string
+	for targetPath, response := range responses {
+		if response.Status != "Success" {
+			failures = append(failures, fmt.Sprintf("%s: %s", targetPath, response.Message))
 		}
+	}
+
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return flex.NewFailResponse(fmt.Sprintf("Failed to resume %d of %d unmounts", len(failures), len(responses)),
+			errors.New(strings.Join(failures, "; ")))
+	}
+
+	return flex.NewSuccessResponse(fmt.Sprintf("Resumed %d unmounts", len(responses)))
+}
+
+// extractRuntimeEndpointFlag removes a crictl style --runtime-endpoint flag from the arguments, passing
+// it to the configuration through CONTAINER_RUNTIME_ENDPOINT
+func extractRuntimeEndpointFlag(args []string) []string {
+	var remainingArgs []string
 
-		mounter, err := flex.NewMounter()
-		if err != nil {
-			return flex.NewFailResponse("Failed to create mounter", err)
+	for argIdx := 0; argIdx < len(args); argIdx++ {
+		switch {
+		case args[argIdx] == "--runtime-endpoint" && argIdx+1 < len(args):
+			os.Setenv("CONTAINER_RUNTIME_ENDPOINT", args[argIdx+1]) // nolint: errcheck
+			argIdx++
+		case strings.HasPrefix(args[argIdx], "--runtime-endpoint="):
+			os.Setenv("CONTAINER_RUNTIME_ENDPOINT", strings.TrimPrefix(args[argIdx], "--runtime-endpoint=")) // nolint: errcheck
+		default:
+			remainingArgs = append(remainingArgs, args[argIdx])
 		}
+	}
 
-		return mounter.Unmount(os.Args[2])
+	return remainingArgs
+}
 
+// openOperationLog logs mount/unmount invocations to their own file as well. The returned function closes it
+func openOperationLog(args []string, logger *journal.Logger) func() {
+	switch getAction(args) {
+	case "mount", "unmount", "mountdevice", "unmountdevice":
 	default:
-		return getArgumentFailResponse(fmt.Sprintf("Received (%s) action is not supported", action))
+		return func() {}
 	}
+
+	driverConfig, err := config.New()
+	if err != nil || driverConfig.OperationLogDir == "-" {
+		return func() {}
+	}
+
+	closeOperationLog, err := logger.OpenOperationLog(driverConfig.OperationLogDir,
+		driverConfig.OperationLogMaxFiles,
+		int64(driverConfig.OperationLogMaxSizeMB)*1024*1024)
+	if err != nil {
+		logger.Warn("Failed to open operation log", "err", err.Error())
+		return func() {}
+	}
+
+	return closeOperationLog
 }
 
-func getArgumentFailResponse(message string) *flex.Response {
-	return flex.NewFailResponse(message, fmt.Errorf("Got %s", os.Args))
+// initialize prepares the node for the driver, returning a description of what was done
+func initialize() string {
+	driverConfig, err := config.New()
+	if err != nil {
+		return "No initialization required"
+	}
+
+	var messages []string
+
+	message, err := flex.ConfigureFUSE(driverConfig.FUSEConf)
+	if err != nil {
+		journal.Warn("Failed to configure FUSE", "err", err.Error())
+		message = fmt.Sprintf("Failed to configure FUSE: %s", err)
+	}
+
+	if message != "" {
+		messages = append(messages, message)
+	}
+
+	if message, err = installShutdownHook(&driverConfig.ShutdownHook); err != nil {
+		journal.Warn("Failed to install shutdown hook", "err", err.Error())
+		message = fmt.Sprintf("Failed to install shutdown hook: %s", err)
+	}
+
+	if message != "" {
+		messages = append(messages, message)
+	}
+
+	if len(messages) == 0 {
+		return "No initialization required"
+	}
+
+	return strings.Join(messages, ". ")
+}
+
+func getInitCapabilities() map[string]interface{} {
+	driverConfig, err := config.New()
+	if err != nil {
+		journal.Warn("Failed to read configuration, attach is disabled", "err", err.Error())
+		return flex.GetInitCapabilities(nil)
+	}
+
+	return flex.GetInitCapabilities(driverConfig)
+}
+
+func getArgumentFailResponse(args []string, message string) *flex.Response {
+	return flex.NewFailResponse(message, fmt.Errorf("Got %s", args))
 }
 
 func main() {
+	journal.SetTraceID(journal.NewTraceID())
+	journal.Info("Starting flex-fuse", "version", version.Get().String())
+
+	os.Args = extractRuntimeEndpointFlag(os.Args)
+
+	if len(os.Args) > 1 {
+		if command, found := commands[os.Args[1]]; found {
+			exitCode := command(os.Args[2:])
+			journal.Flush()
+			os.Exit(exitCode)
+		}
+	}
+
+	// the response must be the only thing on stdout
+	stdout := reserveStdout()
+
+	defer journal.Flush()
+
+	// handle the action - by the daemon if it's running, which logs it to the operation log - and print the result
+	response, forwarded := forwardToDaemon(os.Args[1:])
+	if !forwarded {
+		closeOperationLog := openOperationLog(os.Args[1:], journal.Default())
+		response = handleAction(os.Args[1:], journal.Default())
+
+		// summarize rate limited messages before the operation log is closed
+		journal.Flush()
+		closeOperationLog()
+	}
 
-	// handle the action and print the result
-	fmt.Print(handleAction().ToJSON())
+	fmt.Fprint(stdout, response.ToJSON())
 }
@@ -0,0 +1,56 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/monitor"
+)
+
+// runMonitorCommand runs the node monitor until interrupted
+func runMonitorCommand(args []string) int {
+	monitorConfig, err := config.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read configuration: %s\n", err)
+		return 1
+	}
+
+	nodeMonitor, err := monitor.NewMonitor(monitorConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create monitor: %s\n", err)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if err := nodeMonitor.Run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Monitor failed: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+// runShutdownHookCommand handles "shutdown-hook install|uninstall|run". run is the hook's stop action, flushing and
+// unmounting all mounts if the node is shutting down
+func runShutdownHookCommand(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: fuse shutdown-hook install|uninstall|run [--force]")
+		return 2
+	}
+
+	switch args[0] {
+	case "install":
+		driverConfig, err := config.New()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %s\n", err)
+			return 1
+		}
+
+		// installing explicitly doesn't require enabling it in the configuration
+		hookConfig := driverConfig.ShutdownHook
+		hookConfig.Enabled = true
+
+		message, err := installShutdownHook(&hookConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to install shutdown hook: %s\n", err)
+			return 1
+		}
+
+		fmt.Println(message)
+
+	case "uninstall":
+		if err := flex.UninstallShutdownHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to uninstall shutdown hook: %s\n", err)
+			return 1
+		}
+
+		fmt.Println("Shutdown hook is uninstalled")
+
+	case "run":
+		return runShutdownHook(args[1:])
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown shutdown-hook command %s\n", args[0])
+		return 2
+	}
+
+	return 0
+}
+
+func runShutdownHook(args []string) int {
+	flagSet := flag.NewFlagSet("shutdown-hook run", flag.ContinueOnError)
+	force := flagSet.Bool("force", false, "Unmount even if the node isn't shutting down")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	// the hook's unit is also stopped when it's uninstalled or restarted
+	if !*force && !flex.IsShuttingDown() {
+		fmt.Println("Node is not shutting down, not unmounting")
+		return 0
+	}
+
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create mounter: %s\n", err)
+		return 1
+	}
+
+	if failedFlushes, err := mounter.FlushMounts(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to flush mounts: %s\n", err)
+	} else if failedFlushes > 0 {
+		fmt.Fprintf(os.Stderr, "Failed to flush %d mounts\n", failedFlushes)
+	}
+
+	// pods were stopped by now, so mounts are unmounted regardless of failures
+	return runUnmountAllCommand([]string{"--force"})
+}
+
+func installShutdownHook(hookConfig *config.ShutdownHookConfig) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	return flex.InstallShutdownHook(hookConfig, executable)
+}
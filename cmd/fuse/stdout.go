@@ -0,0 +1,47 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reserveStdout points stdout (both os.Stdout and file descriptor 1, so child processes and library code
+// writing to it directly are covered) at stderr, and returns the original stdout. kubelet parses whatever
+// is written to stdout as the driver's JSON response, so nothing else may end up there
+func reserveStdout() *os.File {
+	stdoutFd, err := unix.Dup(int(os.Stdout.Fd()))
+	if err != nil {
+		return os.Stdout
+	}
+
+	if err := unix.Dup2(int(os.Stderr.Fd()), int(os.Stdout.Fd())); err != nil {
+		unix.Close(stdoutFd) // nolint: errcheck
+		return os.Stdout
+	}
+
+	unix.CloseOnExec(stdoutFd)
+	stdout := os.NewFile(uintptr(stdoutFd), "/dev/stdout")
+	os.Stdout = os.Stderr
+
+	return stdout
+}
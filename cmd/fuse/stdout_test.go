@@ -0,0 +1,84 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// TestReserveStdoutProcess is run by TestReserveStdout in a process of its own, as reserveStdout changes the
+// process' file descriptors
+func TestReserveStdoutProcess(t *testing.T) {
+	if os.Getenv("FLEX_FUSE_TEST_RESERVE_STDOUT") == "" {
+		t.Skip("Run by TestReserveStdout")
+	}
+
+	stdout := reserveStdout()
+
+	// library code printing to stdout, and a child process inheriting file descriptor 1
+	fmt.Println("library output")
+
+	childCommand := exec.Command("sh", "-c", "echo child output")
+	childCommand.Stdout = os.NewFile(1, "/dev/stdout")
+	if err := childCommand.Run(); err != nil {
+		t.Fatalf("Failed to run child process: %s", err)
+	}
+
+	response := handleAction([]string{"version"}, journal.Default())
+	fmt.Fprint(stdout, response.ToJSON())
+
+	os.Exit(0)
+}
+
+func TestReserveStdout(t *testing.T) {
+	command := exec.Command(os.Args[0], "-test.run=^TestReserveStdoutProcess$")
+	command.Env = append(os.Environ(), "FLEX_FUSE_TEST_RESERVE_STDOUT=1")
+
+	var stderr strings.Builder
+	command.Stderr = &stderr
+
+	output, err := command.Output()
+	if err != nil {
+		t.Fatalf("Failed to run action: %s (%s)", err, stderr.String())
+	}
+
+	// stdout holds exactly the response, and the rest went to stderr
+	var response map[string]interface{}
+	if err := json.Unmarshal(output, &response); err != nil {
+		t.Fatalf("Expected only the JSON response on stdout, got %q", output)
+	}
+
+	if response["status"] != "Success" {
+		t.Errorf("Expected a successful response, got %v", response)
+	}
+
+	for _, expectedOutput := range []string{"library output", "child output"} {
+		if !strings.Contains(stderr.String(), expectedOutput) {
+			t.Errorf("Expected %q on stderr, got %q", expectedOutput, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+// runUnmountAllCommand unmounts all of the driver's mounts on the node, e.g. from a node termination hook
+func runUnmountAllCommand(args []string) int {
+	flagSet := flag.NewFlagSet("unmount-all", flag.ContinueOnError)
+	force := flagSet.Bool("force", false, "Lazily detach mounts that fail to unmount, and unmount device mounts regardless")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create mounter: %s\n", err)
+		return 1
+	}
+
+	outcomes, err := mounter.UnmountAll(*force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to unmount: %s\n", err)
+		return 1
+	}
+
+	summary := map[string]int{}
+	for _, outcome := range outcomes {
+		fmt.Printf("[%-7s] %s: %s\n", outcome.Status, outcome.TargetPath, outcome.Message)
+		summary[outcome.Status]++
+	}
+
+	fmt.Printf("%d mounts: %d unmounted, %d forced, %d failed, %d skipped\n",
+		len(outcomes),
+		summary["Success"],
+		summary["Forced"],
+		summary["Failure"],
+		summary["Skipped"])
+
+	if summary["Failure"] > 0 || summary["Skipped"] > 0 {
+		return 1
+	}
+
+	return 0
+}
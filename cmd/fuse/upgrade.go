@@ -0,0 +1,198 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/kube"
+	"github.com/v3io/flex-fuse/pkg/version"
+)
+
+// pods must opt in to having their mounts recreated on upgrade, as it's disruptive
+const remountAnnotation = "v3io.io/fuse-remount-on-upgrade"
+
+// runUpgradeCommand reinstalls the plugin binary on version skew, and optionally recreates mounts made by
+// other driver versions, one at a time
+func runUpgradeCommand(args []string) int {
+	flagSet := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	pluginDir := flagSet.String("plugin-dir", "/flexmnt/v3io~fuse", "Directory of the installed plugin")
+	remount := flagSet.Bool("remount", false, "Recreate mounts made by other driver versions")
+	remountInterval := flagSet.Duration("remount-interval", 10*time.Second, "Pause between remounts")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	installedVersion := getInstalledVersion(path.Join(*pluginDir, "fuse"))
+	currentVersion := version.Get().Version
+
+	if installedVersion != currentVersion {
+		fmt.Printf("Version skew - installed %q, running %q. Installing\n", installedVersion, currentVersion)
+
+		if err := installBinary(path.Join(*pluginDir, "fuse")); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to install: %s\n", err)
+			return 1
+		}
+	}
+
+	if *remount {
+		return remountOtherVersions(currentVersion, *remountInterval)
+	}
+
+	return 0
+}
+
+func remountOtherVersions(currentVersion string, remountInterval time.Duration) int {
+	mounter, err := flex.NewMounter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create mounter: %s\n", err)
+		return 1
+	}
+
+	mountRecords, err := mounter.ListMountRecords()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list mounts: %s\n", err)
+		return 1
+	}
+
+	remountablePodUIDs, err := getRemountablePodUIDs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get pods, not remounting: %s\n", err)
+		return 1
+	}
+
+	failed := 0
+	for _, mountRecord := range mountRecords {
+		if mountRecord.DriverVersion == currentVersion {
+			continue
+		}
+
+		if !remountablePodUIDs[flex.GetPodUIDFromTargetPath(mountRecord.TargetPath)] {
+			fmt.Printf("Skipping %s (version %s), pod is not annotated with %s\n",
+				mountRecord.TargetPath,
+				mountRecord.DriverVersion,
+				remountAnnotation)
+			continue
+		}
+
+		response := mounter.Remount(mountRecord)
+		fmt.Printf("Remounted %s: %s\n", mountRecord.TargetPath, response)
+
+		if response.Status != "Success" {
+			failed++
+		}
+
+		time.Sleep(remountInterval)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// getRemountablePodUIDs returns the UIDs of the node's pods that opted in to remounts
+func getRemountablePodUIDs() (map[string]bool, error) {
+	kubeClient, err := kube.NewInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		return nil, fmt.Errorf("NODE_NAME must be set")
+	}
+
+	pods, err := kubeClient.ListNodePods(nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	remountablePodUIDs := map[string]bool{}
+	for _, pod := range pods {
+		if pod.Metadata.Annotations[remountAnnotation] == "true" {
+			remountablePodUIDs[pod.Metadata.UID] = true
+		}
+	}
+
+	return remountablePodUIDs, nil
+}
+
+// getInstalledVersion returns the version reported by an installed binary, or an empty string
+func getInstalledVersion(binaryPath string) string {
+	output, err := exec.Command(binaryPath, "version").Output()
+	if err != nil {
+		return ""
+	}
+
+	response := flex.Response{}
+	if err := json.Unmarshal(output, &response); err != nil || response.Version == nil {
+		return ""
+	}
+
+	return response.Version.Version
+}
+
+// installBinary atomically replaces the binary at a path with the running one
+func installBinary(binaryPath string) error {
+	executablePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(executablePath)
+	if err != nil {
+		return err
+	}
+
+	defer source.Close() // nolint: errcheck
+
+	if err := os.MkdirAll(path.Dir(binaryPath), 0755); err != nil {
+		return err
+	}
+
+	temporaryPath := binaryPath + ".new"
+
+	destination, err := os.OpenFile(temporaryPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close() // nolint: errcheck
+		return err
+	}
+
+	if err := destination.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, binaryPath)
+}
@@ -0,0 +1,28 @@
+#!/bin/sh
+#
+# Copyright 2018 Iguazio
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# stand-in for the v3io FUSE mounter - ignores its arguments, mounts a tmpfs on /fuse_mount and stays up
+# until terminated, like the FUSE client
+
+mount -t tmpfs -o size=16m e2e /fuse_mount || exit 1
+
+echo "flex-fuse e2e stand-in mounted"
+
+trap 'umount /fuse_mount; exit 0' TERM INT
+
+while true; do
+    sleep 1
+done
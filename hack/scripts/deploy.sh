@@ -24,6 +24,10 @@ driver_dir=$VENDOR${VENDOR:+"~"}${DRIVER}
 echo "$(date) - Preparing to install $driver_dir"
 
 plugin_dir="/flexmnt/$driver_dir"
+if [ -x "$plugin_dir/$DRIVER" ]; then
+  echo "$(date) - Installed driver version: $("$plugin_dir/$DRIVER" version)"
+fi
+
 if [ -d "$plugin_dir" ]; then
   echo "$(date) - Driver exists at $plugin_dir - replacing"
   rm -rf "$plugin_dir"
@@ -63,6 +67,16 @@ ls -lahR "$install_dir"
 echo "$(date) - Moving $install_dir to $plugin_dir"
 mv -f "$install_dir" "$plugin_dir"
 
+if [ "$FLEX_FUSE_REMOUNT_ON_UPGRADE" = "true" ]; then
+  echo "$(date) - Recreating mounts of previous driver versions"
+  "/$DRIVER" upgrade --plugin-dir "$plugin_dir" --remount || echo "$(date) - Some mounts failed to be recreated"
+fi
+
+if [ "$FLEX_FUSE_MONITOR" = "true" ]; then
+  echo "$(date) - Completed. Running monitor"
+  exec "/$DRIVER" monitor
+fi
+
 echo "$(date) - Completed. Going to sleep now"
 while : ; do
   sleep 3600
@@ -0,0 +1,162 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/probe"
+)
+
+const (
+	cgroup2SuperMagic = 0x63677270
+
+	// container IDs are expected to be longer than this
+	minContainerIDLength = 32
+
+	// bounds probing the cgroup filesystem, which may hang (e.g. on a wedged cgroup or an unresponsive mount)
+	statfsTimeout = 5 * time.Second
+)
+
+// systemd scope units wrapping container IDs, e.g. cri-containerd-<id>.scope. Longer prefixes come first, as
+// they're trimmed in order
+var scopePrefixes = []string{"cri-containerd-", "docker-", "crio-conmon-", "crio-", "libpod-"}
+
+// Parent returns the cgroup parent for created containers, according to the host's cgroup version
+func Parent() string {
+	var parent string
+
+	probe.Cached("cgroup-parent", &parent, func() error { // nolint: errcheck
+		parent = probeParent()
+		return nil
+	})
+
+	return parent
+}
+
+func probeParent() string {
+	statfsTypeChan := make(chan int64, 1)
+
+	go func() {
+		var statfs syscall.Statfs_t
+		if err := syscall.Statfs("/sys/fs/cgroup/", &statfs); err != nil {
+			statfsTypeChan <- 0
+			return
+		}
+
+		statfsTypeChan <- int64(statfs.Type)
+	}()
+
+	select {
+	case statfsType := <-statfsTypeChan:
+		if statfsType == cgroup2SuperMagic {
+			return "/kubepods.slice"
+		}
+	case <-time.After(statfsTimeout):
+	}
+
+	return "/kubepods"
+}
+
+// ContainerIDFromFile reads a process' cgroup file (e.g. /proc/<pid>/cgroup) and returns the container ID in it,
+// giving up after timeout
+func ContainerIDFromFile(cgroupFilePath string, timeout time.Duration) (string, error) {
+	type readResult struct {
+		content []byte
+		err     error
+	}
+
+	readResultChan := make(chan readResult, 1)
+
+	go func() {
+		content, err := ioutil.ReadFile(cgroupFilePath)
+		readResultChan <- readResult{content, err}
+	}()
+
+	select {
+	case result := <-readResultChan:
+		if result.err != nil {
+			return "", result.err
+		}
+
+		return ParseContainerID(string(result.content))
+	case <-time.After(timeout):
+		return "", fmt.Errorf("Timed out reading %s", cgroupFilePath)
+	}
+}
+
+// ParseContainerID returns the container ID found in the contents of a cgroup file. Both cgroup v1 (one line per
+// hierarchy) and v2 (a single "0::" line) formats are supported, e.g.:
+//
+//	11:memory:/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/466f13d55e758cf1e969744007435e2eb3d48f4d64f81fa7f2c2c7ac14690c23
+//	1:name=systemd:/kubepods/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage
+//	0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0404f9f9.slice/cri-containerd-466f13d55e75...c23.scope
+func ParseContainerID(content string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+
+	for scanner.Scan() {
+
+		// hierarchy-ID:controller-list:cgroup-path
+		lineParts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(lineParts) != 3 {
+			continue
+		}
+
+		if containerID := ContainerIDFromPath(lineParts[2]); containerID != "" {
+			return containerID, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("No container ID found")
+}
+
+// ContainerIDFromPath returns the container ID which is the last element of a cgroup path,
+// or an empty string if the last element doesn't look like one. Both cgroupfs paths and systemd scopes
+// are supported, e.g.:
+//
+//	/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/466f13d55e758cf1e969744007435e2eb3d48f4d64f81fa7f2c2c7ac14690c23
+//	/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0404f9f9.slice/cri-containerd-466f13d55e75...c23.scope
+func ContainerIDFromPath(cgroupPath string) string {
+	containerID := path.Base(cgroupPath)
+
+	if strings.HasSuffix(containerID, ".scope") {
+		containerID = strings.TrimSuffix(containerID, ".scope")
+
+		for _, scopePrefix := range scopePrefixes {
+			containerID = strings.TrimPrefix(containerID, scopePrefix)
+		}
+	}
+
+	if len(containerID) <= minContainerIDLength {
+		return ""
+	}
+
+	return containerID
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestContainerIDFromPath(t *testing.T) {
+	const containerID = "466f13d55e758cf1e969744007435e2eb3d48f4d64f81fa7f2c2c7ac14690c23"
+
+	for _, testCase := range []struct {
+		name                string
+		cgroupPath          string
+		expectedContainerID string
+	}{
+		{
+			name:                "cgroup v1, cgroupfs driver, containerd",
+			cgroupPath:          "/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/" + containerID,
+			expectedContainerID: containerID,
+		},
+		{
+			name:                "cgroup v1, cgroupfs driver, docker",
+			cgroupPath:          "/docker/" + containerID,
+			expectedContainerID: containerID,
+		},
+		{
+			name: "cgroup v1, systemd driver, docker",
+			cgroupPath: "/kubepods.slice/kubepods-burstable.slice/" +
+				"kubepods-burstable-pod0404f9f9_7e8f_4cf0_848a_a7a23ef63393.slice/docker-" + containerID + ".scope",
+			expectedContainerID: containerID,
+		},
+		{
+			name: "cgroup v2, systemd driver, containerd",
+			cgroupPath: "/kubepods.slice/kubepods-besteffort.slice/" +
+				"kubepods-besteffort-pod0404f9f9_7e8f_4cf0_848a_a7a23ef63393.slice/cri-containerd-" + containerID + ".scope",
+			expectedContainerID: containerID,
+		},
+		{
+			name: "cgroup v2, systemd driver, cri-o",
+			cgroupPath: "/kubepods.slice/kubepods-pod0404f9f9_7e8f_4cf0_848a_a7a23ef63393.slice/crio-" +
+				containerID + ".scope",
+			expectedContainerID: containerID,
+		},
+		{
+			name: "cgroup v2, systemd driver, cri-o conmon",
+			cgroupPath: "/kubepods.slice/kubepods-pod0404f9f9_7e8f_4cf0_848a_a7a23ef63393.slice/crio-conmon-" +
+				containerID + ".scope",
+			expectedContainerID: containerID,
+		},
+		{
+			name:                "cgroup v2, cgroupfs driver, cri-o",
+			cgroupPath:          "/kubepods/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/" + containerID,
+			expectedContainerID: containerID,
+		},
+		{
+			name:                "FUSE container named after its volume",
+			cgroupPath:          "/kubepods/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage",
+			expectedContainerID: "v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage",
+		},
+		{
+			name:       "systemd service",
+			cgroupPath: "/system.slice/containerd.service",
+		},
+		{
+			name:       "root",
+			cgroupPath: "/",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if containerID := ContainerIDFromPath(testCase.cgroupPath); containerID != testCase.expectedContainerID {
+				t.Errorf("Expected container ID %q, got %q", testCase.expectedContainerID, containerID)
+			}
+		})
+	}
+}
+
+// cgroup files of FUSE containers and containers, as read from the host's /proc/<pid>/cgroup
+func TestParseContainerID(t *testing.T) {
+	const containerID = "466f13d55e758cf1e969744007435e2eb3d48f4d64f81fa7f2c2c7ac14690c23"
+
+	for _, testCase := range []struct {
+		name                string
+		content             string
+		expectedContainerID string
+		expectedError       bool
+	}{
+		{
+			name: "cgroup v1, cgroupfs driver",
+			content: "13:misc:/\n" +
+				"12:rdma:/\n" +
+				"11:memory:/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/" + containerID + "\n" +
+				"10:freezer:/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/" + containerID + "\n" +
+				"1:name=systemd:/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/" + containerID + "\n" +
+				"0::/system.slice/containerd.service\n",
+			expectedContainerID: containerID,
+		},
+		{
+			name: "cgroup v1, FUSE container",
+			content: "11:perf_event:/kubepods/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage\n" +
+				"2:devices:/kubepods/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage\n" +
+				"1:name=systemd:/kubepods/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage\n",
+			expectedContainerID: "v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage",
+		},
+		{
+			name:                "cgroup v2, FUSE container",
+			content:             "0::/kubepods.slice/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage\n",
+			expectedContainerID: "v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage",
+		},
+		{
+			name: "cgroup v2, systemd scope",
+			content: "0::/kubepods.slice/kubepods-besteffort.slice/" +
+				"kubepods-besteffort-pod0404f9f9_7e8f_4cf0_848a_a7a23ef63393.slice/cri-containerd-" + containerID + ".scope\n",
+			expectedContainerID: containerID,
+		},
+		{
+			name: "cgroup v1, systemd scope",
+			content: "4:memory:/kubepods.slice/kubepods-burstable.slice/" +
+				"kubepods-burstable-pod0404f9f9_7e8f_4cf0_848a_a7a23ef63393.slice/docker-" + containerID + ".scope\n" +
+				"1:name=systemd:/kubepods.slice/kubepods-burstable.slice/" +
+				"kubepods-burstable-pod0404f9f9_7e8f_4cf0_848a_a7a23ef63393.slice/docker-" + containerID + ".scope\n",
+			expectedContainerID: containerID,
+		},
+		{
+			name:          "cgroup v2, within a cgroup namespace",
+			content:       "0::/\n",
+			expectedError: true,
+		},
+		{
+			name:          "host process",
+			content:       "0::/system.slice/kubelet.service\n",
+			expectedError: true,
+		},
+		{
+			name:          "malformed",
+			content:       "not a cgroup file\n",
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			containerID, err := ParseContainerID(testCase.content)
+			if testCase.expectedError {
+				if err == nil {
+					t.Errorf("Expected an error, got container ID %q", containerID)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if containerID != testCase.expectedContainerID {
+				t.Errorf("Expected container ID %q, got %q", testCase.expectedContainerID, containerID)
+			}
+		})
+	}
+}
+
+func TestContainerIDFromFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cgroupFilePath := path.Join(tempDir, "cgroup")
+	if err := ioutil.WriteFile(cgroupFilePath,
+		[]byte("0::/kubepods.slice/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage\n"),
+		0644); err != nil {
+		t.Fatal(err)
+	}
+
+	containerID, err := ContainerIDFromFile(cgroupFilePath, time.Second)
+	if err != nil || containerID != "v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage" {
+		t.Errorf("Expected the FUSE container's ID, got %q (%v)", containerID, err)
+	}
+
+	if _, err := ContainerIDFromFile(path.Join(tempDir, "missing"), time.Second); err == nil {
+		t.Error("Expected an error reading a missing file")
+	}
+
+	// opening a fifo without a writer blocks, like a read of a wedged cgroup
+	blockingFilePath := path.Join(tempDir, "blocking")
+	if err := syscall.Mkfifo(blockingFilePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	startTime := time.Now()
+	if _, err := ContainerIDFromFile(blockingFilePath, 100*time.Millisecond); err == nil {
+		t.Error("Expected a timeout reading a blocking file")
+	}
+
+	if elapsed := time.Since(startTime); elapsed > time.Second {
+		t.Errorf("Expected the read to be bounded, took %s", elapsed)
+	}
+
+	// unblock the reader
+	if writer, err := os.OpenFile(blockingFilePath, os.O_WRONLY, 0); err == nil {
+		writer.Close() // nolint: errcheck
+	}
+}
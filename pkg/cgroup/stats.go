@@ -0,0 +1,147 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+type Stats struct {
+	MemoryRSSBytes      uint64
+	CPUUsageNanoseconds uint64
+	OpenFiles           int
+}
+
+// GetStats returns the resource usage of the cgroup a process belongs to, read from the cgroup filesystem. Used
+// for runtimes that don't report it (docker), see cri.ContainerStatsGetter
+func GetStats(pid uint32) (*Stats, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	cgroupPaths := parseCgroupPaths(string(content))
+	stats := Stats{}
+
+	// cgroup v2 has a single unified hierarchy
+	if unifiedPath, found := cgroupPaths[""]; found && len(cgroupPaths) == 1 {
+		cgroupPath := path.Join(cgroupRoot, unifiedPath)
+
+		stats.MemoryRSSBytes, _ = readStatValue(path.Join(cgroupPath, "memory.stat"), "anon")
+
+		cpuUsageMicroseconds, _ := readStatValue(path.Join(cgroupPath, "cpu.stat"), "usage_usec")
+		stats.CPUUsageNanoseconds = cpuUsageMicroseconds * 1000
+
+		stats.OpenFiles = countOpenFiles(path.Join(cgroupPath, "cgroup.procs"))
+	} else {
+		stats.MemoryRSSBytes, _ = readStatValue(path.Join(cgroupRoot, "memory", cgroupPaths["memory"], "memory.stat"), "total_rss")
+		stats.CPUUsageNanoseconds, _ = readValue(path.Join(cgroupRoot, "cpuacct", cgroupPaths["cpuacct"], "cpuacct.usage"))
+		stats.OpenFiles = countOpenFiles(path.Join(cgroupRoot, "pids", cgroupPaths["pids"], "cgroup.procs"))
+	}
+
+	return &stats, nil
+}
+
+// parseCgroupPaths returns a controller -> path mapping. The v2 hierarchy has an empty controller name
+func parseCgroupPaths(content string) map[string]string {
+	cgroupPaths := map[string]string{}
+
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		lineParts := strings.SplitN(line, ":", 3)
+		if len(lineParts) != 3 {
+			continue
+		}
+
+		for _, controller := range strings.Split(lineParts[1], ",") {
+			cgroupPaths[controller] = lineParts[2]
+		}
+	}
+
+	return cgroupPaths
+}
+
+// readStatValue reads a "<key> <value>" formatted file and returns the value of a key
+func readStatValue(statFilePath string, key string) (uint64, error) {
+	statFile, err := os.Open(statFilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	defer statFile.Close() // nolint: errcheck
+
+	scanner := bufio.NewScanner(statFile)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("%s not found in %s", key, statFilePath)
+}
+
+func readValue(valueFilePath string) (uint64, error) {
+	content, err := ioutil.ReadFile(valueFilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// countOpenFiles sums the open file descriptors of all processes listed in a cgroup.procs file
+func countOpenFiles(procsFilePath string) int {
+	content, err := ioutil.ReadFile(procsFilePath)
+	if err != nil {
+		return 0
+	}
+
+	var pids []uint32
+	for _, pidString := range strings.Fields(string(content)) {
+		if pid, err := strconv.ParseUint(pidString, 10, 32); err == nil {
+			pids = append(pids, uint32(pid))
+		}
+	}
+
+	return CountOpenFiles(pids)
+}
+
+// CountOpenFiles sums the open file descriptors of processes
+func CountOpenFiles(pids []uint32) int {
+	openFiles := 0
+	for _, pid := range pids {
+		fds, err := ioutil.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+		if err != nil {
+			continue
+		}
+
+		openFiles += len(fds)
+	}
+
+	return openFiles
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package common
+
+import (
+	"strings"
+)
+
+// SplitTemplateArgs splits a command template into argument templates by whitespace, except within actions
+// (e.g. "{{ .Image }}"), so that each argument can be rendered separately and run without a shell
+func SplitTemplateArgs(commandTemplate string) []string {
+	var args []string
+	var arg strings.Builder
+	inAction := false
+
+	for i := 0; i < len(commandTemplate); i++ {
+		switch {
+		case strings.HasPrefix(commandTemplate[i:], "{{"):
+			inAction = true
+		case strings.HasPrefix(commandTemplate[i:], "}}"):
+			inAction = false
+		case !inAction && (commandTemplate[i] == ' ' || commandTemplate[i] == '\t' || commandTemplate[i] == '\n'):
+			if arg.Len() > 0 {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+
+			continue
+		}
+
+		arg.WriteByte(commandTemplate[i])
+	}
+
+	if arg.Len() > 0 {
+		args = append(args, arg.String())
+	}
+
+	return args
+}
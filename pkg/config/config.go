@@ -0,0 +1,1258 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/common"
+	"github.com/v3io/flex-fuse/pkg/journal"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// oom_score_adj of the FUSE processes, as kubelet's for node critical pods (-997 and up are for pods)
+const defaultOOMScoreAdj = -998
+
+// cpusetPattern matches cpuset lists - comma separated IDs and ID ranges
+var cpusetPattern = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+
+const (
+	DefaultPath = "/etc/v3io/fuse/v3io.conf"
+
+	// environment variable overriding the config file path
+	pathEnvVar = "V3IO_FUSE_CONFIG"
+
+	// environment variable used by crictl and kubelet to configure the runtime endpoint
+	runtimeEndpointEnvVar = "CONTAINER_RUNTIME_ENDPOINT"
+
+	// crictl's image endpoint environment variable
+	imageEndpointEnvVar = "IMAGE_SERVICE_ENDPOINT"
+
+	// prefix of environment variables overriding top level fields, e.g. V3IO_FUSE_IMAGE_TAG
+	envVarPrefix = "V3IO_FUSE_"
+)
+
+type ClusterConfig struct {
+
+	// Name is referenced by the "cluster" volume option
+	Name string `json:"name"`
+
+	// DataUrls are the data connection strings passed to the FUSE client
+	DataUrls []string `json:"data_urls"`
+
+	// APIURL is the cluster's management API (e.g. https://dashboard.default-tenant.app.example.com), used by the
+	// controller to manage data containers
+	APIURL string `json:"api_url"`
+
+	// ConnectionPoolSize overrides the global connection_pool_size for volumes of this cluster
+	ConnectionPoolSize int `json:"connection_pool_size"`
+}
+
+type UserNamespaceConfig struct {
+	UIDMappings []specs.LinuxIDMapping `json:"uid_mappings"`
+	GIDMappings []specs.LinuxIDMapping `json:"gid_mappings"`
+}
+
+type ControllerConfig struct {
+
+	// LeaseNamespace and LeaseName identify the lease used for leader election
+	LeaseNamespace string `json:"lease_namespace"`
+	LeaseName      string `json:"lease_name"`
+
+	// LeaseDurationSeconds is how long a leader holds the lease without renewing it
+	LeaseDurationSeconds int `json:"lease_duration_seconds"`
+
+	// ResyncIntervalSeconds is the interval between reconciliations while leading
+	ResyncIntervalSeconds int `json:"resync_interval_seconds"`
+
+	// PVCContainers enables validating (and creating) the data containers of annotated PVCs
+	PVCContainers bool `json:"pvc_containers"`
+
+	// Provisioning enables dynamic provisioning of PVCs of StorageClasses with the v3io.io/fuse provisioner
+	Provisioning bool `json:"provisioning"`
+
+	// AccessKeyPath is a file holding the access key used with the clusters' management APIs
+	AccessKeyPath string `json:"access_key_path"`
+}
+
+// StaleMountThreshold is an action taken once a mount failed a number of consecutive probes
+type StaleMountThreshold struct {
+	Failures int `json:"failures"`
+
+	// Action is "log", "remount" or "taint-node"
+	Action string `json:"action"`
+}
+
+type StaleMountProbeConfig struct {
+
+	// IntervalSeconds is the interval between statfs probes of each mount. -1 disables probing
+	IntervalSeconds int `json:"interval_seconds"`
+
+	// TimeoutSeconds is how long a probe may take before it's considered failed
+	TimeoutSeconds int `json:"timeout_seconds"`
+
+	// Thresholds are the actions taken as consecutive failures accumulate, e.g. log at 3 and remount at 10
+	Thresholds []StaleMountThreshold `json:"thresholds"`
+}
+
+type NodeHealthConfig struct {
+
+	// Taint and Condition set the v3io.io/fuse-unhealthy taint and the V3ioFuseUnhealthy condition on the node
+	// while the driver is unhealthy
+	Taint     bool `json:"taint"`
+	Condition bool `json:"condition"`
+
+	// MountFailureThreshold mount failures within MountFailureWindowSeconds make the driver unhealthy
+	MountFailureThreshold     int `json:"mount_failure_threshold"`
+	MountFailureWindowSeconds int `json:"mount_failure_window_seconds"`
+}
+
+// ContainerLogConfig bounds the logs of the FUSE containers in /var/log/containers
+type ContainerLogConfig struct {
+
+	// MaxFileSizeMB and MaxFiles bound the rotation of each container's log (containerd only)
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	MaxFiles      int `json:"max_files"`
+
+	// Compress gzips rotated files (containerd only)
+	Compress bool `json:"compress"`
+
+	// MaxMountSizeMB and MaxNodeSizeMB cap the total size of the logs of a mount (across container restarts) and
+	// of the node, enforced by the monitor removing the oldest rotated files. 0 is unlimited
+	MaxMountSizeMB int `json:"max_mount_size_mb"`
+	MaxNodeSizeMB  int `json:"max_node_size_mb"`
+
+	// Driver is where the logs go - "file" (default), "none" to discard them or "fluentd" to forward them to the
+	// fluentd (or fluent-bit) forward input at FluentdAddress (unix:///path or tcp://host:port)
+	Driver         string `json:"driver"`
+	FluentdAddress string `json:"fluentd_address"`
+}
+
+// HostPathsConfig are host locations the driver uses, for nodes whose layout differs from the defaults
+type HostPathsConfig struct {
+
+	// DockerBinary is the docker CLI
+	DockerBinary string `json:"docker_binary"`
+
+	// CtrBinary is the ctr CLI images are pulled with, looked up if empty
+	CtrBinary string `json:"ctr_binary"`
+
+	// ContainerLogsDir is where the log directories of the FUSE containers are written
+	ContainerLogsDir string `json:"container_logs_dir"`
+
+	// DriverBinary is the installed driver, which the containerd shim runs on the host to forward the logs of
+	// the fluentd log driver. Empty is fuse in kubelet's volume plugin directory
+	DriverBinary string `json:"driver_binary"`
+}
+
+// RegistryTLSConfig holds the files authenticating a registry and the driver to it
+type RegistryTLSConfig struct {
+	CAFile   string `json:"ca_file"`
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+// MountEventsConfig emits the progress of slow mounts as events on the pods using them
+type MountEventsConfig struct {
+
+	// DelaySeconds is how long a mount runs before its progress is emitted. -1 disables the events
+	DelaySeconds int `json:"delay_seconds"`
+
+	// APIServer and CredentialsDir (holding a service account's "token" and "ca.crt") authenticate the events
+	// when the driver isn't running in a pod
+	APIServer      string `json:"api_server"`
+	CredentialsDir string `json:"credentials_dir"`
+}
+
+// TokenExchangeConfig exchanges the service account tokens of pods for v3io sessions, for volumes with the
+// "serviceAccountToken" auth
+type TokenExchangeConfig struct {
+
+	// URL is the RFC8693 token exchange endpoint, returning the session key as the access_token
+	URL string `json:"url"`
+
+	// Audience is the audience of the requested service account tokens, expected by the endpoint
+	Audience string `json:"audience"`
+
+	// ExpirationSeconds is the lifetime of the requested service account tokens
+	ExpirationSeconds int `json:"expiration_seconds"`
+
+	// TimeoutSeconds bounds the exchange
+	TimeoutSeconds int `json:"timeout_seconds"`
+
+	// RenewBeforeSeconds is how long before a session expires it's renewed rather than reused by further mounts
+	// of the service account. Sessions are cached in the state directory. -1 exchanges a token per mount
+	RenewBeforeSeconds int `json:"renew_before_seconds"`
+
+	// APIServer and CredentialsDir (holding a service account's "token" and "ca.crt") authenticate requesting the
+	// tokens when the driver isn't running in a pod
+	APIServer      string `json:"api_server"`
+	CredentialsDir string `json:"credentials_dir"`
+}
+
+// K8sImportConfig paces importing images from containerd's k8s.io namespace
+type K8sImportConfig struct {
+
+	// Attempts of finding and importing the image, which may still be pulled by kubelet. -1 skips the k8s.io
+	// namespace, for nodes where the image is never there
+	Attempts int `json:"attempts"`
+
+	// IntervalSeconds is the interval between attempts
+	IntervalSeconds int `json:"interval_seconds"`
+}
+
+// ShutdownHookConfig installs a systemd unit unmounting all mounts on node shutdown
+type ShutdownHookConfig struct {
+
+	// Enabled installs the unit on init
+	Enabled bool `json:"enabled"`
+
+	// TimeoutSeconds bounds flushing and unmounting, after which systemd continues the shutdown
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// SyslogConfig sends the driver's messages to a syslog server instead of the systemd journal
+type SyslogConfig struct {
+
+	// Network is "udp" (default), "tcp" or "unix"
+	Network string `json:"network"`
+
+	// Address is the server's host:port, or its socket path with "unix" (default /dev/log)
+	Address string `json:"address"`
+
+	// Facility is the facility of the messages, e.g. "daemon" (default) or "local0"
+	Facility string `json:"facility"`
+
+	// AppName identifies the driver in the messages (default "flex-fuse")
+	AppName string `json:"app_name"`
+}
+
+// AlertsConfig sends alerts about failed mounts and crash looping FUSE containers, as a JSON payload
+type AlertsConfig struct {
+
+	// WebhookURL is POSTed the payload
+	WebhookURL string `json:"webhook_url"`
+
+	// Exec is a command run with the payload on its stdin
+	Exec []string `json:"exec"`
+
+	// TimeoutSeconds bounds sending an alert
+	TimeoutSeconds int `json:"timeout_seconds"`
+
+	// RepeatIntervalSeconds is the minimal interval between alerts of the same event for the same mount. -1 always
+	// alerts
+	RepeatIntervalSeconds int `json:"repeat_interval_seconds"`
+}
+
+// RestartBackoffConfig paces restarts of exited FUSE containers by the monitor
+type RestartBackoffConfig struct {
+
+	// InitialSeconds is the delay before the first restart, doubled on every restart up to MaxSeconds
+	InitialSeconds int `json:"initial_seconds"`
+	MaxSeconds     int `json:"max_seconds"`
+
+	// CrashLoopRestarts restarts within CrashLoopWindowSeconds mark the mount as failed, and it's no longer
+	// restarted
+	CrashLoopRestarts      int `json:"crash_loop_restarts"`
+	CrashLoopWindowSeconds int `json:"crash_loop_window_seconds"`
+}
+
+type Config struct {
+
+	// Version of the config file format
+	Version string `json:"version"`
+
+	// ImageRepository and ImageTag of the v3io-fuse image (default iguazio/v3io-fuse:local)
+	ImageRepository string `json:"image_repository"`
+	ImageTag        string `json:"image_tag"`
+
+	// AllowedImages are the images volumes may override the image with (the "image" option), as path.Match
+	// patterns (e.g. iguazio/v3io-fuse:*). Empty rejects the option
+	AllowedImages []string `json:"allowed_images"`
+
+	// AllowedRegistries are the registries (e.g. docker.io) or repositories under them (e.g. docker.io/iguazio) the
+	// FUSE image, configured or overridden, must be from. Empty allows any registry
+	AllowedRegistries []string `json:"allowed_registries"`
+
+	RootPath string `json:"root_path"`
+	FusePath string `json:"fuse_path"`
+	Debug    bool   `json:"debug"`
+
+	// Type is the mount type - "os" (default) creates a FUSE container per mount, "link" shares
+	// a FUSE container per namespace and container and links to it
+	Type string `json:"type"`
+
+	// Clusters are the data clusters volumes can connect to
+	Clusters []ClusterConfig `json:"clusters"`
+
+	// ConnectionPoolSize is the number of data connections the FUSE client opens per mount, unless set by the
+	// cluster or the connectionPoolSize volume option. 0 keeps the FUSE client's default
+	ConnectionPoolSize int `json:"connection_pool_size"`
+
+	// DataInterface is the host interface whose address the FUSE client binds its data connections to, unless
+	// DataSourceIP or the dataInterface/dataSourceIP volume options are set
+	DataInterface string `json:"data_interface"`
+	DataSourceIP  string `json:"data_source_ip"`
+
+	// Sysctls tune the network of the FUSE containers (e.g. {"net.core.rmem_max": "268435456"}). As the containers
+	// share the host's network namespace, where runtimes refuse to set sysctls, they're applied on the host before
+	// creating a container, which must be opted into with ApplyHostSysctls
+	Sysctls          map[string]string `json:"sysctls"`
+	ApplyHostSysctls bool              `json:"apply_host_sysctls"`
+
+	// StopSignal is the signal stopping the FUSE containers (e.g. "SIGINT"), overriding the image's stop signal.
+	// Empty uses the image's, or SIGTERM
+	StopSignal string `json:"stop_signal"`
+
+	// ContainerAnnotations are set on the FUSE container's OCI spec, for runtimes keyed off annotations (e.g. kata
+	// configuration annotations)
+	ContainerAnnotations map[string]string `json:"container_annotations"`
+
+	// MemlockLimitBytes is the FUSE container's locked memory limit, -1 for unlimited, for FUSE clients using pinned
+	// buffers. 0 keeps the runtime's default
+	MemlockLimitBytes int64 `json:"memlock_limit_bytes"`
+
+	// OOMScoreAdj is the FUSE process's oom_score_adj (-1000 to 1000). Strongly negative by default, so that the
+	// kernel kills workloads before the process serving their mounts
+	OOMScoreAdj *int `json:"oom_score_adj"`
+
+	// CPUSetCPUs and CPUSetMems pin the FUSE container to CPUs and NUMA memory nodes (cpuset lists, e.g. "0-3,8"),
+	// so that the data path runs on the socket of the pods it serves
+	CPUSetCPUs string `json:"cpuset_cpus"`
+	CPUSetMems string `json:"cpuset_mems"`
+
+	// IOLimitDevices are the block devices (e.g. /dev/sda) the I/O limit options of volumes throttle
+	IOLimitDevices []string `json:"io_limit_devices"`
+
+	// HugepagesPath is a host hugetlbfs mount (e.g. /dev/hugepages) made available to the FUSE container
+	HugepagesPath string `json:"hugepages_path"`
+
+	// TargetPathMode are the octal permissions (e.g. "0750") a missing target path is created with
+	TargetPathMode string `json:"target_path_mode"`
+
+	// ForeignMounts is what mounting does with another filesystem mounted on the target path - "fail" (default) or
+	// "unmount" it
+	ForeignMounts string `json:"foreign_mounts"`
+
+	// PropagationCheck is what mounting does when the mount holding the target path isn't shared - "fail"
+	// (default), "warn" or "off"
+	PropagationCheck string `json:"propagation_check"`
+
+	// V3ioConfigPath is passed to the FUSE client as its config file, if set
+	V3ioConfigPath string `json:"v3io_config_path"`
+
+	// StartupWaitSeconds is how long the FUSE process is watched after starting. If it exits meanwhile, the mount
+	// fails with the tail of its log. -1 doesn't wait
+	StartupWaitSeconds int `json:"startup_wait_seconds"`
+
+	// MountTimeoutSeconds bounds mount operations of volumes without a mountTimeout option. 0 keeps the
+	// built in wait for the FUSE mount
+	MountTimeoutSeconds int `json:"mount_timeout_seconds"`
+
+	// KubeletTimeoutSeconds is how long kubelet waits for a mount. Without a mount timeout, it bounds creating the
+	// FUSE container, so that imports and retries that wouldn't complete in time are skipped. -1 doesn't bound it
+	KubeletTimeoutSeconds int `json:"kubelet_timeout_seconds"`
+
+	// Attach makes the driver attachable - the FUSE container is created once per volume on the device
+	// mount path, and pods bind mount it
+	Attach bool `json:"attach"`
+
+	// ShareVolumeContainers makes pods on the node mounting the same volume share a FUSE container on a path under
+	// the device mount root, which their target paths bind mount
+	ShareVolumeContainers bool `json:"share_volume_containers"`
+
+	// DeviceMountRoot is where kubelet mounts devices of this driver when attaching, under the node's kubelet root
+	// directory if empty
+	DeviceMountRoot string `json:"device_mount_root"`
+
+	// RuntimeEndpoint is the container runtime socket (e.g. unix:///run/containerd/containerd.sock),
+	// auto detected if empty. Also set by CONTAINER_RUNTIME_ENDPOINT or --runtime-endpoint
+	RuntimeEndpoint string `json:"runtime_endpoint"`
+
+	// RuntimeBackend is the container runtime backend - "containerd", "docker" or "simulate" (tmpfs mounts instead
+	// of FUSE containers, for development). Detected from the runtime endpoint if empty
+	RuntimeBackend string `json:"runtime_backend"`
+
+	// ImageLayoutDir is a host directory holding an OCI image layout (e.g. baked into the machine image) the
+	// v3io-fuse image is imported from before anything is pulled (containerd only)
+	ImageLayoutDir string `json:"image_layout_dir"`
+
+	// ImageEndpoint is the containerd socket whose k8s.io namespace images are imported from, when it's not the
+	// runtime endpoint (e.g. nested containerd instances in kind/k3d). Also set by IMAGE_SERVICE_ENDPOINT
+	ImageEndpoint string `json:"image_endpoint"`
+
+	// K8sImport paces importing images from the k8s.io namespace (containerd only)
+	K8sImport K8sImportConfig `json:"k8s_import"`
+
+	// KubeletImageStore is where kubelet's images are imported from before pulling (containerd only) -
+	// "containerd" (its k8s.io namespace), "docker" (docker's image store, on nodes where kubelet runs pods through
+	// cri-dockerd) or "auto" (default) to detect it from kubelet
+	KubeletImageStore string `json:"kubelet_image_store"`
+
+	// RegistryTLS holds TLS files of registries requiring mutual TLS, by registry host (containerd only)
+	RegistryTLS map[string]*RegistryTLSConfig `json:"registry_tls"`
+
+	// PullCommand is a command template (text/template, with the fields Image, Registry, Address and Namespace)
+	// run to pull missing images, instead of the runtime. It's split into arguments by whitespace, each rendered
+	// separately, and runs without a shell
+	PullCommand string `json:"pull_command"`
+
+	// DiskPreflightHeadroomMB is the free space containerd's data volume must have beyond an image's estimated size
+	// before the image is pulled or unpacked (containerd only). -1 disables the check
+	DiskPreflightHeadroomMB int `json:"disk_preflight_headroom_mb"`
+
+	// ContainerdVersionCheck is what creating containers and pulling images do when containerd's version is outside
+	// the tested range - "fail" (default), "warn" or "off". Other operations only warn
+	ContainerdVersionCheck string `json:"containerd_version_check"`
+
+	// ContainerNameTemplate is the Go template naming the FUSE containers of pod mounts after the prefix, with
+	// the fields PodUID, VolumeName and Hash (of the target path). Default {{.PodUID}}-{{.VolumeName}}
+	ContainerNameTemplate string `json:"container_name_template"`
+
+	// ContainerLogs bounds the logs of the FUSE containers
+	ContainerLogs ContainerLogConfig `json:"container_logs"`
+
+	// Profiles are named sets of volume options (e.g. {"training": {"connectionPoolSize": "16"}}), applied to
+	// volumes with the profile option where they don't set the options themselves
+	Profiles map[string]map[string]string `json:"profiles"`
+
+	// FUSEModprobe loads the fuse kernel module if it's not loaded when mounting
+	FUSEModprobe bool `json:"fuse_modprobe"`
+
+	// ImmutableOS runs on nodes with a read only /usr and no kernel module loading (e.g. Bottlerocket, Flatcar) -
+	// CLIs are only looked up in PATH or HostPaths rather than in /usr/bin and /usr/local/bin, and modprobe is
+	// never run
+	ImmutableOS bool `json:"immutable_os"`
+
+	// HostPaths are host locations the driver uses
+	HostPaths HostPathsConfig `json:"host_paths"`
+
+	// TokenExchange exchanges pods' service account tokens for v3io sessions
+	TokenExchange TokenExchangeConfig `json:"token_exchange"`
+
+	// Alerts are sent on failed mounts and crash looping FUSE containers
+	Alerts AlertsConfig `json:"alerts"`
+
+	// ShutdownHook unmounts all mounts on node shutdown, after the pods were stopped and before the runtime is
+	ShutdownHook ShutdownHookConfig `json:"shutdown_hook"`
+
+	// FUSEConf is how the driver manages /etc/fuse.conf on init - "off" (default), "report" whether
+	// user_allow_other is missing, or "manage" to add it
+	FUSEConf string `json:"fuse_conf"`
+
+	// LogLevel is the level of messages logged - "error", "warn", "info" or "debug" (default)
+	LogLevel string `json:"log_level"`
+
+	// LogLevels overrides LogLevel per module (package), e.g. {"cri": "debug"}
+	LogLevels map[string]string `json:"log_levels"`
+
+	// LogRateLimitBurst messages with the same text are logged per LogRateLimitIntervalSeconds, and the rest are
+	// summarized. -1 disables rate limiting
+	LogRateLimitBurst           int `json:"log_rate_limit_burst"`
+	LogRateLimitIntervalSeconds int `json:"log_rate_limit_interval_seconds"`
+
+	// LogTimestampFormat is the format of the timestamps in the operation logs and the TIMESTAMP journal field -
+	// "rfc3339nano" (default), "rfc3339" or "epoch-millis"
+	LogTimestampFormat string `json:"log_timestamp_format"`
+
+	// LogTimestampUTC formats the timestamps in UTC rather than the node's timezone
+	LogTimestampUTC bool `json:"log_timestamp_utc"`
+
+	// LogOutput is where messages are sent - "journal" (default) or "syslog"
+	LogOutput string `json:"log_output"`
+
+	// Syslog is the syslog server messages are sent to when LogOutput is "syslog"
+	Syslog SyslogConfig `json:"syslog"`
+
+	// DaemonSocket is the unix socket of the daemon (fuse daemon). When the daemon is running, the driver forwards
+	// mount and unmount invocations to it, avoiding connecting to the runtime per invocation
+	DaemonSocket string `json:"daemon_socket"`
+
+	// StateDir holds node local state shared by driver invocations, e.g. operation results
+	StateDir string `json:"state_dir"`
+
+	// TempDir holds scratch files - the FUSE containers' log files with containerd, docker pull credentials and the
+	// work directories of bench and e2e. Defaults to $TMPDIR, or /tmp. Set it where /tmp is a small tmpfs
+	TempDir string `json:"temp_dir"`
+
+	// TempDirMinFreeMB is the free space TempDir must have before scratch files are created in it, so that a full
+	// TempDir fails with an actionable error rather than ENOSPC midway. -1 disables the check
+	TempDirMinFreeMB int `json:"temp_dir_min_free_mb"`
+
+	// ProbeCacheTTLSeconds is how long results of environment probes (e.g. the node's container runtime and cgroup
+	// version) are cached in the state dir. -1 disables caching
+	ProbeCacheTTLSeconds int `json:"probe_cache_ttl_seconds"`
+
+	// OperationLogDir holds a log file per mount/unmount invocation, named by its trace ID. Set to "-" to disable
+	OperationLogDir string `json:"operation_log_dir"`
+
+	// StatusOperations is the number of last operations summarized in <state dir>/status.json. -1 disables it
+	StatusOperations int `json:"status_operations"`
+
+	// OperationLogMaxFiles and OperationLogMaxSizeMB bound the operation logs kept, removing the oldest
+	OperationLogMaxFiles  int `json:"operation_log_max_files"`
+	OperationLogMaxSizeMB int `json:"operation_log_max_size_mb"`
+
+	// MetricsListenAddress is where the monitor serves /metrics
+	MetricsListenAddress string `json:"metrics_listen_address"`
+
+	// HealthListenAddress is where the monitor serves the grpc.health.v1 Health service (host:port or unix://<path>)
+	HealthListenAddress string `json:"health_listen_address"`
+
+	// RestartPolicy is how the monitor reacts to FUSE containers exiting - "none" (default, log only),
+	// "remove" the container or "restart" it
+	RestartPolicy string `json:"restart_policy"`
+
+	// RestartBackoff paces restarts when RestartPolicy is "restart"
+	RestartBackoff RestartBackoffConfig `json:"restart_backoff"`
+
+	// MountEvents emits Kubernetes events on pods waiting for slow mounts
+	MountEvents MountEventsConfig `json:"mount_events"`
+
+	// NodeHealth configures reporting the driver's health on the node, for schedulers to avoid broken nodes
+	NodeHealth NodeHealthConfig `json:"node_health"`
+
+	// StaleMountProbe configures the monitor's detection of stale mounts
+	StaleMountProbe StaleMountProbeConfig `json:"stale_mount_probe"`
+
+	// Topology are labels describing the node's location (e.g. topology.kubernetes.io/zone), reported by the monitor
+	// along with a cluster.v3io.io/<name> label per data cluster
+	Topology map[string]string `json:"topology"`
+
+	// Controller configures the cluster scoped controller mode
+	Controller ControllerConfig `json:"controller"`
+
+	// UserNamespace runs the FUSE container in a user namespace with the given mappings (containerd only). It's
+	// rejected for now, as mounts made in a user namespace's mount namespace don't propagate back to the host
+	UserNamespace *UserNamespaceConfig `json:"user_namespace"`
+}
+
+// New reads the configuration from the default path (or V3IO_FUSE_CONFIG), applying defaults and
+// environment overrides
+func New() (*Config, error) {
+	return NewFromFile(Path(), false)
+}
+
+// NewFromFile reads the configuration from a given path, merging the files in conf.d and the node's file in
+// nodes next to it. When strict, unknown fields are an error
+func NewFromFile(path string, strict bool) (*Config, error) {
+	layerPaths, err := getLayerPaths(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := readLayers(layerPaths, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Config{}
+
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %s", path, err)
+	}
+
+	config.setDefaults()
+
+	if err := config.applyEnvOverrides(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	journal.SetLevels(config.LogLevel, config.LogLevels)
+	journal.SetRateLimit(config.LogRateLimitBurst, time.Duration(config.LogRateLimitIntervalSeconds)*time.Second)
+	journal.SetTimestampFormat(config.LogTimestampFormat, config.LogTimestampUTC)
+
+	if config.LogOutput == "syslog" {
+		config.setSyslogSink()
+	}
+
+	for _, warning := range config.Warnings() {
+		journal.Warn("Questionable configuration", "path", path, "warning", warning)
+	}
+
+	journal.Debug("Created configuration", "layers", layerPaths, "content", string(content))
+
+	return &config, nil
+}
+
+// Validate returns an error describing the first invalid field
+func (c *Config) Validate() error {
+	if _, err := c.GetTargetPathMode(); err != nil {
+		return err
+	}
+
+	for registry, registryTLS := range c.RegistryTLS {
+		if registryTLS == nil || (registryTLS.CertFile == "") != (registryTLS.KeyFile == "") {
+			return fmt.Errorf("Invalid registry_tls of %s, cert_file and key_file must be set together", registry)
+		}
+	}
+
+	switch c.ForeignMounts {
+	case "", "fail", "unmount":
+	default:
+		return fmt.Errorf("Invalid foreign_mounts %q, expected \"fail\" or \"unmount\"", c.ForeignMounts)
+	}
+
+	if c.StopSignal != "" && unix.SignalNum(c.StopSignal) == 0 {
+		return fmt.Errorf("Invalid stop_signal %q, expected a signal name (e.g. \"SIGINT\")", c.StopSignal)
+	}
+
+	switch c.ContainerdVersionCheck {
+	case "", "fail", "warn", "off":
+	default:
+		return fmt.Errorf("Invalid containerd_version_check %q, expected \"fail\", \"warn\" or \"off\"",
+			c.ContainerdVersionCheck)
+	}
+
+	switch c.PropagationCheck {
+	case "", "fail", "warn", "off":
+	default:
+		return fmt.Errorf("Invalid propagation_check %q, expected \"fail\", \"warn\" or \"off\"", c.PropagationCheck)
+	}
+
+	if c.ShareVolumeContainers && (c.Attach || c.Type == "link") {
+		return fmt.Errorf("share_volume_containers can't be used with attach or the link type")
+	}
+
+	switch c.RestartPolicy {
+	case "none", "remove", "restart":
+	default:
+		return fmt.Errorf("Invalid restart_policy %q, expected \"none\", \"remove\" or \"restart\"", c.RestartPolicy)
+	}
+
+	if _, err := template.New("container_name_template").Parse(c.ContainerNameTemplate); err != nil {
+		return fmt.Errorf("Invalid container_name_template %q: %s", c.ContainerNameTemplate, err)
+	}
+
+	// the pull command runs without a shell, with each argument rendered separately
+	for _, pullCommandArg := range common.SplitTemplateArgs(c.PullCommand) {
+		if _, err := template.New("pull_command").Parse(pullCommandArg); err != nil {
+			return fmt.Errorf("Invalid pull_command argument %q: %s", pullCommandArg, err)
+		}
+	}
+
+	if c.ContainerLogs.MaxFileSizeMB < 0 || c.ContainerLogs.MaxFileSizeMB > 16 {
+		return fmt.Errorf("Invalid container_logs max_file_size_mb %d, expected 1 to 16", c.ContainerLogs.MaxFileSizeMB)
+	}
+
+	if c.ContainerLogs.MaxMountSizeMB < 0 || c.ContainerLogs.MaxNodeSizeMB < 0 {
+		return fmt.Errorf("Invalid container_logs, max_mount_size_mb and max_node_size_mb must not be negative")
+	}
+
+	if c.ImmutableOS && c.FUSEModprobe {
+		return fmt.Errorf("fuse_modprobe can't be used with immutable_os, as kernel modules can't be loaded")
+	}
+
+	switch c.ContainerLogs.Driver {
+	case "file", "none":
+	case "fluentd":
+		if !strings.HasPrefix(c.ContainerLogs.FluentdAddress, "unix://") {
+			if _, _, err := net.SplitHostPort(strings.TrimPrefix(c.ContainerLogs.FluentdAddress, "tcp://")); err != nil {
+				return fmt.Errorf("Invalid container_logs fluentd_address %q, expected unix:///path or tcp://host:port",
+					c.ContainerLogs.FluentdAddress)
+			}
+		}
+	default:
+		return fmt.Errorf("Invalid container_logs driver %q, expected \"file\", \"none\" or \"fluentd\"",
+			c.ContainerLogs.Driver)
+	}
+
+	switch c.FUSEConf {
+	case "", "off", "report", "manage":
+	default:
+		return fmt.Errorf("Invalid fuse_conf %q, expected \"off\", \"report\" or \"manage\"", c.FUSEConf)
+	}
+
+	if !journal.ValidLevel(c.LogLevel) {
+		return fmt.Errorf("Invalid log_level %q, expected \"error\", \"warn\", \"info\" or \"debug\"", c.LogLevel)
+	}
+
+	for module, moduleLevel := range c.LogLevels {
+		if !journal.ValidLevel(moduleLevel) {
+			return fmt.Errorf("Invalid log level %q of module %s", moduleLevel, module)
+		}
+	}
+
+	if !journal.ValidTimestampFormat(c.LogTimestampFormat) {
+		return fmt.Errorf("Invalid log_timestamp_format %q, expected \"rfc3339\", \"rfc3339nano\" or \"epoch-millis\"",
+			c.LogTimestampFormat)
+	}
+
+	if c.TokenExchange.URL != "" {
+		exchangeURL, err := url.Parse(c.TokenExchange.URL)
+		if err != nil || (exchangeURL.Scheme != "http" && exchangeURL.Scheme != "https") {
+			return fmt.Errorf("Invalid token_exchange url %q, expected an http or https URL", c.TokenExchange.URL)
+		}
+	}
+
+	// the API server bounds expiration to at least 10 minutes
+	if c.TokenExchange.ExpirationSeconds < 600 {
+		return fmt.Errorf("Invalid token_exchange expiration_seconds %d, expected at least 600",
+			c.TokenExchange.ExpirationSeconds)
+	}
+
+	if c.Alerts.WebhookURL != "" {
+		webhookURL, err := url.Parse(c.Alerts.WebhookURL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") {
+			return fmt.Errorf("Invalid alerts webhook_url %q, expected an http or https URL", c.Alerts.WebhookURL)
+		}
+	}
+
+	switch c.KubeletImageStore {
+	case "auto", "containerd", "docker":
+	default:
+		return fmt.Errorf("Invalid kubelet_image_store %q, expected \"auto\", \"containerd\" or \"docker\"",
+			c.KubeletImageStore)
+	}
+
+	switch c.LogOutput {
+	case "journal":
+	case "syslog":
+		switch c.Syslog.Network {
+		case "udp", "tcp", "unix":
+		default:
+			return fmt.Errorf("Invalid syslog network %q, expected \"udp\", \"tcp\" or \"unix\"", c.Syslog.Network)
+		}
+
+		if c.Syslog.Address == "" {
+			return fmt.Errorf("Invalid syslog, address is required with network %s", c.Syslog.Network)
+		}
+
+		if !journal.ValidSyslogFacility(c.Syslog.Facility) {
+			return fmt.Errorf("Invalid syslog facility %q", c.Syslog.Facility)
+		}
+	default:
+		return fmt.Errorf("Invalid log_output %q, expected \"journal\" or \"syslog\"", c.LogOutput)
+	}
+
+	if c.RestartBackoff.MaxSeconds < c.RestartBackoff.InitialSeconds {
+		return fmt.Errorf("Invalid restart_backoff, max_seconds %d is less than initial_seconds %d",
+			c.RestartBackoff.MaxSeconds,
+			c.RestartBackoff.InitialSeconds)
+	}
+
+	if c.ConnectionPoolSize < 0 {
+		return fmt.Errorf("Invalid connection_pool_size %d, must not be negative", c.ConnectionPoolSize)
+	}
+
+	if c.DataSourceIP != "" && net.ParseIP(c.DataSourceIP) == nil {
+		return fmt.Errorf("Invalid data_source_ip %q", c.DataSourceIP)
+	}
+
+	if len(c.Sysctls) > 0 && !c.ApplyHostSysctls {
+		return fmt.Errorf("Invalid sysctls, the FUSE containers share the host's network namespace so they can " +
+			"only be applied on the host, which requires apply_host_sysctls")
+	}
+
+	for sysctlKey := range c.Sysctls {
+		if !strings.HasPrefix(sysctlKey, "net.") || strings.Contains(sysctlKey, "/") || strings.Contains(sysctlKey, "..") {
+			return fmt.Errorf("Invalid sysctl %q, only net.* sysctls can be set", sysctlKey)
+		}
+	}
+
+	if c.OOMScoreAdj != nil && (*c.OOMScoreAdj < -1000 || *c.OOMScoreAdj > 1000) {
+		return fmt.Errorf("Invalid oom_score_adj %d, expected -1000 to 1000", *c.OOMScoreAdj)
+	}
+
+	for cpusetName, cpuset := range map[string]string{"cpuset_cpus": c.CPUSetCPUs, "cpuset_mems": c.CPUSetMems} {
+		if cpuset != "" && !cpusetPattern.MatchString(cpuset) {
+			return fmt.Errorf("Invalid %s %q, expected a list of IDs and ranges (e.g. \"0-3,8\")", cpusetName, cpuset)
+		}
+	}
+
+	if c.MemlockLimitBytes < -1 {
+		return fmt.Errorf("Invalid memlock_limit_bytes %d, expected -1 (unlimited) or more", c.MemlockLimitBytes)
+	}
+
+	if !strings.HasPrefix(c.TempDir, "/") {
+		return fmt.Errorf("Invalid temp_dir %q, expected an absolute path", c.TempDir)
+	}
+
+	if c.DiskPreflightHeadroomMB < -1 {
+		return fmt.Errorf("Invalid disk_preflight_headroom_mb %d, expected -1 (no check) or more",
+			c.DiskPreflightHeadroomMB)
+	}
+
+	if c.TempDirMinFreeMB < -1 {
+		return fmt.Errorf("Invalid temp_dir_min_free_mb %d, expected -1 (no check) or more", c.TempDirMinFreeMB)
+	}
+
+	for _, threshold := range c.StaleMountProbe.Thresholds {
+		if threshold.Failures <= 0 {
+			return fmt.Errorf("Stale mount probe threshold of action %q must have a positive number of failures",
+				threshold.Action)
+		}
+
+		switch threshold.Action {
+		case "log", "remount", "taint-node":
+		default:
+			return fmt.Errorf("Invalid stale mount probe action %q, expected \"log\", \"remount\" or \"taint-node\"",
+				threshold.Action)
+		}
+	}
+
+	for _, clusterConfig := range c.Clusters {
+		if clusterConfig.ConnectionPoolSize < 0 {
+			return fmt.Errorf("Cluster %s has a negative connection_pool_size", clusterConfig.Name)
+		}
+	}
+
+	// the FUSE container's mount namespace would be owned by the user namespace, making the kernel turn the shared
+	// target path mount into a slave mount (mount_namespaces(7)), so the FUSE mount would never reach the pods
+	if c.UserNamespace != nil {
+		return fmt.Errorf("Invalid user_namespace, FUSE mounts made in a user namespace don't propagate to the host")
+	}
+
+	return nil
+}
+
+// Warnings describes fields which are likely mistakes, but which earlier versions accepted and so aren't rejected by
+// Validate - an unknown type is treated as "os", and a cluster without a name, data urls, or defined after another
+// of the same name is only an error when mounting from it
+func (c *Config) Warnings() []string {
+	var warnings []string
+
+	switch c.Type {
+	case "", "os", "link":
+	default:
+		warnings = append(warnings, fmt.Sprintf("Unknown type %q is treated as \"os\"", c.Type))
+	}
+
+	clusterNames := map[string]bool{}
+	for clusterIdx, clusterConfig := range c.Clusters {
+		if clusterConfig.Name == "" {
+			warnings = append(warnings, fmt.Sprintf("Cluster #%d has no name and can't be mounted", clusterIdx))
+			continue
+		}
+
+		if clusterNames[clusterConfig.Name] {
+			warnings = append(warnings, fmt.Sprintf("Cluster %s is defined more than once, only the first is used",
+				clusterConfig.Name))
+		}
+		clusterNames[clusterConfig.Name] = true
+
+		if len(clusterConfig.DataUrls) == 0 {
+			warnings = append(warnings, fmt.Sprintf("Cluster %s has no data urls", clusterConfig.Name))
+		}
+	}
+
+	return warnings
+}
+
+func (c *Config) DataURLs(cluster string) (string, error) {
+	clusterConfig, err := c.findCluster(cluster)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(clusterConfig.DataUrls, ","), nil
+}
+
+// APIURL returns the management API of a cluster
+func (c *Config) APIURL(cluster string) (string, error) {
+	clusterConfig, err := c.findCluster(cluster)
+	if err != nil {
+		return "", err
+	}
+
+	return clusterConfig.APIURL, nil
+}
+
+// GetConnectionPoolSize returns the connection pool size of a cluster's mounts - the cluster's, if set, or the global one
+func (c *Config) GetConnectionPoolSize(cluster string) (int, error) {
+	clusterConfig, err := c.findCluster(cluster)
+	if err != nil {
+		return 0, err
+	}
+
+	if clusterConfig.ConnectionPoolSize != 0 {
+		return clusterConfig.ConnectionPoolSize, nil
+	}
+
+	return c.ConnectionPoolSize, nil
+}
+
+// String returns the configuration as indented JSON
+func (c *Config) String() string {
+	configBytes, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(configBytes)
+}
+
+func (c *Config) findCluster(cluster string) (*ClusterConfig, error) {
+	for _, clusterConfig := range c.Clusters {
+		if clusterConfig.Name == cluster {
+			return &clusterConfig, nil
+		}
+	}
+	return nil, fmt.Errorf("no such cluster %s", cluster)
+}
+
+// syslogSink is the sink set by the last configuration read, replaced when the configuration is read again
+var syslogSink *journal.SyslogSink
+
+// setSyslogSink sends all subsequent messages to the syslog server. If it's unreachable, messages keep going to
+// the journal
+func (c *Config) setSyslogSink() {
+	sink, err := journal.NewSyslogSink(c.Syslog.Network, c.Syslog.Address, c.Syslog.Facility, c.Syslog.AppName)
+	if err != nil {
+		journal.Warn("Failed to connect to syslog, logging to the journal", "err", err.Error())
+		return
+	}
+
+	journal.SetSink(sink)
+
+	if syslogSink != nil {
+		syslogSink.Close() // nolint: errcheck
+	}
+
+	syslogSink = sink
+}
+
+func (c *Config) setDefaults() {
+	if c.ImageRepository == "" {
+		c.ImageRepository = "iguazio/v3io-fuse"
+	}
+
+	if c.ImageTag == "" {
+		c.ImageTag = "local"
+	}
+
+	if c.HealthListenAddress == "" {
+		c.HealthListenAddress = ":9754"
+	}
+
+	if c.RestartPolicy == "" {
+		c.RestartPolicy = "none"
+	}
+
+	if c.StateDir == "" {
+		c.StateDir = "/var/run/flex-fuse"
+	}
+
+	if c.TempDir == "" {
+		c.TempDir = os.TempDir()
+	}
+
+	if c.TempDirMinFreeMB == 0 {
+		c.TempDirMinFreeMB = 64
+	}
+
+	if c.StatusOperations == 0 {
+		c.StatusOperations = 50
+	}
+
+	if c.OperationLogDir == "" {
+		c.OperationLogDir = "/var/log/flex-fuse"
+	}
+
+	if c.OperationLogMaxFiles == 0 {
+		c.OperationLogMaxFiles = 500
+	}
+
+	if c.OperationLogMaxSizeMB == 0 {
+		c.OperationLogMaxSizeMB = 100
+	}
+
+	if c.MetricsListenAddress == "" {
+		c.MetricsListenAddress = ":9753"
+	}
+
+	if c.OOMScoreAdj == nil {
+		defaultOOMScoreAdj := defaultOOMScoreAdj
+		c.OOMScoreAdj = &defaultOOMScoreAdj
+	}
+
+	if c.KubeletTimeoutSeconds == 0 {
+		c.KubeletTimeoutSeconds = 120
+	}
+
+	if c.StartupWaitSeconds == 0 {
+		c.StartupWaitSeconds = 3
+	}
+
+	if c.ProbeCacheTTLSeconds == 0 {
+		c.ProbeCacheTTLSeconds = 300
+	}
+
+	if c.ContainerLogs.MaxFileSizeMB == 0 {
+		c.ContainerLogs.MaxFileSizeMB = 16
+	}
+
+	if c.ContainerLogs.MaxFiles == 0 {
+		c.ContainerLogs.MaxFiles = 20
+	}
+
+	if c.HostPaths.DockerBinary == "" {
+		c.HostPaths.DockerBinary = "/usr/bin/docker"
+	}
+
+	if c.HostPaths.ContainerLogsDir == "" {
+		c.HostPaths.ContainerLogsDir = "/var/log/containers"
+	}
+
+	if c.ContainerLogs.Driver == "" {
+		c.ContainerLogs.Driver = "file"
+	}
+
+	if c.ContainerLogs.FluentdAddress == "" {
+		c.ContainerLogs.FluentdAddress = "tcp://127.0.0.1:24224"
+	}
+
+	if c.LogRateLimitBurst == 0 {
+		c.LogRateLimitBurst = 5
+	}
+
+	if c.LogRateLimitIntervalSeconds == 0 {
+		c.LogRateLimitIntervalSeconds = 10
+	}
+
+	if c.LogTimestampFormat == "" {
+		c.LogTimestampFormat = "rfc3339nano"
+	}
+
+	if c.KubeletImageStore == "" {
+		c.KubeletImageStore = "auto"
+	}
+
+	if c.LogOutput == "" {
+		c.LogOutput = "journal"
+	}
+
+	if c.Syslog.Network == "" {
+		c.Syslog.Network = "udp"
+	}
+
+	if c.Syslog.Address == "" && c.Syslog.Network == "unix" {
+		c.Syslog.Address = "/dev/log"
+	}
+
+	if c.Syslog.Facility == "" {
+		c.Syslog.Facility = "daemon"
+	}
+
+	if c.Syslog.AppName == "" {
+		c.Syslog.AppName = "flex-fuse"
+	}
+
+	if c.ContainerNameTemplate == "" {
+		c.ContainerNameTemplate = "{{.PodUID}}-{{.VolumeName}}"
+	}
+
+	if c.DaemonSocket == "" {
+		c.DaemonSocket = "/run/v3io-fuse/daemon.sock"
+	}
+
+	if c.LogLevel == "" {
+		c.LogLevel = "debug"
+	}
+
+	if c.RestartBackoff.InitialSeconds == 0 {
+		c.RestartBackoff.InitialSeconds = 1
+	}
+
+	if c.RestartBackoff.MaxSeconds == 0 {
+		c.RestartBackoff.MaxSeconds = 300
+	}
+
+	if c.RestartBackoff.CrashLoopRestarts == 0 {
+		c.RestartBackoff.CrashLoopRestarts = 5
+	}
+
+	if c.RestartBackoff.CrashLoopWindowSeconds == 0 {
+		c.RestartBackoff.CrashLoopWindowSeconds = 600
+	}
+
+	if c.NodeHealth.MountFailureThreshold == 0 {
+		c.NodeHealth.MountFailureThreshold = 3
+	}
+
+	if c.NodeHealth.MountFailureWindowSeconds == 0 {
+		c.NodeHealth.MountFailureWindowSeconds = 600
+	}
+
+	if c.StaleMountProbe.IntervalSeconds == 0 {
+		c.StaleMountProbe.IntervalSeconds = 30
+	}
+
+	if c.StaleMountProbe.TimeoutSeconds == 0 {
+		c.StaleMountProbe.TimeoutSeconds = 10
+	}
+
+	if c.StaleMountProbe.Thresholds == nil {
+		c.StaleMountProbe.Thresholds = []StaleMountThreshold{{Failures: 3, Action: "log"}}
+	}
+
+	if c.Controller.LeaseNamespace == "" {
+		c.Controller.LeaseNamespace = "default"
+	}
+
+	if c.Controller.LeaseName == "" {
+		c.Controller.LeaseName = "flex-fuse-controller"
+	}
+
+	if c.Controller.LeaseDurationSeconds == 0 {
+		c.Controller.LeaseDurationSeconds = 15
+	}
+
+	if c.Controller.ResyncIntervalSeconds == 0 {
+		c.Controller.ResyncIntervalSeconds = 60
+	}
+
+	if c.Controller.AccessKeyPath == "" {
+		c.Controller.AccessKeyPath = "/var/run/secrets/v3io/access-key"
+	}
+
+	if c.ContainerdVersionCheck == "" {
+		c.ContainerdVersionCheck = "fail"
+	}
+
+	if c.DiskPreflightHeadroomMB == 0 {
+		c.DiskPreflightHeadroomMB = 256
+	}
+
+	if c.ShutdownHook.TimeoutSeconds == 0 {
+		c.ShutdownHook.TimeoutSeconds = 120
+	}
+
+	if c.TokenExchange.Audience == "" {
+		c.TokenExchange.Audience = "v3io"
+	}
+
+	if c.TokenExchange.ExpirationSeconds == 0 {
+		c.TokenExchange.ExpirationSeconds = 600
+	}
+
+	if c.TokenExchange.TimeoutSeconds == 0 {
+		c.TokenExchange.TimeoutSeconds = 10
+	}
+
+	if c.TokenExchange.RenewBeforeSeconds == 0 {
+		c.TokenExchange.RenewBeforeSeconds = 120
+	}
+
+	if c.Alerts.TimeoutSeconds == 0 {
+		c.Alerts.TimeoutSeconds = 5
+	}
+
+	if c.Alerts.RepeatIntervalSeconds == 0 {
+		c.Alerts.RepeatIntervalSeconds = 600
+	}
+
+	if c.K8sImport.Attempts == 0 {
+		c.K8sImport.Attempts = 10
+	}
+
+	if c.K8sImport.IntervalSeconds == 0 {
+		c.K8sImport.IntervalSeconds = 3
+	}
+
+	if c.MountEvents.DelaySeconds == 0 {
+		c.MountEvents.DelaySeconds = 5
+	}
+}
+
+// GetTargetPathMode returns the permissions a missing target path is created with, or 0 for the default
+func (c *Config) GetTargetPathMode() (os.FileMode, error) {
+	if c.TargetPathMode == "" {
+		return 0, nil
+	}
+
+	mode, err := strconv.ParseUint(c.TargetPathMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("Invalid target_path_mode %q, expected octal permissions (e.g. \"0750\")", c.TargetPathMode)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// applyEnvOverrides overrides top level scalar fields from V3IO_FUSE_<JSON NAME> environment variables
+func (c *Config) applyEnvOverrides() error {
+	if runtimeEndpoint := os.Getenv(runtimeEndpointEnvVar); runtimeEndpoint != "" {
+		c.RuntimeEndpoint = runtimeEndpoint
+	}
+
+	if imageEndpoint := os.Getenv(imageEndpointEnvVar); imageEndpoint != "" {
+		c.ImageEndpoint = imageEndpoint
+	}
+
+	configValue := reflect.ValueOf(c).Elem()
+	configType := configValue.Type()
+
+	for fieldIdx := 0; fieldIdx < configType.NumField(); fieldIdx++ {
+		jsonName := strings.Split(configType.Field(fieldIdx).Tag.Get("json"), ",")[0]
+		envVarName := envVarPrefix + strings.ToUpper(jsonName)
+
+		envVarValue, found := os.LookupEnv(envVarName)
+		if !found {
+			continue
+		}
+
+		field := configValue.Field(fieldIdx)
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(envVarValue)
+		case reflect.Bool:
+			boolValue, err := strconv.ParseBool(envVarValue)
+			if err != nil {
+				return fmt.Errorf("Invalid value for %s: %s", envVarName, err)
+			}
+			field.SetBool(boolValue)
+		case reflect.Int, reflect.Int64:
+			intValue, err := strconv.ParseInt(envVarValue, 10, 64)
+			if err != nil {
+				return fmt.Errorf("Invalid value for %s: %s", envVarName, err)
+			}
+			field.SetInt(intValue)
+		default:
+			return fmt.Errorf("%s can't be overridden from the environment", envVarName)
+		}
+	}
+
+	return nil
+}
+
+// Path returns the configuration file path
+func Path() string {
+	if path := os.Getenv(pathEnvVar); path != "" {
+		return path
+	}
+
+	return DefaultPath
+}
@@ -0,0 +1,388 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestValidateSysctls(t *testing.T) {
+	for _, testCase := range []struct {
+		name             string
+		sysctls          map[string]string
+		applyHostSysctls bool
+		expectedError    bool
+	}{
+		{
+			name: "no sysctls",
+		},
+		{
+			name:          "not opted into the host",
+			sysctls:       map[string]string{"net.core.rmem_max": "268435456"},
+			expectedError: true,
+		},
+		{
+			name:             "opted into the host",
+			sysctls:          map[string]string{"net.core.rmem_max": "268435456"},
+			applyHostSysctls: true,
+		},
+		{
+			name:             "not a network sysctl",
+			sysctls:          map[string]string{"kernel.panic": "10"},
+			applyHostSysctls: true,
+			expectedError:    true,
+		},
+		{
+			name:             "escaping /proc/sys",
+			sysctls:          map[string]string{"net../../../etc/passwd": "x"},
+			applyHostSysctls: true,
+			expectedError:    true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			config := Config{
+				Sysctls:          testCase.sysctls,
+				ApplyHostSysctls: testCase.applyHostSysctls,
+			}
+			config.setDefaults()
+
+			err := config.Validate()
+			if testCase.expectedError && err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			if !testCase.expectedError && err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestValidateUserNamespace(t *testing.T) {
+	config := Config{
+		UserNamespace: &UserNamespaceConfig{
+			UIDMappings: []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}},
+			GIDMappings: []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}},
+		},
+	}
+	config.setDefaults()
+
+	if err := config.Validate(); err == nil {
+		t.Fatal("Expected user_namespace to be rejected")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalidOOMScoreAdj := 1001
+
+	for _, testCase := range []struct {
+		name          string
+		modify        func(config *Config)
+		expectedError bool
+	}{
+		{
+			name:   "defaults",
+			modify: func(config *Config) {},
+		},
+		{
+			name: "clusters accepted by earlier versions",
+			modify: func(config *Config) {
+				config.Type = "fuse"
+				config.Clusters = []ClusterConfig{{Name: "default"}, {Name: "default"}, {}}
+			},
+		},
+		{
+			name: "negative cluster connection pool size",
+			modify: func(config *Config) {
+				config.Clusters = []ClusterConfig{{Name: "default", ConnectionPoolSize: -1}}
+			},
+			expectedError: true,
+		},
+		{
+			name: "registry cert without key",
+			modify: func(config *Config) {
+				config.RegistryTLS = map[string]*RegistryTLSConfig{"registry.example.com": {CertFile: "client.crt"}}
+			},
+			expectedError: true,
+		},
+		{
+			name:          "unknown foreign mounts",
+			modify:        func(config *Config) { config.ForeignMounts = "ignore" },
+			expectedError: true,
+		},
+		{
+			name:          "unknown stop signal",
+			modify:        func(config *Config) { config.StopSignal = "SIGNOPE" },
+			expectedError: true,
+		},
+		{
+			name: "shared containers with link",
+			modify: func(config *Config) {
+				config.ShareVolumeContainers = true
+				config.Type = "link"
+			},
+			expectedError: true,
+		},
+		{
+			name:          "unknown restart policy",
+			modify:        func(config *Config) { config.RestartPolicy = "always" },
+			expectedError: true,
+		},
+		{
+			name:          "unparsable container name template",
+			modify:        func(config *Config) { config.ContainerNameTemplate = "{{.PodUID" },
+			expectedError: true,
+		},
+		{
+			name:          "unknown log level",
+			modify:        func(config *Config) { config.LogLevel = "verbose" },
+			expectedError: true,
+		},
+		{
+			name:          "unknown log output",
+			modify:        func(config *Config) { config.LogOutput = "stdout" },
+			expectedError: true,
+		},
+		{
+			name:          "token exchange url without scheme",
+			modify:        func(config *Config) { config.TokenExchange.URL = "exchange.example.com" },
+			expectedError: true,
+		},
+		{
+			name: "restart backoff max below initial",
+			modify: func(config *Config) {
+				config.RestartBackoff.InitialSeconds = 10
+				config.RestartBackoff.MaxSeconds = 5
+			},
+			expectedError: true,
+		},
+		{
+			name:          "invalid data source ip",
+			modify:        func(config *Config) { config.DataSourceIP = "10.0.0" },
+			expectedError: true,
+		},
+		{
+			name:          "oom score adj out of range",
+			modify:        func(config *Config) { config.OOMScoreAdj = &invalidOOMScoreAdj },
+			expectedError: true,
+		},
+		{
+			name:   "cpuset list",
+			modify: func(config *Config) { config.CPUSetCPUs = "0-3,8" },
+		},
+		{
+			name:          "invalid cpuset",
+			modify:        func(config *Config) { config.CPUSetCPUs = "0-3;8" },
+			expectedError: true,
+		},
+		{
+			name:          "relative temp dir",
+			modify:        func(config *Config) { config.TempDir = "tmp" },
+			expectedError: true,
+		},
+		{
+			name: "unknown stale mount probe action",
+			modify: func(config *Config) {
+				config.StaleMountProbe.Thresholds = []StaleMountThreshold{{Failures: 3, Action: "reboot"}}
+			},
+			expectedError: true,
+		},
+		{
+			name: "stale mount probe threshold without failures",
+			modify: func(config *Config) {
+				config.StaleMountProbe.Thresholds = []StaleMountThreshold{{Action: "log"}}
+			},
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			config := Config{}
+			config.setDefaults()
+			testCase.modify(&config)
+
+			err := config.Validate()
+			if testCase.expectedError && err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			if !testCase.expectedError && err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	for _, testCase := range []struct {
+		name             string
+		config           Config
+		expectedWarnings int
+	}{
+		{
+			name: "valid",
+			config: Config{
+				Type:     "link",
+				Clusters: []ClusterConfig{{Name: "default", DataUrls: []string{"tcp://10.0.0.1:1234"}}},
+			},
+		},
+		{
+			name:             "unknown type",
+			config:           Config{Type: "fuse"},
+			expectedWarnings: 1,
+		},
+		{
+			name:             "cluster without name",
+			config:           Config{Clusters: []ClusterConfig{{DataUrls: []string{"tcp://10.0.0.1:1234"}}}},
+			expectedWarnings: 1,
+		},
+		{
+			name:             "cluster without data urls",
+			config:           Config{Clusters: []ClusterConfig{{Name: "default"}}},
+			expectedWarnings: 1,
+		},
+		{
+			name: "cluster defined twice",
+			config: Config{
+				Clusters: []ClusterConfig{
+					{Name: "default", DataUrls: []string{"tcp://10.0.0.1:1234"}},
+					{Name: "default", DataUrls: []string{"tcp://10.0.0.2:1234"}},
+				},
+			},
+			expectedWarnings: 1,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if warnings := testCase.config.Warnings(); len(warnings) != testCase.expectedWarnings {
+				t.Fatalf("Expected %d warnings, got %v", testCase.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestNewFromFileAcceptsEarlierConfigs(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "v3io.conf")
+
+	// a configuration of the first versions, before it was validated
+	if err := os.WriteFile(configPath, []byte(`{
+		"image_repository": "iguazio/v3io-fuse",
+		"image_tag": "1.0",
+		"debug": true,
+		"type": "fuse",
+		"clusters": [{"name": "default"}, {"name": "other", "data_urls": ["tcp://10.0.0.1:1234"]}]
+	}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewFromFile(configPath, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := config.DataURLs("default"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	for _, testCase := range []struct {
+		name           string
+		env            map[string]string
+		expectedConfig Config
+		expectedError  bool
+	}{
+		{
+			name:           "no overrides",
+			expectedConfig: Config{ImageTag: "1.0"},
+		},
+		{
+			name: "scalars",
+			env: map[string]string{
+				"V3IO_FUSE_IMAGE_TAG":            "2.0",
+				"V3IO_FUSE_DEBUG":                "true",
+				"V3IO_FUSE_LOG_RATE_LIMIT_BURST": "10",
+				"V3IO_FUSE_MEMLOCK_LIMIT_BYTES":  "-1",
+			},
+			expectedConfig: Config{ImageTag: "2.0", Debug: true, LogRateLimitBurst: 10, MemlockLimitBytes: -1},
+		},
+		{
+			name: "empty string",
+			env:  map[string]string{"V3IO_FUSE_IMAGE_TAG": ""},
+		},
+		{
+			name: "runtime endpoints",
+			env: map[string]string{
+				"CONTAINER_RUNTIME_ENDPOINT": "unix:///run/k3s/containerd/containerd.sock",
+				"IMAGE_SERVICE_ENDPOINT":     "unix:///run/containerd/containerd.sock",
+			},
+			expectedConfig: Config{
+				ImageTag:        "1.0",
+				RuntimeEndpoint: "unix:///run/k3s/containerd/containerd.sock",
+				ImageEndpoint:   "unix:///run/containerd/containerd.sock",
+			},
+		},
+		{
+			name:          "invalid bool",
+			env:           map[string]string{"V3IO_FUSE_DEBUG": "yes please"},
+			expectedError: true,
+		},
+		{
+			name:          "invalid int",
+			env:           map[string]string{"V3IO_FUSE_LOG_RATE_LIMIT_BURST": "ten"},
+			expectedError: true,
+		},
+		{
+			name:          "not a scalar",
+			env:           map[string]string{"V3IO_FUSE_CLUSTERS": "default"},
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Setenv("CONTAINER_RUNTIME_ENDPOINT", "")
+			t.Setenv("IMAGE_SERVICE_ENDPOINT", "")
+
+			for envVarName, envVarValue := range testCase.env {
+				t.Setenv(envVarName, envVarValue)
+			}
+
+			config := Config{ImageTag: "1.0"}
+
+			err := config.applyEnvOverrides()
+			if testCase.expectedError {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(config, testCase.expectedConfig) {
+				t.Fatalf("Expected %+v, got %+v", testCase.expectedConfig, config)
+			}
+		})
+	}
+}
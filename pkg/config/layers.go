@@ -0,0 +1,120 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+
+	// directory, next to the config file, of files merged over it in lexical order
+	confDirName = "conf.d"
+
+	// directory, next to the config file, of node specific files (<node name>.json) merged last
+	nodesDirName = "nodes"
+)
+
+// getLayerPaths returns the files making up the configuration, in merge order - the config file, the files in
+// conf.d and the node's file in nodes
+func getLayerPaths(path string) ([]string, error) {
+	configDir := filepath.Dir(path)
+	layerPaths := []string{path}
+
+	confDirPaths, err := filepath.Glob(filepath.Join(configDir, confDirName, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(confDirPaths)
+	layerPaths = append(layerPaths, confDirPaths...)
+
+	nodePath := filepath.Join(configDir, nodesDirName, getNodeName()+".json")
+	if _, err := os.Stat(nodePath); err == nil {
+		layerPaths = append(layerPaths, nodePath)
+	}
+
+	return layerPaths, nil
+}
+
+// readLayers merges the configuration files into a single JSON document. Objects are merged key by key,
+// any other value (including lists) replaces the value of previous files
+func readLayers(layerPaths []string, strict bool) ([]byte, error) {
+	merged := map[string]interface{}{}
+
+	for _, layerPath := range layerPaths {
+		content, err := ioutil.ReadFile(layerPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// check each file on its own, so that errors name the file
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+
+		if err := decoder.Decode(&Config{}); err != nil {
+			return nil, fmt.Errorf("Failed to parse %s: %s", layerPath, err)
+		}
+
+		layer := map[string]interface{}{}
+		if err := json.Unmarshal(content, &layer); err != nil {
+			return nil, fmt.Errorf("Failed to parse %s: %s", layerPath, err)
+		}
+
+		mergeLayer(merged, layer)
+	}
+
+	return json.Marshal(merged)
+}
+
+func mergeLayer(merged map[string]interface{}, layer map[string]interface{}) {
+	for key, value := range layer {
+		layerObject, layerIsObject := value.(map[string]interface{})
+		mergedObject, mergedIsObject := merged[key].(map[string]interface{})
+
+		if layerIsObject && mergedIsObject {
+			mergeLayer(mergedObject, layerObject)
+			continue
+		}
+
+		merged[key] = value
+	}
+}
+
+func getNodeName() string {
+	if nodeName := os.Getenv("NODE_NAME"); nodeName != "" {
+		return nodeName
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+
+	return hostname
+}
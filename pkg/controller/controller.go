@@ -0,0 +1,114 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package controller
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/kube"
+)
+
+// Reconciler performs cluster scoped work, invoked periodically by the leader only
+type Reconciler interface {
+
+	// Name identifies the reconciler in logs
+	Name() string
+
+	// Reconcile brings the cluster to the desired state
+	Reconcile(context.Context) error
+}
+
+// Controller runs reconcilers on a single instance across the cluster, using lease based leader election
+type Controller struct {
+	config        *config.ControllerConfig
+	kubeClient    *kube.Client
+	leaderElector *kube.LeaderElector
+	reconcilers   []Reconciler
+}
+
+func NewController(controllerConfig *config.ControllerConfig) (*Controller, error) {
+	kubeClient, err := kube.NewInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Controller{
+		config:     controllerConfig,
+		kubeClient: kubeClient,
+		leaderElector: kube.NewLeaderElector(kubeClient,
+			controllerConfig.LeaseNamespace,
+			controllerConfig.LeaseName,
+			getIdentity(),
+			time.Duration(controllerConfig.LeaseDurationSeconds)*time.Second),
+	}, nil
+}
+
+// KubeClient returns the client reconcilers should use
+func (c *Controller) KubeClient() *kube.Client {
+	return c.kubeClient
+}
+
+// AddReconciler registers a reconciler to run while leading
+func (c *Controller) AddReconciler(reconciler Reconciler) {
+	c.reconcilers = append(c.reconcilers, reconciler)
+}
+
+// Run participates in leader election until the context is done
+func (c *Controller) Run(ctx context.Context) {
+	c.leaderElector.Run(ctx, c.lead)
+}
+
+func (c *Controller) lead(ctx context.Context) {
+	resyncInterval := time.Duration(c.config.ResyncIntervalSeconds) * time.Second
+
+	for {
+		for _, reconciler := range c.reconcilers {
+			journal.Debug("Reconciling", "reconciler", reconciler.Name())
+
+			if err := reconciler.Reconcile(ctx); err != nil {
+				journal.Warn("Reconcile failed", "reconciler", reconciler.Name(), "err", err.Error())
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resyncInterval):
+		}
+	}
+}
+
+// getIdentity returns the node name (set through the downward API) or hostname
+func getIdentity() string {
+	if nodeName := os.Getenv("NODE_NAME"); nodeName != "" {
+		return nodeName
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+
+	return hostname
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/kube"
+)
+
+const (
+
+	// StorageClasses with this provisioner are provisioned by the controller
+	ProvisionerName = "v3io.io/fuse"
+
+	// the flex volume driver of provisioned PVs
+	flexDriverName = "v3io/fuse"
+
+	// set on provisioned PVs, as by external provisioners
+	provisionedByAnnotation = "pv.kubernetes.io/provisioned-by"
+)
+
+// StorageClass parameters used by the provisioner, rather than passed to the PV as mount parameters
+var provisionerParameters = []string{"containerPrefix", "secretName", "secretNamespace"}
+
+// Provisioner creates a data container and a PV bound to it for pending PVCs of the driver's StorageClasses, and
+// deletes released PVs and their containers per the reclaim policy
+type Provisioner struct {
+	config     *config.Config
+	kubeClient *kube.Client
+}
+
+func NewProvisioner(provisionerConfig *config.Config, kubeClient *kube.Client) *Provisioner {
+	return &Provisioner{
+		config:     provisionerConfig,
+		kubeClient: kubeClient,
+	}
+}
+
+func (p *Provisioner) Name() string {
+	return "provisioner"
+}
+
+func (p *Provisioner) Reconcile(ctx context.Context) error {
+	storageClasses, err := p.kubeClient.ListStorageClasses()
+	if err != nil {
+		return fmt.Errorf("Failed to list storage classes: %s", err)
+	}
+
+	driverStorageClasses := map[string]*kube.StorageClass{}
+	for storageClassIdx := range storageClasses {
+		if storageClasses[storageClassIdx].Provisioner == ProvisionerName {
+			driverStorageClasses[storageClasses[storageClassIdx].Metadata.Name] = &storageClasses[storageClassIdx]
+		}
+	}
+
+	if len(driverStorageClasses) == 0 {
+		return nil
+	}
+
+	persistentVolumeClaims, err := p.kubeClient.ListPersistentVolumeClaims()
+	if err != nil {
+		return fmt.Errorf("Failed to list PVCs: %s", err)
+	}
+
+	for pvcIdx := range persistentVolumeClaims {
+		persistentVolumeClaim := &persistentVolumeClaims[pvcIdx]
+
+		storageClass := driverStorageClasses[persistentVolumeClaim.Spec.StorageClassName]
+		if storageClass == nil ||
+			persistentVolumeClaim.Status.Phase != "Pending" ||
+			persistentVolumeClaim.Spec.VolumeName != "" {
+			continue
+		}
+
+		if err := p.provision(persistentVolumeClaim, storageClass); err != nil {
+			journal.Warn("Failed to provision",
+				"namespace", persistentVolumeClaim.Metadata.Namespace,
+				"pvc", persistentVolumeClaim.Metadata.Name,
+				"err", err.Error())
+		}
+	}
+
+	return p.deleteReleased()
+}
+
+func (p *Provisioner) provision(persistentVolumeClaim *kube.PersistentVolumeClaim, storageClass *kube.StorageClass) error {
+	spec, err := flex.NewSpecFromParameters(storageClass.Parameters, provisionerParameters...)
+	if err != nil {
+		return fmt.Errorf("Invalid parameters of storage class %s: %s", storageClass.Metadata.Name, err)
+	}
+
+	if spec.Container != "" {
+		return fmt.Errorf("Storage class %s can't set a container, as one is created per PVC", storageClass.Metadata.Name)
+	}
+
+	clusterName := spec.GetClusterName()
+
+	containerPrefix := storageClass.Parameters["containerPrefix"]
+	if containerPrefix == "" {
+		containerPrefix = "pvc-"
+	}
+
+	containerName := containerPrefix + persistentVolumeClaim.Metadata.UID
+	spec.Container = containerName
+	spec.Cluster = clusterName
+
+	var quotaBytes int64
+	requestedStorage := persistentVolumeClaim.Spec.Resources.Requests["storage"]
+	if requestedStorage != "" {
+		if quotaBytes, err = kube.ParseQuantity(requestedStorage); err != nil {
+			return err
+		}
+	}
+
+	iguazioClient, err := newIguazioClient(p.config, clusterName)
+	if err != nil {
+		return err
+	}
+
+	containers, err := iguazioClient.ListContainers()
+	if err != nil {
+		return fmt.Errorf("Failed to list containers: %s", err)
+	}
+
+	containerExists := false
+	for _, container := range containers {
+		containerExists = containerExists || container.Name == containerName
+	}
+
+	if !containerExists {
+		if err := iguazioClient.CreateContainer(containerName, quotaBytes); err != nil {
+			return fmt.Errorf("Failed to create container %s: %s", containerName, err)
+		}
+	}
+
+	reclaimPolicy := storageClass.ReclaimPolicy
+	if reclaimPolicy == "" {
+		reclaimPolicy = "Delete"
+	}
+
+	secretNamespace := storageClass.Parameters["secretNamespace"]
+	if secretNamespace == "" {
+		secretNamespace = persistentVolumeClaim.Metadata.Namespace
+	}
+
+	persistentVolume := kube.PersistentVolume{
+		Metadata: kube.ObjectMeta{
+			Name: "pvc-" + persistentVolumeClaim.Metadata.UID,
+			Annotations: map[string]string{
+				provisionedByAnnotation: ProvisionerName,
+				containerAnnotation:     containerName,
+				clusterAnnotation:       clusterName,
+			},
+		},
+		Spec: kube.PersistentVolumeSpec{
+			Capacity:                      map[string]string{"storage": requestedStorage},
+			AccessModes:                   persistentVolumeClaim.Spec.AccessModes,
+			PersistentVolumeReclaimPolicy: reclaimPolicy,
+			StorageClassName:              storageClass.Metadata.Name,
+			ClaimRef: &kube.ObjectReference{
+				Kind:      "PersistentVolumeClaim",
+				Namespace: persistentVolumeClaim.Metadata.Namespace,
+				Name:      persistentVolumeClaim.Metadata.Name,
+				UID:       persistentVolumeClaim.Metadata.UID,
+			},
+			FlexVolume: &kube.FlexPersistentVolumeSource{
+				Driver:  flexDriverName,
+				Options: getPersistentVolumeOptions(spec, persistentVolumeClaim),
+			},
+		},
+	}
+
+	if secretName := storageClass.Parameters["secretName"]; secretName != "" {
+		persistentVolume.Spec.FlexVolume.SecretRef = &kube.SecretReference{
+			Name:      secretName,
+			Namespace: secretNamespace,
+		}
+	}
+
+	if err := p.kubeClient.CreatePersistentVolume(&persistentVolume); err != nil && err != kube.ErrConflict {
+		return fmt.Errorf("Failed to create PV: %s", err)
+	}
+
+	journal.Info("Provisioned volume",
+		"namespace", persistentVolumeClaim.Metadata.Namespace,
+		"pvc", persistentVolumeClaim.Metadata.Name,
+		"pv", persistentVolume.Metadata.Name,
+		"container", containerName,
+		"quotaBytes", quotaBytes)
+
+	return nil
+}
+
+// getPersistentVolumeOptions returns the flexvolume options of a provisioned PV - its mount parameters, and the
+// name of the PVC it's bound to, passed to the FUSE container
+func getPersistentVolumeOptions(spec *flex.Spec, persistentVolumeClaim *kube.PersistentVolumeClaim) map[string]string {
+	options := spec.Parameters()
+	options["pvcName"] = persistentVolumeClaim.Metadata.Name
+
+	return options
+}
+
+// deleteReleased deletes the containers and PVs of released provisioned PVs whose reclaim policy is Delete
+func (p *Provisioner) deleteReleased() error {
+	persistentVolumes, err := p.kubeClient.ListPersistentVolumes()
+	if err != nil {
+		return fmt.Errorf("Failed to list PVs: %s", err)
+	}
+
+	for _, persistentVolume := range persistentVolumes {
+		annotations := persistentVolume.Metadata.Annotations
+
+		if annotations[provisionedByAnnotation] != ProvisionerName ||
+			persistentVolume.Status.Phase != "Released" ||
+			persistentVolume.Spec.PersistentVolumeReclaimPolicy != "Delete" {
+			continue
+		}
+
+		iguazioClient, err := newIguazioClient(p.config, annotations[clusterAnnotation])
+		if err != nil {
+			journal.Warn("Failed to delete volume", "pv", persistentVolume.Metadata.Name, "err", err.Error())
+			continue
+		}
+
+		if err := iguazioClient.DeleteContainer(annotations[containerAnnotation]); err != nil {
+			journal.Warn("Failed to delete container",
+				"pv", persistentVolume.Metadata.Name,
+				"container", annotations[containerAnnotation],
+				"err", err.Error())
+			continue
+		}
+
+		if err := p.kubeClient.DeletePersistentVolume(persistentVolume.Metadata.Name); err != nil && err != kube.ErrNotFound {
+			journal.Warn("Failed to delete PV", "pv", persistentVolume.Metadata.Name, "err", err.Error())
+			continue
+		}
+
+		journal.Info("Deleted volume", "pv", persistentVolume.Metadata.Name, "container", annotations[containerAnnotation])
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/iguazio"
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/kube"
+)
+
+const (
+
+	// PVCs annotated with a data container are managed by the reconciler
+	containerAnnotation = "v3io.io/container"
+
+	// the data cluster of the container, "default" if not set
+	clusterAnnotation = "v3io.io/cluster"
+
+	// create the container if it's missing
+	createContainerAnnotation = "v3io.io/create-container"
+
+	// set by the reconciler - "Ready", "Missing" or the error
+	containerStatusAnnotation = "v3io.io/container-status"
+)
+
+// PVCReconciler validates the data containers of annotated PVCs exist, creating them if requested, so volumes
+// bound to the PVCs can be mounted
+type PVCReconciler struct {
+	config     *config.Config
+	kubeClient *kube.Client
+}
+
+func NewPVCReconciler(reconcilerConfig *config.Config, kubeClient *kube.Client) *PVCReconciler {
+	return &PVCReconciler{
+		config:     reconcilerConfig,
+		kubeClient: kubeClient,
+	}
+}
+
+func (r *PVCReconciler) Name() string {
+	return "pvc"
+}
+
+func (r *PVCReconciler) Reconcile(ctx context.Context) error {
+	persistentVolumeClaims, err := r.kubeClient.ListPersistentVolumeClaims()
+	if err != nil {
+		return fmt.Errorf("Failed to list PVCs: %s", err)
+	}
+
+	// list the containers of each cluster once per reconciliation
+	clusterContainers := map[string]map[string]bool{}
+
+	for _, persistentVolumeClaim := range persistentVolumeClaims {
+		annotations := persistentVolumeClaim.Metadata.Annotations
+
+		containerName := annotations[containerAnnotation]
+		if containerName == "" {
+			continue
+		}
+
+		status := r.reconcileContainer(containerName,
+			annotations[clusterAnnotation],
+			annotations[createContainerAnnotation] == "true",
+			clusterContainers)
+
+		if annotations[containerStatusAnnotation] == status {
+			continue
+		}
+
+		journal.Info("Data container status changed",
+			"namespace", persistentVolumeClaim.Metadata.Namespace,
+			"pvc", persistentVolumeClaim.Metadata.Name,
+			"container", containerName,
+			"status", status)
+
+		if err := r.kubeClient.PatchPersistentVolumeClaimAnnotations(persistentVolumeClaim.Metadata.Namespace,
+			persistentVolumeClaim.Metadata.Name,
+			map[string]string{containerStatusAnnotation: status}); err != nil {
+			journal.Warn("Failed to annotate PVC",
+				"namespace", persistentVolumeClaim.Metadata.Namespace,
+				"pvc", persistentVolumeClaim.Metadata.Name,
+				"err", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// reconcileContainer returns the status of a data container, creating it if missing and requested
+func (r *PVCReconciler) reconcileContainer(containerName string,
+	clusterName string,
+	createContainer bool,
+	clusterContainers map[string]map[string]bool) string {
+
+	if clusterName == "" {
+		clusterName = "default"
+	}
+
+	iguazioClient, err := newIguazioClient(r.config, clusterName)
+	if err != nil {
+		return fmt.Sprintf("Error: %s", err)
+	}
+
+	containers, listed := clusterContainers[clusterName]
+	if !listed {
+		containerList, err := iguazioClient.ListContainers()
+		if err != nil {
+			return fmt.Sprintf("Error: Failed to list containers: %s", err)
+		}
+
+		containers = map[string]bool{}
+		for _, container := range containerList {
+			containers[container.Name] = true
+		}
+
+		clusterContainers[clusterName] = containers
+	}
+
+	if containers[containerName] {
+		return "Ready"
+	}
+
+	if !createContainer {
+		return "Missing"
+	}
+
+	if err := iguazioClient.CreateContainer(containerName, 0); err != nil {
+		return fmt.Sprintf("Error: Failed to create container: %s", err)
+	}
+
+	journal.Info("Created data container", "cluster", clusterName, "container", containerName)
+	containers[containerName] = true
+
+	return "Ready"
+}
+
+// newIguazioClient returns a client of a cluster's management API
+func newIguazioClient(clientConfig *config.Config, clusterName string) (*iguazio.Client, error) {
+	apiURL, err := clientConfig.APIURL(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiURL == "" {
+		return nil, fmt.Errorf("Cluster %s has no api_url", clusterName)
+	}
+
+	accessKey, err := ioutil.ReadFile(clientConfig.Controller.AccessKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read access key: %s", err)
+	}
+
+	return iguazio.NewClient(apiURL, strings.TrimSpace(string(accessKey))), nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// FUSEConfigDir holds the v3io FUSE configuration, bound read only into FUSE containers
+const FUSEConfigDir = "/etc/v3io/fuse"
+
+// the default host directory of the FUSE containers' logs, bound into them
+const defaultContainerLogsDir = "/var/log/containers"
+
+// the target path's permissions when it's created, unless set by TargetPathMode
+const defaultTargetPathMode = 0750
+
+// checkBindSources verifies the host directories bound into a FUSE container before it's created, so that a
+// missing or misconfigured directory fails with an actionable error rather than a runtime error. The target path
+// is created if missing, and so are the container logs directory if bound
+func checkBindSources(targetPath string, options *ContainerOptions, bindsLogs bool) error {
+	targetPathMode := os.FileMode(defaultTargetPathMode)
+	if options != nil && options.TargetPathMode != 0 {
+		targetPathMode = options.TargetPathMode
+	}
+
+	if err := ensureDir(targetPath, targetPathMode); err != nil {
+		return fmt.Errorf("Target path %s is unusable: %s", targetPath, err)
+	}
+
+	fuseConfigDirInfo, err := os.Stat(FUSEConfigDir)
+	if err != nil {
+		return fmt.Errorf("Failed to stat %s, which should hold the v3io FUSE configuration - "+
+			"make sure the driver was installed on the node: %s", FUSEConfigDir, err)
+	}
+
+	if !fuseConfigDirInfo.IsDir() {
+		return fmt.Errorf("%s is not a directory, remove it and reinstall the driver on the node", FUSEConfigDir)
+	}
+
+	if fuseConfigDirInfo.Mode().Perm()&0002 != 0 {
+		return fmt.Errorf("%s is world writable (mode %o), remove write permission for others (chmod o-w %s)",
+			FUSEConfigDir,
+			fuseConfigDirInfo.Mode().Perm(),
+			FUSEConfigDir)
+	}
+
+	if bindsLogs {
+		if err := ensureDir(getContainerLogsDir(), 0755); err != nil {
+			return fmt.Errorf("Container logs directory %s is unusable: %s", getContainerLogsDir(), err)
+		}
+	}
+
+	return nil
+}
+
+// ensureDir makes sure a path is a directory, creating it with a mode if it doesn't exist
+func ensureDir(dirPath string, mode os.FileMode) error {
+	dirInfo, err := os.Stat(dirPath)
+	if os.IsNotExist(err) {
+		journal.Info("Creating missing directory", "path", dirPath, "mode", fmt.Sprintf("%o", mode))
+		return os.MkdirAll(dirPath, mode)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !dirInfo.IsDir() {
+		return fmt.Errorf("not a directory (mode %s)", dirInfo.Mode())
+	}
+
+	return nil
+}
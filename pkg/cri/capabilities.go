@@ -0,0 +1,189 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/probe"
+
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/oci"
+)
+
+// snapshotters of the FUSE containers, by preference. native works everywhere, but copies the image's layers
+var preferredSnapshotters = []string{"overlayfs", "native"}
+
+// runtimes of the FUSE containers by preference, with the shim binaries implementing them
+var preferredRuntimes = []struct {
+	name string
+	shim string
+}{
+	{name: "io.containerd.runc.v2", shim: "containerd-shim-runc-v2"},
+	{name: "io.containerd.runc.v1", shim: "containerd-shim-runc-v1"},
+}
+
+// RuntimeCapabilities are the features of the node's container runtime the FUSE containers' spec adapts to
+type RuntimeCapabilities struct {
+	Snapshotters []string `json:"snapshotters"`
+	Runtimes     []string `json:"runtimes"`
+	AppArmor     bool     `json:"apparmor"`
+	SELinux      bool     `json:"selinux"`
+	Seccomp      bool     `json:"seccomp"`
+}
+
+// GetCapabilities returns the features of containerd and the node, cached as a probe
+func (c *Containerd) GetCapabilities() (*RuntimeCapabilities, error) {
+	capabilities := RuntimeCapabilities{}
+
+	if err := probe.Cached("containerd-capabilities", &capabilities, func() error {
+		plugins, err := c.containerdClient.IntrospectionService().Plugins(c.containerdContext,
+			[]string{`type=="io.containerd.snapshotter.v1"`})
+		if err != nil {
+			return err
+		}
+
+		capabilities.Snapshotters = []string{}
+		for _, plugin := range plugins.Plugins {
+			if plugin.InitErr == nil {
+				capabilities.Snapshotters = append(capabilities.Snapshotters, plugin.ID)
+			}
+		}
+
+		// shims are binaries found by containerd, rather than plugins
+		capabilities.Runtimes = []string{}
+		for _, runtime := range preferredRuntimes {
+			if _, err := exec.LookPath(runtime.shim); err == nil {
+				capabilities.Runtimes = append(capabilities.Runtimes, runtime.name)
+			}
+		}
+
+		capabilities.AppArmor = isAppArmorEnabled()
+		capabilities.SELinux = IsSELinuxEnabled()
+		capabilities.Seccomp = isSeccompSupported()
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &capabilities, nil
+}
+
+// getSnapshotter returns the preferred snapshotter that's available, or an error if none is
+func (c *Containerd) getSnapshotter() (string, error) {
+	if c.snapshotter != "" {
+		return c.snapshotter, nil
+	}
+
+	capabilities, err := c.GetCapabilities()
+	if err != nil {
+		journal.Debug("Failed to get runtime capabilities", "err", err.Error())
+		return preferredSnapshotters[0], nil
+	}
+
+	for _, snapshotter := range preferredSnapshotters {
+		if contains(capabilities.Snapshotters, snapshotter) {
+			if snapshotter != preferredSnapshotters[0] {
+				journal.Warn("Preferred snapshotter is not available, falling back",
+					"preferred", preferredSnapshotters[0],
+					"snapshotter", snapshotter)
+			}
+
+			c.snapshotter = snapshotter
+			return snapshotter, nil
+		}
+	}
+
+	return "", fmt.Errorf("None of the snapshotters %s is available in containerd (available: %s)",
+		strings.Join(preferredSnapshotters, ", "),
+		strings.Join(capabilities.Snapshotters, ", "))
+}
+
+// getRuntime returns the preferred runtime whose shim is installed, or the most preferred one if none is found
+// (e.g. when the driver doesn't share containerd's PATH)
+func (c *Containerd) getRuntime() string {
+	capabilities, err := c.GetCapabilities()
+	if err != nil || len(capabilities.Runtimes) == 0 {
+		return preferredRuntimes[0].name
+	}
+
+	return capabilities.Runtimes[0]
+}
+
+// withCapabilities drops options of the spec the node doesn't support, so that runtimes without them (e.g.
+// stripped down ones) still run the container
+func withCapabilities(capabilities *RuntimeCapabilities) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		if capabilities == nil || s.Process == nil {
+			return nil
+		}
+
+		if !capabilities.AppArmor {
+			s.Process.ApparmorProfile = ""
+		}
+
+		if !capabilities.SELinux {
+			s.Process.SelinuxLabel = ""
+			if s.Linux != nil {
+				s.Linux.MountLabel = ""
+			}
+		}
+
+		if !capabilities.Seccomp && s.Linux != nil {
+			s.Linux.Seccomp = nil
+		}
+
+		return nil
+	}
+}
+
+func isAppArmorEnabled() bool {
+	enabled, err := ioutil.ReadFile("/sys/module/apparmor/parameters/enabled")
+	return err == nil && strings.HasPrefix(string(enabled), "Y")
+}
+
+// IsSELinuxEnabled returns whether SELinux is enabled on the node
+func IsSELinuxEnabled() bool {
+	_, err := os.Stat("/sys/fs/selinux/enforce")
+	return err == nil
+}
+
+// isSeccompSupported returns whether the kernel supports seccomp, as reported for processes in their status
+func isSeccompSupported() bool {
+	status, err := ioutil.ReadFile("/proc/self/status")
+	return err == nil && strings.Contains(string(status), "\nSeccomp:")
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
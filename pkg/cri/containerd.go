@@ -20,57 +20,190 @@ such restriction.
 package cri
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/v3io/flex-fuse/pkg/cgroup"
 	"github.com/v3io/flex-fuse/pkg/common"
 	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/probe"
+	"github.com/v3io/flex-fuse/pkg/state"
 
 	"github.com/containerd/containerd"
+	apievents "github.com/containerd/containerd/api/events"
 	"github.com/containerd/containerd/cio"
 	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/images"
 	"github.com/containerd/containerd/images/archive"
 	"github.com/containerd/containerd/namespaces"
 	"github.com/containerd/containerd/oci"
+	"github.com/containerd/typeurl/v2"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
 type Containerd struct {
+	namespace         string
 	containerdContext context.Context
 	kubernetesContext context.Context
 	containerdClient  *containerd.Client
+
+	// the k8s.io namespace images are imported from may be served by a different containerd instance
+	imageClient *containerd.Client
+	imageSock   string
+
+	// snapshotter of the FUSE containers, chosen by the runtime's capabilities
+	snapshotter string
+}
+
+// label of the FUSE containers holding their log directory
+const logDirLabel = "io.iguazio.flex-fuse/log-dir"
+
+// bounds reading a task's cgroup file when naming its log directory
+const cgroupReadTimeout = 5 * time.Second
+
+// largest log file multilog supports
+const multilogMaxFileBytes = 16777215
+
+// least time left before a deadline for importing an image from the k8s.io namespace, which takes a while
+// for large images
+const minImportTime = 15 * time.Second
+
+// how long connecting to containerd may take
+const containerdConnectTimeout = 5 * time.Second
+
+// image pulls are retried, resuming from the content fetched by previous attempts
+const (
+	pullAttempts      = 3
+	pullRetryInterval = 5 * time.Second
+)
+
+// images missing in the running namespace are looked up in the k8s.io namespace, retried as they may still be
+// pulled there. Set by SetK8sImport
+var (
+	k8sImportLock     sync.Mutex
+	k8sImportAttempts = 10
+	k8sImportInterval = 3 * time.Second
+)
+
+// SetK8sImport sets the attempts and the interval between attempts of importing images from the k8s.io namespace.
+// 0 attempts skips the k8s.io namespace, for nodes where the image is never there
+func SetK8sImport(attempts int, interval time.Duration) {
+	k8sImportLock.Lock()
+	defer k8sImportLock.Unlock()
+
+	k8sImportAttempts = attempts
+	k8sImportInterval = interval
+}
+
+func getK8sImport() (int, time.Duration) {
+	k8sImportLock.Lock()
+	defer k8sImportLock.Unlock()
+
+	return k8sImportAttempts, k8sImportInterval
 }
 
 func NewContainerd(containerdSock string, contextName string) (*Containerd, error) {
 	var err error
 
-	newContainerd := Containerd{}
+	newContainerd := Containerd{
+		namespace: contextName,
+	}
 
-	newContainerd.containerdClient, err = containerd.New(containerdSock)
+	newContainerd.containerdClient, err = newContainerdClient(containerdSock, contextName)
 	if err != nil {
 		return nil, err
 	}
 
+	newContainerd.imageClient = newContainerd.containerdClient
+	newContainerd.imageSock = containerdSock
+
 	// specify a namespace
 	newContainerd.containerdContext = namespaces.WithNamespace(context.Background(), contextName)
 
 	// kubernetes namespace
 	newContainerd.kubernetesContext = namespaces.WithNamespace(context.Background(), "k8s.io")
 
+	// only warn, as removing existing containers must keep working after containerd is upgraded
+	newContainerd.checkVersion(false) // nolint: errcheck
+
 	return &newContainerd, nil
 }
 
+// SetImageSource imports images from the k8s.io namespace of another containerd instance, and pulls them to it
+func (c *Containerd) SetImageSource(containerdSock string) error {
+	if containerdSock == c.imageSock {
+		return nil
+	}
+
+	imageClient, err := containerd.New(containerdSock,
+		containerd.WithTimeout(containerdConnectTimeout),
+		containerd.WithDefaultNamespace(c.namespace),
+		containerd.WithDefaultRuntime(c.containerdClient.Runtime()))
+	if err != nil {
+		return err
+	}
+
+	if c.imageClient != c.containerdClient {
+		c.imageClient.Close() // nolint: errcheck
+	}
+
+	c.imageClient = imageClient
+	c.imageSock = containerdSock
+
+	return nil
+}
+
+// newContainerdClient connects to containerd. The default runtime of the namespace, which the client looks up
+// on every connection, is cached as a probe
+func newContainerdClient(containerdSock string, namespace string) (*containerd.Client, error) {
+	var client *containerd.Client
+	var runtime string
+
+	if err := probe.Cached("containerd-runtime-"+namespace, &runtime, func() error {
+		var err error
+
+		client, err = containerd.New(containerdSock,
+			containerd.WithTimeout(containerdConnectTimeout),
+			containerd.WithDefaultNamespace(namespace))
+		if err != nil {
+			return err
+		}
+
+		runtime = client.Runtime()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if client != nil {
+		return client, nil
+	}
+
+	return containerd.New(containerdSock,
+		containerd.WithTimeout(containerdConnectTimeout),
+		containerd.WithDefaultNamespace(namespace),
+		containerd.WithDefaultRuntime(runtime))
+}
+
 func (c *Containerd) Close() error {
+	if c.imageClient != c.containerdClient {
+		c.imageClient.Close() // nolint: errcheck
+	}
+
 	return c.containerdClient.Close()
 }
 
@@ -78,31 +211,43 @@ func (c *Containerd) Close() error {
 func (c *Containerd) CreateContainer(image string,
 	containerName string,
 	targetPath string,
-	args []string) error {
+	args []string,
+	options *ContainerOptions) error {
 
-	// get the path to a log file
-	logFilePath, err := c.getLogFilePath(containerName, targetPath)
-	if err != nil {
+	if err := c.checkVersion(true); err != nil {
 		return err
 	}
 
-	journal.Debug("Creating log file",
-		"containerName", containerName,
-		"targetPath", targetPath,
-		"logFilePath", logFilePath)
+	if options == nil {
+		options = &ContainerOptions{}
+	}
 
-	v3ioFUSEContainer, err := c.createContainer(image, containerName, targetPath, args)
-	if err != nil {
+	if err := checkBindSources(targetPath, options, true); err != nil {
 		return err
 	}
 
-	// create the actual process
-	v3ioFUSETask, err := v3ioFUSEContainer.NewTask(c.containerdContext, cio.LogFile(logFilePath))
+	return createContainerWithRollback(c, image, containerName, targetPath, args, options)
+}
+
+// getContext returns the context of the backend's namespace, implementing containerCreator
+func (c *Containerd) getContext() context.Context {
+	return c.containerdContext
+}
+
+// withLeasedCreator implements containerCreator with withLease
+func (c *Containerd) withLeasedCreator(containerName string) (containerCreator, func(), error) {
+	return c.withLease(containerName)
+}
+
+// removeSnapshot removes a container's snapshot, if it exists
+func (c *Containerd) removeSnapshot(containerName string) error {
+	snapshotter, err := c.getSnapshotter()
 	if err != nil {
 		return err
 	}
 
-	if err := v3ioFUSETask.Start(c.containerdContext); err != nil {
+	if err := c.containerdClient.SnapshotService(snapshotter).Remove(c.containerdContext,
+		containerName); err != nil && !errdefs.IsNotFound(err) {
 		return err
 	}
 
@@ -123,7 +268,13 @@ func (c *Containerd) RemoveContainer(containerName string) error {
 		journal.Debug("No task found for container, removing container",
 			"containerName", containerName)
 
-		return container.Delete(c.containerdContext)
+		if err := container.Delete(c.containerdContext); err != nil {
+			return err
+		}
+
+		removeLogLink(containerName)
+
+		return nil
 	}
 
 	journal.Debug("Got task for container",
@@ -140,10 +291,16 @@ func (c *Containerd) RemoveContainer(containerName string) error {
 		"status", status.Status)
 
 	if status.Status != containerd.Stopped && status.Status != containerd.Created {
-		journal.Debug("Killing task", "containerName", containerName)
+		stopSignal, err := containerd.GetStopSignal(c.containerdContext, container, syscall.SIGTERM)
+		if err != nil {
+			journal.Warn("Failed to get stop signal, using SIGTERM", "containerName", containerName, "err", err.Error())
+			stopSignal = syscall.SIGTERM
+		}
+
+		journal.Debug("Killing task", "containerName", containerName, "signal", stopSignal.String())
 
 		err = task.Kill(c.containerdContext,
-			syscall.SIGTERM,
+			stopSignal,
 			containerd.WithKillAll)
 
 		if err != nil {
@@ -163,43 +320,488 @@ func (c *Containerd) RemoveContainer(containerName string) error {
 			journal.Debug("Done waiting for task to exist",
 				"containerName", containerName, "exitStatus", exitStatus)
 		case <-time.After(20 * time.Second):
-			return fmt.Errorf("Timed out waiting for %s's task to exit", containerName)
+			journal.Warn("Timed out waiting for task to exit, escalating", "containerName", containerName)
 		}
 	}
 
-	if _, err := task.Delete(c.containerdContext); err != nil {
-		return fmt.Errorf("Failed to delete %s's task: %s", containerName, err)
+	if err := c.deleteTask(container, task); err != nil {
+		return err
 	}
 
 	journal.Debug("Task deleted, deleting container", "containerName", containerName)
 
-	return container.Delete(c.containerdContext)
+	if err := container.Delete(c.containerdContext); err != nil {
+		return err
+	}
+
+	removeLogLink(containerName)
+
+	return nil
+}
+
+// deleteTask deletes a container's task. If deleting fails (e.g. the process is stuck in D state on a stale
+// FUSE mount) it escalates - killing the task with SIGKILL, force unmounting the bind target and retrying with
+// backoff, returning a TaskDeleteError with diagnostics if the task still can't be deleted
+func (c *Containerd) deleteTask(container containerd.Container, task containerd.Task) error {
+	_, err := task.Delete(c.containerdContext)
+	if err == nil {
+		return nil
+	}
+
+	containerName := container.ID()
+	attemptErrors := []string{err.Error()}
+
+	journal.Warn("Failed to delete task, escalating", "containerName", containerName, "err", err.Error())
+
+	if err := task.Kill(c.containerdContext, syscall.SIGKILL, containerd.WithKillAll); err != nil {
+		attemptErrors = append(attemptErrors, fmt.Sprintf("SIGKILL: %s", err))
+	}
+
+	if bindTargetPath := c.getBindTargetPath(container); bindTargetPath != "" {
+		journal.Warn("Force unmounting bind target", "containerName", containerName, "target", bindTargetPath)
+
+		if output, err := exec.Command("umount", "-f", "-l", bindTargetPath).CombinedOutput(); err != nil {
+			attemptErrors = append(attemptErrors, fmt.Sprintf("umount %s: %s %s", bindTargetPath, err, string(output)))
+		}
+	}
+
+	for _, interval := range []time.Duration{1, 2, 4, 8} {
+		time.Sleep(interval * time.Second)
+
+		if _, err = task.Delete(c.containerdContext, containerd.WithProcessKill); err == nil {
+			journal.Info("Deleted task after escalation", "containerName", containerName)
+			return nil
+		}
+
+		attemptErrors = append(attemptErrors, err.Error())
+	}
+
+	return &TaskDeleteError{
+		ContainerName: containerName,
+		Pid:           task.Pid(),
+		Diagnostics:   getProcessDiagnostics(task.Pid(), attemptErrors),
+		Err:           err,
+	}
+}
+
+// getBindTargetPath returns the host path bound to the container's FUSE mount point
+func (c *Containerd) getBindTargetPath(container containerd.Container) string {
+	spec, err := container.Spec(c.containerdContext)
+	if err != nil {
+		return ""
+	}
+
+	for _, mount := range spec.Mounts {
+		if mount.Destination == "/fuse_mount" {
+			return mount.Source
+		}
+	}
+
+	return ""
+}
+
+// RestartContainer starts a new task for a container whose task exited
+func (c *Containerd) RestartContainer(containerName string) error {
+	journal.Debug("Restarting container", "containerName", containerName)
+
+	container, err := c.containerdClient.LoadContainer(c.containerdContext, containerName)
+	if err != nil {
+		return err
+	}
+
+	// delete the exited task, if it's still around
+	if task, err := container.Task(c.containerdContext, nil); err == nil {
+		if _, err := task.Delete(c.containerdContext); err != nil {
+			return fmt.Errorf("Failed to delete %s's task: %s", containerName, err)
+		}
+	}
+
+	labels, err := container.Labels(c.containerdContext)
+	if err != nil {
+		return err
+	}
+
+	// containers created before log drivers were labeled log to files
+	logDriver := labels[logDriverLabel]
+	if logDriver == "" {
+		logDriver = LogDriverFile
+	}
+
+	taskIO, _, err := c.getTaskIO(containerName, "restart", logDriver, labels[logAddressLabel])
+	if err != nil {
+		return err
+	}
+
+	task, err := container.NewTask(c.containerdContext, taskIO)
+	if err != nil {
+		return err
+	}
+
+	return task.Start(c.containerdContext)
+}
+
+// PauseContainer freezes all processes of a container
+func (c *Containerd) PauseContainer(containerName string) error {
+	journal.Debug("Pausing container", "containerName", containerName)
+
+	task, err := c.loadTask(containerName)
+	if err != nil {
+		return err
+	}
+
+	return task.Pause(c.containerdContext)
+}
+
+// ResumeContainer thaws the processes of a paused container
+func (c *Containerd) ResumeContainer(containerName string) error {
+	journal.Debug("Resuming container", "containerName", containerName)
+
+	task, err := c.loadTask(containerName)
+	if err != nil {
+		return err
+	}
+
+	return task.Resume(c.containerdContext)
+}
+
+func (c *Containerd) loadTask(containerName string) (containerd.Task, error) {
+	container, err := c.containerdClient.LoadContainer(c.containerdContext, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	return container.Task(c.containerdContext, nil)
+}
+
+// PullImage pulls an image, with credentials if given
+func (c *Containerd) PullImage(image string, credentials *RegistryCredentials) error {
+	if err := c.checkVersion(true); err != nil {
+		return err
+	}
+
+	if pullCommand := getPullCommand(); pullCommand != "" {
+		if err := c.checkPullDiskSpace(image, credentials); err != nil {
+			return err
+		}
+
+		return common.RetryFunc(c.kubernetesContext, pullAttempts, pullRetryInterval, func(attempt int) (bool, error) {
+			if err := runPullCommand(pullCommand, &PullCommandFields{
+				Image:     image,
+				Registry:  GetImageRegistry(image),
+				Address:   c.imageSock,
+				Namespace: "k8s.io",
+			}, credentials, func() bool {
+				_, err := c.imageClient.GetImage(c.kubernetesContext, image)
+				return err == nil
+			}); err != nil {
+				journal.Error("Failed pulling", "image", image, "attempt", attempt, "err", err.Error())
+				return true, err
+			}
+
+			return false, nil
+		})
+	}
+
+	// [IG-23016] MountVolume.SetUp failed for volume storage in k8s 1.29
+	if credentials == nil {
+		if awsPath, err := exec.LookPath("aws"); err == nil {
+			// Get ECR password
+			cmd := exec.Command(awsPath, "ecr", "get-login-password", "--region", "us-east-2")
+			ecrPasswordBytes, err := cmd.Output()
+			if err != nil {
+				// Return an error if neither file exists
+				journal.Error("Failed to pull image: Error retrieving ECR password", "image", image)
+				return err
+			}
+
+			credentials = &RegistryCredentials{
+				Username: "AWS",
+				Password: strings.TrimSpace(string(ecrPasswordBytes)),
+			}
+		}
+	}
+
+	// credentials are handed to the resolver rather than to ctr, whose arguments any local user can read
+	if credentials != nil {
+		journal.Debug("Pulling with provided credentials",
+			"image", image,
+			"username", credentials.Username)
+
+		return c.pullImageWithResolver(image, credentials)
+	}
+
+	// Get path to ctr - k3s and RKE2 bundle theirs
+	ctrPath, err := getCtrPath()
+	if err != nil {
+		// Return an error if neither file exists
+		journal.Error("Failed to pull image: ctr not found", "image", image)
+		return err
+	}
+
+	nodeLayout := GetNodeLayout()
+	pullArgs := []string{"--address", c.imageSock, "-n", "k8s.io", "images", "pull", "--hosts-dir", nodeLayout.HostsDir, image}
+
+	// the image is the last argument
+	if tlsArgs := getRegistryTLSArgs(image); len(tlsArgs) > 0 {
+		pullArgs = append(append(pullArgs[:len(pullArgs)-1:len(pullArgs)-1], tlsArgs...), image)
+	}
+
+	// fail before fetching rather than leaving a partially unpacked image
+	if err := c.checkPullDiskSpace(image, credentials); err != nil {
+		return err
+	}
+
+	// content fetched by a failed attempt stays in the content store, so a retry only fetches what's missing -
+	// blobs are verified against their digest as they're committed
+	return common.RetryFunc(c.kubernetesContext, pullAttempts, pullRetryInterval, func(attempt int) (bool, error) {
+		output, err := exec.Command(ctrPath, pullArgs...).CombinedOutput()
+		if err == nil {
+			return false, nil
+		}
+
+		journal.Error("Failed pulling",
+			"image", image,
+			"attempt", attempt,
+			"error", err,
+			"command output", string(output),
+			"resumableBytes", c.getIngestedBytes())
+
+		return true, err
+	})
+}
+
+// pullImageWithResolver pulls and unpacks an image through the image client, resolving it with the given credentials
+func (c *Containerd) pullImageWithResolver(image string, credentials *RegistryCredentials) error {
+	// fail before fetching rather than leaving a partially unpacked image
+	if err := c.checkPullDiskSpace(image, credentials); err != nil {
+		return err
+	}
+
+	return common.RetryFunc(c.kubernetesContext, pullAttempts, pullRetryInterval, func(attempt int) (bool, error) {
+		resolver, err := newRegistryResolver(c.kubernetesContext, image, credentials)
+		if err != nil {
+			return false, err
+		}
+
+		if _, err := c.imageClient.Pull(c.kubernetesContext,
+			image,
+			containerd.WithResolver(resolver),
+			containerd.WithPullUnpack); err != nil {
+			journal.Error("Failed pulling",
+				"image", image,
+				"attempt", attempt,
+				"error", err,
+				"resumableBytes", c.getIngestedBytes())
+
+			return true, err
+		}
+
+		return false, nil
+	})
+}
+
+// getIngestedBytes returns the size of content partially fetched to the image content store
+func (c *Containerd) getIngestedBytes() int64 {
+	statuses, err := c.imageClient.ContentStore().ListStatuses(c.kubernetesContext)
+	if err != nil {
+		return 0
+	}
+
+	var ingestedBytes int64
+	for _, status := range statuses {
+		ingestedBytes += status.Offset
+	}
+
+	return ingestedBytes
+}
+
+// GetContainerStatus returns the status of a container, which may not exist
+func (c *Containerd) GetContainerStatus(containerName string) (*ContainerStatus, error) {
+	container, err := c.containerdClient.LoadContainer(c.containerdContext, containerName)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return &ContainerStatus{}, nil
+		}
+
+		return nil, err
+	}
+
+	containerStatus := ContainerStatus{
+		Exists: true,
+		State:  "stopped",
+	}
+
+	task, err := container.Task(c.containerdContext, nil)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return &containerStatus, nil
+		}
+
+		return nil, err
+	}
+
+	status, err := task.Status(c.containerdContext)
+	if err != nil {
+		return nil, err
+	}
+
+	containerStatus.Pid = task.Pid()
+	containerStatus.ExitCode = status.ExitStatus
+
+	switch status.Status {
+	case containerd.Running, containerd.Pausing:
+		containerStatus.State = "running"
+	case containerd.Paused:
+		containerStatus.State = "paused"
+	case containerd.Created:
+		containerStatus.State = "created"
+	}
+
+	if containerStatus.State == "running" || containerStatus.State == "paused" {
+		if containerStatus.StartedAt, err = getProcessStartTime(containerStatus.Pid); err != nil {
+			journal.Debug("Failed to get process start time", "containerName", containerName, "err", err.Error())
+		}
+	}
+
+	return &containerStatus, nil
+}
+
+// HasSnapshot returns whether a container's snapshot exists
+func (c *Containerd) HasSnapshot(containerName string) (bool, error) {
+	snapshotter, err := c.getSnapshotter()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = c.containerdClient.SnapshotService(snapshotter).Stat(c.containerdContext, containerName)
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// WatchTaskExits invokes the handler whenever the main task of a container exits, until the context is done
+func (c *Containerd) WatchTaskExits(ctx context.Context, handler func(containerName string, exitStatus uint32)) error {
+	envelopeChan, errChan := c.containerdClient.Subscribe(ctx,
+		`topic=="/tasks/exit"`,
+		fmt.Sprintf(`namespace==%s`, c.namespace))
+
+	for {
+		select {
+		case envelope := <-envelopeChan:
+			event, err := typeurl.UnmarshalAny(envelope.Event)
+			if err != nil {
+				journal.Warn("Failed to decode event", "topic", envelope.Topic, "err", err.Error())
+				continue
+			}
+
+			taskExit, ok := event.(*apievents.TaskExit)
+
+			// ignore exits of exec'd processes
+			if !ok || taskExit.ID != taskExit.ContainerID {
+				continue
+			}
+
+			handler(taskExit.ContainerID, taskExit.ExitStatus)
+
+		case err := <-errChan:
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// ListContainers returns the names of containers starting with a prefix
+func (c *Containerd) ListContainers(namePrefix string) ([]string, error) {
+	containers, err := c.containerdClient.Containers(c.containerdContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var containerNames []string
+	for _, container := range containers {
+		if strings.HasPrefix(container.ID(), namePrefix) {
+			containerNames = append(containerNames, container.ID())
+		}
+	}
+
+	return containerNames, nil
+}
+
+// ListOwned returns the names of the containers created by the driver
+func (c *Containerd) ListOwned() ([]string, error) {
+
+	// filters are OR'ed
+	containers, err := c.containerdClient.Containers(c.containerdContext,
+		fmt.Sprintf(`labels."%s"==%s`, OwnerLabel, OwnerLabelValue),
+		fmt.Sprintf(`labels."%s"`, legacyOwnerLabel))
+	if err != nil {
+		return nil, err
+	}
+
+	var containerNames []string
+	for _, container := range containers {
+		containerNames = append(containerNames, container.ID())
+	}
+
+	return containerNames, nil
+}
+
+// GetContainerPid returns the pid of a container's running process
+func (c *Containerd) GetContainerPid(containerName string) (uint32, error) {
+	container, err := c.containerdClient.LoadContainer(c.containerdContext, containerName)
+	if err != nil {
+		return 0, err
+	}
+
+	task, err := container.Task(c.containerdContext, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return task.Pid(), nil
+}
+
+// GetContainerSpec returns the OCI spec of a container, as JSON
+func (c *Containerd) GetContainerSpec(containerName string) ([]byte, error) {
+	container, err := c.containerdClient.LoadContainer(c.containerdContext, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := container.Spec(c.containerdContext)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(spec, "", "  ")
 }
 
 func (c *Containerd) createContainer(image string,
 	containerName string,
 	targetPath string,
-	args []string) (containerd.Container, error) {
-
-	// The log filename incorporates the container-ID found in the `/proc/self/cgroup` file.
-	// Specifically, we're scanning for a character sequence longer than 32 characters that appears after the last '/'.
-	// If such a sequence isn't located, we use the term 'random'.
-	// Additionally, a random number is appended to the end of the filename.
-	// Here are examples of such cgroup files:
-	// root@gke-zd-gke1-app-clust-zd-gke1-initial-7b135c73-jxn0:/#  cat /proc/self/cgroup
-	// 13:misc:/
-	// 12:rdma:/
-	// 11:memory:/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/466f13d55e758cf1e969744007435e2eb3d48f4d64f81fa7f2c2c7ac14690c23
-	// 10:freezer:/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/466f13d55e758cf1e969744007435e2eb3d48f4d64f81fa7f2c2c7ac14690c23
-	// ...
-	// 1:name=systemd:/kubepods/besteffort/pod0404f9f9-7e8f-4cf0-848a-a7a23ef63393/466f13d55e758cf1e969744007435e2eb3d48f4d64f81fa7f2c2c7ac14690c23
-	// 0::/system.slice/containerd.service
-	// [root@k8s-node1 /]# cat /proc/25512/cgroup
-	// 11:perf_event:/kubepods/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage
-	// ...
-	// 2:devices:/kubepods/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage
-	// 1:name=systemd:/kubepods/v3io-fuse-ef516052-8c8f-4ddc-b1ac-53a2b63c6d47-storage
-	args = append(args, " 2>&1 | multilog s16777215 n20 /var/log/containers/flex-fuse-`awk 'match($0, /\\/([^/]+)$/) {if (RLENGTH>32) {printf \"%s.%08x\",substr($0, RSTART+1, RLENGTH-1), int(rand()*1e8) ;exit}} BEGIN {srand()} END {if (RLENGTH <= 32) { printf \"random.%08x\", int(rand()*1e8);}}' /proc/self/cgroup`")
+	args []string,
+	logLinkPath string,
+	options *ContainerOptions) (containerd.Container, error) {
+
+	options.startStep(StepImageResolve)
+
+	snapshotter, err := c.getSnapshotter()
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities, err := c.GetCapabilities()
+	if err != nil {
+		journal.Debug("Failed to get runtime capabilities", "err", err.Error())
+	}
+
+	cgroupsPath := path.Join(cgroup.Parent(), containerName)
+	args = append(args, getLogPipeline(options, logLinkPath))
 
 	journal.Debug("Creating container",
 		"image", image,
@@ -207,19 +809,13 @@ func (c *Containerd) createContainer(image string,
 		"targetPath", targetPath,
 		"args", args)
 
-	// try to get image from k8s namespace
-	importedImages, err := c.tryImportFromK8sNamespace(image)
-	if err != nil {
-		journal.Debug("Failed to import image from k8s namespace. Error: " + err.Error())
-	} else {
-		journal.Debug("Successfully imported image from k8s namespace",
-			"containerName", containerName,
-			"lenImportedImages", strconv.Itoa(len(importedImages)),
-			"currentImageName", image)
+	// a node local image layout takes precedence over the k8s namespace and registries
+	layoutImported := options.ImageLayoutDir != "" && c.importImageLayoutIfMissing(options.ImageLayoutDir, image)
 
-		// override image
-		if len(importedImages) > 0 {
-			image = importedImages[0].Name
+	// try to get image from kubelet's images, unless it was already imported
+	if !layoutImported {
+		if importedImage := c.importFromKubelet(image, options.Deadline); importedImage != "" {
+			image = importedImage
 		}
 	}
 
@@ -230,55 +826,17 @@ func (c *Containerd) createContainer(image string,
 			"containerName", containerName,
 			"image", image)
 
-		// pull the v3io-fuse image
-		// [IG-23016] MountVolume.SetUp failed for volume storage in k8s 1.29
-		//
-
-		var err error
-
-		// Get path to ctr
-		var ctrPath string
-		if ctrPath, err = exec.LookPath("ctr"); err == nil {
-		} else if _, err = os.Stat("/usr/local/bin/ctr"); err == nil {
-			ctrPath = "/usr/local/bin/ctr"
-		} else if _, err = os.Stat("/usr/bin/ctr"); err == nil {
-			ctrPath = "/usr/bin/ctr"
-		}
-		if err != nil {
-			// Return an error if neither file exists
-			journal.Error("Failed to pull image: ctr not found",
-				"containerName", containerName,
-				"image", image)
+		if err := options.checkDeadline(); err != nil {
 			return nil, err
 		}
 
-		// Check if AWS CLI is installed
-		var cmd *exec.Cmd
-		var awsPath string
-
-		if awsPath, err = exec.LookPath("aws"); err == nil {
-			// Get ECR password
-			cmd = exec.Command(awsPath, "ecr", "get-login-password", "--region", "us-east-2")
-			ecrPasswordBytes, err := cmd.Output()
-			if err != nil {
-				// Return an error if neither file exists
-				journal.Error("Failed to pull image: Error retrieving ECR password",
-					"containerName", containerName,
-					"image", image)
-				return nil, err
-			}
-			ecrPassword := strings.TrimSpace(string(ecrPasswordBytes))
-			cmd = exec.Command(ctrPath, "-n", "k8s.io", "images", "pull", "--user", fmt.Sprintf("AWS:%s", ecrPassword), image)
-		} else {
-			cmd = exec.Command(ctrPath, "-n", "k8s.io", "images", "pull", "--hosts-dir", "/etc/containerd/certs.d/", image)
-		}
+		options.reportProgress(ProgressPulling)
+		options.startStep(StepImagePull)
 
-		output, err := cmd.CombinedOutput()
-		// Handle errors
-		if err != nil {
-			journal.Error("Failed pulling", "containerName", containerName, "image", image, "error", err, "command output", string(output))
+		if err := c.PullImage(image, options.PullCredentials); err != nil {
 			return nil, err
 		}
+
 		v3ioFUSEImage, err = c.containerdClient.GetImage(c.containerdContext, image)
 		if err != nil {
 			journal.Error("Failed to pull image",
@@ -288,11 +846,18 @@ func (c *Containerd) createContainer(image string,
 		}
 	}
 
+	if err := c.ensureUnpacked(c.containerdContext, v3ioFUSEImage); err != nil {
+		return nil, fmt.Errorf("Failed to unpack %s: %s", image, err)
+	}
+
+	options.startStep(StepSpecBuild)
+
+	logsDir := getContainerLogsDir()
 	mounts := []specs.Mount{
 		{
-			Destination: "/etc/v3io/fuse",
+			Destination: FUSEConfigDir,
 			Type:        "bind",
-			Source:      "/etc/v3io/fuse",
+			Source:      FUSEConfigDir,
 			Options:     []string{"rbind", "ro"},
 		},
 		{
@@ -302,14 +867,23 @@ func (c *Containerd) createContainer(image string,
 			Options:     []string{"rbind", "shared"},
 		},
 		{
-			Destination: "/var/log/containers",
+			Destination: logsDir,
 			Type:        "bind",
-			Source:      "/var/log/containers",
+			Source:      logsDir,
 			Options:     []string{"rbind", "shared"},
 		},
 	}
 
-	options := []oci.SpecOpts{
+	if options.HugepagesPath != "" {
+		mounts = append(mounts, specs.Mount{
+			Destination: hugepagesMountPath,
+			Type:        "bind",
+			Source:      options.HugepagesPath,
+			Options:     []string{"rbind", "rw"},
+		})
+	}
+
+	specOpts := []oci.SpecOpts{
 		oci.WithDefaultSpec(),
 		oci.WithDefaultUnixDevices,
 		oci.WithMounts(mounts),
@@ -322,33 +896,115 @@ func (c *Containerd) createContainer(image string,
 		oci.WithHostHostsFile,
 		oci.WithHostResolvconf,
 		oci.WithDevices("/dev/fuse", "", "rwm"),
-		withCgroupParent(getCgroupParent()),
+		withCgroupsPath(cgroupsPath),
 		withRootfsPropagation,
+		withCapabilities(capabilities),
+	}
+
+	if len(options.Env) > 0 {
+		specOpts = append(specOpts, oci.WithEnv(options.Env))
+	}
+
+	if len(options.Annotations) > 0 {
+		specOpts = append(specOpts, oci.WithAnnotations(options.Annotations))
+	}
+
+	if options.MemlockLimit != 0 {
+		specOpts = append(specOpts, withMemlockLimit(options.MemlockLimit))
+	}
+
+	if options.OOMScoreAdj != nil {
+		specOpts = append(specOpts, withOOMScoreAdj(*options.OOMScoreAdj))
+	}
+
+	if options.CPUSetCPUs != "" {
+		specOpts = append(specOpts, oci.WithCPUs(options.CPUSetCPUs))
+	}
+
+	if options.CPUSetMems != "" {
+		specOpts = append(specOpts, oci.WithCPUsMems(options.CPUSetMems))
+	}
+
+	if options.IOLimits.IsSet() {
+		specOpts = append(specOpts, withIOLimits(options.IOLimits))
+	}
+
+	snapshotOpt := containerd.WithNewSnapshot(containerName, v3ioFUSEImage)
+
+	// run in a user namespace, with the snapshot owned by the remapped root
+	if len(options.UIDMappings) > 0 || len(options.GIDMappings) > 0 {
+		rootUID, err := getRemappedRootID(options.UIDMappings)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid UID mappings: %s", err)
+		}
+
+		rootGID, err := getRemappedRootID(options.GIDMappings)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid GID mappings: %s", err)
+		}
+
+		journal.Debug("Running container in user namespace",
+			"containerName", containerName,
+			"rootUID", rootUID,
+			"rootGID", rootGID)
+
+		specOpts = append(specOpts, oci.WithUserNamespace(options.UIDMappings, options.GIDMappings))
+		snapshotOpt = containerd.WithRemappedSnapshot(containerName, v3ioFUSEImage, rootUID, rootGID)
+	}
+
+	// the image's digest is labeled for auditing, and the log directory once the task is created (see linkLogDir)
+	imageDigest := v3ioFUSEImage.Target().Digest.String()
+	options.reportImageResolved(v3ioFUSEImage.Name(), imageDigest)
+
+	labels := map[string]string{
+		OwnerLabel:       OwnerLabelValue,
+		ImageDigestLabel: imageDigest,
+		logDriverLabel:   options.getLogDriver(),
+	}
+	if options.LogAddress != "" {
+		labels[logAddressLabel] = options.LogAddress
+	}
+	for labelKey, labelValue := range options.Labels {
+		labels[labelKey] = labelValue
+	}
+
+	// the stop signal is labeled as well, and the image's is used unless overridden
+	stopSignalOpt := containerd.WithImageStopSignal(v3ioFUSEImage, "SIGTERM")
+	if options.StopSignal != "" {
+		stopSignalOpt = containerd.WithAdditionalContainerLabels(map[string]string{
+			containerd.StopSignalLabel: options.StopSignal,
+		})
 	}
 
 	var spec specs.Spec
 
-	snapshotterName := "overlayfs"
+	options.startStep(StepCreate)
 
 	// before creating, try to delete the snapshot if it exists - otherwise it'll fail
-	c.containerdClient.SnapshotService(snapshotterName).Remove(c.containerdContext, containerName)
+	c.containerdClient.SnapshotService(snapshotter).Remove(c.containerdContext, containerName)
 
-	return c.containerdClient.NewContainer(
+	container, err := c.containerdClient.NewContainer(
 		c.containerdContext,
 		containerName,
 		containerd.WithImage(v3ioFUSEImage),
-		containerd.WithSnapshotter(snapshotterName),
-		containerd.WithNewSnapshot(containerName, v3ioFUSEImage),
-		containerd.WithImageStopSignal(v3ioFUSEImage, "SIGTERM"),
-		containerd.WithRuntime("io.containerd.runc.v2", nil),
-		containerd.WithSpec(&spec, options...),
+		containerd.WithSnapshotter(snapshotter),
+		snapshotOpt,
+		containerd.WithRuntime(c.getRuntime(), nil),
+		containerd.WithContainerLabels(labels),
+		stopSignalOpt,
+		containerd.WithSpec(&spec, specOpts...),
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	return container, nil
 }
 
 func (c *Containerd) getLogFilePath(containerName string, targetPath string) (string, error) {
 	sanitizedTargetPath := strings.Replace(targetPath, "/", "-", -1)
 
-	logFile, err := ioutil.TempFile("", fmt.Sprintf("%s-%s-", containerName, sanitizedTargetPath))
+	logFile, err := CreateTempFile(fmt.Sprintf("%s-%s-", containerName, sanitizedTargetPath))
 	if err != nil {
 		return "", err
 	}
@@ -358,20 +1014,105 @@ func (c *Containerd) getLogFilePath(containerName string, targetPath string) (st
 	return logFile.Name(), nil
 }
 
-func (c *Containerd) tryImportFromK8sNamespace(imageName string) ([]images.Image, error) {
-	var buf bytes.Buffer
+// isImageImported returns whether an image was already imported from the k8s namespace, comparing its digest
+// to the (cached) digest of the image in the k8s namespace
+func (c *Containerd) isImageImported(imageName string) bool {
+	image, err := c.containerdClient.GetImage(c.containerdContext, imageName)
+	if err != nil {
+		return false
+	}
+
+	var k8sDigest string
+	if err := probe.Cached("k8s-image-"+state.NameFromPath(imageName), &k8sDigest, func() error {
+		k8sImage, err := c.imageClient.GetImage(c.kubernetesContext, imageName)
+		if err != nil {
+			return err
+		}
+
+		k8sDigest = k8sImage.Target().Digest.String()
+		return nil
+	}); err != nil {
+		return false
+	}
+
+	return image.Target().Digest.String() == k8sDigest
+}
+
+// importFromKubelet imports an image from where kubelet's images are - the k8s.io namespace, or docker's image store
+// on cri-dockerd nodes - returning the imported image's name, or an empty string if it wasn't imported
+func (c *Containerd) importFromKubelet(image string, deadline time.Time) string {
+	var importedImages []images.Image
+	var err error
+
+	switch getKubeletImageStore() {
+	case KubeletImageStoreDocker:
+		if _, getErr := c.containerdClient.GetImage(c.containerdContext, image); getErr == nil {
+			return ""
+		}
+
+		importedImages, err = c.tryImportFromDocker(image, deadline)
+		if err != nil {
+			journal.Debug("Failed to import image from docker", "image", image, "err", err.Error())
+			return ""
+		}
+
+	default:
+		k8sImportAttempts, k8sImportInterval := getK8sImport()
+		if k8sImportAttempts <= 0 || c.isImageImported(image) {
+			return ""
+		}
+
+		importedImages, err = c.tryImportFromK8sNamespace(image, k8sImportAttempts, k8sImportInterval, deadline)
+		if err != nil {
+			journal.Debug("Failed to import image from k8s namespace. Error: " + err.Error())
+			return ""
+		}
+	}
+
+	journal.Debug("Successfully imported image from kubelet's images",
+		"lenImportedImages", strconv.Itoa(len(importedImages)),
+		"currentImageName", image)
+
+	if len(importedImages) == 0 {
+		return ""
+	}
+
+	return importedImages[0].Name
+}
+
+// tryImportFromK8sNamespace imports an image from the k8s.io namespace, retrying while it may still be pulled there.
+// A deadline (if not zero) aborts the retries and an import in progress, and the import is skipped if it's too
+// close for an import to complete
+func (c *Containerd) tryImportFromK8sNamespace(imageName string,
+	attempts int,
+	interval time.Duration,
+	deadline time.Time) ([]images.Image, error) {
 	var err error
 	var imageInstance containerd.Image
 	var importedImages []images.Image
 
-	err = common.RetryFunc(c.containerdContext,
-		10,
-		3*time.Second,
+	containerdContext, kubernetesContext := c.containerdContext, c.kubernetesContext
+	if !deadline.IsZero() {
+		if time.Until(deadline) < minImportTime {
+			return nil, fmt.Errorf("Less than %s left before the deadline, skipping", minImportTime)
+		}
+
+		var cancelContainerd, cancelKubernetes context.CancelFunc
+		containerdContext, cancelContainerd = context.WithDeadline(containerdContext, deadline)
+		kubernetesContext, cancelKubernetes = context.WithDeadline(kubernetesContext, deadline)
+
+		defer cancelContainerd()
+		defer cancelKubernetes()
+	}
+
+	err = common.RetryFunc(containerdContext,
+		attempts,
+		interval,
 		func(attempt int) (bool, error) {
 
 			// make sure image is on k8s namespace
-			imageInstance, err = c.containerdClient.GetImage(
-				c.kubernetesContext,
+			imageInstance, err = c.imageClient.GetImage(
+				kubernetesContext,
 				imageName,
 			)
 			if err != nil {
@@ -381,29 +1122,10 @@ func (c *Containerd) tryImportFromK8sNamespace(imageName string) ([]images.Image
 				return true, err
 			}
 
-			// reset buffer for next retry, if needed at all
-			defer buf.Reset()
-
-			// export from k8s context
-			if err = c.containerdClient.Export(
-				c.kubernetesContext,
-				&buf,
-				archive.WithImage(c.containerdClient.ImageService(), imageInstance.Name()),
-			); err != nil {
-
-				// exported failed - try again
-				journal.Debug("Failed to export image from k8s namespace, retrying",
-					"attempt", attempt,
-					"err", err.Error())
-				return true, err
-			}
-
-			// import to current containerd context
-			importedImages, err = c.containerdClient.Import(c.containerdContext, &buf)
+			// stream the export into the import, so that the image isn't held in memory
+			importedImages, err = c.streamImport(containerdContext, kubernetesContext, imageInstance.Name())
 			if err != nil {
-
-				// import failed, try again
-				journal.Debug("Failed to import image to running namespace, retrying",
+				journal.Debug("Failed to import image from k8s namespace to running namespace, retrying",
 					"attempt", attempt,
 					"err", err.Error())
 				return true, err
@@ -411,7 +1133,7 @@ func (c *Containerd) tryImportFromK8sNamespace(imageName string) ([]images.Image
 
 			// get imported image
 			imageInstance, err = c.containerdClient.GetImage(
-				c.containerdContext,
+				containerdContext,
 				imageName,
 			)
 			if err != nil {
@@ -422,7 +1144,7 @@ func (c *Containerd) tryImportFromK8sNamespace(imageName string) ([]images.Image
 			}
 
 			// unpack imported
-			if err = imageInstance.Unpack(c.containerdContext, ""); err != nil {
+			if err = c.unpackImage(containerdContext, imageInstance); err != nil {
 				journal.Debug("Failed to unpack imported image in running namespace, retrying",
 					"attempt", attempt,
 					"err", err.Error())
@@ -435,27 +1157,215 @@ func (c *Containerd) tryImportFromK8sNamespace(imageName string) ([]images.Image
 	return importedImages, err
 }
 
+// streamImport exports an image from the k8s.io namespace and concurrently imports it to the running namespace
+// through a pipe
+func (c *Containerd) streamImport(containerdContext context.Context,
+	kubernetesContext context.Context,
+	imageName string) ([]images.Image, error) {
+	reader, writer := io.Pipe()
+
+	exportErrChan := make(chan error, 1)
+	go func() {
+		exportErr := c.imageClient.Export(kubernetesContext,
+			writer,
+			archive.WithImage(c.imageClient.ImageService(), imageName))
+
+		// the import reads until the archive ends, or the export's error
+		writer.CloseWithError(exportErr) // nolint: errcheck
+		exportErrChan <- exportErr
+	}()
+
+	importedImages, err := c.containerdClient.Import(containerdContext, reader)
+	if err == nil {
+
+		// the import may stop reading before the archive's trailer is written
+		_, err = io.Copy(ioutil.Discard, reader)
+	}
+
+	// a failed import stops the export writing to the pipe
+	reader.CloseWithError(err) // nolint: errcheck
+
+	// an export failing midway fails the import with the export's error
+	exportErr := <-exportErrChan
+	if err != nil {
+		return nil, fmt.Errorf("Failed to import: %s", err)
+	}
+
+	if exportErr != nil {
+		return nil, fmt.Errorf("Failed to export: %s", exportErr)
+	}
+
+	return importedImages, nil
+}
+
+// getRemappedRootID returns the host ID that the container's root (ID 0) is mapped to
+func getRemappedRootID(mappings []specs.LinuxIDMapping) (uint32, error) {
+	for _, mapping := range mappings {
+		if mapping.ContainerID == 0 && mapping.Size > 0 {
+			return mapping.HostID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("No mapping found for container ID 0")
+}
+
 func withRootfsPropagation(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
 	s.Linux.RootfsPropagation = "shared"
 	return nil
 }
 
-func withCgroupParent(cgroupParentPath string) oci.SpecOpts {
-	return func(_ context.Context, _ oci.Client, c *containers.Container, s *oci.Spec) error {
-		s.Linux.CgroupsPath = path.Join(cgroupParentPath, c.ID)
+func withCgroupsPath(cgroupsPath string) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		s.Linux.CgroupsPath = cgroupsPath
+
+		return nil
+	}
+}
+
+func withMemlockLimit(memlockLimit int64) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		limit := uint64(memlockLimit)
+		if memlockLimit < 0 {
+			limit = math.MaxUint64
+		}
+
+		var rlimits []specs.POSIXRlimit
+		for _, rlimit := range s.Process.Rlimits {
+			if rlimit.Type != "RLIMIT_MEMLOCK" {
+				rlimits = append(rlimits, rlimit)
+			}
+		}
+
+		s.Process.Rlimits = append(rlimits, specs.POSIXRlimit{
+			Type: "RLIMIT_MEMLOCK",
+			Hard: limit,
+			Soft: limit,
+		})
+
+		return nil
+	}
+}
 
+func withOOMScoreAdj(oomScoreAdj int) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		s.Process.OOMScoreAdj = &oomScoreAdj
 		return nil
 	}
 }
-func getCgroupParent() string {
-	cmd := exec.Command("stat", "-fc", "%T", "/sys/fs/cgroup/")
-	out, err := cmd.Output()
+
+// getMultilogCommand returns the multilog invocation rotating the container's log. multilog replaces rotated
+// files with the output of the processor, which compresses them
+func getMultilogCommand(options *ContainerOptions) string {
+	logMaxFileBytes := options.LogMaxFileBytes
+	if logMaxFileBytes <= 0 || logMaxFileBytes > multilogMaxFileBytes {
+		logMaxFileBytes = multilogMaxFileBytes
+	}
+
+	logMaxFiles := options.LogMaxFiles
+	if logMaxFiles <= 0 {
+		logMaxFiles = 20
+	}
+
+	multilogCommand := fmt.Sprintf("multilog s%d n%d", logMaxFileBytes, logMaxFiles)
+	if options.LogCompress {
+		multilogCommand += " '!gzip'"
+	}
+
+	return multilogCommand
+}
+
+// getLogPrefixCommand returns a pipeline stage prepending the log prefix to every line, if set. Characters that
+// would need quoting are dropped from the prefix
+func getLogPrefixCommand(options *ContainerOptions) string {
+	logPrefix := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '/' || r == '=' || r == '.' || r == '-' || r == '_' || r == ':' ||
+			(r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+
+		return -1
+	}, options.LogPrefix)
+
+	if logPrefix == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(`awk '{ print "%s " $0; fflush() }' | `, logPrefix)
+}
+
+// GetContainerLogTail returns up to a number of last lines of a container's log
+func (c *Containerd) GetContainerLogTail(containerName string, lines int) (string, error) {
+	logDir, err := c.GetContainerLogDir(containerName)
 	if err != nil {
-		return "/kubepods"
+		return "", err
 	}
-	if strings.TrimSpace(string(out)) == "cgroup2fs" {
-		return "/kubepods.slice"
-	} else {
-		return "/kubepods"
+
+	return readLogTail(path.Join(logDir, "current"), lines), nil
+}
+
+// GetContainerLogDir returns the directory the current log of a container is written in, as labeled when the
+// container was created
+func (c *Containerd) GetContainerLogDir(containerName string) (string, error) {
+	container, err := c.containerdClient.LoadContainer(c.containerdContext, containerName)
+	if err != nil {
+		return "", err
+	}
+
+	labels, err := container.Labels(c.containerdContext)
+	if err != nil {
+		return "", err
+	}
+
+	logDir, found := labels[logDirLabel]
+	if !found {
+		return "", fmt.Errorf("Container %s has no log directory label", containerName)
+	}
+
+	return logDir, nil
+}
+
+// getLogLinkPath returns the path multilog is given as a container's log directory. The log directory is named
+// after the task's cgroup, which is only known once the task is created, so the path links to it (see linkLogDir).
+// It's hidden, so that it isn't taken for a log directory itself
+func getLogLinkPath(containerName string) string {
+	return path.Join(getContainerLogsDir(), ".flex-fuse-"+containerName)
+}
+
+// linkLogDir creates the log directory of a created task and links the container's log link path to it. The
+// directory incorporates the container ID found in the task's cgroup file, and a random suffix so that recreated
+// containers don't share a log
+func linkLogDir(containerName string, pid uint32) (string, error) {
+	logDir := path.Join(getContainerLogsDir(), "flex-fuse-"+getLogName(fmt.Sprintf("/proc/%d/cgroup", pid)))
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		return "", err
 	}
+
+	logLinkPath := getLogLinkPath(containerName)
+	if err := os.Remove(logLinkPath); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := os.Symlink(logDir, logLinkPath); err != nil {
+		return "", err
+	}
+
+	return logDir, nil
+}
+
+// removeLogLink removes the log link of a removed container. Its log directory is left for pruning
+func removeLogLink(containerName string) {
+	if err := os.Remove(getLogLinkPath(containerName)); err != nil && !os.IsNotExist(err) {
+		journal.Debug("Failed to remove log link", "containerName", containerName, "err", err.Error())
+	}
+}
+
+// getLogName returns <container ID>.<random>, or random.<random> if no container ID is found in the cgroup file
+func getLogName(cgroupFilePath string) string {
+	containerID, err := cgroup.ContainerIDFromFile(cgroupFilePath, cgroupReadTimeout)
+	if err != nil {
+		journal.Debug("Failed to get container ID", "path", cgroupFilePath, "err", err.Error())
+		containerID = "random"
+	}
+
+	return fmt.Sprintf("%s.%08x", containerID, rand.Uint32())
 }
@@ -0,0 +1,77 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestGetRemappedRootID(t *testing.T) {
+	for _, testCase := range []struct {
+		name           string
+		mappings       []specs.LinuxIDMapping
+		expectedRootID uint32
+		expectedError  bool
+	}{
+		{
+			name:           "root mapped",
+			mappings:       []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}},
+			expectedRootID: 100000,
+		},
+		{
+			name: "root mapped by a later mapping",
+			mappings: []specs.LinuxIDMapping{
+				{ContainerID: 1000, HostID: 1000, Size: 1},
+				{ContainerID: 0, HostID: 200000, Size: 1000},
+			},
+			expectedRootID: 200000,
+		},
+		{
+			name:          "root not mapped",
+			mappings:      []specs.LinuxIDMapping{{ContainerID: 1, HostID: 100001, Size: 65535}},
+			expectedError: true,
+		},
+		{
+			name:          "empty mapping",
+			mappings:      []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 0}},
+			expectedError: true,
+		},
+		{
+			name:          "no mappings",
+			expectedError: true,
+		},
+	} {
+		rootID, err := getRemappedRootID(testCase.mappings)
+		if testCase.expectedError {
+			if err == nil {
+				t.Errorf("%s: expected an error, got root ID %d", testCase.name, rootID)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.name, err)
+		} else if rootID != testCase.expectedRootID {
+			t.Errorf("%s: expected root ID %d, got %d", testCase.name, testCase.expectedRootID, rootID)
+		}
+	}
+}
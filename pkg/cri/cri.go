@@ -19,14 +19,241 @@ such restriction.
 */
 package cri
 
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/cgroup"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// where HugepagesPath is mounted in the container
+const hugepagesMountPath = "/dev/hugepages"
+
+// progress reported while creating a container
+const (
+	ProgressPulling  = "Pulling"
+	ProgressStarting = "Starting"
+)
+
+// steps of creating a container, timed by OnStep
+const (
+	StepImageResolve = "imageResolve"
+	StepImagePull    = "imagePull"
+	StepSpecBuild    = "specBuild"
+	StepCreate       = "create"
+	StepStart        = "start"
+)
+
+// RegistryCredentials authenticate pulling an image
+type RegistryCredentials struct {
+	Username string
+	Password string
+}
+
+// ContainerOptions holds optional settings applied when creating a container
+type ContainerOptions struct {
+
+	// UIDMappings and GIDMappings run the container in a user namespace when set
+	UIDMappings []specs.LinuxIDMapping
+	GIDMappings []specs.LinuxIDMapping
+
+	// Labels are set on the created container
+	Labels map[string]string
+
+	// Env are KEY=VALUE environment variables added to the container's process
+	Env []string
+
+	// Annotations are set on the container's OCI spec
+	Annotations map[string]string
+
+	// StopSignal is the signal stopping the container (e.g. "SIGINT"), overriding the image's stop signal. Empty
+	// uses the image's, or SIGTERM
+	StopSignal string
+
+	// MemlockLimit is the container's RLIMIT_MEMLOCK in bytes, -1 for unlimited. 0 keeps the runtime's default
+	MemlockLimit int64
+
+	// OOMScoreAdj is the container process's oom_score_adj, if set
+	OOMScoreAdj *int
+
+	// CPUSetCPUs and CPUSetMems pin the container to CPUs and NUMA memory nodes (cpuset lists, e.g. "0-3")
+	CPUSetCPUs string
+	CPUSetMems string
+
+	// IOLimits throttle the container's I/O on block devices, if set
+	IOLimits *IOLimits
+
+	// HugepagesPath is a host hugetlbfs mount, bound at /dev/hugepages in the container if set
+	HugepagesPath string
+
+	// PullCredentials are used if the image has to be pulled
+	PullCredentials *RegistryCredentials
+
+	// ImageLayoutDir is a host directory holding an OCI image layout, imported if the image doesn't exist before
+	// anything is pulled (containerd only)
+	ImageLayoutDir string
+
+	// LogMaxFileBytes and LogMaxFiles bound the rotation of the container's log, and LogCompress gzips rotated
+	// files (containerd only). 0 keeps the defaults of 16MB and 20 files
+	LogMaxFileBytes int64
+	LogMaxFiles     int
+	LogCompress     bool
+
+	// LogPrefix is prepended to every line of the container's log, e.g. to attribute it to a pod (containerd only)
+	LogPrefix string
+
+	// LogDriver is where the container's log goes - LogDriverFile (default), LogDriverNone or LogDriverFluentd,
+	// forwarding it to the fluentd at LogAddress (unix:///path, tcp://host:port or host:port)
+	LogDriver  string
+	LogAddress string
+
+	// StartupWait is how long the container's process is watched after starting. If it exits meanwhile, creating
+	// the container fails with the tail of its log. 0 doesn't wait
+	StartupWait time.Duration
+
+	// TargetPathMode are the permissions the target path is created with if it doesn't exist (default 0750)
+	TargetPathMode os.FileMode
+
+	// Deadline bounds creating the container (e.g. by kubelet's timeout), so that imports and retries that wouldn't
+	// complete before it are skipped and the next attempt starts fresh. Zero has no deadline
+	Deadline time.Time
+
+	// OnProgress is called when the image starts being pulled (ProgressPulling) and when the container's process
+	// starts (ProgressStarting)
+	OnProgress func(string)
+
+	// OnImageResolved is called with the image and the digest the container is created from (the manifest digest,
+	// or the image ID for docker images without one)
+	OnImageResolved func(string, string)
+
+	// OnStep is called when a step of creating the container (e.g. StepImagePull) starts, ending the previous one
+	OnStep func(string)
+}
+
+func (o *ContainerOptions) startStep(step string) {
+	if o != nil && o.OnStep != nil {
+		o.OnStep(step)
+	}
+}
+
+// hasTimeLeft returns whether there's at least a duration left before the deadline
+func (o *ContainerOptions) hasTimeLeft(duration time.Duration) bool {
+	return o == nil || o.Deadline.IsZero() || time.Until(o.Deadline) >= duration
+}
+
+// checkDeadline returns an error if the deadline passed
+func (o *ContainerOptions) checkDeadline() error {
+	if !o.hasTimeLeft(0) {
+		return fmt.Errorf("Deadline %s exceeded, giving up for a fresh attempt", o.Deadline.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (o *ContainerOptions) reportImageResolved(image string, digest string) {
+	if o != nil && o.OnImageResolved != nil {
+		o.OnImageResolved(image, digest)
+	}
+}
+
+func (o *ContainerOptions) reportProgress(progress string) {
+	if o != nil && o.OnProgress != nil {
+		o.OnProgress(progress)
+	}
+}
+
+// OwnerLabel marks the containers created by the driver, listed by ListOwned
+const (
+	OwnerLabel      = "io.iguazio.flex-fuse/owner"
+	OwnerLabelValue = "flex-fuse"
+)
+
+// containers created by versions predating OwnerLabel are recognized by their driver version label
+const legacyOwnerLabel = "io.iguazio.flex-fuse/version"
+
+// ImageDigestLabel is the label of the FUSE containers holding the digest of their image
+const ImageDigestLabel = "io.iguazio.flex-fuse/image-digest"
+
+// TaskExitWatcher is implemented by CRIs that can report exits of container processes
+type TaskExitWatcher interface {
+
+	// WatchTaskExits invokes the handler whenever the main task of a container exits, until the context is done
+	WatchTaskExits(context.Context, func(string, uint32)) error
+}
+
+// ContainerStatsGetter is implemented by CRIs whose runtime reports the resource usage of containers
+type ContainerStatsGetter interface {
+
+	// GetContainerStats returns the resource usage of a container's processes
+	GetContainerStats(string) (*cgroup.Stats, error)
+}
+
+// ContainerLogDirGetter is implemented by CRIs that write container logs in a log directory per container start
+type ContainerLogDirGetter interface {
+
+	// GetContainerLogDir returns the directory the current log of a container is written in
+	GetContainerLogDir(string) (string, error)
+}
+
+// ContainerStatus is the state of a container, normalized across backends
+type ContainerStatus struct {
+	Exists bool
+
+	// State is the state of the container's process - "running", "paused", "stopped" or "created"
+	State string
+	Pid   uint32
+
+	// ExitCode is the exit code of a stopped process
+	ExitCode uint32
+
+	// StartedAt is when the process started, zero if it isn't known or it didn't start
+	StartedAt time.Time
+}
+
+// CRI is implemented by container runtime backends, registered with RegisterBackend
 type CRI interface {
 
 	// CreateContainer creates a container
-	CreateContainer(string, string, string, []string) error
+	CreateContainer(string, string, string, []string, *ContainerOptions) error
+
+	// PullImage pulls an image, with credentials if given
+	PullImage(string, *RegistryCredentials) error
+
+	// GetContainerStatus returns the status of a container, which may not exist
+	GetContainerStatus(string) (*ContainerStatus, error)
 
 	// RemoveContainer removes a container
 	RemoveContainer(string) error
 
+	// RestartContainer starts a container whose process exited
+	RestartContainer(string) error
+
+	// PauseContainer freezes all processes of a container
+	PauseContainer(string) error
+
+	// ResumeContainer thaws the processes of a paused container
+	ResumeContainer(string) error
+
+	// ListContainers returns the names of containers starting with a prefix
+	ListContainers(string) ([]string, error)
+
+	// ListOwned returns the names of the containers created by the driver, so that unrelated containers aren't
+	// touched even if their names collide
+	ListOwned() ([]string, error)
+
+	// GetContainerPid returns the pid of a container's running process
+	GetContainerPid(string) (uint32, error)
+
+	// GetContainerLogTail returns up to a number of last lines of a container's log
+	GetContainerLogTail(string, int) (string, error)
+
+	// GetContainerSpec returns the spec the runtime created a container with, as JSON
+	GetContainerSpec(string) ([]byte, error)
+
 	// Close closes a CRI
 	Close() error
 }
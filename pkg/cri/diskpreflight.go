@@ -0,0 +1,346 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	"github.com/containerd/containerd/reference/docker"
+	"github.com/containerd/containerd/remotes"
+	remotesdocker "github.com/containerd/containerd/remotes/docker"
+	dockerconfig "github.com/containerd/containerd/remotes/docker/config"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sys/unix"
+)
+
+// layers' uncompressed size isn't in image manifests - it's estimated as a multiple of their compressed size, which
+// gzip compressed layers rarely exceed
+const uncompressedSizeRatio = 3
+
+// manifests and image configs larger than this are rejected rather than read into memory
+const maxManifestSize = 4 << 20
+
+var (
+	diskPreflightLock          sync.Mutex
+	diskPreflightHeadroomBytes int64 = 256 << 20
+)
+
+// SetDiskPreflight sets the free space, beyond an image's estimated size, containerd's data volume must have before
+// the image is pulled or unpacked. A negative headroom disables the check
+func SetDiskPreflight(headroomBytes int64) {
+	diskPreflightLock.Lock()
+	defer diskPreflightLock.Unlock()
+
+	diskPreflightHeadroomBytes = headroomBytes
+}
+
+func getDiskPreflightHeadroom() int64 {
+	diskPreflightLock.Lock()
+	defer diskPreflightLock.Unlock()
+
+	return diskPreflightHeadroomBytes
+}
+
+// diskRequirement is the space an operation needs in a directory of containerd's
+type diskRequirement struct {
+	dir   string
+	bytes int64
+}
+
+// checkPullDiskSpace verifies containerd's data volume can hold an image before it's pulled - its layers not yet in
+// the content store, and their unpacked snapshots. The image's manifest is read from the registry. Failing to read it
+// skips the check, leaving the pull to report why the registry is unreachable
+func (c *Containerd) checkPullDiskSpace(image string, credentials *RegistryCredentials) error {
+	if getDiskPreflightHeadroom() < 0 {
+		return nil
+	}
+
+	layers, err := getRemoteImageLayers(c.kubernetesContext, image, credentials)
+	if err != nil {
+		journal.Warn("Failed to read the image's manifest, skipping the disk space check",
+			"image", image,
+			"err", err.Error())
+		return nil
+	}
+
+	var fetchBytes, unpackBytes int64
+	for _, layer := range layers {
+		unpackBytes += layer.Size * uncompressedSizeRatio
+
+		if _, err := c.imageClient.ContentStore().Info(c.kubernetesContext, layer.Digest); err != nil {
+			fetchBytes += layer.Size
+		}
+	}
+
+	requirements, err := c.getDiskRequirements(c.imageClient, c.kubernetesContext, fetchBytes, unpackBytes)
+	if err != nil {
+		journal.Warn("Failed to locate containerd's data, skipping the disk space check", "err", err.Error())
+		return nil
+	}
+
+	return checkDiskSpace("pull "+image, requirements)
+}
+
+// checkUnpackDiskSpace verifies the FUSE containers' snapshotter can hold an image's layers that aren't unpacked yet
+func (c *Containerd) checkUnpackDiskSpace(ctx context.Context, image containerd.Image, unpackedLayers int) error {
+	if getDiskPreflightHeadroom() < 0 {
+		return nil
+	}
+
+	manifest, err := images.Manifest(ctx, image.ContentStore(), image.Target(), platforms.Default())
+	if err != nil {
+		journal.Warn("Failed to read the image's manifest, skipping the disk space check",
+			"image", image.Name(),
+			"err", err.Error())
+		return nil
+	}
+
+	var unpackBytes int64
+	for layerIdx, layer := range manifest.Layers {
+		if layerIdx >= unpackedLayers {
+			unpackBytes += layer.Size * uncompressedSizeRatio
+		}
+	}
+
+	requirements, err := c.getDiskRequirements(c.containerdClient, ctx, 0, unpackBytes)
+	if err != nil {
+		journal.Warn("Failed to locate containerd's data, skipping the disk space check", "err", err.Error())
+		return nil
+	}
+
+	return checkDiskSpace("unpack "+image.Name(), requirements)
+}
+
+// getDiskRequirements returns the space needed in the content store's directory and the snapshotter's
+func (c *Containerd) getDiskRequirements(client *containerd.Client,
+	ctx context.Context,
+	contentBytes int64,
+	snapshotBytes int64) ([]diskRequirement, error) {
+	var requirements []diskRequirement
+
+	if contentBytes > 0 {
+		contentRoot, err := getPluginRoot(ctx, client, "io.containerd.content.v1", "content")
+		if err != nil {
+			return nil, err
+		}
+
+		requirements = append(requirements, diskRequirement{dir: contentRoot, bytes: contentBytes})
+	}
+
+	snapshotter, err := c.getSnapshotter()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotterRoot, err := getPluginRoot(ctx, client, "io.containerd.snapshotter.v1", snapshotter)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(requirements, diskRequirement{dir: snapshotterRoot, bytes: snapshotBytes}), nil
+}
+
+// getPluginRoot returns the directory a containerd plugin keeps its data in, e.g. the content store's blobs
+func getPluginRoot(ctx context.Context, client *containerd.Client, pluginType string, pluginID string) (string, error) {
+	plugins, err := client.IntrospectionService().Plugins(ctx,
+		[]string{fmt.Sprintf(`type==%q,id==%q`, pluginType, pluginID)})
+	if err != nil {
+		return "", err
+	}
+
+	for _, plugin := range plugins.Plugins {
+		if root := plugin.Exports["root"]; root != "" {
+			return root, nil
+		}
+	}
+
+	return "", fmt.Errorf("Plugin %s.%s has no root directory", pluginType, pluginID)
+}
+
+// checkDiskSpace verifies the filesystems of directories have the space required in them, plus the headroom.
+// Requirements of directories on the same filesystem add up
+func checkDiskSpace(operation string, requirements []diskRequirement) error {
+	headroomBytes := getDiskPreflightHeadroom()
+
+	type filesystem struct {
+		dirs          []string
+		freeBytes     int64
+		requiredBytes int64
+	}
+
+	var filesystems []*filesystem
+	filesystemsByDevice := map[uint64]*filesystem{}
+
+	for _, requirement := range requirements {
+		var stat unix.Stat_t
+		var statfs unix.Statfs_t
+		if err := unix.Stat(requirement.dir, &stat); err != nil {
+			journal.Warn("Failed to stat, skipping its disk space check", "dir", requirement.dir, "err", err.Error())
+			continue
+		}
+
+		if err := unix.Statfs(requirement.dir, &statfs); err != nil {
+			journal.Warn("Failed to get free space, skipping its disk space check",
+				"dir", requirement.dir,
+				"err", err.Error())
+			continue
+		}
+
+		requirementFilesystem, found := filesystemsByDevice[uint64(stat.Dev)]
+		if !found {
+			requirementFilesystem = &filesystem{
+				freeBytes:     int64(statfs.Bavail) * int64(statfs.Bsize),
+				requiredBytes: headroomBytes,
+			}
+
+			filesystemsByDevice[uint64(stat.Dev)] = requirementFilesystem
+			filesystems = append(filesystems, requirementFilesystem)
+		}
+
+		requirementFilesystem.dirs = append(requirementFilesystem.dirs, requirement.dir)
+		requirementFilesystem.requiredBytes += requirement.bytes
+	}
+
+	for _, requirementFilesystem := range filesystems {
+		journal.Debug("Checking disk space",
+			"operation", operation,
+			"dirs", requirementFilesystem.dirs,
+			"freeBytes", requirementFilesystem.freeBytes,
+			"requiredBytes", requirementFilesystem.requiredBytes)
+
+		if requirementFilesystem.freeBytes < requirementFilesystem.requiredBytes {
+			return fmt.Errorf("Insufficient disk to %s: %s has %d MB free, but needs about %d MB "+
+				"(unpacked layers estimated as %dx their compressed size, and %d MB headroom) - "+
+				"free space on containerd's data volume",
+				operation,
+				strings.Join(requirementFilesystem.dirs, " and "),
+				requirementFilesystem.freeBytes>>20,
+				requirementFilesystem.requiredBytes>>20,
+				uncompressedSizeRatio,
+				headroomBytes>>20)
+		}
+	}
+
+	return nil
+}
+
+// newRegistryResolver returns a resolver for an image's registry, with the node's registry host configurations, the
+// configured registry TLS files and credentials if given
+func newRegistryResolver(ctx context.Context, image string, credentials *RegistryCredentials) (remotes.Resolver,
+	error) {
+	hostOptions := dockerconfig.HostOptions{
+		HostDir: dockerconfig.HostDirFromRoot(GetNodeLayout().HostsDir),
+	}
+
+	if credentials != nil {
+		hostOptions.Credentials = func(string) (string, string, error) {
+			return credentials.Username, credentials.Password, nil
+		}
+	}
+
+	if imageRegistryTLS := getRegistryTLS(image); imageRegistryTLS != nil {
+		var err error
+		if hostOptions.DefaultTLS, err = imageRegistryTLS.getTLSConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	return remotesdocker.NewResolver(remotesdocker.ResolverOptions{
+		Hosts: dockerconfig.ConfigureHosts(ctx, hostOptions),
+	}), nil
+}
+
+// getRemoteImageLayers reads the layers of an image's manifest for the node's platform from its registry, with the
+// node's registry host configurations and the configured registry TLS files
+func getRemoteImageLayers(ctx context.Context, image string, credentials *RegistryCredentials) ([]ocispec.Descriptor,
+	error) {
+	imageReference, err := docker.ParseDockerRef(image)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := newRegistryResolver(ctx, image, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	name, descriptor, err := resolver.Resolve(ctx, imageReference.String())
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher, err := resolver.Fetcher(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := images.Manifest(ctx, &fetcherProvider{fetcher: fetcher}, descriptor, platforms.Default())
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest.Layers, nil
+}
+
+// fetcherProvider reads an image's manifests and config from its registry, as a content provider. They're small,
+// so each is read into memory
+type fetcherProvider struct {
+	fetcher remotes.Fetcher
+}
+
+func (p *fetcherProvider) ReaderAt(ctx context.Context, descriptor ocispec.Descriptor) (content.ReaderAt, error) {
+	if descriptor.Size > maxManifestSize {
+		return nil, fmt.Errorf("%s is too large (%d bytes)", descriptor.Digest, descriptor.Size)
+	}
+
+	reader, err := p.fetcher.Fetch(ctx, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close() // nolint: errcheck
+
+	data, err := ioutil.ReadAll(io.LimitReader(reader, maxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &bytesReaderAt{Reader: bytes.NewReader(data)}, nil
+}
+
+type bytesReaderAt struct {
+	*bytes.Reader
+}
+
+func (r *bytesReaderAt) Close() error {
+	return nil
+}
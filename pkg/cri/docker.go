@@ -20,9 +20,19 @@ such restriction.
 package cri
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
-	"github.com/v3io/flex-fuse/pkg/journal"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/common"
+	"github.com/v3io/flex-fuse/pkg/journal"
 )
 
 type Docker struct {
@@ -39,7 +49,16 @@ func NewDocker(dockerBinaryPath string) (*Docker, error) {
 func (d *Docker) CreateContainer(image string,
 	containerName string,
 	targetPath string,
-	args []string) error {
+	args []string,
+	options *ContainerOptions) error {
+
+	if options != nil && (len(options.UIDMappings) > 0 || len(options.GIDMappings) > 0) {
+		return fmt.Errorf("User namespace mappings are not supported with docker")
+	}
+
+	if err := checkBindSources(targetPath, options, false); err != nil {
+		return err
+	}
 
 	// Create the new container
 	dockerCommandArgs := []string{
@@ -54,14 +73,99 @@ func (d *Docker) CreateContainer(image string,
 		"--device",
 		"/dev/fuse",
 		"--net=host",
+		"--label",
+		fmt.Sprintf("%s=%s", OwnerLabel, OwnerLabelValue),
 		"--mount",
 		fmt.Sprintf("type=bind,src=%s,target=/fuse_mount,bind-propagation=shared", targetPath),
-		image,
 	}
 
+	if options != nil {
+		for labelKey, labelValue := range options.Labels {
+			dockerCommandArgs = append(dockerCommandArgs, "--label", fmt.Sprintf("%s=%s", labelKey, labelValue))
+		}
+
+		for _, envVar := range options.Env {
+			dockerCommandArgs = append(dockerCommandArgs, "--env", envVar)
+		}
+
+		for annotationKey, annotationValue := range options.Annotations {
+			dockerCommandArgs = append(dockerCommandArgs,
+				"--annotation", fmt.Sprintf("%s=%s", annotationKey, annotationValue))
+		}
+
+		if options.StopSignal != "" {
+			dockerCommandArgs = append(dockerCommandArgs, "--stop-signal", options.StopSignal)
+		}
+
+		if options.MemlockLimit != 0 {
+			dockerCommandArgs = append(dockerCommandArgs,
+				"--ulimit", fmt.Sprintf("memlock=%d:%d", options.MemlockLimit, options.MemlockLimit))
+		}
+
+		if options.OOMScoreAdj != nil {
+			dockerCommandArgs = append(dockerCommandArgs, "--oom-score-adj", strconv.Itoa(*options.OOMScoreAdj))
+		}
+
+		if options.CPUSetCPUs != "" {
+			dockerCommandArgs = append(dockerCommandArgs, "--cpuset-cpus", options.CPUSetCPUs)
+		}
+
+		if options.CPUSetMems != "" {
+			dockerCommandArgs = append(dockerCommandArgs, "--cpuset-mems", options.CPUSetMems)
+		}
+
+		if options.IOLimits.IsSet() {
+			if err := checkIODevices(options.IOLimits); err != nil {
+				return err
+			}
+
+			dockerCommandArgs = append(dockerCommandArgs, getIOLimitArgs(options.IOLimits)...)
+		}
+
+		switch options.getLogDriver() {
+		case LogDriverNone:
+			dockerCommandArgs = append(dockerCommandArgs, "--log-driver", "none")
+		case LogDriverFluentd:
+
+			// asynchronously, so that the container starts while fluentd is unreachable
+			dockerCommandArgs = append(dockerCommandArgs,
+				"--log-driver", "fluentd",
+				"--log-opt", "fluentd-address="+options.LogAddress,
+				"--log-opt", "fluentd-async=true",
+				"--log-opt", "tag=flex-fuse.{{.ID}}")
+		}
+
+		if options.HugepagesPath != "" {
+			dockerCommandArgs = append(dockerCommandArgs,
+				"--mount", fmt.Sprintf("type=bind,src=%s,target=%s", options.HugepagesPath, hugepagesMountPath))
+		}
+	}
+
+	// with a pull command the image is pulled if missing, otherwise docker pulls it unless credentials are needed
+	if options != nil && (options.PullCredentials != nil || (getPullCommand() != "" && !d.imageExists(image))) {
+		options.reportProgress(ProgressPulling)
+		options.startStep(StepImagePull)
+
+		if err := d.PullImage(image, options.PullCredentials); err != nil {
+			return fmt.Errorf("Failed to pull %s: %s", image, err)
+		}
+	}
+
+	// the digest is only known before running if the image exists - otherwise docker pulls it, and it's reported
+	// once the container runs
+	imageDigest := d.getImageDigest(image)
+	if imageDigest != "" {
+		dockerCommandArgs = append(dockerCommandArgs, "--label", fmt.Sprintf("%s=%s", ImageDigestLabel, imageDigest))
+	}
+
+	dockerCommandArgs = append(dockerCommandArgs, image)
+
 	// add the args, but skip the executable name, as the docker image already points to it
 	dockerCommandArgs = append(dockerCommandArgs, args[1:]...)
 
+	options.reportProgress(ProgressStarting)
+	options.startStep(StepStart)
+
 	// execute the command
 	dockerCommand := exec.Command(d.dockerBinaryPath, dockerCommandArgs...)
 
@@ -73,9 +177,158 @@ func (d *Docker) CreateContainer(image string,
 			string(dockerCommandOutput))
 	}
 
+	if imageDigest == "" {
+		imageDigest = d.getImageDigest(image)
+	}
+
+	options.reportImageResolved(image, imageDigest)
+
+	if options != nil && options.StartupWait > 0 {
+		return d.waitStartup(containerName, options.StartupWait)
+	}
+
+	return nil
+}
+
+// waitStartup fails if the container's process exits within the wait, removing the container
+func (d *Docker) waitStartup(containerName string, startupWait time.Duration) error {
+	time.Sleep(startupWait)
+
+	dockerCommandOutput, err := exec.Command(d.dockerBinaryPath,
+		"inspect",
+		"--type", "container",
+		"--format", "{{.State.Running}} {{.State.ExitCode}}",
+		containerName).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to inspect container %s: [%s] %s", containerName, err, string(dockerCommandOutput))
+	}
+
+	var running bool
+	var exitCode uint32
+	if _, err := fmt.Sscanf(string(dockerCommandOutput), "%t %d", &running, &exitCode); err != nil {
+		return fmt.Errorf("Failed to parse container state %q: %s", string(dockerCommandOutput), err)
+	}
+
+	if running {
+		return nil
+	}
+
+	logTail, _ := d.GetContainerLogTail(containerName, logTailLines)
+
+	if err := d.RemoveContainer(containerName); err != nil {
+		journal.Warn("Failed to remove exited container", "containerName", containerName, "err", err.Error())
+	}
+
+	return newExitedError(exitCode, logTail)
+}
+
+// GetContainerLogTail returns up to a number of last lines of a container's log
+func (d *Docker) GetContainerLogTail(containerName string, lines int) (string, error) {
+	dockerCommandOutput, err := exec.Command(d.dockerBinaryPath,
+		"logs",
+		"--tail", strconv.Itoa(lines),
+		containerName).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("[%s] %s", err.Error(), string(dockerCommandOutput))
+	}
+
+	return strings.TrimRight(string(dockerCommandOutput), "\n"), nil
+}
+
+// pullImage pulls an image with a temporary docker config holding the credentials
+// PullImage pulls an image, with credentials if given
+func (d *Docker) PullImage(image string, credentials *RegistryCredentials) error {
+
+	// docker keeps the layers fetched by a failed attempt, so a retry only fetches what's missing
+	return common.RetryFunc(context.Background(), pullAttempts, pullRetryInterval, func(attempt int) (bool, error) {
+		if err := d.pullImage(image, credentials); err != nil {
+			journal.Error("Failed pulling", "image", image, "attempt", attempt, "err", err.Error())
+			return true, err
+		}
+
+		return false, nil
+	})
+}
+
+func (d *Docker) pullImage(image string, credentials *RegistryCredentials) error {
+	if pullCommand := getPullCommand(); pullCommand != "" {
+		return runPullCommand(pullCommand, &PullCommandFields{
+			Image:    image,
+			Registry: GetImageRegistry(image),
+		}, credentials, func() bool {
+			return d.imageExists(image)
+		})
+	}
+
+	if getRegistryTLS(image) != nil {
+		journal.Debug("Registry TLS files are not passed to docker, which reads them from /etc/docker/certs.d",
+			"image", image)
+	}
+
+	if credentials == nil {
+		return d.runContainerCommand("pull", image)
+	}
+
+	configDir, err := CreateTempDir("flex-fuse-docker-")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(configDir) // nolint: errcheck
+
+	auth := base64.StdEncoding.EncodeToString([]byte(credentials.Username + ":" + credentials.Password))
+	registry := GetImageRegistry(image)
+	if registry == DockerHubRegistry {
+		registry = "https://index.docker.io/v1/"
+	}
+
+	dockerConfig := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`, registry, auth)
+
+	if err := ioutil.WriteFile(path.Join(configDir, "config.json"), []byte(dockerConfig), 0600); err != nil {
+		return err
+	}
+
+	dockerCommand := exec.Command(d.dockerBinaryPath, "--config", configDir, "pull", image)
+
+	journal.Debug("Executing docker pull command", "path", dockerCommand.Path, "image", image)
+	if dockerCommandOutput, err := dockerCommand.CombinedOutput(); err != nil {
+		return fmt.Errorf("[%s] %s", err.Error(), string(dockerCommandOutput))
+	}
+
 	return nil
 }
 
+// imageExists returns whether an image was pulled
+// getImageDigest returns the manifest digest of a local image, or its ID if it has none (e.g. images built on the
+// node). Empty if the image doesn't exist
+func (d *Docker) getImageDigest(image string) string {
+	output, err := exec.Command(d.dockerBinaryPath,
+		"image", "inspect",
+		"--format", `{{.Id}} {{join .RepoDigests " "}}`,
+		image).Output()
+	if err != nil {
+		return ""
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	// repo digests are <repository>@<digest>
+	for _, repoDigest := range fields[1:] {
+		if digestIdx := strings.LastIndex(repoDigest, "@"); digestIdx != -1 {
+			return repoDigest[digestIdx+1:]
+		}
+	}
+
+	return fields[0]
+}
+
+func (d *Docker) imageExists(image string) bool {
+	return exec.Command(d.dockerBinaryPath, "image", "inspect", image).Run() == nil
+}
+
 // RemoveContainer removes a container
 func (d *Docker) RemoveContainer(containerName string) error {
 	args := []string{
@@ -94,6 +347,170 @@ func (d *Docker) RemoveContainer(containerName string) error {
 	return nil
 }
 
+// RestartContainer starts a container whose process exited
+func (d *Docker) RestartContainer(containerName string) error {
+	dockerCommand := exec.Command(d.dockerBinaryPath, "start", containerName)
+
+	journal.Debug("Executing docker start command", "path", dockerCommand.Path, "args", dockerCommand.Args)
+	if dockerCommandOutput, err := dockerCommand.CombinedOutput(); err != nil {
+		return fmt.Errorf("[%s] %s", err.Error(), string(dockerCommandOutput))
+	}
+
+	return nil
+}
+
+// PauseContainer freezes all processes of a container
+func (d *Docker) PauseContainer(containerName string) error {
+	return d.runContainerCommand("pause", containerName)
+}
+
+// ResumeContainer thaws the processes of a paused container
+func (d *Docker) ResumeContainer(containerName string) error {
+	return d.runContainerCommand("unpause", containerName)
+}
+
+func (d *Docker) runContainerCommand(command string, containerName string) error {
+	dockerCommand := exec.Command(d.dockerBinaryPath, command, containerName)
+
+	journal.Debug("Executing docker command", "path", dockerCommand.Path, "args", dockerCommand.Args)
+	if dockerCommandOutput, err := dockerCommand.CombinedOutput(); err != nil {
+		return fmt.Errorf("[%s] %s", err.Error(), string(dockerCommandOutput))
+	}
+
+	return nil
+}
+
+// ListContainers returns the names of containers starting with a prefix
+func (d *Docker) ListContainers(namePrefix string) ([]string, error) {
+	dockerCommand := exec.Command(d.dockerBinaryPath,
+		"ps",
+		"--all",
+		"--filter", fmt.Sprintf("name=^%s", namePrefix),
+		"--format", "{{.Names}}")
+
+	dockerCommandOutput, err := dockerCommand.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(dockerCommandOutput)), nil
+}
+
+// ListOwned returns the names of the containers created by the driver
+func (d *Docker) ListOwned() ([]string, error) {
+	containerNames := map[string]bool{}
+
+	// filters of different labels are AND'ed, so each is listed separately
+	for _, labelFilter := range []string{
+		fmt.Sprintf("label=%s=%s", OwnerLabel, OwnerLabelValue),
+		fmt.Sprintf("label=%s", legacyOwnerLabel),
+	} {
+		dockerCommandOutput, err := exec.Command(d.dockerBinaryPath,
+			"ps",
+			"--all",
+			"--filter", labelFilter,
+			"--format", "{{.Names}}").Output()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, containerName := range strings.Fields(string(dockerCommandOutput)) {
+			containerNames[containerName] = true
+		}
+	}
+
+	var ownedContainerNames []string
+	for containerName := range containerNames {
+		ownedContainerNames = append(ownedContainerNames, containerName)
+	}
+
+	return ownedContainerNames, nil
+}
+
+// GetContainerPid returns the pid of a container's running process
+func (d *Docker) GetContainerPid(containerName string) (uint32, error) {
+	dockerCommand := exec.Command(d.dockerBinaryPath, "inspect", "--format", "{{.State.Pid}}", containerName)
+
+	dockerCommandOutput, err := dockerCommand.Output()
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.ParseUint(strings.TrimSpace(string(dockerCommandOutput)), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	if pid == 0 {
+		return 0, fmt.Errorf("Container %s is not running", containerName)
+	}
+
+	return uint32(pid), nil
+}
+
+// GetContainerSpec returns the configuration of a container as inspected, as docker doesn't expose the OCI spec
+// it renders
+func (d *Docker) GetContainerSpec(containerName string) ([]byte, error) {
+	dockerCommand := exec.Command(d.dockerBinaryPath, "inspect", "--type", "container", containerName)
+
+	dockerCommandOutput, err := dockerCommand.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to inspect container %s: %s", containerName, err)
+	}
+
+	return dockerCommandOutput, nil
+}
+
+// GetContainerStatus returns the status of a container, which may not exist
+func (d *Docker) GetContainerStatus(containerName string) (*ContainerStatus, error) {
+	dockerCommand := exec.Command(d.dockerBinaryPath,
+		"inspect",
+		"--type", "container",
+		"--format", "{{.State.Status}} {{.State.Pid}} {{.State.ExitCode}} {{.State.StartedAt}}",
+		containerName)
+
+	dockerCommandOutput, err := dockerCommand.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(dockerCommandOutput), "No such") {
+			return &ContainerStatus{}, nil
+		}
+
+		return nil, fmt.Errorf("[%s] %s", err.Error(), string(dockerCommandOutput))
+	}
+
+	var dockerState, startedAt string
+	var pid, exitCode uint32
+	if _, err := fmt.Sscanf(string(dockerCommandOutput),
+		"%s %d %d %s",
+		&dockerState,
+		&pid,
+		&exitCode,
+		&startedAt); err != nil {
+		return nil, fmt.Errorf("Failed to parse container state %q: %s", string(dockerCommandOutput), err)
+	}
+
+	containerStatus := ContainerStatus{
+		Exists:   true,
+		State:    "stopped",
+		Pid:      pid,
+		ExitCode: exitCode,
+	}
+
+	// containers that never started have a zero start time
+	if parsedStartedAt, err := time.Parse(time.RFC3339Nano, startedAt); err == nil && parsedStartedAt.Year() > 1 {
+		containerStatus.StartedAt = parsedStartedAt
+	}
+
+	switch dockerState {
+	case "running", "restarting":
+		containerStatus.State = "running"
+	case "paused", "created":
+		containerStatus.State = dockerState
+	}
+
+	return &containerStatus, nil
+}
+
 func (d *Docker) Close() error {
 	return nil
 }
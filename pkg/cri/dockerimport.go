@@ -0,0 +1,201 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/images"
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/probe"
+)
+
+// where kubelet's images are, and so where images are imported from before pulling
+const (
+	KubeletImageStoreAuto       = "auto"
+	KubeletImageStoreContainerd = "containerd"
+	KubeletImageStoreDocker     = "docker"
+)
+
+// the socket of cri-dockerd, through which kubelet runs pods with docker
+const criDockerdSock = "/run/cri-dockerd.sock"
+
+var (
+	kubeletImageStoreLock sync.Mutex
+	kubeletImageStore     = KubeletImageStoreAuto
+)
+
+// SetKubeletImageStore sets where kubelet's images are imported from by the containerd backend - containerd's
+// k8s.io namespace, docker's image store on nodes where kubelet runs pods with docker through cri-dockerd, or
+// detected from kubelet ("auto")
+func SetKubeletImageStore(store string) {
+	kubeletImageStoreLock.Lock()
+	defer kubeletImageStoreLock.Unlock()
+
+	kubeletImageStore = store
+}
+
+func getKubeletImageStore() string {
+	kubeletImageStoreLock.Lock()
+	store := kubeletImageStore
+	kubeletImageStoreLock.Unlock()
+
+	if store != KubeletImageStoreAuto {
+		return store
+	}
+
+	// detecting reads the processes of the node, so it's cached
+	probe.Cached("kubelet-image-store", &store, func() error { // nolint: errcheck
+		store = detectKubeletImageStore()
+		return nil
+	})
+
+	return store
+}
+
+// detectKubeletImageStore returns docker if kubelet runs pods through cri-dockerd (or the dockershim of older
+// versions), and containerd otherwise
+func detectKubeletImageStore() string {
+	if kubeletCommandLine := getKubeletCommandLine(); kubeletCommandLine != "" {
+		if strings.Contains(kubeletCommandLine, "cri-dockerd") ||
+			strings.Contains(kubeletCommandLine, "dockershim") ||
+			strings.Contains(kubeletCommandLine, "--container-runtime=docker") {
+			return KubeletImageStoreDocker
+		}
+
+		if strings.Contains(kubeletCommandLine, "--container-runtime-endpoint") {
+			return KubeletImageStoreContainerd
+		}
+	}
+
+	// the endpoint may be in kubelet's configuration file rather than on its command line
+	if _, err := os.Stat(criDockerdSock); err == nil {
+		return KubeletImageStoreDocker
+	}
+
+	return KubeletImageStoreContainerd
+}
+
+// getKubeletCommandLine returns the command line of the node's kubelet, or an empty string if it isn't found
+func getKubeletCommandLine() string {
+	commandLinePaths, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil {
+		return ""
+	}
+
+	for _, commandLinePath := range commandLinePaths {
+		commandLine, err := ioutil.ReadFile(commandLinePath)
+		if err != nil || len(commandLine) == 0 {
+			continue
+		}
+
+		args := bytes.Split(bytes.TrimRight(commandLine, "\x00"), []byte{0})
+		if filepath.Base(string(args[0])) == "kubelet" {
+			return string(bytes.Join(args, []byte(" ")))
+		}
+	}
+
+	return ""
+}
+
+// tryImportFromDocker imports an image from docker's image store, streaming docker save into the import. A deadline
+// (if not zero) aborts the import, and it's skipped if it's too close for an import to complete
+func (c *Containerd) tryImportFromDocker(imageName string, deadline time.Time) ([]images.Image, error) {
+	if _, err := os.Stat(getDockerBinary()); err != nil {
+		return nil, fmt.Errorf("Docker isn't installed: %s", err)
+	}
+
+	containerdContext := c.containerdContext
+	if !deadline.IsZero() {
+		if time.Until(deadline) < minImportTime {
+			return nil, fmt.Errorf("Less than %s left before the deadline, skipping", minImportTime)
+		}
+
+		var cancel context.CancelFunc
+		containerdContext, cancel = context.WithDeadline(containerdContext, deadline)
+		defer cancel()
+	}
+
+	// inspecting first tells a missing image (or a stopped docker) from a failed import
+	if output, err := exec.CommandContext(containerdContext,
+		getDockerBinary(),
+		"image", "inspect", "--format", "{{.Id}}",
+		imageName).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("Image isn't in docker's image store: %s", strings.TrimSpace(string(output)))
+	}
+
+	saveCommand := exec.CommandContext(containerdContext, getDockerBinary(), "save", imageName)
+
+	stderr := bytes.Buffer{}
+	saveCommand.Stderr = &stderr
+
+	stdout, err := saveCommand.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveCommand.Start(); err != nil {
+		return nil, fmt.Errorf("Failed to run docker save: %s", err)
+	}
+
+	importedImages, err := c.containerdClient.Import(containerdContext, stdout)
+	if err == nil {
+
+		// the import may stop reading before the archive's end
+		_, err = io.Copy(ioutil.Discard, stdout)
+	}
+
+	// a failed import stops docker save writing to the pipe
+	stdout.Close() // nolint: errcheck
+
+	saveErr := saveCommand.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to import: %s", err)
+	}
+
+	if saveErr != nil {
+		return nil, fmt.Errorf("Failed to run docker save: %s (%s)", saveErr, strings.TrimSpace(stderr.String()))
+	}
+
+	for _, importedImage := range importedImages {
+		imageInstance, err := c.containerdClient.GetImage(containerdContext, importedImage.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.unpackImage(containerdContext, imageInstance); err != nil {
+			return nil, err
+		}
+	}
+
+	journal.Debug("Imported image from docker", "image", imageName, "importedImages", len(importedImages))
+
+	return importedImages, nil
+}
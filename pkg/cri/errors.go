@@ -0,0 +1,62 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TaskDeleteError is returned when a container's task can't be deleted even after escalation, and the
+// container requires manual intervention
+type TaskDeleteError struct {
+	ContainerName string            `json:"containerName"`
+	Pid           uint32            `json:"pid"`
+	Diagnostics   map[string]string `json:"diagnostics"`
+	Err           error             `json:"-"`
+}
+
+func (e *TaskDeleteError) Error() string {
+	return fmt.Sprintf("Failed to delete %s's task (pid %d): %s", e.ContainerName, e.Pid, e.Err)
+}
+
+func (e *TaskDeleteError) Unwrap() error {
+	return e.Err
+}
+
+// getProcessDiagnostics collects what the kernel reports about a (possibly stuck) process
+func getProcessDiagnostics(pid uint32, attemptErrors []string) map[string]string {
+	diagnostics := map[string]string{
+		"attemptErrors": strings.Join(attemptErrors, "\n"),
+	}
+
+	for _, procFileName := range []string{"status", "wchan", "stack", "cmdline"} {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/%s", pid, procFileName))
+		if err != nil {
+			diagnostics[procFileName] = err.Error()
+			continue
+		}
+
+		diagnostics[procFileName] = strings.Replace(string(content), "\x00", " ", -1)
+	}
+
+	return diagnostics
+}
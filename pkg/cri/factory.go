@@ -0,0 +1,208 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/v3io/flex-fuse/pkg/probe"
+)
+
+const (
+	defaultContainerdSock = "/run/containerd/containerd.sock"
+	defaultDockerBinary   = "/usr/bin/docker"
+)
+
+// BackendFactory creates a CRI for a runtime endpoint, which may be empty
+type BackendFactory func(runtimeEndpoint string) (CRI, error)
+
+var (
+	backendsLock  sync.Mutex
+	backends      = map[string]BackendFactory{}
+	imageEndpoint string
+)
+
+func init() {
+	RegisterBackend("containerd", newContainerdBackend)
+	RegisterBackend("docker", newDockerBackend)
+	RegisterBackend("simulate", newSimulateBackend)
+}
+
+// RegisterBackend makes a backend available by name, to be selected by the runtime_backend configuration
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	backends[name] = factory
+}
+
+// SetImageEndpoint sets the endpoint images are imported from by subsequently created backends, if it's not
+// the runtime endpoint (e.g. nested containerd instances). Empty uses the runtime endpoint
+func SetImageEndpoint(endpoint string) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	imageEndpoint = endpoint
+}
+
+// New creates the CRI of a backend for a runtime endpoint (e.g. unix:///run/containerd/containerd.sock), as
+// configured for crictl and kubelet. If the backend is empty, it's detected from the runtime endpoint or, if that's
+// empty as well, from the node's container runtime
+func New(backendName string, runtimeEndpoint string) (CRI, error) {
+	if backendName == "" {
+		backendName = detectBackend(runtimeEndpoint)
+	}
+
+	backendsLock.Lock()
+	factory, found := backends[backendName]
+	backendsLock.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("Unknown runtime backend %s, expected one of %s",
+			backendName,
+			strings.Join(getBackendNames(), ", "))
+	}
+
+	return factory(runtimeEndpoint)
+}
+
+func detectBackend(runtimeEndpoint string) string {
+	switch {
+	case strings.HasPrefix(runtimeEndpoint, "fake://"):
+		return "fake"
+
+	case strings.HasPrefix(runtimeEndpoint, "simulate://"):
+		return "simulate"
+
+	// docker is managed through its CLI rather than its CRI shim
+	case strings.Contains(runtimeEndpoint, "dockershim") || strings.Contains(runtimeEndpoint, "cri-dockerd"):
+		return "docker"
+
+	case runtimeEndpoint != "":
+		return "containerd"
+	}
+
+	var backendName string
+
+	// detecting the node's runtime shells out, so it's cached
+	probe.Cached("runtime-backend", &backendName, func() error { // nolint: errcheck
+		backendName = detectNodeBackend()
+		return nil
+	})
+
+	return backendName
+}
+
+func detectNodeBackend() string {
+
+	// if docker binary does not exist, use containerd
+	if _, err := os.Stat(getDockerBinary()); os.IsNotExist(err) {
+		return "containerd"
+	}
+
+	// NOTE: On some managed kubernetes services, docker is installed but not activated
+	// while containerd is the CRI runtime. In this case, we want to use containerd.
+	// if docker binary exists, has systemd unit but is not running, create containerd.
+	if notRunningDocker() {
+		return "containerd"
+	}
+
+	return "docker"
+}
+
+func newContainerdBackend(runtimeEndpoint string) (CRI, error) {
+	containerdSock, err := getSocketPath(runtimeEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	newContainerd, err := NewContainerd(containerdSock, "v3io")
+	if err != nil {
+		return nil, err
+	}
+
+	backendsLock.Lock()
+	currentImageEndpoint := imageEndpoint
+	backendsLock.Unlock()
+
+	if currentImageEndpoint == "" {
+		return newContainerd, nil
+	}
+
+	imageSock, err := getSocketPath(currentImageEndpoint)
+	if err != nil {
+		newContainerd.Close() // nolint: errcheck
+		return nil, err
+	}
+
+	if err := newContainerd.SetImageSource(imageSock); err != nil {
+		newContainerd.Close() // nolint: errcheck
+		return nil, fmt.Errorf("Failed to connect to image endpoint %s: %s", currentImageEndpoint, err)
+	}
+
+	return newContainerd, nil
+}
+
+// getSocketPath returns the containerd socket path of an endpoint, the node's default socket (e.g. k3s's) if it's
+// empty
+func getSocketPath(endpoint string) (string, error) {
+	if endpoint == "" {
+		return GetNodeLayout().ContainerdSocket, nil
+	}
+
+	if !strings.HasPrefix(endpoint, "unix://") {
+		return "", fmt.Errorf("Unsupported runtime endpoint %s, expected unix://<socket path>", endpoint)
+	}
+
+	return strings.TrimPrefix(endpoint, "unix://"), nil
+}
+
+func newDockerBackend(runtimeEndpoint string) (CRI, error) {
+	return NewDocker(getDockerBinary())
+}
+
+func getBackendNames() []string {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	var backendNames []string
+	for backendName := range backends {
+		backendNames = append(backendNames, backendName)
+	}
+
+	sort.Strings(backendNames)
+
+	return backendNames
+}
+
+func notRunningDocker() bool {
+	cmd := exec.Command("sh", "-c", "ps -ef | grep kubelet | grep container-runtime | grep -q docker")
+	out, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	outStr := strings.TrimSpace(string(out))
+	return outStr == ""
+}
@@ -0,0 +1,397 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fake CRIs, by name, returned for fake://<name> runtime endpoints
+var (
+	fakesLock sync.Mutex
+	fakes     = map[string]*Fake{}
+)
+
+// FakeContainer is a container created by a fake CRI
+type FakeContainer struct {
+	Image      string
+	Name       string
+	TargetPath string
+	Args       []string
+	Options    ContainerOptions
+	Pid        uint32
+	Running    bool
+	Paused     bool
+
+	// ExitCode is set by SimulateExit
+	ExitCode  uint32
+	StartedAt time.Time
+
+	// Log is returned by GetContainerLogTail, e.g. as set by OnCreate
+	Log string
+}
+
+// Fake is an in memory CRI, so that the mount orchestration can be exercised without a container runtime.
+// Register it with RegisterFake and set the runtime endpoint to fake://<name>
+type Fake struct {
+
+	// OnCreate, if set, is invoked when a container is created (e.g. to simulate the FUSE mount) and fails the
+	// creation if it returns an error
+	OnCreate func(*FakeContainer) error
+
+	// Errors fail calls of the methods they're keyed by (e.g. "RemoveContainer")
+	Errors map[string]error
+
+	lock         sync.Mutex
+	containers   map[string]*FakeContainer
+	nextPid      uint32
+	exitHandlers []func(string, uint32)
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		Errors:     map[string]error{},
+		containers: map[string]*FakeContainer{},
+		nextPid:    1000,
+	}
+}
+
+// RegisterFake makes a fake CRI returned for the fake://<name> runtime endpoint. The fake backend is only
+// registered by tests, so that a node configured with it fails rather than mounting nothing
+func RegisterFake(name string, fake *Fake) {
+	RegisterBackend("fake", getFake)
+
+	fakesLock.Lock()
+	defer fakesLock.Unlock()
+
+	fakes[name] = fake
+}
+
+// getFake is the factory of the fake backend
+func getFake(runtimeEndpoint string) (CRI, error) {
+	fakesLock.Lock()
+	defer fakesLock.Unlock()
+
+	name := strings.TrimPrefix(runtimeEndpoint, "fake://")
+
+	fake, found := fakes[name]
+	if !found {
+		return nil, fmt.Errorf("No fake CRI registered as %s", name)
+	}
+
+	return fake, nil
+}
+
+// CreateContainer creates a container
+func (f *Fake) CreateContainer(image string,
+	containerName string,
+	targetPath string,
+	args []string,
+	options *ContainerOptions) error {
+	if err := f.getError("CreateContainer"); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+
+	if _, exists := f.containers[containerName]; exists {
+		f.lock.Unlock()
+		return fmt.Errorf("Container %s already exists", containerName)
+	}
+
+	if options == nil {
+		options = &ContainerOptions{}
+	}
+
+	f.nextPid++
+	container := FakeContainer{
+		Image:      image,
+		Name:       containerName,
+		TargetPath: targetPath,
+		Args:       args,
+		Options:    *options,
+		Pid:        f.nextPid,
+		Running:    true,
+		StartedAt:  time.Now(),
+	}
+
+	f.containers[containerName] = &container
+	f.lock.Unlock()
+
+	if f.OnCreate != nil {
+		if err := f.OnCreate(&container); err != nil {
+			f.lock.Lock()
+			delete(f.containers, containerName)
+			f.lock.Unlock()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PullImage pulls an image, with credentials if given
+func (f *Fake) PullImage(image string, credentials *RegistryCredentials) error {
+	return f.getError("PullImage")
+}
+
+// GetContainerStatus returns the status of a container, which may not exist
+func (f *Fake) GetContainerStatus(containerName string) (*ContainerStatus, error) {
+	if err := f.getError("GetContainerStatus"); err != nil {
+		return nil, err
+	}
+
+	container := f.GetContainer(containerName)
+	if container == nil {
+		return &ContainerStatus{}, nil
+	}
+
+	containerStatus := ContainerStatus{
+		Exists:   true,
+		State:    "stopped",
+		ExitCode: container.ExitCode,
+	}
+
+	if container.Running {
+		containerStatus.State = "running"
+		containerStatus.Pid = container.Pid
+		containerStatus.StartedAt = container.StartedAt
+
+		if container.Paused {
+			containerStatus.State = "paused"
+		}
+	}
+
+	return &containerStatus, nil
+}
+
+// RemoveContainer removes a container
+func (f *Fake) RemoveContainer(containerName string) error {
+	if err := f.getError("RemoveContainer"); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, exists := f.containers[containerName]; !exists {
+		return fmt.Errorf("Container %s not found", containerName)
+	}
+
+	delete(f.containers, containerName)
+
+	return nil
+}
+
+// RestartContainer starts a container whose process exited
+func (f *Fake) RestartContainer(containerName string) error {
+	if err := f.getError("RestartContainer"); err != nil {
+		return err
+	}
+
+	return f.updateContainer(containerName, func(container *FakeContainer) {
+		f.nextPid++
+		container.Pid = f.nextPid
+		container.Running = true
+		container.StartedAt = time.Now()
+	})
+}
+
+// PauseContainer freezes all processes of a container
+func (f *Fake) PauseContainer(containerName string) error {
+	if err := f.getError("PauseContainer"); err != nil {
+		return err
+	}
+
+	return f.updateContainer(containerName, func(container *FakeContainer) {
+		container.Paused = true
+	})
+}
+
+// ResumeContainer thaws the processes of a paused container
+func (f *Fake) ResumeContainer(containerName string) error {
+	if err := f.getError("ResumeContainer"); err != nil {
+		return err
+	}
+
+	return f.updateContainer(containerName, func(container *FakeContainer) {
+		container.Paused = false
+	})
+}
+
+// ListContainers returns the names of containers starting with a prefix
+func (f *Fake) ListContainers(namePrefix string) ([]string, error) {
+	if err := f.getError("ListContainers"); err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var containerNames []string
+	for containerName := range f.containers {
+		if strings.HasPrefix(containerName, namePrefix) {
+			containerNames = append(containerNames, containerName)
+		}
+	}
+
+	return containerNames, nil
+}
+
+// ListOwned returns the names of the containers created by the driver - all of the fake's containers
+func (f *Fake) ListOwned() ([]string, error) {
+	if err := f.getError("ListOwned"); err != nil {
+		return nil, err
+	}
+
+	return f.ListContainers("")
+}
+
+// GetContainerPid returns the pid of a container's running process
+func (f *Fake) GetContainerPid(containerName string) (uint32, error) {
+	if err := f.getError("GetContainerPid"); err != nil {
+		return 0, err
+	}
+
+	container := f.GetContainer(containerName)
+	if container == nil || !container.Running {
+		return 0, fmt.Errorf("Container %s has no running process", containerName)
+	}
+
+	return container.Pid, nil
+}
+
+// GetContainerSpec returns the container as created, as JSON
+func (f *Fake) GetContainerSpec(containerName string) ([]byte, error) {
+	if err := f.getError("GetContainerSpec"); err != nil {
+		return nil, err
+	}
+
+	container := f.GetContainer(containerName)
+	if container == nil {
+		return nil, fmt.Errorf("Container %s does not exist", containerName)
+	}
+
+	// the options hold callbacks, which aren't marshaled
+	return json.MarshalIndent(map[string]interface{}{
+		"image":      container.Image,
+		"name":       container.Name,
+		"targetPath": container.TargetPath,
+		"args":       container.Args,
+		"labels":     container.Options.Labels,
+		"env":        container.Options.Env,
+	}, "", "  ")
+}
+
+// GetContainerLogTail returns up to a number of last lines of a container's log
+func (f *Fake) GetContainerLogTail(containerName string, lines int) (string, error) {
+	if err := f.getError("GetContainerLogTail"); err != nil {
+		return "", err
+	}
+
+	container := f.GetContainer(containerName)
+	if container == nil {
+		return "", fmt.Errorf("Container %s does not exist", containerName)
+	}
+
+	logLines := strings.Split(strings.TrimRight(container.Log, "\n"), "\n")
+	if len(logLines) > lines {
+		logLines = logLines[len(logLines)-lines:]
+	}
+
+	return strings.Join(logLines, "\n"), nil
+}
+
+// WatchTaskExits invokes the handler whenever SimulateExit is called, until the context is done
+func (f *Fake) WatchTaskExits(ctx context.Context, handler func(string, uint32)) error {
+	f.lock.Lock()
+	f.exitHandlers = append(f.exitHandlers, handler)
+	f.lock.Unlock()
+
+	<-ctx.Done()
+
+	return nil
+}
+
+// Close closes a CRI
+func (f *Fake) Close() error {
+	return nil
+}
+
+// GetContainer returns a copy of a container, or nil if it doesn't exist
+func (f *Fake) GetContainer(containerName string) *FakeContainer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	container, exists := f.containers[containerName]
+	if !exists {
+		return nil
+	}
+
+	containerCopy := *container
+	return &containerCopy
+}
+
+// SimulateExit marks a container's process as exited and notifies the task exit watchers
+func (f *Fake) SimulateExit(containerName string, exitStatus uint32) error {
+	if err := f.updateContainer(containerName, func(container *FakeContainer) {
+		container.Running = false
+		container.ExitCode = exitStatus
+	}); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	exitHandlers := append([]func(string, uint32){}, f.exitHandlers...)
+	f.lock.Unlock()
+
+	for _, exitHandler := range exitHandlers {
+		exitHandler(containerName, exitStatus)
+	}
+
+	return nil
+}
+
+func (f *Fake) updateContainer(containerName string, update func(*FakeContainer)) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	container, exists := f.containers[containerName]
+	if !exists {
+		return fmt.Errorf("Container %s not found", containerName)
+	}
+
+	update(container)
+
+	return nil
+}
+
+func (f *Fake) getError(method string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.Errors[method]
+}
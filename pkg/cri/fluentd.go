@@ -0,0 +1,177 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how long connecting to (or writing to) fluentd may take before lines are dropped
+const fluentdTimeout = 5 * time.Second
+
+// longest line forwarded, longer lines are split
+const fluentdMaxLineLength = 64 * 1024
+
+// ForwardLogs forwards the lines of a container's outputs to a fluentd (or fluent-bit) forward input at an address
+// (unix:///path, tcp://host:port or host:port) until they're closed, tagged flex-fuse.<container ID>. Lines are
+// dropped while fluentd is unreachable, so that the FUSE client never blocks on its output
+func ForwardLogs(ctx context.Context, address string, containerID string, outputs map[string]io.Reader) error {
+	network, dialAddress := parseFluentdAddress(address)
+
+	forwarder := fluentdForwarder{
+		network: network,
+		address: dialAddress,
+		tag:     "flex-fuse." + containerID,
+	}
+
+	defer forwarder.close()
+
+	var waitGroup sync.WaitGroup
+	for source, output := range outputs {
+		waitGroup.Add(1)
+
+		go func(source string, output io.Reader) {
+			defer waitGroup.Done()
+
+			scanner := bufio.NewScanner(output)
+			scanner.Buffer(make([]byte, 4096), fluentdMaxLineLength)
+
+			for scanner.Scan() {
+				forwarder.forward(map[string]string{
+					"log":          scanner.Text(),
+					"source":       source,
+					"container_id": containerID,
+				})
+			}
+		}(source, output)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		waitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	return nil
+}
+
+type fluentdForwarder struct {
+	network string
+	address string
+	tag     string
+
+	lock       sync.Mutex
+	connection net.Conn
+}
+
+// forward sends a record as a message of fluentd's forward protocol, reconnecting once if sending fails
+func (f *fluentdForwarder) forward(record map[string]string) {
+	message := encodeFluentdMessage(f.tag, time.Now(), record)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if f.connection == nil {
+			connection, err := net.DialTimeout(f.network, f.address, fluentdTimeout)
+			if err != nil {
+				return
+			}
+
+			f.connection = connection
+		}
+
+		f.connection.SetWriteDeadline(time.Now().Add(fluentdTimeout)) // nolint: errcheck
+
+		if _, err := f.connection.Write(message); err == nil {
+			return
+		}
+
+		f.connection.Close() // nolint: errcheck
+		f.connection = nil
+	}
+}
+
+func (f *fluentdForwarder) close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.connection != nil {
+		f.connection.Close() // nolint: errcheck
+		f.connection = nil
+	}
+}
+
+func parseFluentdAddress(address string) (string, string) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		return "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		return "tcp", strings.TrimPrefix(address, "tcp://")
+	default:
+		return "tcp", address
+	}
+}
+
+// encodeFluentdMessage encodes a [tag, time, record] message of the forward protocol in msgpack
+func encodeFluentdMessage(tag string, timestamp time.Time, record map[string]string) []byte {
+	message := []byte{0x93}
+	message = appendMsgpackString(message, tag)
+
+	message = append(message, 0xce)
+	message = binary.BigEndian.AppendUint32(message, uint32(timestamp.Unix()))
+
+	message = append(message, 0x80|byte(len(record)))
+	for key, value := range record {
+		message = appendMsgpackString(message, key)
+		message = appendMsgpackString(message, value)
+	}
+
+	return message
+}
+
+func appendMsgpackString(buffer []byte, value string) []byte {
+	switch length := len(value); {
+	case length < 32:
+		buffer = append(buffer, 0xa0|byte(length))
+	case length < 1<<8:
+		buffer = append(buffer, 0xd9, byte(length))
+	case length < 1<<16:
+		buffer = append(buffer, 0xda)
+		buffer = binary.BigEndian.AppendUint16(buffer, uint16(length))
+	default:
+		buffer = append(buffer, 0xdb)
+		buffer = binary.BigEndian.AppendUint32(buffer, uint32(length))
+	}
+
+	return append(buffer, value...)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sync"
+)
+
+// HostPaths are the host locations the runtime backends use, configurable for nodes whose layout differs (e.g.
+// immutable OSes such as Bottlerocket and Flatcar, where /usr is read only and holds no runtime CLIs)
+type HostPaths struct {
+
+	// DockerBinary is the docker CLI
+	DockerBinary string
+
+	// CtrBinary is the ctr CLI images are pulled with. If empty, it's looked up in PATH
+	CtrBinary string
+
+	// ContainerLogsDir is where the log directories of the FUSE containers are written
+	ContainerLogsDir string
+
+	// DriverBinary is the installed driver the shim forwards logs with. If empty, it's the driver in kubelet's
+	// volume plugin directory
+	DriverBinary string
+
+	// SearchSystemBinaries looks CLIs missing from PATH up in /usr/local/bin and /usr/bin
+	SearchSystemBinaries bool
+}
+
+var (
+	hostPathsLock sync.Mutex
+	hostPaths     = HostPaths{
+		DockerBinary:         defaultDockerBinary,
+		ContainerLogsDir:     defaultContainerLogsDir,
+		SearchSystemBinaries: true,
+	}
+)
+
+// SetHostPaths sets the host locations used by subsequently created backends. Empty paths keep the defaults
+func SetHostPaths(paths HostPaths) {
+	hostPathsLock.Lock()
+	defer hostPathsLock.Unlock()
+
+	if paths.DockerBinary == "" {
+		paths.DockerBinary = defaultDockerBinary
+	}
+
+	if paths.ContainerLogsDir == "" {
+		paths.ContainerLogsDir = defaultContainerLogsDir
+	}
+
+	hostPaths = paths
+}
+
+func getHostPaths() HostPaths {
+	hostPathsLock.Lock()
+	defer hostPathsLock.Unlock()
+
+	return hostPaths
+}
+
+func getDockerBinary() string {
+	return getHostPaths().DockerBinary
+}
+
+func getContainerLogsDir() string {
+	return getHostPaths().ContainerLogsDir
+}
+
+// getDriverBinary returns the host path of the installed driver. The running executable can't be used, as the
+// driver may be running in a pod (e.g. the monitor or daemon) whose filesystem the shim doesn't see
+func getDriverBinary() string {
+	if driverBinary := getHostPaths().DriverBinary; driverBinary != "" {
+		return driverBinary
+	}
+
+	return path.Join(GetNodeLayout().VolumePluginDir, "v3io~fuse", "fuse")
+}
+
+// getCtrPath returns the path of the ctr CLI - as configured, from PATH, the system directories (unless disabled)
+// or bundled by the node's distribution
+func getCtrPath() (string, error) {
+	paths := getHostPaths()
+	if paths.CtrBinary != "" {
+		return paths.CtrBinary, nil
+	}
+
+	if ctrPath, err := exec.LookPath("ctr"); err == nil {
+		return ctrPath, nil
+	}
+
+	var candidatePaths []string
+	if paths.SearchSystemBinaries {
+		candidatePaths = append(candidatePaths, "/usr/local/bin/ctr", "/usr/bin/ctr")
+	}
+
+	if ctrPath := GetNodeLayout().CtrPath; ctrPath != "" {
+		candidatePaths = append(candidatePaths, ctrPath)
+	}
+
+	for _, candidatePath := range candidatePaths {
+		if _, err := os.Stat(candidatePath); err == nil {
+			return candidatePath, nil
+		}
+	}
+
+	return "", fmt.Errorf("ctr not found in PATH or %v", candidatePaths)
+}
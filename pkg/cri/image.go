@@ -0,0 +1,69 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"strings"
+)
+
+const DockerHubRegistry = "docker.io"
+
+// GetImageRegistry returns the registry host of an image reference (e.g. docker.io for iguazio/v3io-fuse:local)
+func GetImageRegistry(image string) string {
+	imageParts := strings.SplitN(image, "/", 2)
+
+	if len(imageParts) == 1 ||
+		!(strings.ContainsAny(imageParts[0], ".:") || imageParts[0] == "localhost") {
+		return DockerHubRegistry
+	}
+
+	return imageParts[0]
+}
+
+// GetImageRepository returns the repository of an image reference, without its tag or digest
+func GetImageRepository(image string) string {
+	if digestIdx := strings.Index(image, "@"); digestIdx >= 0 {
+		image = image[:digestIdx]
+	}
+
+	// a colon after the last slash separates the tag, while one before it is the registry's port
+	if tagIdx := strings.LastIndex(image, ":"); tagIdx > strings.LastIndex(image, "/") {
+		image = image[:tagIdx]
+	}
+
+	return image
+}
+
+// GetNormalizedImageRepository returns the repository of an image reference including its registry (e.g.
+// docker.io/library/busybox for busybox:latest)
+func GetNormalizedImageRepository(image string) string {
+	repository := GetImageRepository(image)
+	registry := GetImageRegistry(repository)
+
+	if !strings.HasPrefix(repository, registry+"/") {
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+
+		repository = registry + "/" + repository
+	}
+
+	return repository
+}
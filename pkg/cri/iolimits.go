@@ -0,0 +1,142 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/oci"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// IOLimits throttle the container's I/O on block devices, applied by the runtime through the cgroup io controller
+// (io.max) on cgroup v2 or blkio on v1. Zero rates are unlimited
+type IOLimits struct {
+
+	// Devices are the block devices (e.g. /dev/sda) throttled. Partitions can't be throttled, only whole devices
+	Devices []string
+
+	ReadBps   uint64
+	WriteBps  uint64
+	ReadIOPS  uint64
+	WriteIOPS uint64
+}
+
+// IsSet returns whether any rate is limited
+func (l *IOLimits) IsSet() bool {
+	return l != nil && (l.ReadBps != 0 || l.WriteBps != 0 || l.ReadIOPS != 0 || l.WriteIOPS != 0)
+}
+
+// withIOLimits throttles the container's I/O on the limits' devices
+func withIOLimits(limits *IOLimits) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *oci.Spec) error {
+		if s.Linux == nil {
+			s.Linux = &specs.Linux{}
+		}
+
+		if s.Linux.Resources == nil {
+			s.Linux.Resources = &specs.LinuxResources{}
+		}
+
+		if s.Linux.Resources.BlockIO == nil {
+			s.Linux.Resources.BlockIO = &specs.LinuxBlockIO{}
+		}
+
+		blockIO := s.Linux.Resources.BlockIO
+
+		for _, device := range limits.Devices {
+			major, minor, err := getBlockDeviceNumbers(device)
+			if err != nil {
+				return err
+			}
+
+			throttle := func(throttleDevices *[]specs.LinuxThrottleDevice, rate uint64) {
+				if rate == 0 {
+					return
+				}
+
+				throttleDevice := specs.LinuxThrottleDevice{Rate: rate}
+				throttleDevice.Major = major
+				throttleDevice.Minor = minor
+
+				*throttleDevices = append(*throttleDevices, throttleDevice)
+			}
+
+			throttle(&blockIO.ThrottleReadBpsDevice, limits.ReadBps)
+			throttle(&blockIO.ThrottleWriteBpsDevice, limits.WriteBps)
+			throttle(&blockIO.ThrottleReadIOPSDevice, limits.ReadIOPS)
+			throttle(&blockIO.ThrottleWriteIOPSDevice, limits.WriteIOPS)
+		}
+
+		return nil
+	}
+}
+
+// getIOLimitArgs returns the docker run arguments throttling the container's I/O on the limits' devices
+func getIOLimitArgs(limits *IOLimits) []string {
+	var args []string
+
+	for _, device := range limits.Devices {
+		for _, limit := range []struct {
+			flag string
+			rate uint64
+		}{
+			{"--device-read-bps", limits.ReadBps},
+			{"--device-write-bps", limits.WriteBps},
+			{"--device-read-iops", limits.ReadIOPS},
+			{"--device-write-iops", limits.WriteIOPS},
+		} {
+			if limit.rate != 0 {
+				args = append(args, limit.flag, device+":"+strconv.FormatUint(limit.rate, 10))
+			}
+		}
+	}
+
+	return args
+}
+
+// getBlockDeviceNumbers returns the major and minor numbers of a block device
+func getBlockDeviceNumbers(device string) (int64, int64, error) {
+	stat := unix.Stat_t{}
+	if err := unix.Stat(device, &stat); err != nil {
+		return 0, 0, fmt.Errorf("Failed to stat I/O limited device %s: %s", device, err)
+	}
+
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return 0, 0, fmt.Errorf("I/O limited device %s is not a block device", device)
+	}
+
+	return int64(unix.Major(uint64(stat.Rdev))), int64(unix.Minor(uint64(stat.Rdev))), nil
+}
+
+// checkIODevices verifies the limits' devices are block devices, before the container is created
+func checkIODevices(limits *IOLimits) error {
+	for _, device := range limits.Devices {
+		if _, _, err := getBlockDeviceNumbers(device); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+
+	"github.com/containerd/containerd"
+)
+
+// importImageLayoutIfMissing makes sure an image exists and is unpacked, importing it from an OCI image layout
+// directory if it doesn't exist. Returns whether the image is ready
+func (c *Containerd) importImageLayoutIfMissing(layoutDir string, image string) bool {
+	if _, err := c.containerdClient.GetImage(c.containerdContext, image); err != nil {
+		if err := c.importImageLayout(layoutDir, image); err != nil {
+			journal.Warn("Failed to import image from OCI layout",
+				"layoutDir", layoutDir,
+				"image", image,
+				"err", err.Error())
+			return false
+		}
+	}
+
+	layoutImage, err := c.containerdClient.GetImage(c.containerdContext, image)
+	if err != nil {
+		journal.Warn("Image is not in OCI layout", "layoutDir", layoutDir, "image", image)
+		return false
+	}
+
+	if err := c.unpackImage(c.containerdContext, layoutImage); err != nil {
+		journal.Warn("Failed to unpack image from OCI layout", "image", image, "err", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// importImageLayout imports the images of an OCI image layout directory (e.g. baked into the node's machine
+// image), so the image doesn't have to be pulled. Images named only by a tag in the layout's index are named
+// after the repository of the requested image
+func (c *Containerd) importImageLayout(layoutDir string, image string) error {
+	journal.Debug("Importing image from OCI layout", "layoutDir", layoutDir, "image", image)
+
+	if _, err := os.Stat(filepath.Join(layoutDir, "index.json")); err != nil {
+		return err
+	}
+
+	repository := GetImageRepository(image)
+
+	layoutReader, layoutWriter := io.Pipe()
+	go func() {
+		layoutWriter.CloseWithError(writeLayoutArchive(layoutDir, layoutWriter)) // nolint: errcheck
+	}()
+
+	defer layoutReader.Close() // nolint: errcheck
+
+	importedImages, err := c.containerdClient.Import(c.containerdContext,
+		layoutReader,
+		containerd.WithImageRefTranslator(func(ref string) string {
+			if !strings.ContainsAny(ref, "/:@") {
+				return repository + ":" + ref
+			}
+
+			return ref
+		}))
+	if err != nil {
+		return err
+	}
+
+	for _, importedImage := range importedImages {
+		journal.Debug("Imported image from OCI layout", "layoutDir", layoutDir, "image", importedImage.Name)
+	}
+
+	return nil
+}
+
+// writeLayoutArchive writes the files of a directory as a tar archive, which is what the runtime imports
+func writeLayoutArchive(layoutDir string, writer io.Writer) error {
+	tarWriter := tar.NewWriter(writer)
+
+	if err := filepath.Walk(layoutDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(layoutDir, filePath)
+		if err != nil || relativePath == "." {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		header.Name = filepath.ToSlash(relativePath)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close() // nolint: errcheck
+
+		_, err = io.Copy(tarWriter, file)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return tarWriter.Close()
+}
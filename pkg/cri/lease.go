@@ -0,0 +1,61 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"time"
+
+	"github.com/containerd/containerd/leases"
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// how long a lease protects content if the driver dies before releasing it
+const leaseExpiration = time.Hour
+
+// label of the leases of the driver, holding the name of the container they're taken for
+const leaseContainerLabel = "io.iguazio.flex-fuse/container"
+
+// withLease returns a copy of the CRI whose operations hold a lease, so that containerd's garbage collector doesn't
+// delete the content and snapshots they pull, import and unpack before the container referencing them exists.
+// The returned function releases the lease
+func (c *Containerd) withLease(containerName string) (*Containerd, func(), error) {
+	leasedContext, releaseLease, err := c.containerdClient.WithLease(c.containerdContext,
+		leases.WithRandomID(),
+		leases.WithExpiration(leaseExpiration),
+		leases.WithLabels(map[string]string{
+			OwnerLabel:          OwnerLabelValue,
+			leaseContainerLabel: containerName,
+		}))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the copy shares the clients, and only differs by the context
+	leased := *c
+	leased.containerdContext = leasedContext
+
+	return &leased, func() {
+		if err := releaseLease(c.containerdContext); err != nil {
+			journal.Warn("Failed to release lease, it expires in an hour",
+				"containerName", containerName,
+				"err", err.Error())
+		}
+	}, nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containerd/containerd/cio"
+)
+
+// log drivers of the FUSE containers
+const (
+
+	// LogDriverFile writes the log to a rotated file under /var/log/containers (the runtime's default with docker)
+	LogDriverFile = "file"
+
+	// LogDriverNone discards the log
+	LogDriverNone = "none"
+
+	// LogDriverFluentd forwards the log to a local fluentd or fluent-bit forward input
+	LogDriverFluentd = "fluentd"
+)
+
+// LogForwardCommand is the driver's command the containerd shim runs to forward the output of FUSE containers with
+// the fluentd log driver
+const LogForwardCommand = "log-forward"
+
+// the log driver of a container and its address are labeled, so that restarted tasks log the same way
+const (
+	logDriverLabel  = "io.iguazio.flex-fuse/log-driver"
+	logAddressLabel = "io.iguazio.flex-fuse/log-address"
+)
+
+func (o *ContainerOptions) getLogDriver() string {
+	if o == nil || o.LogDriver == "" {
+		return LogDriverFile
+	}
+
+	return o.LogDriver
+}
+
+// getLogPipeline returns the shell redirection of the FUSE client's output - through multilog into the log
+// directory with the file driver, and to the task's output (forwarded by the shim) with fluentd
+func getLogPipeline(options *ContainerOptions, logDir string) string {
+	switch options.getLogDriver() {
+	case LogDriverNone:
+		return " > /dev/null 2>&1"
+
+	case LogDriverFluentd:
+		if logPrefixCommand := getLogPrefixCommand(options); logPrefixCommand != "" {
+			return " 2>&1 | " + strings.TrimSuffix(logPrefixCommand, " | ")
+		}
+
+		return " 2>&1"
+
+	default:
+		return fmt.Sprintf(" 2>&1 | %s%s %s", getLogPrefixCommand(options), getMultilogCommand(options), logDir)
+	}
+}
+
+// getTaskIO returns the IO of a container's task by its log driver, and the file holding the task's output if any.
+// With the file driver the output is what the shell writes outside the pipeline (e.g. failing to start it)
+func (c *Containerd) getTaskIO(containerName string,
+	targetPath string,
+	logDriver string,
+	logAddress string) (cio.Creator, string, error) {
+
+	switch logDriver {
+	case LogDriverNone:
+		return cio.NullIO, "", nil
+
+	case LogDriverFluentd:
+		return cio.BinaryIO(getDriverBinary(), map[string]string{LogForwardCommand: logAddress}), "", nil
+
+	default:
+		logFilePath, err := c.getLogFilePath(containerName, targetPath)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return cio.LogFile(logFilePath), logFilePath, nil
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// lines of a container's log attached to errors
+const logTailLines = 100
+
+// readLogTail returns the last lines of a file, or an empty string if it can't be read
+func readLogTail(logPath string, lines int) string {
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		return ""
+	}
+
+	logLines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(logLines) > lines {
+		logLines = logLines[len(logLines)-lines:]
+	}
+
+	return strings.Join(logLines, "\n")
+}
+
+// getContainerLogTail returns the tail of the log multilog writes in a container's log directory, falling back
+// to the output of the container's process (e.g. when the pipeline to multilog failed)
+func getContainerLogTail(logDir string, outputPath string) string {
+	if logDir != "" {
+		if logTail := readLogTail(path.Join(logDir, "current"), logTailLines); logTail != "" {
+			return logTail
+		}
+	}
+
+	if outputPath == "" {
+		return ""
+	}
+
+	return readLogTail(outputPath, logTailLines)
+}
+
+// newExitedError returns the error of a container's process exiting while starting, with its log tail
+func newExitedError(exitCode uint32, logTail string) error {
+	if logTail == "" {
+		return fmt.Errorf("The FUSE process exited with status %d while starting", exitCode)
+	}
+
+	return fmt.Errorf("The FUSE process exited with status %d while starting, log tail:\n%s", exitCode, logTail)
+}
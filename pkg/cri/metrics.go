@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+
+	"github.com/v3io/flex-fuse/pkg/cgroup"
+
+	cgroupsv1 "github.com/containerd/cgroups/stats/v1"
+	cgroupsv2 "github.com/containerd/cgroups/v2/stats"
+	"github.com/containerd/typeurl/v2"
+)
+
+// GetContainerStats returns the resource usage of a container's task from the runtime's metrics, which unlike
+// reading the cgroup files doesn't depend on the monitor seeing the host's cgroup filesystem
+func (c *Containerd) GetContainerStats(containerName string) (*cgroup.Stats, error) {
+	container, err := c.containerdClient.LoadContainer(c.containerdContext, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := container.Task(c.containerdContext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	metric, err := task.Metrics(c.containerdContext)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := getStatsFromMetrics(metric.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	processes, err := task.Pids(c.containerdContext)
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make([]uint32, 0, len(processes))
+	for _, process := range processes {
+		pids = append(pids, process.Pid)
+	}
+
+	stats.OpenFiles = cgroup.CountOpenFiles(pids)
+
+	return stats, nil
+}
+
+// getStatsFromMetrics converts the cgroup v1 or v2 metrics of a task
+func getStatsFromMetrics(metricsData typeurl.Any) (*cgroup.Stats, error) {
+	metrics, err := typeurl.UnmarshalAny(metricsData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal metrics: %s", err)
+	}
+
+	stats := cgroup.Stats{}
+
+	switch typedMetrics := metrics.(type) {
+	case *cgroupsv1.Metrics:
+		if typedMetrics.Memory != nil {
+			stats.MemoryRSSBytes = typedMetrics.Memory.TotalRSS
+		}
+
+		if typedMetrics.CPU != nil && typedMetrics.CPU.Usage != nil {
+			stats.CPUUsageNanoseconds = typedMetrics.CPU.Usage.Total
+		}
+	case *cgroupsv2.Metrics:
+		if typedMetrics.Memory != nil {
+			stats.MemoryRSSBytes = typedMetrics.Memory.Anon
+		}
+
+		if typedMetrics.CPU != nil {
+			stats.CPUUsageNanoseconds = typedMetrics.CPU.UsageUsec * 1000
+		}
+	default:
+		return nil, fmt.Errorf("Unexpected metrics type %T", metrics)
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"testing"
+
+	cgroupsv1 "github.com/containerd/cgroups/stats/v1"
+	cgroupsv2 "github.com/containerd/cgroups/v2/stats"
+	"github.com/containerd/typeurl/v2"
+)
+
+func TestGetStatsFromMetrics(t *testing.T) {
+	for _, testCase := range []struct {
+		name                        string
+		metrics                     interface{}
+		expectedMemoryRSSBytes      uint64
+		expectedCPUUsageNanoseconds uint64
+	}{
+		{
+			name: "cgroup v1",
+			metrics: &cgroupsv1.Metrics{
+				Memory: &cgroupsv1.MemoryStat{TotalRSS: 4096},
+				CPU:    &cgroupsv1.CPUStat{Usage: &cgroupsv1.CPUUsage{Total: 2000}},
+			},
+			expectedMemoryRSSBytes:      4096,
+			expectedCPUUsageNanoseconds: 2000,
+		},
+		{
+			name: "cgroup v2",
+			metrics: &cgroupsv2.Metrics{
+				Memory: &cgroupsv2.MemoryStat{Anon: 8192},
+				CPU:    &cgroupsv2.CPUStat{UsageUsec: 3},
+			},
+			expectedMemoryRSSBytes:      8192,
+			expectedCPUUsageNanoseconds: 3000,
+		},
+		{
+			name:    "missing stats",
+			metrics: &cgroupsv2.Metrics{},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			metricsData, err := typeurl.MarshalAny(testCase.metrics)
+			if err != nil {
+				t.Fatalf("Failed to marshal metrics: %s", err)
+			}
+
+			stats, err := getStatsFromMetrics(metricsData)
+			if err != nil {
+				t.Fatalf("Failed to get stats: %s", err)
+			}
+
+			if stats.MemoryRSSBytes != testCase.expectedMemoryRSSBytes ||
+				stats.CPUUsageNanoseconds != testCase.expectedCPUUsageNanoseconds {
+				t.Errorf("Expected %d bytes and %dns, got %d bytes and %dns",
+					testCase.expectedMemoryRSSBytes,
+					testCase.expectedCPUUsageNanoseconds,
+					stats.MemoryRSSBytes,
+					stats.CPUUsageNanoseconds)
+			}
+		})
+	}
+}
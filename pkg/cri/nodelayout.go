@@ -0,0 +1,150 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/probe"
+)
+
+// Kubernetes distributions whose node layout differs from upstream's
+const (
+	DistroUpstream = "upstream"
+	DistroK3s      = "k3s"
+	DistroRKE2     = "rke2"
+)
+
+const (
+	defaultHostsDir        = "/etc/containerd/certs.d"
+	defaultKubeletRootDir  = "/var/lib/kubelet"
+	defaultVolumePluginDir = "/usr/libexec/kubernetes/kubelet-plugins/volume/exec"
+
+	// k3s and RKE2 run their own containerd, with its socket (and registry hosts) under their data directory
+	k3sContainerdSock = "/run/k3s/containerd/containerd.sock"
+	k3sDataDir        = "/var/lib/rancher/k3s"
+	rke2DataDir       = "/var/lib/rancher/rke2"
+)
+
+// NodeLayout is where the node's distribution keeps the paths the driver uses
+type NodeLayout struct {
+	Distro string `json:"distro"`
+
+	// ContainerdSocket is the default runtime endpoint's socket
+	ContainerdSocket string `json:"containerdSocket"`
+
+	// HostsDir holds containerd's registry host configurations (certs.d), passed to ctr when pulling
+	HostsDir string `json:"hostsDir"`
+
+	// CtrPath is the distribution's ctr binary, if it bundles one outside of PATH
+	CtrPath string `json:"ctrPath,omitempty"`
+
+	// KubeletRootDir and VolumePluginDir are kubelet's --root-dir and --volume-plugin-dir, where pods' volumes are
+	// and where the driver must be installed
+	KubeletRootDir  string `json:"kubeletRootDir"`
+	VolumePluginDir string `json:"volumePluginDir"`
+}
+
+// GetNodeLayout returns the layout of the node's distribution, detecting k3s and RKE2 from their data directories.
+// Detecting reads the processes of the node, so it's cached
+func GetNodeLayout() *NodeLayout {
+	nodeLayout := NodeLayout{}
+
+	probe.Cached("node-layout", &nodeLayout, func() error { // nolint: errcheck
+		nodeLayout = *detectNodeLayout()
+		return nil
+	})
+
+	return &nodeLayout
+}
+
+func detectNodeLayout() *NodeLayout {
+	nodeLayout := NodeLayout{
+		Distro:           DistroUpstream,
+		ContainerdSocket: defaultContainerdSock,
+		HostsDir:         defaultHostsDir,
+		KubeletRootDir:   defaultKubeletRootDir,
+		VolumePluginDir:  defaultVolumePluginDir,
+	}
+
+	// RKE2 embeds k3s, so it's checked first
+	for _, distro := range []struct {
+		name    string
+		dataDir string
+	}{
+		{DistroRKE2, rke2DataDir},
+		{DistroK3s, k3sDataDir},
+	} {
+		if _, err := os.Stat(distro.dataDir); err != nil {
+			continue
+		}
+
+		nodeLayout.Distro = distro.name
+		nodeLayout.HostsDir = path.Join(distro.dataDir, "agent/etc/containerd/certs.d")
+
+		if _, err := os.Stat(k3sContainerdSock); err == nil {
+			nodeLayout.ContainerdSocket = k3sContainerdSock
+		}
+
+		if ctrPath := path.Join(distro.dataDir, "bin/ctr"); isExecutable(ctrPath) {
+			nodeLayout.CtrPath = ctrPath
+		} else if ctrPath := path.Join(distro.dataDir, "data/current/bin/ctr"); isExecutable(ctrPath) {
+			nodeLayout.CtrPath = ctrPath
+		}
+
+		break
+	}
+
+	// kubelet's flags take precedence, if it runs as a process of its own (k3s embeds it)
+	if kubeletCommandLine := getKubeletCommandLine(); kubeletCommandLine != "" {
+		if rootDir := getCommandLineFlag(kubeletCommandLine, "--root-dir"); rootDir != "" {
+			nodeLayout.KubeletRootDir = rootDir
+		}
+
+		if volumePluginDir := getCommandLineFlag(kubeletCommandLine, "--volume-plugin-dir"); volumePluginDir != "" {
+			nodeLayout.VolumePluginDir = volumePluginDir
+		}
+	}
+
+	return &nodeLayout
+}
+
+// getCommandLineFlag returns the value of a flag on a command line, as --flag=value or --flag value
+func getCommandLineFlag(commandLine string, flag string) string {
+	args := strings.Fields(commandLine)
+	for argIdx, arg := range args {
+		if strings.HasPrefix(arg, flag+"=") {
+			return strings.TrimPrefix(arg, flag+"=")
+		}
+
+		if arg == flag && argIdx+1 < len(args) {
+			return args[argIdx+1]
+		}
+	}
+
+	return ""
+}
+
+func isExecutable(filePath string) bool {
+	fileInfo, err := os.Stat(filePath)
+	return err == nil && !fileInfo.IsDir() && fileInfo.Mode()&0111 != 0
+}
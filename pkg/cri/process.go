@@ -0,0 +1,84 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clock ticks per second of process times in /proc, fixed on the architectures kubelet runs on
+const clockTicksPerSecond = 100
+
+// getProcessStartTime returns when a process started, from its start time in clock ticks since boot
+func getProcessStartTime(pid uint32) (time.Time, error) {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// the fields following the command, which may hold spaces, start with the state (field 3)
+	commandEndIdx := strings.LastIndex(string(stat), ")")
+	if commandEndIdx == -1 {
+		return time.Time{}, fmt.Errorf("Malformed stat of process %d", pid)
+	}
+
+	fields := strings.Fields(string(stat)[commandEndIdx+1:])
+	if len(fields) < 20 {
+		return time.Time{}, fmt.Errorf("Malformed stat of process %d", pid)
+	}
+
+	startTicks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Malformed start time of process %d: %s", pid, err)
+	}
+
+	bootTime, err := getBootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return bootTime.Add(time.Duration(startTicks) * time.Second / clockTicksPerSecond), nil
+}
+
+func getBootTime() (time.Time, error) {
+	stat, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(string(stat), "\n") {
+		if !strings.HasPrefix(line, "btime ") {
+			continue
+		}
+
+		bootTime, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Malformed boot time: %s", err)
+		}
+
+		return time.Unix(bootTime, 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("Boot time not found")
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"text/template"
+
+	"github.com/v3io/flex-fuse/pkg/common"
+	"github.com/v3io/flex-fuse/pkg/journal"
+
+	"github.com/distribution/reference"
+)
+
+// PullCommandFields are available to the pull command template
+type PullCommandFields struct {
+	Image    string
+	Registry string
+
+	// Address and Namespace are where containerd images are pulled to (e.g. for ctr or nerdctl)
+	Address   string
+	Namespace string
+}
+
+var (
+	pullCommandLock     sync.Mutex
+	pullCommandTemplate string
+)
+
+// SetPullCommand sets a command template (e.g. "crane pull {{.Image}} ...") run to pull missing images instead of
+// the runtime, for bespoke pull tooling. It's split into arguments by whitespace and run without a shell. Credentials
+// are passed in the V3IO_PULL_USERNAME and V3IO_PULL_PASSWORD environment variables. Empty pulls through the runtime
+func SetPullCommand(commandTemplate string) {
+	pullCommandLock.Lock()
+	defer pullCommandLock.Unlock()
+
+	pullCommandTemplate = commandTemplate
+}
+
+func getPullCommand() string {
+	pullCommandLock.Lock()
+	defer pullCommandLock.Unlock()
+
+	return pullCommandTemplate
+}
+
+// runPullCommand runs the pull command for an image, and verifies the image exists afterwards
+func runPullCommand(commandTemplate string,
+	fields *PullCommandFields,
+	credentials *RegistryCredentials,
+	imageExists func() bool) error {
+
+	// the image may come from a volume option, so it's validated before it reaches a command
+	if _, err := reference.ParseNormalizedNamed(fields.Image); err != nil {
+		return fmt.Errorf("Invalid image reference %q: %s", fields.Image, err)
+	}
+
+	args, err := renderPullCommand(commandTemplate, fields)
+	if err != nil {
+		return err
+	}
+
+	pullCommand := exec.Command(args[0], args[1:]...)
+	pullCommand.Env = append(os.Environ(),
+		"V3IO_PULL_IMAGE="+fields.Image,
+		"V3IO_PULL_REGISTRY="+fields.Registry,
+		"V3IO_PULL_ADDRESS="+fields.Address,
+		"V3IO_PULL_NAMESPACE="+fields.Namespace)
+	if credentials != nil {
+		pullCommand.Env = append(pullCommand.Env,
+			"V3IO_PULL_USERNAME="+credentials.Username,
+			"V3IO_PULL_PASSWORD="+credentials.Password)
+	}
+
+	journal.Debug("Running pull command", "image", fields.Image, "args", args)
+
+	if output, err := pullCommand.CombinedOutput(); err != nil {
+		return fmt.Errorf("Pull command failed: [%s] %s", err, string(output))
+	}
+
+	if !imageExists() {
+		return fmt.Errorf("Pull command succeeded, but image %s doesn't exist", fields.Image)
+	}
+
+	return nil
+}
+
+// renderPullCommand splits the pull command template into arguments and renders each separately, so
+// that a field is always a single argument - it can't add arguments or commands, as there's no shell to interpret it
+func renderPullCommand(commandTemplate string, fields *PullCommandFields) ([]string, error) {
+	argTemplates := common.SplitTemplateArgs(commandTemplate)
+	if len(argTemplates) == 0 {
+		return nil, fmt.Errorf("Pull command is empty")
+	}
+
+	var args []string
+	for _, argTemplate := range argTemplates {
+		parsedTemplate, err := template.New("pull_command").Option("missingkey=error").Parse(argTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse pull command argument %q: %s", argTemplate, err)
+		}
+
+		var arg bytes.Buffer
+		if err := parsedTemplate.Execute(&arg, fields); err != nil {
+			return nil, fmt.Errorf("Failed to render pull command argument %q: %s", argTemplate, err)
+		}
+
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestRenderPullCommand(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		commandTemplate string
+		fields          PullCommandFields
+		expectedArgs    []string
+	}{
+		{
+			name:            "fields",
+			commandTemplate: "ctr -a {{.Address}} -n {{.Namespace}} images pull {{.Image}}",
+			fields: PullCommandFields{
+				Image:     "iguazio/v3io-fuse:1.0",
+				Address:   "/run/containerd.sock",
+				Namespace: "k8s.io",
+			},
+			expectedArgs: []string{
+				"ctr", "-a", "/run/containerd.sock", "-n", "k8s.io", "images", "pull", "iguazio/v3io-fuse:1.0",
+			},
+		},
+		{
+			name:            "spaces within actions",
+			commandTemplate: "  crane\tpull {{ .Image }}  {{ printf \"%s.tar\" .Registry }}\n",
+			fields:          PullCommandFields{Image: "iguazio/v3io-fuse:1.0", Registry: "docker.io"},
+			expectedArgs:    []string{"crane", "pull", "iguazio/v3io-fuse:1.0", "docker.io.tar"},
+		},
+		{
+			name:            "field is a single argument",
+			commandTemplate: "crane pull {{.Image}}",
+			fields:          PullCommandFields{Image: "x; touch /tmp/injected $(id) `id`"},
+			expectedArgs:    []string{"crane", "pull", "x; touch /tmp/injected $(id) `id`"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			args, err := renderPullCommand(testCase.commandTemplate, &testCase.fields)
+			if err != nil {
+				t.Fatalf("Failed to render: %s", err)
+			}
+
+			if !reflect.DeepEqual(args, testCase.expectedArgs) {
+				t.Fatalf("Expected %q, got %q", testCase.expectedArgs, args)
+			}
+		})
+	}
+}
+
+func TestRenderPullCommandErrors(t *testing.T) {
+	for _, commandTemplate := range []string{"", "  ", "crane pull {{.Missing}}", "crane pull {{.Image"} {
+		if _, err := renderPullCommand(commandTemplate, &PullCommandFields{Image: "busybox"}); err == nil {
+			t.Fatalf("Expected %q to fail", commandTemplate)
+		}
+	}
+}
+
+func TestRunPullCommandRejectsInvalidImages(t *testing.T) {
+	injectedPath := path.Join(t.TempDir(), "injected")
+
+	for _, image := range []string{
+		"busybox; touch " + injectedPath,
+		"busybox$(touch " + injectedPath + ")",
+		"busybox:latest touch",
+		"",
+	} {
+		err := runPullCommand("/bin/sh -c {{.Image}}",
+			&PullCommandFields{Image: image},
+			nil,
+			func() bool { return true })
+		if err == nil {
+			t.Fatalf("Expected image %q to be rejected", image)
+		}
+	}
+
+	if _, err := os.Stat(injectedPath); err == nil {
+		t.Fatalf("Pull command ran an injected command")
+	}
+}
+
+func TestRunPullCommand(t *testing.T) {
+	workDir := t.TempDir()
+	argsPath := path.Join(workDir, "args")
+	scriptPath := path.Join(workDir, "pull.sh")
+
+	script := "#!/bin/sh\necho \"$#|$1|$V3IO_PULL_IMAGE|$V3IO_PULL_USERNAME\" > " + argsPath + "\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0700); err != nil {
+		t.Fatalf("Failed to write script: %s", err)
+	}
+
+	err := runPullCommand(scriptPath+" {{.Image}}",
+		&PullCommandFields{Image: "registry.example.com/v3io-fuse:1.0"},
+		&RegistryCredentials{Username: "user", Password: "password"},
+		func() bool { return true })
+	if err != nil {
+		t.Fatalf("Failed to run pull command: %s", err)
+	}
+
+	args, err := os.ReadFile(argsPath)
+	if err != nil {
+		t.Fatalf("Pull command didn't run: %s", err)
+	}
+
+	// the image is a single argument, and in the environment
+	expectedArgs := "1|registry.example.com/v3io-fuse:1.0|registry.example.com/v3io-fuse:1.0|user\n"
+	if string(args) != expectedArgs {
+		t.Fatalf("Expected %q, got %q", expectedArgs, string(args))
+	}
+
+	if err := runPullCommand("/bin/true", &PullCommandFields{Image: "busybox"}, nil, func() bool {
+		return false
+	}); err == nil {
+		t.Fatalf("Expected a pull leaving the image missing to fail")
+	}
+}
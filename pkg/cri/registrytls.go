@@ -0,0 +1,106 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// RegistryTLS holds the files authenticating a registry (CAFile) and the client to it (CertFile and KeyFile), for
+// registries requiring mutual TLS
+type RegistryTLS struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+var (
+	registryTLSLock sync.Mutex
+	registryTLS     map[string]*RegistryTLS
+)
+
+// SetRegistryTLS sets the TLS files of registries (by host, e.g. registry.example.com:5000) used by subsequent
+// pulls (containerd only)
+func SetRegistryTLS(newRegistryTLS map[string]*RegistryTLS) {
+	registryTLSLock.Lock()
+	defer registryTLSLock.Unlock()
+
+	registryTLS = newRegistryTLS
+}
+
+// getRegistryTLS returns the TLS files of an image's registry, or nil if none were set
+func getRegistryTLS(image string) *RegistryTLS {
+	registryTLSLock.Lock()
+	defer registryTLSLock.Unlock()
+
+	return registryTLS[GetImageRegistry(image)]
+}
+
+// getRegistryTLSArgs returns the ctr pull flags of the TLS files of an image's registry
+func getRegistryTLSArgs(image string) []string {
+	imageRegistryTLS := getRegistryTLS(image)
+	if imageRegistryTLS == nil {
+		return nil
+	}
+
+	var args []string
+
+	if imageRegistryTLS.CAFile != "" {
+		args = append(args, "--tlscacert", imageRegistryTLS.CAFile)
+	}
+
+	if imageRegistryTLS.CertFile != "" {
+		args = append(args, "--tlscert", imageRegistryTLS.CertFile, "--tlskey", imageRegistryTLS.KeyFile)
+	}
+
+	return args
+}
+
+// getTLSConfig returns the TLS configuration of connections to the registry
+func (r *RegistryTLS) getTLSConfig() (*tls.Config, error) {
+	tlsConfig := tls.Config{}
+
+	if r.CAFile != "" {
+		caCert, err := ioutil.ReadFile(r.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("No certificates in %s", r.CAFile)
+		}
+	}
+
+	if r.CertFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return &tlsConfig, nil
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+)
+
+// rollback undoes the steps of a multi step creation that failed midway, so that no partially created resources
+// (e.g. a container without a task, or a snapshot without a container) are left behind
+type rollback struct {
+	steps     []rollbackStep
+	committed bool
+}
+
+type rollbackStep struct {
+	name string
+	undo func() error
+}
+
+// add registers how to undo a step that succeeded
+func (r *rollback) add(name string, undo func() error) {
+	r.steps = append(r.steps, rollbackStep{name: name, undo: undo})
+}
+
+// commit keeps everything that was created
+func (r *rollback) commit() {
+	r.committed = true
+}
+
+// run undoes the registered steps in reverse order, unless committed. Meant to be deferred
+func (r *rollback) run() {
+	if r.committed {
+		return
+	}
+
+	for stepIdx := len(r.steps) - 1; stepIdx >= 0; stepIdx-- {
+		step := r.steps[stepIdx]
+
+		journal.Debug("Rolling back", "step", step.name)
+		if err := step.undo(); err != nil {
+			journal.Warn("Failed to roll back", "step", step.name, "err", err.Error())
+		}
+	}
+}
+
+// containerCreator holds the steps of creating a FUSE container, each of which may fail and must then be rolled back
+type containerCreator interface {
+	getContext() context.Context
+	getTaskIO(containerName string, targetPath string, logDriver string, logAddress string) (cio.Creator, string, error)
+	withLeasedCreator(containerName string) (containerCreator, func(), error)
+	createContainer(image string,
+		containerName string,
+		targetPath string,
+		args []string,
+		logLinkPath string,
+		options *ContainerOptions) (containerd.Container, error)
+	removeSnapshot(containerName string) error
+}
+
+// createContainerWithRollback creates a container and starts its task, removing whatever was created if a step fails
+func createContainerWithRollback(creator containerCreator,
+	image string,
+	containerName string,
+	targetPath string,
+	args []string,
+	options *ContainerOptions) error {
+
+	// everything created is removed if creation fails midway
+	var createRollback rollback
+	defer createRollback.run()
+
+	// the task's IO, and a log file holding its output with the file log driver
+	taskIO, logFilePath, err := creator.getTaskIO(containerName, targetPath, options.getLogDriver(), options.LogAddress)
+	if err != nil {
+		return err
+	}
+
+	if logFilePath != "" {
+		createRollback.add("log file", func() error {
+			return os.Remove(logFilePath)
+		})
+
+		journal.Debug("Creating log file",
+			"containerName", containerName,
+			"targetPath", targetPath,
+			"logFilePath", logFilePath)
+	}
+
+	// multilog writes the log in a directory with the file log driver, through a link to it
+	logLinkPath := ""
+	if options.getLogDriver() == LogDriverFile {
+		logLinkPath = getLogLinkPath(containerName)
+	}
+
+	leased, releaseLease, err := creator.withLeasedCreator(containerName)
+	if err != nil {
+		return fmt.Errorf("Failed to create lease: %s", err)
+	}
+
+	// the snapshot is prepared before the container is created, and isn't removed if creating it fails
+	createRollback.add("snapshot", func() error {
+		return creator.removeSnapshot(containerName)
+	})
+
+	// once the container exists, its image and snapshot are referenced by it
+	v3ioFUSEContainer, err := leased.createContainer(image, containerName, targetPath, args, logLinkPath, options)
+	releaseLease()
+
+	if err != nil {
+		return err
+	}
+
+	createRollback.add("container", func() error {
+		return v3ioFUSEContainer.Delete(creator.getContext(), containerd.WithSnapshotCleanup)
+	})
+
+	options.reportProgress(ProgressStarting)
+	options.startStep(StepStart)
+
+	// create the actual process
+	v3ioFUSETask, err := v3ioFUSEContainer.NewTask(creator.getContext(), taskIO)
+	if err != nil {
+		return err
+	}
+
+	createRollback.add("task", func() error {
+		_, err := v3ioFUSETask.Delete(creator.getContext(), containerd.WithProcessKill)
+		return err
+	})
+
+	// the log directory is named after the created task's cgroup, and labeled as its name is random
+	logDir := ""
+	if logLinkPath != "" {
+		logDir, err = linkLogDir(containerName, v3ioFUSETask.Pid())
+		if err != nil {
+			return fmt.Errorf("Failed to create log directory: %s", err)
+		}
+
+		createRollback.add("log link", func() error {
+			if err := os.Remove(logLinkPath); err != nil {
+				return err
+			}
+
+			return os.Remove(logDir)
+		})
+
+		if _, err := v3ioFUSEContainer.SetLabels(creator.getContext(),
+			map[string]string{logDirLabel: logDir}); err != nil {
+			return err
+		}
+	}
+
+	// wait on the exit before starting, so an immediate exit isn't missed
+	exitStatusChan, err := v3ioFUSETask.Wait(creator.getContext())
+	if err != nil {
+		return err
+	}
+
+	if err := v3ioFUSETask.Start(creator.getContext()); err != nil {
+		return err
+	}
+
+	// a process failing on its arguments exits immediately - fail rather than report a mount that's gone moments
+	// later
+	if options.StartupWait > 0 {
+		select {
+		case exitStatus := <-exitStatusChan:
+			return newExitedError(exitStatus.ExitCode(), getContainerLogTail(logDir, logFilePath))
+		case <-time.After(options.StartupWait):
+		}
+	}
+
+	createRollback.commit()
+
+	return nil
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+)
+
+// rollbackFakeCreator records which resources exist, failing the step named by failStep
+type rollbackFakeCreator struct {
+	failStep     string
+	exitCode     int
+	logFilePath  string
+	leaseHeld    bool
+	snapshot     bool
+	container    bool
+	task         bool
+	rolledBack   []string
+	leaseRelease int
+	labels       map[string]string
+}
+
+type rollbackFakeContainer struct {
+	containerd.Container
+	creator *rollbackFakeCreator
+}
+
+type rollbackFakeTask struct {
+	containerd.Task
+	creator *rollbackFakeCreator
+}
+
+var errInjected = errors.New("Injected failure")
+
+func (f *rollbackFakeCreator) getContext() context.Context {
+	return context.Background()
+}
+
+func (f *rollbackFakeCreator) getTaskIO(containerName string,
+	targetPath string,
+	logDriver string,
+	logAddress string) (cio.Creator, string, error) {
+
+	if f.failStep == "task io" {
+		return nil, "", errInjected
+	}
+
+	if err := ioutil.WriteFile(f.logFilePath, nil, 0644); err != nil {
+		return nil, "", err
+	}
+
+	return cio.NullIO, f.logFilePath, nil
+}
+
+func (f *rollbackFakeCreator) withLeasedCreator(containerName string) (containerCreator, func(), error) {
+	if f.failStep == "lease" {
+		return nil, nil, errInjected
+	}
+
+	f.leaseHeld = true
+
+	return f, func() {
+		f.leaseHeld = false
+		f.leaseRelease++
+	}, nil
+}
+
+func (f *rollbackFakeCreator) createContainer(image string,
+	containerName string,
+	targetPath string,
+	args []string,
+	logDir string,
+	options *ContainerOptions) (containerd.Container, error) {
+
+	// the snapshot is prepared before creating the container fails
+	f.snapshot = true
+
+	if f.failStep == "container" {
+		return nil, errInjected
+	}
+
+	f.container = true
+
+	return &rollbackFakeContainer{creator: f}, nil
+}
+
+func (f *rollbackFakeCreator) removeSnapshot(containerName string) error {
+	f.rolledBack = append(f.rolledBack, "snapshot")
+	f.snapshot = false
+
+	return nil
+}
+
+func (c *rollbackFakeContainer) NewTask(ctx context.Context,
+	ioCreate cio.Creator,
+	opts ...containerd.NewTaskOpts) (containerd.Task, error) {
+
+	if c.creator.failStep == "task" {
+		return nil, errInjected
+	}
+
+	c.creator.task = true
+
+	return &rollbackFakeTask{creator: c.creator}, nil
+}
+
+func (c *rollbackFakeContainer) Delete(ctx context.Context, opts ...containerd.DeleteOpts) error {
+	c.creator.rolledBack = append(c.creator.rolledBack, "container")
+	c.creator.container = false
+
+	return nil
+}
+
+func (c *rollbackFakeContainer) SetLabels(ctx context.Context, labels map[string]string) (map[string]string, error) {
+	c.creator.labels = labels
+
+	return labels, nil
+}
+
+// Pid returns the test's pid, whose cgroup file stands for the task's
+func (t *rollbackFakeTask) Pid() uint32 {
+	return uint32(os.Getpid())
+}
+
+func (t *rollbackFakeTask) Wait(ctx context.Context) (<-chan containerd.ExitStatus, error) {
+	if t.creator.failStep == "wait" {
+		return nil, errInjected
+	}
+
+	exitStatusChan := make(chan containerd.ExitStatus, 1)
+	if t.creator.failStep == "exit" {
+		exitStatusChan <- *containerd.NewExitStatus(uint32(t.creator.exitCode), time.Now(), nil)
+	}
+
+	return exitStatusChan, nil
+}
+
+func (t *rollbackFakeTask) Start(ctx context.Context) error {
+	if t.creator.failStep == "start" {
+		return errInjected
+	}
+
+	return nil
+}
+
+func (t *rollbackFakeTask) Delete(ctx context.Context, opts ...containerd.ProcessDeleteOpts) (*containerd.ExitStatus, error) {
+	t.creator.rolledBack = append(t.creator.rolledBack, "task")
+	t.creator.task = false
+
+	return nil, nil
+}
+
+func TestCreateContainerWithRollback(t *testing.T) {
+	for _, testCase := range []struct {
+		failStep           string
+		expectedRolledBack []string
+	}{
+		{failStep: "task io"},
+		{failStep: "lease", expectedRolledBack: []string{"log file"}},
+		{failStep: "container", expectedRolledBack: []string{"snapshot", "log file"}},
+		{failStep: "task", expectedRolledBack: []string{"container", "snapshot", "log file"}},
+		{failStep: "wait", expectedRolledBack: []string{"task", "container", "snapshot", "log file"}},
+		{failStep: "start", expectedRolledBack: []string{"task", "container", "snapshot", "log file"}},
+		{failStep: "exit", expectedRolledBack: []string{"task", "container", "snapshot", "log file"}},
+		{failStep: ""},
+	} {
+		t.Run(testCase.failStep, func(t *testing.T) {
+			creator := &rollbackFakeCreator{
+				failStep:    testCase.failStep,
+				exitCode:    1,
+				logFilePath: path.Join(t.TempDir(), "output.log"),
+			}
+
+			err := createContainerWithRollback(creator, "image", "container", "/target", nil, &ContainerOptions{
+				LogDriver:   LogDriverNone,
+				StartupWait: 100 * time.Millisecond,
+			})
+
+			if testCase.failStep == "" {
+				if err != nil {
+					t.Fatalf("Expected creation to succeed, got %s", err)
+				}
+
+				if !creator.snapshot || !creator.container || !creator.task || len(creator.rolledBack) != 0 {
+					t.Errorf("Expected nothing rolled back, got %v", creator.rolledBack)
+				}
+			} else if err == nil {
+				t.Fatalf("Expected creation to fail")
+			}
+
+			// the log file isn't a fake resource, check it's really removed
+			rolledBack := creator.rolledBack
+			if _, err := os.Stat(creator.logFilePath); os.IsNotExist(err) && testCase.failStep != "task io" {
+				rolledBack = append(rolledBack, "log file")
+			}
+
+			if !reflect.DeepEqual(rolledBack, testCase.expectedRolledBack) {
+				t.Errorf("Expected rolled back %v, got %v", testCase.expectedRolledBack, rolledBack)
+			}
+
+			if testCase.failStep != "" && (creator.snapshot || creator.container || creator.task) {
+				t.Errorf("Expected no resources left, got snapshot %v container %v task %v",
+					creator.snapshot, creator.container, creator.task)
+			}
+
+			if creator.leaseHeld {
+				t.Errorf("Expected the lease to be released")
+			}
+		})
+	}
+}
+
+func TestCreateContainerWithRollbackLogDir(t *testing.T) {
+	containerLogsDir := t.TempDir()
+
+	SetHostPaths(HostPaths{ContainerLogsDir: containerLogsDir})
+	defer SetHostPaths(HostPaths{})
+
+	for _, testCase := range []struct {
+		failStep        string
+		expectedLogLink bool
+	}{
+		{failStep: "start"},
+		{failStep: "", expectedLogLink: true},
+	} {
+		creator := &rollbackFakeCreator{
+			failStep:    testCase.failStep,
+			logFilePath: path.Join(t.TempDir(), "output.log"),
+		}
+
+		err := createContainerWithRollback(creator, "image", "container", "/target", nil, &ContainerOptions{
+			LogDriver: LogDriverFile,
+		})
+		if (err == nil) != (testCase.failStep == "") {
+			t.Fatalf("%q: unexpected error %v", testCase.failStep, err)
+		}
+
+		logDir, err := os.Readlink(getLogLinkPath("container"))
+		if !testCase.expectedLogLink {
+			if err == nil {
+				t.Errorf("%q: expected the log link to be rolled back", testCase.failStep)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Expected a log link: %s", err)
+		}
+
+		if path.Dir(logDir) != containerLogsDir || !strings.HasPrefix(path.Base(logDir), "flex-fuse-") {
+			t.Errorf("Expected the log link to point to a log directory, got %s", logDir)
+		}
+
+		if dirInfo, err := os.Stat(logDir); err != nil || !dirInfo.IsDir() {
+			t.Errorf("Expected the log directory to be created: %v", err)
+		}
+
+		if creator.labels[logDirLabel] != logDir {
+			t.Errorf("Expected the log directory %s to be labeled, got %v", logDir, creator.labels)
+		}
+	}
+}
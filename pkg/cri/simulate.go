@@ -0,0 +1,351 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// SimulatedMountSource is the source of the tmpfs mounts made by the simulate backend instead of FUSE mounts
+const SimulatedMountSource = "flex-fuse-simulate"
+
+// where the simulate backend keeps its containers if its runtime endpoint has no directory
+const defaultSimulateDir = "/run/flex-fuse/simulate"
+
+// size of each simulated mount
+const simulatedMountSize = "64m"
+
+// simulatedContainer is a container of the simulate backend, persisted as a JSON file
+type simulatedContainer struct {
+	Name       string            `json:"name"`
+	Image      string            `json:"image"`
+	TargetPath string            `json:"targetPath"`
+	Labels     map[string]string `json:"labels"`
+	StartedAt  time.Time         `json:"startedAt"`
+	Paused     bool              `json:"paused"`
+	Log        string            `json:"log"`
+}
+
+// Simulate mounts a tmpfs on the target path instead of running a FUSE container, so that the mount orchestration,
+// state, locking and cleanup can be exercised without an Iguazio cluster (e.g. on a laptop). Unlike the fake, its
+// containers persist across invocations, in the directory of its simulate://<dir> runtime endpoint. A simulated
+// container's process is running while its tmpfs is mounted
+type Simulate struct {
+	dir string
+}
+
+func NewSimulate(dir string) (*Simulate, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Simulate{
+		dir: dir,
+	}, nil
+}
+
+// newSimulateBackend is the factory of the simulate backend
+func newSimulateBackend(runtimeEndpoint string) (CRI, error) {
+	dir := strings.TrimPrefix(runtimeEndpoint, "simulate://")
+	if dir == "" {
+		dir = defaultSimulateDir
+	}
+
+	return NewSimulate(dir)
+}
+
+// CreateContainer creates a container
+func (s *Simulate) CreateContainer(image string,
+	containerName string,
+	targetPath string,
+	args []string,
+	options *ContainerOptions) error {
+	journal.Info("Creating simulated container", "containerName", containerName, "targetPath", targetPath)
+
+	if _, err := s.readContainer(containerName); err == nil {
+		return fmt.Errorf("Container %s already exists", containerName)
+	}
+
+	options.startStep(StepImageResolve)
+	options.reportImageResolved(image, "")
+
+	options.startStep(StepCreate)
+
+	targetPathMode := os.FileMode(defaultTargetPathMode)
+	if options != nil && options.TargetPathMode != 0 {
+		targetPathMode = options.TargetPathMode
+	}
+
+	if err := ensureDir(targetPath, targetPathMode); err != nil {
+		return fmt.Errorf("Target path %s is unusable: %s", targetPath, err)
+	}
+
+	labels := map[string]string{
+		OwnerLabel: OwnerLabelValue,
+	}
+
+	if options != nil {
+		for labelName, labelValue := range options.Labels {
+			labels[labelName] = labelValue
+		}
+	}
+
+	container := simulatedContainer{
+		Name:       containerName,
+		Image:      image,
+		TargetPath: targetPath,
+		Labels:     labels,
+		Log:        fmt.Sprintf("Simulating the FUSE mount of %s with a tmpfs\n", targetPath),
+	}
+
+	options.startStep(StepStart)
+	options.reportProgress(ProgressStarting)
+
+	if err := mountSimulated(targetPath); err != nil {
+		return err
+	}
+
+	container.StartedAt = time.Now()
+
+	return s.writeContainer(&container)
+}
+
+// PullImage pulls an image, with credentials if given - images aren't needed by simulated containers
+func (s *Simulate) PullImage(image string, credentials *RegistryCredentials) error {
+	return nil
+}
+
+// GetContainerStatus returns the status of a container, which may not exist
+func (s *Simulate) GetContainerStatus(containerName string) (*ContainerStatus, error) {
+	container, err := s.readContainer(containerName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ContainerStatus{}, nil
+		}
+
+		return nil, err
+	}
+
+	containerStatus := ContainerStatus{
+		Exists: true,
+		State:  "stopped",
+	}
+
+	if isSimulatedMount(container.TargetPath) {
+		containerStatus.State = "running"
+		containerStatus.StartedAt = container.StartedAt
+
+		if container.Paused {
+			containerStatus.State = "paused"
+		}
+	}
+
+	return &containerStatus, nil
+}
+
+// RemoveContainer removes a container. Its tmpfs is left for the caller to unmount, as a FUSE mount would be
+func (s *Simulate) RemoveContainer(containerName string) error {
+	journal.Debug("Removing simulated container", "containerName", containerName)
+
+	if err := os.Remove(s.getContainerPath(containerName)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Container %s not found", containerName)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// RestartContainer starts a container whose process exited, mounting its tmpfs again
+func (s *Simulate) RestartContainer(containerName string) error {
+	container, err := s.readContainer(containerName)
+	if err != nil {
+		return err
+	}
+
+	if !isSimulatedMount(container.TargetPath) {
+		if err := mountSimulated(container.TargetPath); err != nil {
+			return err
+		}
+	}
+
+	container.StartedAt = time.Now()
+	container.Paused = false
+
+	return s.writeContainer(container)
+}
+
+// PauseContainer freezes all processes of a container
+func (s *Simulate) PauseContainer(containerName string) error {
+	return s.updateContainer(containerName, func(container *simulatedContainer) {
+		container.Paused = true
+	})
+}
+
+// ResumeContainer thaws the processes of a paused container
+func (s *Simulate) ResumeContainer(containerName string) error {
+	return s.updateContainer(containerName, func(container *simulatedContainer) {
+		container.Paused = false
+	})
+}
+
+// ListContainers returns the names of containers starting with a prefix
+func (s *Simulate) ListContainers(namePrefix string) ([]string, error) {
+	containerPaths, err := filepath.Glob(path.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var containerNames []string
+	for _, containerPath := range containerPaths {
+		containerName := strings.TrimSuffix(filepath.Base(containerPath), ".json")
+		if strings.HasPrefix(containerName, namePrefix) {
+			containerNames = append(containerNames, containerName)
+		}
+	}
+
+	return containerNames, nil
+}
+
+// ListOwned returns the names of the containers created by the driver - all of the simulated containers
+func (s *Simulate) ListOwned() ([]string, error) {
+	return s.ListContainers("")
+}
+
+// GetContainerPid returns the pid of a container's running process - simulated containers have none
+func (s *Simulate) GetContainerPid(containerName string) (uint32, error) {
+	return 0, fmt.Errorf("Simulated container %s has no process", containerName)
+}
+
+// GetContainerLogTail returns up to a number of last lines of a container's log
+func (s *Simulate) GetContainerLogTail(containerName string, lines int) (string, error) {
+	container, err := s.readContainer(containerName)
+	if err != nil {
+		return "", err
+	}
+
+	logLines := strings.Split(strings.TrimRight(container.Log, "\n"), "\n")
+	if len(logLines) > lines {
+		logLines = logLines[len(logLines)-lines:]
+	}
+
+	return strings.Join(logLines, "\n"), nil
+}
+
+// GetContainerSpec returns the simulated container's record, as JSON
+func (s *Simulate) GetContainerSpec(containerName string) ([]byte, error) {
+	container, err := s.readContainer(containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(container, "", "  ")
+}
+
+// Close closes a CRI
+func (s *Simulate) Close() error {
+	return nil
+}
+
+func (s *Simulate) getContainerPath(containerName string) string {
+	return path.Join(s.dir, containerName+".json")
+}
+
+func (s *Simulate) readContainer(containerName string) (*simulatedContainer, error) {
+	content, err := ioutil.ReadFile(s.getContainerPath(containerName))
+	if err != nil {
+		return nil, err
+	}
+
+	container := simulatedContainer{}
+	if err := json.Unmarshal(content, &container); err != nil {
+		return nil, err
+	}
+
+	return &container, nil
+}
+
+// writeContainer writes a container's file through a temporary file, so that it's never read partially written
+func (s *Simulate) writeContainer(container *simulatedContainer) error {
+	content, err := json.Marshal(container)
+	if err != nil {
+		return err
+	}
+
+	containerPath := s.getContainerPath(container.Name)
+	if err := ioutil.WriteFile(containerPath+".tmp", content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(containerPath+".tmp", containerPath)
+}
+
+func (s *Simulate) updateContainer(containerName string, update func(*simulatedContainer)) error {
+	container, err := s.readContainer(containerName)
+	if err != nil {
+		return err
+	}
+
+	update(container)
+
+	return s.writeContainer(container)
+}
+
+func mountSimulated(targetPath string) error {
+	output, err := exec.Command("mount",
+		"-t", "tmpfs",
+		"-o", "size="+simulatedMountSize,
+		SimulatedMountSource,
+		targetPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to mount a tmpfs on %s: %s (%s)", targetPath, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// isSimulatedMount returns whether a simulated tmpfs is mounted on a path
+func isSimulatedMount(targetPath string) bool {
+	mounts, err := ioutil.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(mounts), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == SimulatedMountSource && fields[1] == targetPath && fields[2] == "tmpfs" {
+			return true
+		}
+	}
+
+	return false
+}
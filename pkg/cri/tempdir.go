@@ -0,0 +1,103 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	tempDirLock         sync.Mutex
+	tempDir             = os.TempDir()
+	tempDirMinFreeBytes int64
+)
+
+// SetTempDir sets the directory scratch files are created in, and the free space it must have before they are.
+// A non positive minimum disables the check
+func SetTempDir(dir string, minFreeBytes int64) {
+	tempDirLock.Lock()
+	defer tempDirLock.Unlock()
+
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	tempDir = dir
+	tempDirMinFreeBytes = minFreeBytes
+}
+
+// GetTempDir returns the directory scratch files are created in
+func GetTempDir() string {
+	tempDirLock.Lock()
+	defer tempDirLock.Unlock()
+
+	return tempDir
+}
+
+// CheckTempDirSpace verifies the temporary directory has the configured free space, so that a full directory fails
+// with an actionable error rather than ENOSPC midway (e.g. where /tmp is a small tmpfs)
+func CheckTempDirSpace() error {
+	tempDirLock.Lock()
+	dir, minFreeBytes := tempDir, tempDirMinFreeBytes
+	tempDirLock.Unlock()
+
+	if minFreeBytes <= 0 {
+		return nil
+	}
+
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(dir, &statfs); err != nil {
+		return fmt.Errorf("Failed to get the free space of temporary directory %s: %s", dir, err)
+	}
+
+	freeBytes := int64(statfs.Bavail) * int64(statfs.Bsize)
+	if freeBytes < minFreeBytes {
+		return fmt.Errorf("Temporary directory %s has %d MB free, less than the required %d MB - "+
+			"free space or set temp_dir to a larger filesystem",
+			dir,
+			freeBytes>>20,
+			minFreeBytes>>20)
+	}
+
+	return nil
+}
+
+// CreateTempFile creates a scratch file in the temporary directory, after checking its free space
+func CreateTempFile(pattern string) (*os.File, error) {
+	if err := CheckTempDirSpace(); err != nil {
+		return nil, err
+	}
+
+	return ioutil.TempFile(GetTempDir(), pattern)
+}
+
+// CreateTempDir creates a scratch directory in the temporary directory, after checking its free space
+func CreateTempDir(pattern string) (string, error) {
+	if err := CheckTempDirSpace(); err != nil {
+		return "", err
+	}
+
+	return ioutil.TempDir(GetTempDir(), pattern)
+}
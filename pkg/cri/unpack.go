@@ -0,0 +1,127 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/snapshots"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/identity"
+)
+
+// interval of logging the progress of unpacking an image
+const unpackProgressInterval = 5 * time.Second
+
+// unpackImage unpacks an image with the FUSE containers' snapshotter, logging its progress, and verifies the
+// unpacked chain of snapshots
+func (c *Containerd) unpackImage(ctx context.Context, image containerd.Image) error {
+	snapshotter, err := c.getSnapshotter()
+	if err != nil {
+		return err
+	}
+
+	diffIDs, err := image.RootFS(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to get layers of %s: %s", image.Name(), err)
+	}
+
+	chainIDs := identity.ChainIDs(diffIDs)
+	snapshotService := c.containerdClient.SnapshotService(snapshotter)
+
+	if err := c.checkUnpackDiskSpace(ctx, image, countUnpackedLayers(ctx, snapshotService, chainIDs)); err != nil {
+		return err
+	}
+
+	journal.Debug("Unpacking image", "image", image.Name(), "snapshotter", snapshotter, "layers", len(chainIDs))
+
+	unpackStartedAt := time.Now()
+	unpackDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(unpackProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-unpackDone:
+				return
+			case <-ticker.C:
+				journal.Info("Unpacking image",
+					"image", image.Name(),
+					"unpackedLayers", countUnpackedLayers(ctx, snapshotService, chainIDs),
+					"layers", len(chainIDs),
+					"elapsed", time.Since(unpackStartedAt).String())
+			}
+		}
+	}()
+
+	err = image.Unpack(ctx, snapshotter)
+	close(unpackDone)
+
+	if err != nil {
+		return err
+	}
+
+	if unpackedLayers := countUnpackedLayers(ctx, snapshotService, chainIDs); unpackedLayers != len(chainIDs) {
+		return fmt.Errorf("Only %d of the %d layers of %s are unpacked with the %s snapshotter",
+			unpackedLayers,
+			len(chainIDs),
+			image.Name(),
+			snapshotter)
+	}
+
+	journal.Debug("Unpacked image",
+		"image", image.Name(),
+		"snapshotter", snapshotter,
+		"duration", time.Since(unpackStartedAt).String())
+
+	return nil
+}
+
+// ensureUnpacked unpacks an image if it isn't unpacked with the FUSE containers' snapshotter, so that its chain
+// is verified before containers are created from it
+func (c *Containerd) ensureUnpacked(ctx context.Context, image containerd.Image) error {
+	snapshotter, err := c.getSnapshotter()
+	if err != nil {
+		return err
+	}
+
+	if unpacked, err := image.IsUnpacked(ctx, snapshotter); err == nil && unpacked {
+		return nil
+	}
+
+	return c.unpackImage(ctx, image)
+}
+
+// countUnpackedLayers returns the number of layers, bottom up, whose snapshots exist
+func countUnpackedLayers(ctx context.Context, snapshotService snapshots.Snapshotter, chainIDs []digest.Digest) int {
+	for layerIdx, chainID := range chainIDs {
+		if _, err := snapshotService.Stat(ctx, chainID.String()); err != nil {
+			return layerIdx
+		}
+	}
+
+	return len(chainIDs)
+}
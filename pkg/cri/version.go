@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/probe"
+)
+
+// containerd versions the driver was tested with, from minContainerdVersion up to (excluding)
+// maxContainerdVersion
+var (
+	minContainerdVersion = [3]int{1, 6, 0}
+	maxContainerdVersion = [3]int{2, 1, 0}
+)
+
+var containerdVersionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+var (
+	versionCheckLock sync.Mutex
+	versionCheck     = "fail"
+)
+
+// SetVersionCheck sets what creating containers and pulling images do when containerd's version is outside the
+// tested range - "fail", "warn" or "off". Other operations only warn, so that existing mounts can still be unmounted
+// and cleaned up after containerd is upgraded
+func SetVersionCheck(mode string) {
+	versionCheckLock.Lock()
+	defer versionCheckLock.Unlock()
+
+	versionCheck = mode
+}
+
+// checkVersion verifies containerd's version is in the tested range, rather than failing with obscure API errors
+// mid-mount. Unless enforced, an unsupported version is only logged. The version is cached as a probe
+func (c *Containerd) checkVersion(enforce bool) error {
+	versionCheckLock.Lock()
+	mode := versionCheck
+	versionCheckLock.Unlock()
+
+	if mode == "off" {
+		return nil
+	}
+
+	var serverVersion string
+	if err := probe.Cached("containerd-version", &serverVersion, func() error {
+		version, err := c.containerdClient.Version(c.containerdContext)
+		if err != nil {
+			return err
+		}
+
+		serverVersion = version.Version
+		return nil
+	}); err != nil {
+		journal.Warn("Failed to get containerd version", "err", err.Error())
+		return nil
+	}
+
+	err := checkContainerdVersion(serverVersion)
+	if err == nil {
+		return nil
+	}
+
+	if mode == "warn" || !enforce {
+		journal.Warn("Unsupported containerd version", "err", err.Error())
+		return nil
+	}
+
+	return err
+}
+
+// checkContainerdVersion returns an error if a version isn't in the tested range. Versions that can't be parsed
+// (e.g. development builds) are assumed to be supported
+func checkContainerdVersion(serverVersion string) error {
+	versionParts := containerdVersionPattern.FindStringSubmatch(serverVersion)
+	if versionParts == nil {
+		journal.Debug("Unknown containerd version format, skipping check", "version", serverVersion)
+		return nil
+	}
+
+	var version [3]int
+	for partIdx := range version {
+		version[partIdx], _ = strconv.Atoi(versionParts[partIdx+1])
+	}
+
+	if compareVersions(version, minContainerdVersion) < 0 || compareVersions(version, maxContainerdVersion) >= 0 {
+		return fmt.Errorf("containerd %s is not supported, the driver supports versions %s up to %s. "+
+			"Upgrade containerd, or set containerd_version_check to \"warn\" to run anyway",
+			serverVersion,
+			formatVersion(minContainerdVersion),
+			formatVersion(maxContainerdVersion))
+	}
+
+	return nil
+}
+
+func compareVersions(a [3]int, b [3]int) int {
+	for partIdx := range a {
+		if a[partIdx] != b[partIdx] {
+			return a[partIdx] - b[partIdx]
+		}
+	}
+
+	return 0
+}
+
+func formatVersion(version [3]int) string {
+	return fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2])
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package cri
+
+import (
+	"testing"
+)
+
+func TestCheckContainerdVersion(t *testing.T) {
+	for _, testCase := range []struct {
+		version   string
+		supported bool
+	}{
+		{version: "v1.6.0", supported: true},
+		{version: "1.7.22", supported: true},
+		{version: "v2.0.3-k3s1", supported: true},
+		{version: "v1.5.13", supported: false},
+		{version: "v2.1.0", supported: false},
+		{version: "devel", supported: true},
+	} {
+		err := checkContainerdVersion(testCase.version)
+		if (err == nil) != testCase.supported {
+			t.Errorf("Expected version %s supported to be %v, got error %v", testCase.version, testCase.supported, err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// events alerts are sent for
+const (
+	AlertMountFailed = "MountFailed"
+	AlertCrashLoop   = "CrashLoop"
+)
+
+const alertsName = "alerts.json"
+
+// Alert is the JSON payload sent to the alert webhook and exec hook
+type Alert struct {
+	Event      string    `json:"event"`
+	Node       string    `json:"node,omitempty"`
+	TargetPath string    `json:"targetPath"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Pod        string    `json:"pod,omitempty"`
+	ErrorCode  string    `json:"errorCode,omitempty"`
+	Message    string    `json:"message"`
+	TraceID    string    `json:"traceId"`
+	Time       time.Time `json:"time"`
+}
+
+// SendAlert sends an alert to the configured webhook and exec hook, unless the same event was alerted for the
+// target path within the repeat interval
+func (m *Mounter) SendAlert(alert *Alert) {
+	alertsConfig := m.Config.Alerts
+	if alertsConfig.WebhookURL == "" && len(alertsConfig.Exec) == 0 {
+		return
+	}
+
+	alert.Node = os.Getenv("NODE_NAME")
+	alert.TraceID = m.logger.TraceID()
+	alert.Time = time.Now()
+
+	if !m.shouldAlert(alert) {
+		m.logger.Debug("Alert was sent recently, skipping", "event", alert.Event, "targetPath", alert.TargetPath)
+		return
+	}
+
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		m.logger.Warn("Failed to encode alert", "err", err.Error())
+		return
+	}
+
+	timeout := time.Duration(alertsConfig.TimeoutSeconds) * time.Second
+
+	if alertsConfig.WebhookURL != "" {
+		if err := postAlert(alertsConfig.WebhookURL, payload, timeout); err != nil {
+			m.logger.Warn("Failed to send alert to webhook", "event", alert.Event, "err", err.Error())
+		}
+	}
+
+	if len(alertsConfig.Exec) != 0 {
+		if err := execAlert(alertsConfig.Exec, payload, timeout); err != nil {
+			m.logger.Warn("Failed to run alert hook", "event", alert.Event, "err", err.Error())
+		}
+	}
+
+	m.logger.Info("Sent alert", "event", alert.Event, "targetPath", alert.TargetPath)
+}
+
+// shouldAlert returns whether the alert's event wasn't alerted for its target path within the repeat interval,
+// recording it as sent if so
+func (m *Mounter) shouldAlert(alert *Alert) bool {
+	repeatInterval := time.Duration(m.Config.Alerts.RepeatIntervalSeconds) * time.Second
+	if repeatInterval < 0 {
+		return true
+	}
+
+	key := fmt.Sprintf("%s:%s", alert.Event, alert.TargetPath)
+	should := true
+
+	sentAt := map[string]time.Time{}
+	if err := m.state.UpdateJSON(alertsName, &sentAt, func() error {
+		for sentKey, sentKeyAt := range sentAt {
+			if alert.Time.Sub(sentKeyAt) >= repeatInterval {
+				delete(sentAt, sentKey)
+			}
+		}
+
+		if _, found := sentAt[key]; found {
+			should = false
+		} else {
+			sentAt[key] = alert.Time
+		}
+
+		return nil
+	}); err != nil {
+		m.logger.Debug("Failed to update sent alerts", "err", err.Error())
+	}
+
+	return should
+}
+
+// reportMountFailed alerts about a failed mount
+func (m *Mounter) reportMountFailed(result *Result) {
+	alert := Alert{
+		Event:      AlertMountFailed,
+		TargetPath: result.TargetPath,
+		ErrorCode:  result.ErrorCode,
+		Message:    result.Message,
+	}
+
+	if m.operation != nil {
+		alert.Namespace = m.operation.namespace
+		alert.Pod = m.operation.podName
+	}
+
+	m.SendAlert(&alert)
+}
+
+func postAlert(webhookURL string, payload []byte, timeout time.Duration) error {
+	httpClient := http.Client{
+		Timeout: timeout,
+	}
+
+	response, err := httpClient.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Webhook responded with %s", response.Status)
+	}
+
+	return nil
+}
+
+// execAlert runs the hook with the payload on its stdin
+func execAlert(command []string, payload []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	hookCommand := exec.CommandContext(ctx, command[0], command[1:]...)
+	hookCommand.Stdin = bytes.NewReader(payload)
+
+	if output, err := hookCommand.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, bytes.TrimSpace(output))
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+
+package flex
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+)
+
+// Attach returns the volume name as the device, as there's nothing to attach
+func (m *Mounter) Attach(specString string, nodeName string) *Response {
+	m.logger.Debug("Attaching", "nodeName", nodeName)
+
+	spec := Spec{}
+	if err := json.Unmarshal([]byte(specString), &spec); err != nil {
+		return NewFailResponse("Failed to unmarshal spec", err)
+	}
+
+	result := NewSuccessResponse("Nothing to attach")
+	result.Device = spec.Name
+
+	return result
+}
+
+func (m *Mounter) Detach(device string, nodeName string) *Response {
+	m.logger.Debug("Detaching", "device", device, "nodeName", nodeName)
+
+	return NewSuccessResponse("Nothing to detach")
+}
+
+func (m *Mounter) WaitForAttach(device string) *Response {
+	result := NewSuccessResponse("Attached")
+	result.Device = device
+
+	return result
+}
+
+func (m *Mounter) IsAttached(nodeName string) *Response {
+	result := NewSuccessResponse("Attached")
+	result.Attached = true
+
+	return result
+}
+
+// MountDevice creates the FUSE container on the device mount path, shared by all pods using the volume
+func (m *Mounter) MountDevice(deviceMountPath string, specString string) *Response {
+	return m.runOperation("mountdevice", deviceMountPath, func() *Response {
+		return m.mountDevice(deviceMountPath, specString)
+	})
+}
+
+func (m *Mounter) UnmountDevice(deviceMountPath string) *Response {
+	return m.runOperation("unmountdevice", deviceMountPath, func() *Response {
+		m.logger.Debug("Unmounting device", "deviceMountPath", deviceMountPath)
+
+		return m.unmountFUSE(deviceMountPath)
+	})
+}
+
+func (m *Mounter) mountDevice(deviceMountPath string, specString string) *Response {
+	m.logger.Debug("Mounting device", "deviceMountPath", deviceMountPath)
+
+	specString, err := translateOptions(specString)
+	if err != nil {
+		return NewFailResponse("Failed to unmarshal spec", err)
+	}
+
+	if err := validateOptionNames(specString); err != nil {
+		return NewFailResponse("Mount device failed validation", err)
+	}
+
+	spec := Spec{}
+	if err := json.Unmarshal([]byte(specString), &spec); err != nil {
+		return NewFailResponse("Failed to unmarshal spec", err)
+	}
+
+	if err := m.applyProfile(&spec); err != nil {
+		return NewFailResponse("Mount device failed validation", err)
+	}
+
+	if err := spec.validate(); err != nil {
+		return NewFailResponse("Mount device failed validation", err)
+	}
+
+	if _, err := m.getImage(&spec); err != nil {
+		return NewFailResponse("Mount device failed validation", err)
+	}
+
+	if err := m.checkDraining(); err != nil {
+		return NewFailResponse("Mount device refused", err)
+	}
+
+	if err := m.setMountTimeout(&spec); err != nil {
+		return NewFailResponse("Invalid mount timeout", err)
+	}
+
+	if err := os.MkdirAll(deviceMountPath, 0750); err != nil {
+		return NewFailResponse(fmt.Sprintf("Failed to create device mount path %s", deviceMountPath), err)
+	}
+
+	return m.mountFUSE(&spec, deviceMountPath)
+}
+
+func (m *Mounter) mountFromDevice(spec *Spec, targetPath string) *Response {
+	deviceMountPath := path.Join(m.Config.DeviceMountRoot, spec.Name)
+
+	if !isMountPoint(deviceMountPath) {
+		return NewFailResponse(fmt.Sprintf("Device mount path %s is not mounted", deviceMountPath), nil)
+	}
+
+	if isMountPoint(targetPath) {
+		return NewSuccessResponse(fmt.Sprintf("Already mounted: %s", targetPath))
+	}
+
+	m.logger.Info("Bind mounting device", "deviceMountPath", deviceMountPath, "target", targetPath)
+
+	if response := bindMount(deviceMountPath, targetPath); response != nil {
+		return response
+	}
+
+	return NewSuccessResponse("Successfully mounted from device")
+}
+
+// bindMount bind mounts a source path on a target path, returning a failure response if it fails
+func bindMount(sourcePath string, targetPath string) *Response {
+	if err := os.MkdirAll(targetPath, 0750); err != nil {
+		return NewFailResponse(fmt.Sprintf("Failed to create target %s", targetPath), err)
+	}
+
+	if output, err := exec.Command("mount", "--bind", sourcePath, targetPath).CombinedOutput(); err != nil {
+		return NewFailResponse(fmt.Sprintf("Failed to bind mount %s", sourcePath),
+			fmt.Errorf("%s: %s", err, string(output)))
+	}
+
+	return nil
+}
+
+func (m *Mounter) unmountFromDevice(targetPath string) *Response {
+	if !isMountPoint(targetPath) {
+		return NewSuccessResponse(fmt.Sprintf("%s Not a mountpoint, nothing to do", targetPath))
+	}
+
+	if output, err := exec.Command("umount", targetPath).CombinedOutput(); err != nil {
+		return NewFailResponse(fmt.Sprintf("Failed to unmount %s", targetPath),
+			fmt.Errorf("%s: %s", err, string(output)))
+	}
+
+	if err := os.Remove(targetPath); err != nil {
+		return NewFailResponse(fmt.Sprintf("Could not remove directory %s", targetPath), err)
+	}
+
+	return NewSuccessResponse("Successfully unmounted")
+}
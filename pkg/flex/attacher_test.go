@@ -0,0 +1,63 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"path"
+	"strings"
+	"testing"
+)
+
+// mountdevice validates a volume's options as mount does, so that a volume spec is valid regardless of attaching
+func TestMountDeviceValidation(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		specString      string
+		expectedMessage string
+	}{
+		{
+			name:            "misspelled option",
+			specString:      `{"accessKey": "key", "container": "bigdata", "subpath": "/data"}`,
+			expectedMessage: "subpath",
+		},
+		{
+			name:            "invalid mount timeout",
+			specString:      `{"accessKey": "key", "container": "bigdata", "mountTimeout": "soon"}`,
+			expectedMessage: "mountTimeout",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			mounter, fake := newFakeMounter(t)
+			deviceMountPath := path.Join(t.TempDir(), "devices", "data")
+
+			response := mounter.MountDevice(deviceMountPath, testCase.specString)
+			if response.Status != "Failure" || !strings.Contains(response.Message, testCase.expectedMessage) {
+				t.Fatalf("Expected mountdevice to fail with %q, got %s: %s",
+					testCase.expectedMessage,
+					response.Status,
+					response.Message)
+			}
+
+			if containerNames, _ := fake.ListOwned(); len(containerNames) != 0 {
+				t.Errorf("Expected no containers, got %v", containerNames)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+)
+
+// GetInitCapabilities returns the capabilities reported to kubelet on init, computed from the configuration and the
+// node rather than fixed, so that kubelet handles the volumes the way the node can serve them. A nil configuration
+// reports the driver as not attachable
+func GetInitCapabilities(driverConfig *config.Config) map[string]interface{} {
+	attach := false
+	supportsMetrics := true
+
+	if driverConfig != nil {
+		attach = driverConfig.Attach
+
+		// kubelet's statfs of the volumes has no timeout, so only let it collect metrics while the monitor probes
+		// the mounts and handles stale ones
+		supportsMetrics = driverConfig.StaleMountProbe.IntervalSeconds != -1
+	}
+
+	return map[string]interface{}{
+		"attach": attach,
+
+		// the FUSE client owns the files of the mount - kubelet's recursive chown would walk the whole container
+		"fsGroup": false,
+
+		// relabeling only applies on SELinux nodes, where the volume must be labeled for pods to access it
+		"selinuxRelabel": cri.IsSELinuxEnabled(),
+
+		"supportsMetrics": supportsMetrics,
+	}
+}
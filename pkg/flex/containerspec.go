@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/version"
+)
+
+const containerSpecsDir = "specs"
+
+// ContainerSpecRecord is the spec a FUSE container was created with, as rendered by the runtime (the OCI spec with
+// containerd), so that what ran can be compared against what's expected - e.g. across upgrades of the driver or
+// changes of its configuration. Secrets of the volume are redacted
+type ContainerSpecRecord struct {
+	ContainerName string          `json:"containerName"`
+	TargetPath    string          `json:"targetPath"`
+	DriverVersion string          `json:"driverVersion"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	Spec          json.RawMessage `json:"spec"`
+}
+
+// GetContainerSpecRecord returns the spec record of a FUSE container, or nil if it has none
+func (m *Mounter) GetContainerSpecRecord(containerName string) *ContainerSpecRecord {
+	containerSpecRecord := ContainerSpecRecord{}
+	if err := m.state.ReadJSON(getContainerSpecName(containerName), &containerSpecRecord); err != nil {
+		return nil
+	}
+
+	return &containerSpecRecord
+}
+
+// recordContainerSpec records the spec a FUSE container was created with. Failing to is only logged
+func (m *Mounter) recordContainerSpec(criInstance cri.CRI, containerName string, targetPath string, spec *Spec) {
+	containerSpec, err := criInstance.GetContainerSpec(containerName)
+	if err != nil {
+		m.logger.Warn("Failed to get container spec", "containerName", containerName, "err", err.Error())
+		return
+	}
+
+	containerSpecRecord := ContainerSpecRecord{
+		ContainerName: containerName,
+		TargetPath:    targetPath,
+		DriverVersion: version.Get().Version,
+		CreatedAt:     time.Now(),
+		Spec:          redactSecrets(containerSpec, spec),
+	}
+
+	if err := m.state.WriteJSON(getContainerSpecName(containerName), &containerSpecRecord); err != nil {
+		m.logger.Warn("Failed to record container spec", "containerName", containerName, "err", err.Error())
+	}
+}
+
+func (m *Mounter) removeContainerSpecRecord(containerName string) {
+	if err := m.state.Remove(getContainerSpecName(containerName)); err != nil {
+		m.logger.Warn("Failed to remove container spec record", "containerName", containerName, "err", err.Error())
+	}
+}
+
+// redactSecrets replaces the volume's access key or session key (passed to the FUSE client in its arguments) in a
+// spec
+func redactSecrets(containerSpec []byte, spec *Spec) []byte {
+	redactedSpec := string(containerSpec)
+
+	for _, secret := range []string{spec.GetAccessKey(), spec.OverrideAccessKey, spec.decodeOrDefault(spec.AccessKey)} {
+		if secret != "" {
+			redactedSpec = strings.Replace(redactedSpec, secret, "<redacted>", -1)
+		}
+	}
+
+	return []byte(redactedSpec)
+}
+
+func getContainerSpecName(containerName string) string {
+	return path.Join(containerSpecsDir, containerName+".json")
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/cri"
+)
+
+// Check is the result of a diagnostic of the node's mounts and their prerequisites
+type Check struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+
+	// Fix remediates a failed check, if the doctor knows how
+	Fix *Fix `json:"fix,omitempty"`
+}
+
+// RunChecks diagnoses the node - FUSE support, the container runtime and the state of every recorded mount
+func (m *Mounter) RunChecks() []*Check {
+	var checks []*Check
+
+	if err := getFUSEError(); err != nil {
+		checks = append(checks, &Check{Name: "fuse", Message: err.Error(), Fix: m.getFUSEFix()})
+	} else {
+		checks = append(checks, &Check{Name: "fuse", Passed: true, Message: "FUSE is available"})
+	}
+
+	checks = append(checks, checkNodeLayout())
+	checks = append(checks, m.checkHostDirs()...)
+	checks = append(checks, checkFUSEConfigPermissions())
+
+	if err := cri.CheckTempDirSpace(); err != nil {
+		checks = append(checks, &Check{Name: "temp_dir", Message: err.Error()})
+	} else {
+		checks = append(checks, &Check{Name: "temp_dir", Passed: true, Message: fmt.Sprintf("%s has enough free space",
+			cri.GetTempDir())})
+	}
+
+	if pendingUnmounts, err := m.ListPendingUnmounts(); err == nil {
+		for _, pendingUnmount := range pendingUnmounts {
+			checks = append(checks, &Check{
+				Name: "unmount " + pendingUnmount.TargetPath,
+				Message: fmt.Sprintf("Unmount started at %s failed after %v: %s. Resume it with unmount --resume",
+					pendingUnmount.StartedAt.Format(time.RFC3339),
+					pendingUnmount.Steps,
+					pendingUnmount.Error),
+			})
+		}
+	}
+
+	mountStatuses, err := m.ListMountStatuses()
+	if err != nil {
+		return append(checks, &Check{Name: "runtime", Message: fmt.Sprintf("Failed to query mounts: %s", err)})
+	}
+
+	checks = append(checks, &Check{Name: "runtime", Passed: true, Message: "The container runtime is reachable"})
+
+	for _, mountStatus := range mountStatuses {
+		checks = append(checks, &Check{
+			Name:    "mount " + mountStatus.TargetPath,
+			Passed:  mountStatus.Healthy(),
+			Message: describeMountStatus(mountStatus),
+		})
+	}
+
+	return checks
+}
+
+// checkNodeLayout reports the node's distribution and whether the driver is installed where its kubelet looks
+// for it (e.g. a --volume-plugin-dir other than the default)
+func checkNodeLayout() *Check {
+	nodeLayout := cri.GetNodeLayout()
+	installPath := path.Join(nodeLayout.VolumePluginDir, "v3io~fuse", "fuse")
+
+	message := fmt.Sprintf("Node is %s, with containerd at %s and kubelet's root directory at %s",
+		nodeLayout.Distro,
+		nodeLayout.ContainerdSocket,
+		nodeLayout.KubeletRootDir)
+
+	if _, err := os.Stat(installPath); err != nil {
+		return &Check{Name: "layout", Message: fmt.Sprintf("%s, but the driver isn't installed at %s", message, installPath)}
+	}
+
+	return &Check{Name: "layout", Passed: true, Message: fmt.Sprintf("%s, and the driver is installed at %s",
+		message,
+		installPath)}
+}
+
+func describeMountStatus(mountStatus *MountStatus) string {
+	switch {
+	case mountStatus.Error != "":
+		return fmt.Sprintf("Failed to query container %s: %s", mountStatus.ContainerName, mountStatus.Error)
+	case mountStatus.ContainerState == "missing":
+		return fmt.Sprintf("Container %s does not exist", mountStatus.ContainerName)
+	case mountStatus.ContainerState == "stopped":
+		return fmt.Sprintf("Container %s exited with status %d", mountStatus.ContainerName, mountStatus.ExitCode)
+	case mountStatus.ContainerState == "created":
+		return fmt.Sprintf("Container %s was created, but its process didn't start", mountStatus.ContainerName)
+	case !mountStatus.Mounted:
+		return fmt.Sprintf("Container %s is running, but the target path isn't mounted", mountStatus.ContainerName)
+	}
+
+	if mountStatus.ContainerState == "paused" {
+		return fmt.Sprintf("Frozen, served by container %s (pid %d)", mountStatus.ContainerName, mountStatus.Pid)
+	}
+
+	return fmt.Sprintf("Served by container %s (pid %d)", mountStatus.ContainerName, mountStatus.Pid)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// marks the node as draining while it exists
+const drainingName = "draining.json"
+
+type drainingState struct {
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// Drain marks the node as draining, refusing new mounts, and flushes the active mounts. It returns the
+// number of mounts that failed to flush
+func (m *Mounter) Drain() (int, error) {
+	if err := m.state.WriteJSON(drainingName, &drainingState{StartedAt: time.Now()}); err != nil {
+		return 0, fmt.Errorf("Failed to mark node as draining: %s", err)
+	}
+
+	m.logger.Info("Node is draining")
+
+	return m.FlushMounts()
+}
+
+// FlushMounts flushes the data of all mounts, returning the number of mounts that failed to flush
+func (m *Mounter) FlushMounts() (int, error) {
+	mountRecords, err := m.ListMountRecords()
+	if err != nil {
+		return 0, err
+	}
+
+	failedSyncs := 0
+	for _, mountRecord := range mountRecords {
+		if err := syncFilesystem(mountRecord.TargetPath); err != nil {
+			m.logger.Warn("Failed to flush mount", "targetPath", mountRecord.TargetPath, "err", err.Error())
+			failedSyncs++
+		}
+	}
+
+	return failedSyncs, nil
+}
+
+// Undrain allows new mounts again
+func (m *Mounter) Undrain() error {
+	m.logger.Info("Node is no longer draining")
+
+	return m.state.Remove(drainingName)
+}
+
+// IsDraining returns whether the node is draining
+func (m *Mounter) IsDraining() bool {
+	_, err := os.Stat(m.state.Path(drainingName))
+	return err == nil
+}
+
+// checkDraining returns an error if new mounts are refused as the node is draining
+func (m *Mounter) checkDraining() error {
+	if m.IsDraining() {
+		return fmt.Errorf("Node is draining for maintenance, refusing new mounts (run \"fuse drain --cancel\" to allow them)")
+	}
+
+	return nil
+}
+
+func syncFilesystem(targetPath string) error {
+	file, err := os.Open(targetPath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close() // nolint: errcheck
+
+	return unix.Syncfs(int(file.Fd()))
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/kube"
+)
+
+// progress of a mount emitted as events on its pod
+const (
+	ProgressPulling   = cri.ProgressPulling
+	ProgressCreating  = "Creating"
+	ProgressStarting  = cri.ProgressStarting
+	ProgressVerifying = "Verifying"
+	ProgressReady     = "Ready"
+)
+
+var progressMessages = map[string]string{
+	ProgressPulling:   "Pulling the v3io FUSE image",
+	ProgressCreating:  "Creating the v3io FUSE container",
+	ProgressStarting:  "Starting the v3io FUSE container",
+	ProgressVerifying: "Waiting for the FUSE mount",
+}
+
+// how long a finished mount waits for its events to be sent
+const mountEventsFlushTimeout = 3 * time.Second
+
+// mountEvents emits the progress of a mount as events on its pod, once the mount has run for a delay, so that
+// users can see what a pod stuck in ContainerCreating is waiting for
+type mountEvents struct {
+	lock       sync.Mutex
+	kubeClient *kube.Client
+	pod        kube.ObjectReference
+	startedAt  time.Time
+	progress   string
+	emitting   bool
+	timer      *time.Timer
+	pending    sync.WaitGroup
+}
+
+// startMountEvents starts tracking the progress of a mount for a pod
+func (m *Mounter) startMountEvents(spec *Spec) {
+	if m.Config.MountEvents.DelaySeconds < 0 || spec.PodName == "" {
+		return
+	}
+
+	kubeClient, err := m.newKubeClient(m.Config.MountEvents.APIServer, m.Config.MountEvents.CredentialsDir)
+	if err != nil {
+		m.logger.Debug("Can't emit mount events", "err", err.Error())
+		return
+	}
+
+	events := mountEvents{
+		kubeClient: kubeClient,
+		pod: kube.ObjectReference{
+			Kind:      "Pod",
+			Namespace: spec.Namespace,
+			Name:      spec.PodName,
+			UID:       spec.PodUID,
+		},
+		startedAt: time.Now(),
+	}
+
+	events.timer = time.AfterFunc(time.Duration(m.Config.MountEvents.DelaySeconds)*time.Second, events.startEmitting)
+	m.events = &events
+}
+
+// reportProgress records the progress of the current mount, emitting it if the mount is slow
+func (m *Mounter) reportProgress(progress string) {
+	if m.events != nil {
+		m.events.report(progress)
+	}
+}
+
+// stopMountEvents emits that a slow mount is ready if it succeeded, and waits for the events to be sent
+func (m *Mounter) stopMountEvents(response *Response) {
+	if m.events == nil {
+		return
+	}
+
+	events := m.events
+	m.events = nil
+
+	events.stop(response.Status == "Success")
+}
+
+func (e *mountEvents) report(progress string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.progress = progress
+	if e.emitting {
+		e.emit(progress, progressMessages[progress])
+	}
+}
+
+func (e *mountEvents) startEmitting() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.emitting = true
+	if e.progress != "" {
+		e.emit(e.progress, progressMessages[e.progress])
+	}
+}
+
+func (e *mountEvents) stop(succeeded bool) {
+	e.timer.Stop()
+
+	e.lock.Lock()
+	if e.emitting && succeeded {
+		e.emit(ProgressReady, fmt.Sprintf("Mounted after %s", time.Since(e.startedAt).Round(time.Second)))
+	}
+	e.emitting = false
+	e.lock.Unlock()
+
+	flushed := make(chan struct{})
+	go func() {
+		e.pending.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+	case <-time.After(mountEventsFlushTimeout):
+		journal.Debug("Timed out sending mount events", "pod", e.pod.Name)
+	}
+}
+
+// emit sends an event in the background. Must be called with the lock held
+func (e *mountEvents) emit(progress string, message string) {
+	e.pending.Add(1)
+	go func() {
+		defer e.pending.Done()
+
+		if err := e.kubeClient.RecordEvent(e.pod, "Normal", "FUSE"+progress, message); err != nil {
+			journal.Debug("Failed to emit mount event", "progress", progress, "err", err.Error())
+		}
+	}()
+}
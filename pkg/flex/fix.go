@@ -0,0 +1,200 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// Fix remediates the cause of a failed check. Its changes are listed as a diff of the node, so that a dry run shows
+// what applying it would do
+type Fix struct {
+	Changes []string `json:"changes"`
+	apply   func() error
+}
+
+// Apply makes the fix's changes on the node
+func (f *Fix) Apply() error {
+	journal.Info("Applying fix", "changes", f.Changes)
+
+	return f.apply()
+}
+
+// a directory the driver expects on the host, and the mode it's created with
+type hostDir struct {
+	path string
+	mode os.FileMode
+}
+
+// getFUSEFix loads the fuse kernel module, unless the node's OS doesn't allow loading modules
+func (m *Mounter) getFUSEFix() *Fix {
+	if m.Config.ImmutableOS {
+		return nil
+	}
+
+	return &Fix{
+		Changes: []string{"+ fuse kernel module (modprobe fuse)"},
+		apply: func() error {
+			if output, err := exec.Command("modprobe", "fuse").CombinedOutput(); err != nil {
+				return fmt.Errorf("modprobe fuse failed: %s (%s)", err, strings.TrimSpace(string(output)))
+			}
+
+			return getFUSEError()
+		},
+	}
+}
+
+// checkHostDirs verifies the directories the driver writes to exist, fixing missing ones by creating them
+func (m *Mounter) checkHostDirs() []*Check {
+	hostDirs := []hostDir{
+		{path: cri.FUSEConfigDir, mode: 0755},
+		{path: m.Config.StateDir, mode: 0700},
+		{path: m.Config.HostPaths.ContainerLogsDir, mode: 0755},
+		{path: m.Config.TempDir, mode: 0700},
+	}
+
+	if m.Config.OperationLogDir != "-" {
+		hostDirs = append(hostDirs, hostDir{path: m.Config.OperationLogDir, mode: 0750})
+	}
+
+	var checks []*Check
+	for _, dir := range hostDirs {
+		dir := dir
+		name := "directory " + dir.path
+
+		dirInfo, err := os.Stat(dir.path)
+		switch {
+		case os.IsNotExist(err):
+			checks = append(checks, &Check{
+				Name:    name,
+				Message: "Missing",
+				Fix: &Fix{
+					Changes: []string{fmt.Sprintf("+ %s (directory, mode %o)", dir.path, dir.mode)},
+					apply: func() error {
+						return os.MkdirAll(dir.path, dir.mode)
+					},
+				},
+			})
+		case err != nil:
+			checks = append(checks, &Check{Name: name, Message: fmt.Sprintf("Failed to stat: %s", err)})
+		case !dirInfo.IsDir():
+			checks = append(checks, &Check{Name: name, Message: fmt.Sprintf("Not a directory (mode %s)", dirInfo.Mode())})
+		default:
+			checks = append(checks, &Check{Name: name, Passed: true, Message: "Exists"})
+		}
+	}
+
+	return checks
+}
+
+// checkFUSEConfigPermissions verifies only root can change the v3io FUSE configuration, which FUSE containers
+// trust. It's fixed by changing the owner to root and removing write permission for group and others
+func checkFUSEConfigPermissions() *Check {
+	name := "permissions " + cri.FUSEConfigDir
+
+	paths := []string{cri.FUSEConfigDir}
+	entries, err := os.ReadDir(cri.FUSEConfigDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Check{Name: name, Passed: true, Message: "Nothing to check, the directory is missing"}
+		}
+
+		return &Check{Name: name, Message: fmt.Sprintf("Failed to read: %s", err)}
+	}
+
+	for _, entry := range entries {
+		paths = append(paths, path.Join(cri.FUSEConfigDir, entry.Name()))
+	}
+
+	fix := &Fix{}
+	fixedModes := map[string]os.FileMode{}
+	var writablePaths []string
+	var nonRootPaths []string
+	for _, checkedPath := range paths {
+		pathInfo, err := os.Lstat(checkedPath)
+		if err != nil {
+			return &Check{Name: name, Message: fmt.Sprintf("Failed to stat %s: %s", checkedPath, err)}
+		}
+
+		// symlinks' own modes and owners are meaningless
+		if pathInfo.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		if stat, ok := pathInfo.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 {
+			nonRootPaths = append(nonRootPaths, checkedPath)
+			fix.Changes = append(fix.Changes,
+				fmt.Sprintf("- %s owner %d", checkedPath, stat.Uid),
+				fmt.Sprintf("+ %s owner 0", checkedPath))
+		}
+
+		if mode := pathInfo.Mode().Perm(); mode&0022 != 0 {
+			fixedModes[checkedPath] = mode &^ 0022
+
+			writablePaths = append(writablePaths, checkedPath)
+			fix.Changes = append(fix.Changes,
+				fmt.Sprintf("- %s mode %o", checkedPath, mode),
+				fmt.Sprintf("+ %s mode %o", checkedPath, fixedModes[checkedPath]))
+		}
+	}
+
+	fix.apply = func() error {
+		for _, nonRootPath := range nonRootPaths {
+			if err := os.Chown(nonRootPath, 0, -1); err != nil {
+				return err
+			}
+		}
+
+		for _, writablePath := range writablePaths {
+			if err := os.Chmod(writablePath, fixedModes[writablePath]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if len(writablePaths) == 0 && len(nonRootPaths) == 0 {
+		return &Check{Name: name, Passed: true, Message: "Owned by root and writable only by it"}
+	}
+
+	var problems []string
+	if len(nonRootPaths) > 0 {
+		problems = append(problems, fmt.Sprintf("Not owned by root: %s", strings.Join(nonRootPaths, ", ")))
+	}
+
+	if len(writablePaths) > 0 {
+		problems = append(problems, fmt.Sprintf("Writable by group or others: %s", strings.Join(writablePaths, ", ")))
+	}
+
+	return &Check{
+		Name:    name,
+		Message: strings.Join(problems, "; "),
+		Fix:     fix,
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/cri"
+)
+
+// checkTargetPath returns whether the target path is already mounted by the driver. A target path with another
+// mount on it (e.g. a leftover NFS mount or a FUSE mount of another driver) is detached if ForeignMounts is
+// "unmount", rather than mounting over it, and is an error otherwise
+func (m *Mounter) checkTargetPath(targetPath string) (bool, error) {
+	mount, err := getMountInfo(targetPath)
+	if err != nil {
+		return false, err
+	}
+
+	if mount == nil {
+		return false, nil
+	}
+
+	if m.isOwnMount(targetPath, mount) {
+		return true, nil
+	}
+
+	if m.Config.ForeignMounts != "unmount" {
+		return false, fmt.Errorf("Target path %s already has a %s mount of %s. "+
+			"Unmount it, or set foreign_mounts to \"unmount\"",
+			targetPath,
+			mount.fsType,
+			mount.source)
+	}
+
+	m.logger.Warn("Unmounting foreign mount from target path",
+		"targetPath", targetPath,
+		"fsType", mount.fsType,
+		"source", mount.source)
+
+	if output, err := exec.Command("umount", "-l", targetPath).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("Failed to unmount %s mount of %s from %s: %s",
+			mount.fsType,
+			mount.source,
+			targetPath,
+			strings.TrimSpace(string(output)))
+	}
+
+	// mounts may be stacked
+	return m.checkTargetPath(targetPath)
+}
+
+// isOwnMount returns whether a mount of a target path is the driver's - a FUSE mount (or a tmpfs simulating one),
+// that was either recorded or is served by the target path's container. FUSE mounts are assumed to be the
+// driver's if the runtime can't tell
+func (m *Mounter) isOwnMount(targetPath string, mount *mountInfo) bool {
+	isSimulated := mount.fsType == "tmpfs" && mount.source == cri.SimulatedMountSource
+	if mount.fsType != "fuse" && !strings.HasPrefix(mount.fsType, "fuse.") && !isSimulated {
+		return false
+	}
+
+	mountRecords, err := m.ListMountRecords()
+	if err == nil {
+		for _, mountRecord := range mountRecords {
+			if mountRecord.TargetPath == targetPath {
+				return true
+			}
+		}
+	}
+
+	containerStatus, err := m.getContainerStatus(targetPath)
+	if err != nil {
+		m.logger.Debug("Failed to get container status", "targetPath", targetPath, "err", err.Error())
+		return true
+	}
+
+	return containerStatus.Exists
+}
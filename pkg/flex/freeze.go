@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+const frozenMountsDir = "frozen"
+
+type frozenMount struct {
+	TargetPath string    `json:"targetPath"`
+	FrozenAt   time.Time `json:"frozenAt"`
+}
+
+// Freeze flushes a mount and pauses its FUSE container, blocking further I/O through the mount until thawed, so
+// backup tools can take crash consistent snapshots of the data written through it
+func (m *Mounter) Freeze(targetPath string) error {
+	containerName, err := m.getContainerName(targetPath)
+	if err != nil {
+		return err
+	}
+
+	if !isMountPoint(targetPath) {
+		return fmt.Errorf("%s is not mounted", targetPath)
+	}
+
+	criInstance, err := m.newCRI()
+	if err != nil {
+		return err
+	}
+
+	defer criInstance.Close() // nolint: errcheck
+
+	if err := syncFilesystem(targetPath); err != nil {
+		return fmt.Errorf("Failed to flush %s: %s", targetPath, err)
+	}
+
+	if err := criInstance.PauseContainer(containerName); err != nil {
+		return fmt.Errorf("Failed to pause container %s: %s", containerName, err)
+	}
+
+	if err := m.state.WriteJSON(getFrozenMountName(containerName), &frozenMount{
+		TargetPath: targetPath,
+		FrozenAt:   time.Now(),
+	}); err != nil {
+		m.logger.Warn("Failed to record frozen mount", "targetPath", targetPath, "err", err.Error())
+	}
+
+	m.logger.Info("Froze mount", "targetPath", targetPath, "containerName", containerName)
+
+	return nil
+}
+
+// Thaw resumes the FUSE container of a frozen mount
+func (m *Mounter) Thaw(targetPath string) error {
+	containerName, err := m.getContainerName(targetPath)
+	if err != nil {
+		return err
+	}
+
+	criInstance, err := m.newCRI()
+	if err != nil {
+		return err
+	}
+
+	defer criInstance.Close() // nolint: errcheck
+
+	if err := criInstance.ResumeContainer(containerName); err != nil {
+		return fmt.Errorf("Failed to resume container %s: %s", containerName, err)
+	}
+
+	if err := m.state.Remove(getFrozenMountName(containerName)); err != nil {
+		m.logger.Warn("Failed to remove frozen mount record", "targetPath", targetPath, "err", err.Error())
+	}
+
+	m.logger.Info("Thawed mount", "targetPath", targetPath, "containerName", containerName)
+
+	return nil
+}
+
+// IsFrozen returns whether the FUSE container of a mount was recorded as frozen by Freeze
+func (m *Mounter) IsFrozen(containerName string) bool {
+	return m.state.ReadJSON(getFrozenMountName(containerName), &frozenMount{}) == nil
+}
+
+func getFrozenMountName(containerName string) string {
+	return path.Join(frozenMountsDir, containerName+".json")
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	fuseDevicePath = "/dev/fuse"
+	fuseConfigPath = "/etc/fuse.conf"
+)
+
+// checkFUSE returns an error if the kernel can't serve FUSE mounts, loading the fuse module first if
+// configured to
+func (m *Mounter) checkFUSE() error {
+	err := getFUSEError()
+	if err == nil || !m.Config.FUSEModprobe {
+		return err
+	}
+
+	m.logger.Info("FUSE is unavailable, loading the fuse kernel module", "reason", err.Error())
+
+	if output, modprobeErr := exec.Command("modprobe", "fuse").CombinedOutput(); modprobeErr != nil {
+		return fmt.Errorf("%s, and modprobe fuse failed: %s (%s)",
+			err,
+			modprobeErr,
+			strings.TrimSpace(string(output)))
+	}
+
+	return getFUSEError()
+}
+
+func getFUSEError() error {
+	fuseLoaded, err := isFUSEFilesystemRegistered()
+	if err != nil {
+		return fmt.Errorf("Failed to read the kernel's filesystems: %s", err)
+	}
+
+	if !fuseLoaded {
+		return errors.New("The fuse kernel module is not loaded")
+	}
+
+	fuseDeviceInfo, err := os.Stat(fuseDevicePath)
+	if err != nil {
+		return fmt.Errorf("The FUSE device is unavailable: %s", err)
+	}
+
+	if fuseDeviceInfo.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("%s is not a character device", fuseDevicePath)
+	}
+
+	return nil
+}
+
+// isFUSEFilesystemRegistered returns whether the fuse filesystem is registered, by a module or built in
+func isFUSEFilesystemRegistered() (bool, error) {
+	filesystems, err := os.Open("/proc/filesystems")
+	if err != nil {
+		return false, err
+	}
+
+	defer filesystems.Close() // nolint: errcheck
+
+	scanner := bufio.NewScanner(filesystems)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[len(fields)-1] == "fuse" {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// FUSEOptions are the FUSE mount options that can be set by the fuseOptions volume option
+var FUSEOptions = []string{
+	"allow_other",
+	"allow_root",
+	"default_permissions",
+	"ro",
+	"noatime",
+	"nodev",
+	"noexec",
+	"nosuid",
+}
+
+// GetFUSEOptions returns the FUSE mount options of the fuseOptions option (comma separated), allow_other if
+// not set
+func (s *Spec) GetFUSEOptions() ([]string, error) {
+	if s.FUSEOptions == "" {
+		return []string{"allow_other"}, nil
+	}
+
+	var fuseOptions []string
+	for _, fuseOption := range strings.Split(s.FUSEOptions, ",") {
+		fuseOption = strings.TrimSpace(fuseOption)
+		if fuseOption == "" {
+			continue
+		}
+
+		if !contains(FUSEOptions, fuseOption) {
+			return nil, fmt.Errorf("invalid fuseOptions %q, unknown option %s (expected %s)",
+				s.FUSEOptions,
+				fuseOption,
+				strings.Join(FUSEOptions, ", "))
+		}
+
+		fuseOptions = append(fuseOptions, fuseOption)
+	}
+
+	if contains(fuseOptions, "allow_other") && contains(fuseOptions, "allow_root") {
+		return nil, fmt.Errorf("invalid fuseOptions %q, allow_other and allow_root are mutually exclusive",
+			s.FUSEOptions)
+	}
+
+	return fuseOptions, nil
+}
+
+// checkFUSEOptions returns an error if the FUSE process can't mount with the given options. Only root may
+// let other users access a mount, unless /etc/fuse.conf has user_allow_other - the FUSE process isn't root on
+// the host when it runs in a user namespace
+func (m *Mounter) checkFUSEOptions(fuseOptions []string) error {
+	if !contains(fuseOptions, "allow_other") && !contains(fuseOptions, "allow_root") {
+		return nil
+	}
+
+	userAllowOther, err := isUserAllowOtherEnabled()
+	if err != nil {
+		m.logger.Debug("Failed to read FUSE configuration", "path", fuseConfigPath, "err", err.Error())
+	}
+
+	if userAllowOther {
+		return nil
+	}
+
+	if m.Config.UserNamespace != nil {
+		return fmt.Errorf("FUSE options %s require user_allow_other in %s when running in a user namespace",
+			strings.Join(fuseOptions, ","),
+			fuseConfigPath)
+	}
+
+	m.logger.Debug("user_allow_other is not set, relying on the FUSE process running as root",
+		"fuseOptions", fuseOptions)
+
+	return nil
+}
+
+// isUserAllowOtherEnabled returns whether /etc/fuse.conf lets users other than root use allow_other
+func isUserAllowOtherEnabled() (bool, error) {
+	fuseConfig, err := os.Open(fuseConfigPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	defer fuseConfig.Close() // nolint: errcheck
+
+	scanner := bufio.NewScanner(fuseConfig)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "user_allow_other" {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
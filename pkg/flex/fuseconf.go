@@ -0,0 +1,83 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// ConfigureFUSE applies the fuse_conf setting to /etc/fuse.conf, returning a description of the outcome:
+// "manage" appends user_allow_other (needed for allow_other in user namespaces) if it's missing, and "report"
+// only reports whether it's missing
+func ConfigureFUSE(mode string) (string, error) {
+	if mode == "" || mode == "off" {
+		return "", nil
+	}
+
+	userAllowOther, err := isUserAllowOtherEnabled()
+	if err != nil {
+		return "", fmt.Errorf("Failed to read %s: %s", fuseConfigPath, err)
+	}
+
+	if userAllowOther {
+		return fmt.Sprintf("user_allow_other is set in %s", fuseConfigPath), nil
+	}
+
+	if mode == "report" {
+		journal.Warn("user_allow_other is not set", "path", fuseConfigPath)
+		return fmt.Sprintf("user_allow_other is not set in %s (not changed, fuse_conf is report)", fuseConfigPath), nil
+	}
+
+	if err := appendUserAllowOther(); err != nil {
+		return "", fmt.Errorf("Failed to add user_allow_other to %s: %s", fuseConfigPath, err)
+	}
+
+	journal.Info("Added user_allow_other", "path", fuseConfigPath)
+
+	return fmt.Sprintf("Added user_allow_other to %s", fuseConfigPath), nil
+}
+
+func appendUserAllowOther() error {
+	content, err := ioutil.ReadFile(fuseConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	fuseConfig, err := os.OpenFile(fuseConfigPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	line := "user_allow_other\n"
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		line = "\n" + line
+	}
+
+	if _, err := fuseConfig.WriteString(line); err != nil {
+		fuseConfig.Close() // nolint: errcheck
+		return err
+	}
+
+	return fuseConfig.Close()
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"time"
+)
+
+// steps of a mount besides those of creating the container (see cri.StepImagePull and such)
+const (
+	StepConfigLoad = "configLoad"
+	StepPrepare    = "prepare"
+	StepVerify     = "verify"
+	StepCreateDirs = "createDirs"
+)
+
+// latencyBreakdown times the steps of a mount, which are finer than its phases
+type latencyBreakdown struct {
+	step          string
+	stepStartedAt time.Time
+	steps         []PhaseDuration
+}
+
+func newLatencyBreakdown(configLoadDuration time.Duration) *latencyBreakdown {
+	breakdown := latencyBreakdown{
+		step:          StepPrepare,
+		stepStartedAt: time.Now(),
+	}
+
+	if configLoadDuration != 0 {
+		breakdown.steps = append(breakdown.steps, PhaseDuration{
+			Phase:           StepConfigLoad,
+			DurationSeconds: configLoadDuration.Seconds(),
+		})
+	}
+
+	return &breakdown
+}
+
+func (l *latencyBreakdown) startStep(step string) {
+	now := time.Now()
+
+	l.steps = append(l.steps, PhaseDuration{
+		Phase:           l.step,
+		DurationSeconds: now.Sub(l.stepStartedAt).Seconds(),
+	})
+
+	l.step = step
+	l.stepStartedAt = now
+}
+
+// startStep starts timing a step of the current mount, ending the previous one
+func (m *Mounter) startStep(step string) {
+	if m.latency != nil {
+		m.latency.startStep(step)
+	}
+}
+
+// logLatency logs the duration of every step of a successful mount in one line, so regressions in any of them are
+// visible in the logs
+func (m *Mounter) logLatency(targetPath string) {
+	if m.latency == nil {
+		return
+	}
+
+	m.latency.startStep("")
+
+	vars := []interface{}{"targetPath", targetPath}
+
+	var total time.Duration
+	for _, step := range m.latency.steps {
+		duration := time.Duration(step.DurationSeconds * float64(time.Second))
+		total += duration
+
+		vars = append(vars, step.Phase, duration.Round(time.Millisecond).String())
+	}
+
+	vars = append(vars, "total", total.Round(time.Millisecond).String())
+
+	m.logger.Info("Mount latency", vars...)
+}
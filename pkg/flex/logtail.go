@@ -0,0 +1,66 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/cri"
+)
+
+// lines of the FUSE container's log attached to mount errors
+const logTailLines = 100
+
+// withLogTail attaches the tail of a FUSE container's log to an error of a mount it was created for, so that the
+// mount can be diagnosed without access to the node
+func (m *Mounter) withLogTail(criInstance cri.CRI, containerName string, spec *Spec, err error) error {
+	logTail, logErr := criInstance.GetContainerLogTail(containerName, logTailLines)
+	if logErr != nil {
+		m.logger.Debug("Failed to read container log", "containerName", containerName, "err", logErr.Error())
+		return err
+	}
+
+	logTail = sanitizeLog(logTail, spec)
+	if logTail == "" {
+		return err
+	}
+
+	m.logger.Warn("Mount failed, container log tail", "containerName", containerName, "logTail", logTail)
+
+	return fmt.Errorf("%s, log tail:\n%s", err, logTail)
+}
+
+// sanitizeLog drops control characters from a log and redacts the volume's access key
+func sanitizeLog(log string, spec *Spec) string {
+	log = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || r >= ' ' && r != 0x7f {
+			return r
+		}
+
+		return -1
+	}, log)
+
+	if accessKey := spec.GetAccessKey(); accessKey != "" {
+		log = strings.Replace(log, accessKey, "<redacted>", -1)
+	}
+
+	return strings.TrimSpace(log)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/json"
+)
+
+// kubelet passes flexvolume options with kubernetes.io/ keys, while invocations translated from CSI (e.g. by
+// migration shims) carry the CSI volume context keys
+var csiOptionKeys = map[string]string{
+	"csi.storage.k8s.io/pod.name":            "kubernetes.io/pod.name",
+	"csi.storage.k8s.io/pod.namespace":       "kubernetes.io/pod.namespace",
+	"csi.storage.k8s.io/pod.uid":             "kubernetes.io/pod.uid",
+	"csi.storage.k8s.io/pv.name":             "kubernetes.io/pvOrVolumeName",
+	"csi.storage.k8s.io/serviceAccount.name": "kubernetes.io/serviceAccount.name",
+}
+
+// kubelet's CSI target paths are <pods dir>/<pod UID>/volumes/kubernetes.io~csi/<PV name>/mount
+const (
+	csiVolumesDirName = "kubernetes.io~csi"
+	csiMountDirName   = "mount"
+)
+
+// translateOptions renames the CSI volume context keys of a mount request to their flexvolume names, unless
+// the flexvolume keys are set as well
+func translateOptions(specString string) (string, error) {
+	options := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(specString), &options); err != nil {
+		return "", err
+	}
+
+	translated := false
+	for csiKey, flexKey := range csiOptionKeys {
+		value, found := options[csiKey]
+		if !found {
+			continue
+		}
+
+		if _, found := options[flexKey]; !found {
+			options[flexKey] = value
+		}
+
+		delete(options, csiKey)
+		translated = true
+	}
+
+	if !translated {
+		return specString, nil
+	}
+
+	translatedSpec, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+
+	return string(translatedSpec), nil
+}
+
+// getVolumeDirName returns the name identifying the volume in a target path - the last element of flexvolume
+// target paths, and the PV name of CSI target paths (whose last element is always "mount")
+func getVolumeDirName(splitTargetPath []string) string {
+	volumeDirName := splitTargetPath[len(splitTargetPath)-1]
+
+	if volumeDirName == csiMountDirName &&
+		len(splitTargetPath) >= 3 &&
+		splitTargetPath[len(splitTargetPath)-3] == csiVolumesDirName {
+		return splitTargetPath[len(splitTargetPath)-2]
+	}
+
+	return volumeDirName
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranslateOptions(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		specString      string
+		expectedOptions map[string]interface{}
+		expectedError   bool
+	}{
+		{
+			name:       "classic flexvolume invocation",
+			specString: `{"kubernetes.io/pod.name": "pod", "kubernetes.io/pod.namespace": "default", "container": "bigdata"}`,
+			expectedOptions: map[string]interface{}{
+				"kubernetes.io/pod.name":      "pod",
+				"kubernetes.io/pod.namespace": "default",
+				"container":                   "bigdata",
+			},
+		},
+		{
+			name: "translated CSI invocation",
+			specString: `{"csi.storage.k8s.io/pod.name": "pod", "csi.storage.k8s.io/pod.namespace": "default", ` +
+				`"csi.storage.k8s.io/pod.uid": "uid", "csi.storage.k8s.io/pv.name": "pv", ` +
+				`"csi.storage.k8s.io/serviceAccount.name": "sa", "container": "bigdata"}`,
+			expectedOptions: map[string]interface{}{
+				"kubernetes.io/pod.name":            "pod",
+				"kubernetes.io/pod.namespace":       "default",
+				"kubernetes.io/pod.uid":             "uid",
+				"kubernetes.io/pvOrVolumeName":      "pv",
+				"kubernetes.io/serviceAccount.name": "sa",
+				"container":                         "bigdata",
+			},
+		},
+		{
+			name:       "flexvolume keys take precedence",
+			specString: `{"csi.storage.k8s.io/pod.name": "csi-pod", "kubernetes.io/pod.name": "flex-pod"}`,
+			expectedOptions: map[string]interface{}{
+				"kubernetes.io/pod.name": "flex-pod",
+			},
+		},
+		{
+			name:       "unknown keys are kept",
+			specString: `{"csi.storage.k8s.io/ephemeral": "true", "csi.storage.k8s.io/pod.name": "pod", "other": 1}`,
+			expectedOptions: map[string]interface{}{
+				"csi.storage.k8s.io/ephemeral": "true",
+				"kubernetes.io/pod.name":       "pod",
+				"other":                        float64(1),
+			},
+		},
+		{
+			name:            "missing keys",
+			specString:      `{}`,
+			expectedOptions: map[string]interface{}{},
+		},
+		{
+			name:          "invalid JSON",
+			specString:    `{"container": `,
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			translatedSpec, err := translateOptions(testCase.specString)
+			if testCase.expectedError {
+				if err == nil {
+					t.Fatalf("Expected an error, got %s", translatedSpec)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to translate options: %s", err)
+			}
+
+			options := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(translatedSpec), &options); err != nil {
+				t.Fatalf("Failed to unmarshal translated options: %s", err)
+			}
+
+			if !reflect.DeepEqual(options, testCase.expectedOptions) {
+				t.Errorf("Expected %v, got %v", testCase.expectedOptions, options)
+			}
+		})
+	}
+}
+
+func TestGetVolumeDirName(t *testing.T) {
+	for _, testCase := range []struct {
+		targetPath            string
+		expectedVolumeDirName string
+	}{
+		{
+			targetPath:            "/var/lib/kubelet/pods/uid/volumes/v3io~fuse/data",
+			expectedVolumeDirName: "data",
+		},
+		{
+			targetPath:            "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv-data/mount",
+			expectedVolumeDirName: "pv-data",
+		},
+		{
+			targetPath:            "/var/lib/kubelet/pods/uid/volumes/v3io~fuse/mount",
+			expectedVolumeDirName: "mount",
+		},
+	} {
+		volumeDirName := getVolumeDirName(strings.Split(testCase.targetPath, "/"))
+		if volumeDirName != testCase.expectedVolumeDirName {
+			t.Errorf("Expected volume dir name %s for %s, got %s",
+				testCase.expectedVolumeDirName,
+				testCase.targetPath,
+				volumeDirName)
+		}
+	}
+}
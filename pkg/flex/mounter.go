@@ -22,63 +22,424 @@ package flex
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/v3io/flex-fuse/pkg/config"
 	"github.com/v3io/flex-fuse/pkg/cri"
 
 	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/probe"
+	"github.com/v3io/flex-fuse/pkg/state"
+	"github.com/v3io/flex-fuse/pkg/version"
+
+	"github.com/distribution/reference"
 )
 
+// ContainerNamePrefix prefixes the names of all FUSE containers created by the driver
+const ContainerNamePrefix = "v3io-fuse-"
+
 type Mounter struct {
-	Config *Config
+	Config    *config.Config
+	state     *state.State
+	operation *operation
+	events    *mountEvents
+	latency   *latencyBreakdown
+
+	// how long reading the configuration took, if the mounter read it
+	configLoadDuration time.Duration
+
+	// a runtime connection shared with other mounters, see SetCRI
+	sharedCRI cri.CRI
+
+	// logs the mounter's operations, see SetLogger
+	logger *journal.Logger
 }
 
 func NewMounter() (*Mounter, error) {
 	journal.Debug("Creating configuration")
-	config, err := NewConfig()
+	configLoadStartedAt := time.Now()
+
+	mounterConfig, err := config.New()
 	if err != nil {
 		return nil, err
 	}
 
+	newMounter := NewMounterFromConfig(mounterConfig)
+	newMounter.configLoadDuration = time.Since(configLoadStartedAt)
+
+	return newMounter, nil
+}
+
+// NewMounterFromConfig creates a mounter with a configuration that was already read
+func NewMounterFromConfig(mounterConfig *config.Config) *Mounter {
+	mounterState := state.New(mounterConfig.StateDir)
+
+	// cache environment probes across invocations
+	probe.SetCache(mounterState, time.Duration(mounterConfig.ProbeCacheTTLSeconds)*time.Second)
+
+	cri.SetImageEndpoint(mounterConfig.ImageEndpoint)
+	cri.SetK8sImport(mounterConfig.K8sImport.Attempts,
+		time.Duration(mounterConfig.K8sImport.IntervalSeconds)*time.Second)
+	cri.SetKubeletImageStore(mounterConfig.KubeletImageStore)
+	cri.SetRegistryTLS(getRegistryTLS(mounterConfig))
+	cri.SetPullCommand(mounterConfig.PullCommand)
+	cri.SetVersionCheck(mounterConfig.ContainerdVersionCheck)
+	cri.SetDiskPreflight(int64(mounterConfig.DiskPreflightHeadroomMB) << 20)
+	cri.SetHostPaths(cri.HostPaths{
+		DockerBinary:         mounterConfig.HostPaths.DockerBinary,
+		CtrBinary:            mounterConfig.HostPaths.CtrBinary,
+		ContainerLogsDir:     mounterConfig.HostPaths.ContainerLogsDir,
+		DriverBinary:         mounterConfig.HostPaths.DriverBinary,
+		SearchSystemBinaries: !mounterConfig.ImmutableOS,
+	})
+	cri.SetTempDir(mounterConfig.TempDir, int64(mounterConfig.TempDirMinFreeMB)<<20)
+
+	if mounterConfig.DeviceMountRoot == "" {
+		mounterConfig.DeviceMountRoot = path.Join(cri.GetNodeLayout().KubeletRootDir,
+			"plugins/kubernetes.io/flexvolume/v3io/fuse/mounts")
+	}
+
 	return &Mounter{
-		Config: config,
-	}, nil
+		Config: mounterConfig,
+		state:  mounterState,
+		logger: journal.Default(),
+	}
+}
+
+// SetLogger makes the mounter log with a logger of its own (e.g. with the trace ID of an invocation forwarded to
+// the daemon) rather than the process' logger
+func (m *Mounter) SetLogger(logger *journal.Logger) {
+	m.logger = logger
+}
+
+// SetCRI makes the mounter use a runtime connection rather than connecting per operation. The connection isn't
+// closed by the mounter
+func (m *Mounter) SetCRI(criInstance cri.CRI) {
+	m.sharedCRI = criInstance
+}
+
+// newCRI returns a runtime connection, to be closed by the caller
+func (m *Mounter) newCRI() (cri.CRI, error) {
+	if m.sharedCRI != nil {
+		return &unclosableCRI{m.sharedCRI}, nil
+	}
+
+	return cri.New(m.Config.RuntimeBackend, m.Config.RuntimeEndpoint)
+}
+
+// isSimulated returns whether mounts are simulated by the simulate backend rather than served by FUSE containers
+func (m *Mounter) isSimulated() bool {
+	return m.Config.RuntimeBackend == "simulate" || strings.HasPrefix(m.Config.RuntimeEndpoint, "simulate://")
+}
+
+// unclosableCRI keeps a shared runtime connection open when its users close it
+type unclosableCRI struct {
+	cri.CRI
+}
+
+func (u *unclosableCRI) Close() error {
+	return nil
 }
 
 func (m *Mounter) Mount(targetPath string, specString string) *Response {
-	journal.Debug("Mounting", "targetPath", targetPath)
+	return m.runOperation("mount", targetPath, func() *Response {
+		return m.mount(targetPath, specString)
+	})
+}
+
+func (m *Mounter) Unmount(targetPath string) *Response {
+	return m.runOperation("unmount", targetPath, func() *Response {
+		return m.unmount(targetPath)
+	})
+}
+
+// runOperation runs an operation, writing its result to the target path's result file
+func (m *Mounter) runOperation(name string, targetPath string, handler func() *Response) *Response {
+	m.operation = newOperation(name, targetPath, m.logger)
+	defer func() {
+		m.operation = nil
+	}()
+
+	response := m.lockTargetPath(targetPath, handler)
+
+	result := m.operation.finish(response)
+	if err := m.state.WriteJSON(getResultName(targetPath), result); err != nil {
+		m.logger.Warn("Failed to write result", "targetPath", targetPath, "err", err.Error())
+	}
+
+	m.recordStatus(result)
+
+	if name == "mount" && result.Status == "Failure" {
+		m.reportMountFailed(result)
+	}
+
+	return response
+}
+
+// getImage returns the volume's FUSE image if set (and allowed by AllowedImages), or the configured image. Either
+// must be from AllowedRegistries, if set
+func (m *Mounter) getImage(spec *Spec) (string, error) {
+	image := fmt.Sprintf("%s:%s", m.Config.ImageRepository, m.Config.ImageTag)
+
+	if spec.Image != "" {
+
+		// the FUSE container is privileged, so volumes may only choose among images the node's admin allowed
+		if len(m.Config.AllowedImages) == 0 {
+			return "", fmt.Errorf("The image option is disabled, set allowed_images to allow image %s", spec.Image)
+		}
+
+		if _, err := reference.ParseNormalizedNamed(spec.Image); err != nil {
+			return "", fmt.Errorf("Invalid image %q: %s", spec.Image, err)
+		}
+
+		if !isImageAllowed(spec.Image, m.Config.AllowedImages) {
+			return "", fmt.Errorf("image %s is not in allowed_images", spec.Image)
+		}
+
+		image = spec.Image
+	}
+
+	if len(m.Config.AllowedRegistries) > 0 && !isImageRegistryAllowed(image, m.Config.AllowedRegistries) {
+		return "", fmt.Errorf("image %s is not from allowed_registries", image)
+	}
+
+	return image, nil
+}
+
+// isImageAllowed returns whether an image matches any of the allowed patterns
+func isImageAllowed(image string, allowedImages []string) bool {
+	for _, allowedImage := range allowedImages {
+		if matched, _ := path.Match(allowedImage, image); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isImageRegistryAllowed returns whether an image's repository is one of the allowed registries or repositories,
+// or under one of them
+func isImageRegistryAllowed(image string, allowedRegistries []string) bool {
+	repository := cri.GetNormalizedImageRepository(image)
+
+	for _, allowedRegistry := range allowedRegistries {
+		allowedRegistry = strings.TrimSuffix(allowedRegistry, "/")
+
+		if repository == allowedRegistry || strings.HasPrefix(repository, allowedRegistry+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getIOLimits returns the volume's I/O limits on the configured devices, or nil if it has none
+func (m *Mounter) getIOLimits(spec *Spec) (*cri.IOLimits, error) {
+	ioLimits, err := spec.GetIOLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	if !ioLimits.IsSet() {
+		return nil, nil
+	}
+
+	if len(m.Config.IOLimitDevices) == 0 {
+		return nil, fmt.Errorf("Volume has I/O limits, but io_limit_devices isn't configured")
+	}
+
+	ioLimits.Devices = m.Config.IOLimitDevices
+
+	return ioLimits, nil
+}
+
+// getConnectionPoolSize returns the volume's connection pool size option, falling back to the cluster's and
+// the global configuration
+func (m *Mounter) getConnectionPoolSize(spec *Spec) (int, error) {
+	connectionPoolSize, err := spec.GetConnectionPoolSize()
+	if err != nil || connectionPoolSize != 0 {
+		return connectionPoolSize, err
+	}
+
+	return m.Config.GetConnectionPoolSize(spec.GetClusterName())
+}
+
+// setMountTimeout bounds the current operation by the volume's mount timeout, or the configured default
+func (m *Mounter) setMountTimeout(spec *Spec) error {
+	mountTimeout, err := spec.GetMountTimeout()
+	if err != nil {
+		return err
+	}
+
+	if mountTimeout == 0 {
+		mountTimeout = time.Duration(m.Config.MountTimeoutSeconds) * time.Second
+	}
+
+	if mountTimeout != 0 && m.operation != nil {
+		m.logger.Debug("Setting mount timeout", "mountTimeout", mountTimeout.String())
+		m.operation.deadline = m.operation.startedAt.Add(mountTimeout)
+	}
+
+	return nil
+}
+
+// getDeadline returns the deadline of the current operation, or a zero time if it has none
+func (m *Mounter) getDeadline() time.Time {
+	if m.operation == nil {
+		return time.Time{}
+	}
+
+	return m.operation.deadline
+}
+
+// getCreateDeadline returns the deadline of creating the FUSE container - the operation's deadline, or kubelet's
+// timeout if it has none. A zero time if neither is set
+func (m *Mounter) getCreateDeadline() time.Time {
+	if deadline := m.getDeadline(); !deadline.IsZero() || m.operation == nil || m.Config.KubeletTimeoutSeconds <= 0 {
+		return deadline
+	}
+
+	return m.operation.startedAt.Add(time.Duration(m.Config.KubeletTimeoutSeconds) * time.Second)
+}
+
+// checkDeadline returns an error if the current operation's deadline passed
+func (m *Mounter) checkDeadline() error {
+	if deadline := m.getDeadline(); !deadline.IsZero() && time.Now().After(deadline) {
+		return fmt.Errorf("Mount timeout exceeded (deadline %s)", deadline.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (m *Mounter) setPhase(phase string) {
+	if m.operation != nil {
+		m.operation.setPhase(phase)
+	}
+}
+
+func (m *Mounter) mount(targetPath string, specString string) *Response {
+	m.logger.Debug("Mounting", "targetPath", targetPath)
+
+	m.latency = newLatencyBreakdown(m.configLoadDuration)
+	defer func() {
+		m.latency = nil
+	}()
+
+	specString, err := translateOptions(specString)
+	if err != nil {
+		return NewFailResponse("Failed to unmarshal spec", err)
+	}
+
+	if err := validateOptionNames(specString); err != nil {
+		return NewFailResponse("Mount failed validation", err)
+	}
 
 	spec := Spec{}
 	if err := json.Unmarshal([]byte(specString), &spec); err != nil {
 		return NewFailResponse("Failed to unmarshal spec", err)
 	}
 
+	if err := m.applyProfile(&spec); err != nil {
+		return NewFailResponse("Mount failed validation", err)
+	}
+
 	if err := spec.validate(); err != nil {
 		return NewFailResponse("Mount failed validation", err)
 	}
 
+	if m.operation != nil {
+		m.operation.namespace = spec.Namespace
+		m.operation.podName = spec.PodName
+	}
+
+	if _, err := m.getImage(&spec); err != nil {
+		return NewFailResponse("Mount failed validation", err)
+	}
+
+	if err := m.checkDraining(); err != nil {
+		return NewFailResponse("Mount refused", err)
+	}
+
+	if err := m.setMountTimeout(&spec); err != nil {
+		return NewFailResponse("Invalid mount timeout", err)
+	}
+
 	if m.Config.Type == "link" {
 		return m.mountAsLink(&spec, targetPath)
 	}
 
-	if isMountPoint(targetPath) {
+	// when attaching, the FUSE container serves the device mount path and pods bind mount it
+	if m.Config.Attach {
+		return m.mountFromDevice(&spec, targetPath)
+	}
+
+	m.startMountEvents(&spec)
+
+	var response *Response
+	if m.Config.ShareVolumeContainers {
+		response = m.mountShared(&spec, specString, targetPath)
+	} else {
+		response = m.mountFUSE(&spec, targetPath)
+	}
+
+	m.stopMountEvents(response)
+
+	return response
+}
+
+func (m *Mounter) mountFUSE(spec *Spec, targetPath string) *Response {
+
+	// an unmount that failed midway is completed first, so that the mount starts from a clean slate
+	if m.getPendingUnmount(targetPath) != nil {
+		if response := m.unmountFUSE(targetPath); response.Status != "Success" {
+			return NewFailResponse("Failed to complete the previous unmount of the target path",
+				errors.New(response.Message))
+		}
+	}
+
+	m.setPhase(PhaseCheckingTargetPath)
+
+	mounted, err := m.checkTargetPath(targetPath)
+	if err != nil {
+		return NewFailResponse("Target path is unusable", err)
+	}
+
+	if mounted && !m.isStaleMount(targetPath) {
 		return NewSuccessResponse(fmt.Sprintf("Already mounted: %s", targetPath))
 	}
 
-	if err := m.createV3IOFUSEContainer(&spec, targetPath); err != nil {
+	if err := m.checkDeadline(); err != nil {
+		return NewFailResponse("Mount timed out", err)
+	}
+
+	// simulated mounts are tmpfs mounts
+	if !m.isSimulated() {
+		if err := m.checkFUSE(); err != nil {
+			return NewFailResponse("FUSE is unavailable on the node", err)
+		}
+	}
+
+	if err := m.createV3IOFUSEContainer(spec, targetPath); err != nil {
 		return NewFailResponse("Failed to create v3io FUSE container", err)
 	}
 
-	if err := m.createDirs(spec, targetPath); err != nil {
+	m.setPhase(PhaseCreatingDirs)
+	m.startStep(StepCreateDirs)
+
+	if err := m.createDirs(*spec, targetPath); err != nil {
 		return NewFailResponse("Failed to create folders", err)
 	}
 
+	m.recordMount(spec, targetPath)
+	m.logLatency(targetPath)
+
 	return NewSuccessResponse("Successfully mounted")
 }
 
@@ -98,7 +459,7 @@ func (m *Mounter) createDirs(spec Spec, targetPath string) error {
 
 		_, err := os.Stat(dirToCreate)
 		if err == nil {
-			journal.Debug(fmt.Sprintf("Folder already exists: %s", dirToCreate))
+			m.logger.Debug(fmt.Sprintf("Folder already exists: %s", dirToCreate))
 			continue
 		}
 
@@ -109,83 +470,160 @@ func (m *Mounter) createDirs(spec Spec, targetPath string) error {
 		if err := os.MkdirAll(dirToCreate, dir.Permissions); err != nil {
 			return fmt.Errorf("Failed to create folder (path: %s, filemode: %o): %s", dir.Name, dir.Permissions, err.Error())
 		}
-		journal.Debug(fmt.Sprintf("Created folder: %s", dirToCreate))
+		m.logger.Debug(fmt.Sprintf("Created folder: %s", dirToCreate))
 	}
 	return nil
 }
 
-func (m *Mounter) Unmount(targetPath string) *Response {
-	journal.Debug("Unmounting", "targetPath", targetPath)
+func (m *Mounter) unmount(targetPath string) *Response {
+	m.logger.Debug("Unmounting", "targetPath", targetPath)
 
 	if m.Config.Type == "link" {
 		return m.unmountAsLink(targetPath)
 	}
 
-	if !isMountPoint(targetPath) {
-		return NewSuccessResponse(fmt.Sprintf("%s Not a mountpoint, nothing to do", targetPath))
+	if m.Config.Attach {
+		return m.unmountFromDevice(targetPath)
+	}
+
+	if m.Config.ShareVolumeContainers {
+		return m.unmountShared(targetPath)
 	}
 
-	criInstance, err := createCRI()
+	return m.unmountFUSE(targetPath)
+}
+
+// unmountFUSE removes the FUSE container, unmounts the target path and removes it. The steps are recorded as they
+// complete, so that an unmount that failed midway (e.g. the container's task was killed but deleting the
+// container failed) is resumed by the next unmount or mount of the target path rather than starting over
+func (m *Mounter) unmountFUSE(targetPath string) *Response {
+	pendingUnmount := m.getPendingUnmount(targetPath)
+	if pendingUnmount == nil {
+		if !isMountPoint(targetPath) {
+			m.removeMountRecord(targetPath)
+			return NewSuccessResponse(fmt.Sprintf("%s Not a mountpoint, nothing to do", targetPath))
+		}
+
+		var err error
+		if pendingUnmount, err = m.startPendingUnmount(targetPath); err != nil {
+			return NewFailResponse("Failed to start unmount", err)
+		}
+	} else {
+		m.logger.Info("Resuming unmount",
+			"targetPath", targetPath,
+			"startedAt", pendingUnmount.StartedAt,
+			"completedSteps", pendingUnmount.Steps,
+			"lastError", pendingUnmount.Error)
+	}
+
+	if !pendingUnmount.completed(UnmountStepContainerRemoved) {
+		m.setPhase(PhaseRemovingContainer)
+
+		if err := m.removeContainerIfExists(targetPath); err != nil {
+			m.markForManualIntervention(targetPath, err)
+
+			return m.failPendingUnmount(pendingUnmount, "Failed to remove v3io FUSE container", err)
+		}
+
+		m.completeUnmountStep(pendingUnmount, UnmountStepContainerRemoved)
+	}
+
+	if !pendingUnmount.completed(UnmountStepUnmounted) {
+		m.setPhase(PhaseUnmounting)
+
+		if err := unmountTargetPath(targetPath); err != nil {
+			return m.failPendingUnmount(pendingUnmount, fmt.Sprintf("Failed to umount %s", targetPath), err)
+		}
+
+		m.completeUnmountStep(pendingUnmount, UnmountStepUnmounted)
+	}
+
+	if !pendingUnmount.completed(UnmountStepTargetPathRemoved) {
+
+		// once unmounted, remove it
+		if err := os.Remove(targetPath); err != nil && !os.IsNotExist(err) {
+			return m.failPendingUnmount(pendingUnmount, fmt.Sprintf("Could not remove directory %s", targetPath), err)
+		}
+
+		m.completeUnmountStep(pendingUnmount, UnmountStepTargetPathRemoved)
+	}
+
+	m.removeMountRecord(targetPath)
+	m.removePendingUnmount(targetPath)
+
+	return NewSuccessResponse("Successfully unmounted")
+}
+
+// removeContainerIfExists removes the target path's container, if it wasn't removed already
+func (m *Mounter) removeContainerIfExists(targetPath string) error {
+	criInstance, err := m.newCRI()
 	if err != nil {
-		return NewFailResponse("Failed to create CRI", err)
+		return fmt.Errorf("Failed to create CRI: %s", err)
 	}
 
 	defer criInstance.Close() // nolint: errcheck
 
-	if err := m.removeV3IOFUSEContainer(criInstance, targetPath); err != nil {
-		return NewFailResponse("Failed to remove v3io FUSE container", err)
+	containerName, err := m.getContainerName(targetPath)
+	if err != nil {
+		return fmt.Errorf("Could not get container name: %s", err)
+	}
+
+	if containerStatus, err := criInstance.GetContainerStatus(containerName); err == nil && !containerStatus.Exists {
+		m.logger.Debug("Container was already removed", "containerName", containerName)
+		return nil
+	}
+
+	return m.removeV3IOFUSEContainer(criInstance, targetPath)
+}
+
+// unmountTargetPath unmounts a target path, if it's mounted
+func unmountTargetPath(targetPath string) error {
+	if !isMountPoint(targetPath) {
+		return nil
 	}
 
 	journal.Info("Unmounting target path with umount", "target", targetPath)
 
 	umountCommand := exec.Command("umount", targetPath)
 	if err := umountCommand.Start(); err != nil {
-		return NewFailResponse("Failed to call unmount", err)
+		return fmt.Errorf("Failed to call unmount: %s", err)
 	}
 
 	for _, interval := range []time.Duration{1, 2, 4} {
 		if !isMountPoint(targetPath) {
-
-			// once unmounted, remove it
-			if err := os.Remove(targetPath); err != nil {
-				return NewFailResponse(fmt.Sprintf("Could not remove directory %s", targetPath), err)
-			}
-
-			return NewSuccessResponse("Successfully unmounted")
+			return nil
 		}
 
 		time.Sleep(interval * time.Second)
 	}
 
-	return NewFailResponse(fmt.Sprintf("Failed to umount %s due to timeout", targetPath), nil)
+	return fmt.Errorf("Timed out")
 }
 
 func (m *Mounter) createV3IOFUSEContainer(spec *Spec, targetPath string) error {
-	journal.Info("Creating v3io-fuse container", "target", targetPath)
+	m.logger.Info("Creating v3io-fuse container", "target", targetPath)
+	m.setPhase(PhaseCheckingPropagation)
 
-	criInstance, err := createCRI()
-	if err != nil {
+	if err := m.checkPropagation(targetPath); err != nil {
 		return err
 	}
 
-	defer criInstance.Close() // nolint: errcheck
+	m.setPhase(PhaseCreatingContainer)
+	m.reportProgress(ProgressCreating)
 
-	ImageRepository := m.Config.ImageRepository
-	if ImageRepository == "" {
-		ImageRepository = "iguazio/v3io-fuse"
+	criInstance, err := m.newCRI()
+	if err != nil {
+		return err
 	}
 
-	ImageTag := m.Config.ImageTag
-	if ImageTag == "" {
-		ImageTag = "local"
-	}
+	defer criInstance.Close() // nolint: errcheck
 
 	dataUrls, err := m.Config.DataURLs(spec.GetClusterName())
 	if err != nil {
 		return fmt.Errorf("Could not get cluster data urls: %s", err.Error())
 	}
 
-	containerName, err := getContainerNameFromTargetPath(targetPath)
+	containerName, err := m.getContainerName(targetPath)
 	if err != nil {
 		return fmt.Errorf("Failed to get container name: %s", err.Error())
 	}
@@ -194,15 +632,52 @@ func (m *Mounter) createV3IOFUSEContainer(spec *Spec, targetPath string) error {
 	// It's ok if the command runs but exits with a failure, this is in the case the container doesn't exist.
 	m.removeV3IOFUSEContainer(criInstance, targetPath) // nolint: errcheck
 
+	fuseOptions, err := spec.GetFUSEOptions()
+	if err != nil {
+		return err
+	}
+
+	if err := m.exchangeServiceAccountToken(spec); err != nil {
+		return err
+	}
+
+	if err := m.checkFUSEOptions(fuseOptions); err != nil {
+		return err
+	}
+
+	if err := m.applyHostSysctls(); err != nil {
+		return err
+	}
+
 	// Create the new container
 	args := []string{
 		"/fuse/mounter.sh",
-		"-o", "allow_other",
+		"-o", strings.Join(fuseOptions, ","),
 		"--connection_strings", dataUrls,
 		"--mountpoint", "/fuse_mount",
 		"--session_key", spec.GetAccessKey(),
 	}
 
+	connectionPoolSize, err := m.getConnectionPoolSize(spec)
+	if err != nil {
+		return err
+	}
+
+	if connectionPoolSize != 0 {
+		args = append(args, "--connection_pool_size", strconv.Itoa(connectionPoolSize))
+	}
+
+	sourceAddress, err := m.getSourceAddress(spec)
+	if err != nil {
+		return err
+	}
+
+	containerEnv := getPodEnv(spec, targetPath)
+	if sourceAddress != "" {
+		args = append(args, "--source_address", sourceAddress)
+		containerEnv = append(containerEnv, "V3IO_SOURCE_ADDRESS="+sourceAddress)
+	}
+
 	V3ioConfigPath := m.Config.V3ioConfigPath
 	if V3ioConfigPath != "" {
 		args = append(args, "-f", V3ioConfigPath)
@@ -217,11 +692,98 @@ func (m *Mounter) createV3IOFUSEContainer(spec *Spec, targetPath string) error {
 		}
 	}
 
-	if err := criInstance.CreateContainer(fmt.Sprintf("%s:%s", ImageRepository, ImageTag),
+	targetPathMode, err := m.Config.GetTargetPathMode()
+	if err != nil {
+		return err
+	}
+
+	ioLimits, err := m.getIOLimits(spec)
+	if err != nil {
+		return err
+	}
+
+	containerOptions := cri.ContainerOptions{
+		Labels: version.Get().Labels(),
+		Env:    containerEnv,
+
+		Annotations: m.Config.ContainerAnnotations,
+		StopSignal:  m.Config.StopSignal,
+
+		LogPrefix: getLogPrefix(spec, targetPath),
+
+		MemlockLimit:  m.Config.MemlockLimitBytes,
+		HugepagesPath: m.Config.HugepagesPath,
+
+		OOMScoreAdj: m.Config.OOMScoreAdj,
+		CPUSetCPUs:  m.Config.CPUSetCPUs,
+		CPUSetMems:  m.Config.CPUSetMems,
+		IOLimits:    ioLimits,
+
+		LogMaxFileBytes: int64(m.Config.ContainerLogs.MaxFileSizeMB) * 1024 * 1024,
+		LogMaxFiles:     m.Config.ContainerLogs.MaxFiles,
+		LogCompress:     m.Config.ContainerLogs.Compress,
+		LogDriver:       m.Config.ContainerLogs.Driver,
+		LogAddress:      m.Config.ContainerLogs.FluentdAddress,
+
+		ImageLayoutDir: m.Config.ImageLayoutDir,
+
+		TargetPathMode: targetPathMode,
+
+		StartupWait: time.Duration(m.Config.StartupWaitSeconds) * time.Second,
+		Deadline:    m.getCreateDeadline(),
+
+		OnProgress:      m.reportProgress,
+		OnStep:          m.startStep,
+		OnImageResolved: m.reportImageResolved,
+	}
+	if m.Config.UserNamespace != nil {
+		containerOptions.UIDMappings = m.Config.UserNamespace.UIDMappings
+		containerOptions.GIDMappings = m.Config.UserNamespace.GIDMappings
+	}
+
+	image, err := m.getImage(spec)
+	if err != nil {
+		return err
+	}
+
+	// use the image pull secret passed by kubelet, if it holds credentials for the image's registry
+	if dockerConfigJSON := spec.GetDockerConfigJSON(); dockerConfigJSON != "" {
+		containerOptions.PullCredentials, err = getRegistryCredentials(dockerConfigJSON, image)
+		if err != nil {
+			return fmt.Errorf("Failed to get image pull credentials: %s", err)
+		}
+	}
+
+	if err := criInstance.CreateContainer(image,
 		containerName,
 		targetPath,
-		args); err != nil {
-		return fmt.Errorf("Failed to create container for %s: %s", targetPath, err)
+		args,
+		&containerOptions); err != nil {
+
+		// the error may hold the log of a process that exited while starting
+		return fmt.Errorf("Failed to create container for %s: %s", targetPath, sanitizeLog(err.Error(), spec))
+	}
+
+	m.recordContainerSpec(criInstance, containerName, targetPath, spec)
+
+	m.setPhase(PhaseWaitingForMount)
+	m.reportProgress(ProgressVerifying)
+	m.startStep(StepVerify)
+
+	// with a deadline, wait for the mount until it passes
+	if deadline := m.getDeadline(); !deadline.IsZero() {
+		for {
+			if isMountPoint(targetPath) {
+				return nil
+			}
+
+			if time.Now().After(deadline) {
+				return m.withLogTail(criInstance, containerName, spec,
+					fmt.Errorf("Failed to mount %s due to mount timeout", targetPath))
+			}
+
+			time.Sleep(time.Second)
+		}
 	}
 
 	for _, interval := range []time.Duration{1, 2, 4, 2, 1} {
@@ -232,32 +794,56 @@ func (m *Mounter) createV3IOFUSEContainer(spec *Spec, targetPath string) error {
 		time.Sleep(interval * time.Second)
 	}
 
-	return fmt.Errorf("Failed to mount %s due to timeout", targetPath)
+	return m.withLogTail(criInstance, containerName, spec, fmt.Errorf("Failed to mount %s due to timeout", targetPath))
 }
 
 func (m *Mounter) removeV3IOFUSEContainer(criInstance cri.CRI, targetPath string) error {
-	journal.Info("Removing v3io-fuse container", "target", targetPath)
+	m.logger.Info("Removing v3io-fuse container", "target", targetPath)
 
-	containerName, err := getContainerNameFromTargetPath(targetPath)
+	containerName, err := m.getContainerName(targetPath)
 	if err != nil {
 		return fmt.Errorf("Could not get container name: %s", err)
 	}
 
 	if err := criInstance.RemoveContainer(containerName); err != nil {
-		return fmt.Errorf("Could not remove container for %s: %s", targetPath, err)
+		return fmt.Errorf("Could not remove container for %s: %w", targetPath, err)
 	}
 
-	journal.Debug("Container removed", "containerName", containerName)
+	m.logger.Debug("Container removed", "containerName", containerName)
+	m.removeContainerSpecRecord(containerName)
 
 	return nil
 }
 
+// markForManualIntervention records containers whose task couldn't be deleted in state, with diagnostics
+func (m *Mounter) markForManualIntervention(targetPath string, err error) {
+	var taskDeleteError *cri.TaskDeleteError
+	if !errors.As(err, &taskDeleteError) {
+		return
+	}
+
+	m.logger.Error("Container requires manual intervention",
+		"containerName", taskDeleteError.ContainerName,
+		"targetPath", targetPath,
+		"pid", taskDeleteError.Pid)
+
+	manualInterventionName := path.Join("manual-intervention", taskDeleteError.ContainerName+".json")
+	if err := m.state.WriteJSON(manualInterventionName, map[string]interface{}{
+		"targetPath": targetPath,
+		"error":      taskDeleteError.Error(),
+		"task":       taskDeleteError,
+		"time":       time.Now(),
+	}); err != nil {
+		m.logger.Warn("Failed to write manual intervention state", "err", err.Error())
+	}
+}
+
 func (m *Mounter) mountAsLink(spec *Spec, targetPath string) *Response {
-	journal.Info("Mounting as link", "target", targetPath)
+	m.logger.Info("Mounting as link", "target", targetPath)
 	linkPath := path.Join("/mnt/v3io", spec.Namespace, spec.Container)
 
 	if !isMountPoint(linkPath) {
-		journal.Debug("Creating folder", "linkPath", linkPath)
+		m.logger.Debug("Creating folder", "linkPath", linkPath)
 		if err := os.MkdirAll(linkPath, 0755); err != nil {
 			return NewFailResponse(fmt.Sprintf("Failed to create target %s", linkPath), err)
 		}
@@ -279,7 +865,7 @@ func (m *Mounter) mountAsLink(spec *Spec, targetPath string) *Response {
 }
 
 func (m *Mounter) unmountAsLink(targetPath string) *Response {
-	journal.Info("Calling unmountAsLink command", "target", targetPath)
+	m.logger.Info("Calling unmountAsLink command", "target", targetPath)
 	if err := os.Remove(targetPath); err != nil {
 		return NewFailResponse("unable to remove link", err)
 	}
@@ -287,29 +873,6 @@ func (m *Mounter) unmountAsLink(targetPath string) *Response {
 	return NewSuccessResponse("link removed")
 }
 
-// /var/lib/kubelet/pods/0c082652-d6c7-11e9-9fd4-a4bf015abcab/volumes/v3io~fuse/v3io-fuse -> "v3io-fuse-0c082652-d6c7-11e9-9fd4-a4bf015abcab-v3io-fuse
-func getContainerNameFromTargetPath(targetPath string) (string, error) {
-	splitTargetPath := strings.Split(targetPath, string(filepath.Separator))
-
-	for targetPathPartIdx, targetPathPart := range splitTargetPath {
-
-		// if we found the pods part, return the part after it - if not at the end
-		if targetPathPart == "pods" {
-			podIDIdx := targetPathPartIdx + 1
-
-			if podIDIdx >= len(splitTargetPath) {
-				return "", fmt.Errorf("Expected a directory after pods, found it at the end: %s", targetPath)
-
-			}
-
-			// v3io-fuse-<pod id>-<last part of path, which is the volume name>
-			return fmt.Sprintf("v3io-fuse-%s-%s", splitTargetPath[podIDIdx], splitTargetPath[len(splitTargetPath)-1]), nil
-		}
-	}
-
-	return "", fmt.Errorf("Could not find pod directory in path: %s", targetPath)
-}
-
 func isMountPoint(path string) bool {
 	journal.Debug("Checking if path is a mount point", "target", path)
 
@@ -331,31 +894,3 @@ func isMountPoint(path string) bool {
 
 	return result
 }
-
-func createCRI() (cri.CRI, error) {
-	dockerBinaryPath := "/usr/bin/docker"
-
-	// if docker binary does not exist, use containerd
-	if _, err := os.Stat(dockerBinaryPath); os.IsNotExist(err) {
-		return cri.NewContainerd("/run/containerd/containerd.sock", "v3io")
-	}
-
-	// NOTE: On some managed kubernetes services, docker is installed but not activated
-	// while containerd is the CRI runtime. In this case, we want to use containerd.
-	// if docker binary exists, has systemd unit but is not running, create containerd.
-	if notRunningDocker() {
-		return cri.NewContainerd("/run/containerd/containerd.sock", "v3io")
-	}
-
-	return cri.NewDocker(dockerBinaryPath)
-}
-
-func notRunningDocker() bool {
-	cmd := exec.Command("sh", "-c", "ps -ef | grep kubelet | grep container-runtime | grep -q docker")
-	out, err := cmd.Output()
-	if err != nil {
-		return false
-	}
-	outStr := strings.TrimSpace(string(out))
-	return outStr == ""
-}
@@ -0,0 +1,253 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+)
+
+func TestGetImage(t *testing.T) {
+	for _, testCase := range []struct {
+		name              string
+		specImage         string
+		allowedImages     []string
+		allowedRegistries []string
+		expectedImage     string
+		expectedError     bool
+	}{
+		{
+			name:          "configured image",
+			expectedImage: "iguazio/v3io-fuse:1.0",
+		},
+		{
+			name:          "image option without allowed images",
+			specImage:     "iguazio/v3io-fuse:2.0",
+			expectedError: true,
+		},
+		{
+			name:          "allowed image",
+			specImage:     "iguazio/v3io-fuse:2.0",
+			allowedImages: []string{"iguazio/v3io-fuse:*"},
+			expectedImage: "iguazio/v3io-fuse:2.0",
+		},
+		{
+			name:          "image not allowed",
+			specImage:     "attacker/fuse:2.0",
+			allowedImages: []string{"iguazio/v3io-fuse:*"},
+			expectedError: true,
+		},
+		{
+			name:          "invalid reference matching a pattern",
+			specImage:     "iguazio/v3io-fuse:2.0;id",
+			allowedImages: []string{"iguazio/v3io-fuse:*"},
+			expectedError: true,
+		},
+		{
+			name:              "configured image from a registry that isn't allowed",
+			allowedRegistries: []string{"registry.example.com"},
+			expectedError:     true,
+		},
+		{
+			name:              "allowed image from an allowed registry",
+			specImage:         "registry.example.com/iguazio/v3io-fuse:2.0",
+			allowedImages:     []string{"registry.example.com/iguazio/v3io-fuse:*"},
+			allowedRegistries: []string{"registry.example.com"},
+			expectedImage:     "registry.example.com/iguazio/v3io-fuse:2.0",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			mounter := &Mounter{Config: &config.Config{
+				ImageRepository:   "iguazio/v3io-fuse",
+				ImageTag:          "1.0",
+				AllowedImages:     testCase.allowedImages,
+				AllowedRegistries: testCase.allowedRegistries,
+			}}
+
+			image, err := mounter.getImage(&Spec{Image: testCase.specImage})
+			if testCase.expectedError {
+				if err == nil {
+					t.Fatalf("Expected an error, got image %s", image)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to get image: %s", err)
+			}
+
+			if image != testCase.expectedImage {
+				t.Fatalf("Expected image %s, got %s", testCase.expectedImage, image)
+			}
+		})
+	}
+}
+
+// newFakeMounter creates a mounter whose containers are created by a fake CRI, registered as fake://<test name>
+func newFakeMounter(t *testing.T) (*Mounter, *cri.Fake) {
+	tempDir := t.TempDir()
+	configPath := path.Join(tempDir, "config.json")
+
+	if err := ioutil.WriteFile(configPath, []byte(fmt.Sprintf(`{
+		"clusters": [{"name": "default", "data_urls": ["tcp://127.0.0.1:1234"]}],
+		"runtime_endpoint": "fake://%s",
+		"state_dir": "%s",
+		"propagation_check": "off",
+		"operation_log_dir": "-",
+		"mount_events": {"delay_seconds": -1}
+	}`, t.Name(), path.Join(tempDir, "state"))), 0644); err != nil {
+		t.Fatalf("Failed to write configuration: %s", err)
+	}
+
+	mounterConfig, err := config.NewFromFile(configPath, true)
+	if err != nil {
+		t.Fatalf("Failed to read configuration: %s", err)
+	}
+
+	fake := cri.NewFake()
+	cri.RegisterFake(t.Name(), fake)
+
+	return NewMounterFromConfig(mounterConfig), fake
+}
+
+// getFakeTargetPath returns a kubelet like target path under a temporary directory
+func getFakeTargetPath(t *testing.T) string {
+	targetPath := path.Join(t.TempDir(), "pods/0a1b2c3d-pod/volumes/v3io~fuse/data")
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		t.Fatalf("Failed to create target path: %s", err)
+	}
+
+	return targetPath
+}
+
+func TestMountCreateContainerFailure(t *testing.T) {
+	if getFUSEError() != nil {
+		t.Skip("FUSE is unavailable")
+	}
+
+	mounter, fake := newFakeMounter(t)
+	targetPath := getFakeTargetPath(t)
+
+	fake.Errors["CreateContainer"] = errors.New("Injected failure")
+
+	response := mounter.Mount(targetPath, `{"accessKey": "key", "container": "bigdata"}`)
+	if response.Status != "Failure" || !strings.Contains(response.Message, "Injected failure") {
+		t.Fatalf("Expected the mount to fail with the injected failure, got %s: %s", response.Status, response.Message)
+	}
+
+	if containerNames, _ := fake.ListOwned(); len(containerNames) != 0 {
+		t.Errorf("Expected no containers, got %v", containerNames)
+	}
+
+	if mountRecords, _ := mounter.ListMountRecords(); len(mountRecords) != 0 {
+		t.Errorf("Expected no mount records, got %d", len(mountRecords))
+	}
+}
+
+func TestMountAndUnmount(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Mounting a tmpfs in place of the FUSE mount requires root")
+	}
+
+	if getFUSEError() != nil {
+		t.Skip("FUSE is unavailable")
+	}
+
+	mounter, fake := newFakeMounter(t)
+	targetPath := getFakeTargetPath(t)
+
+	// the FUSE process would mount the target path
+	fake.OnCreate = func(container *cri.FakeContainer) error {
+		output, err := exec.Command("mount", "-t", "tmpfs", "fake-fuse", container.TargetPath).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Failed to mount a tmpfs: %s (%s)", err, output)
+		}
+
+		return nil
+	}
+
+	defer exec.Command("umount", targetPath).Run() // nolint: errcheck
+
+	response := mounter.Mount(targetPath, `{"accessKey": "key", "container": "bigdata"}`)
+	if response.Status != "Success" {
+		t.Fatalf("Expected the mount to succeed, got %s", response.Message)
+	}
+
+	containerName, err := mounter.getContainerName(targetPath)
+	if err != nil {
+		t.Fatalf("Failed to get container name: %s", err)
+	}
+
+	container := fake.GetContainer(containerName)
+	if container == nil {
+		t.Fatalf("Expected container %s to be created", containerName)
+	}
+
+	if container.Image != mounter.Config.ImageRepository+":"+mounter.Config.ImageTag {
+		t.Errorf("Expected the configured image, got %s", container.Image)
+	}
+
+	if !strings.Contains(strings.Join(container.Args, " "), "--connection_strings tcp://127.0.0.1:1234") {
+		t.Errorf("Expected the cluster's data URLs in the arguments, got %v", container.Args)
+	}
+
+	if mountRecords, _ := mounter.ListMountRecords(); len(mountRecords) != 1 {
+		t.Errorf("Expected a mount record, got %d", len(mountRecords))
+	}
+
+	// an unmount failing to remove the container is resumed by the next one
+	fake.Errors["RemoveContainer"] = errors.New("Injected failure")
+
+	if response := mounter.Unmount(targetPath); response.Status != "Failure" {
+		t.Fatalf("Expected the unmount to fail, got %s", response.Message)
+	}
+
+	if mounter.getPendingUnmount(targetPath) == nil {
+		t.Fatalf("Expected a pending unmount")
+	}
+
+	delete(fake.Errors, "RemoveContainer")
+
+	if response := mounter.Unmount(targetPath); response.Status != "Success" {
+		t.Fatalf("Expected the unmount to succeed, got %s", response.Message)
+	}
+
+	if fake.GetContainer(containerName) != nil {
+		t.Errorf("Expected container %s to be removed", containerName)
+	}
+
+	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the target path to be removed")
+	}
+
+	if mountRecords, _ := mounter.ListMountRecords(); len(mountRecords) != 0 {
+		t.Errorf("Expected no mount records, got %d", len(mountRecords))
+	}
+}
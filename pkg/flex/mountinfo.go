@@ -0,0 +1,113 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// mountInfo is an entry of /proc/self/mountinfo
+type mountInfo struct {
+	mountPoint string
+	fsType     string
+	source     string
+
+	// optionalFields hold the propagation of the mount, e.g. shared:1 or master:2
+	optionalFields []string
+}
+
+// mount points in mountinfo have spaces and such octal escaped
+var mountInfoUnescaper = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+// readMountInfo returns the mounts of the mount namespace, in the order they were mounted
+func readMountInfo() ([]*mountInfo, error) {
+	mountInfoFile, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+
+	defer mountInfoFile.Close() // nolint: errcheck
+
+	var mounts []*mountInfo
+
+	scanner := bufio.NewScanner(mountInfoFile)
+	for scanner.Scan() {
+
+		// ID parent major:minor root mount-point options [optional fields...] - type source super-options
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		mount := mountInfo{
+			mountPoint: mountInfoUnescaper.Replace(fields[4]),
+		}
+
+		for fieldIdx := 6; fieldIdx < len(fields); fieldIdx++ {
+			if fields[fieldIdx] == "-" {
+				if fieldIdx+2 < len(fields) {
+					mount.fsType = fields[fieldIdx+1]
+					mount.source = mountInfoUnescaper.Replace(fields[fieldIdx+2])
+				}
+				break
+			}
+
+			mount.optionalFields = append(mount.optionalFields, fields[fieldIdx])
+		}
+
+		mounts = append(mounts, &mount)
+	}
+
+	return mounts, scanner.Err()
+}
+
+// getMountInfo returns the topmost mount of a mount point, or nil if it isn't one
+func getMountInfo(mountPoint string) (*mountInfo, error) {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var topMount *mountInfo
+	for _, mount := range mounts {
+		if mount.mountPoint == mountPoint {
+			topMount = mount
+		}
+	}
+
+	return topMount, nil
+}
+
+// getMountPoints returns the mount points of the mount namespace
+func getMountPoints() ([]string, error) {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var mountPoints []string
+	for _, mount := range mounts {
+		mountPoints = append(mountPoints, mount.mountPoint)
+	}
+
+	return mountPoints, nil
+}
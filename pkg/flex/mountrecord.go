@@ -0,0 +1,139 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+
+package flex
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/version"
+)
+
+const mountRecordsDir = "mounts"
+
+// MountRecord describes an active FUSE container mount, so it can be recreated (e.g. on upgrade). The state
+// directory is only accessible by root, as the spec holds the access key
+type MountRecord struct {
+	ContainerName string    `json:"containerName"`
+	TargetPath    string    `json:"targetPath"`
+	Spec          Spec      `json:"spec"`
+	DriverVersion string    `json:"driverVersion"`
+	MountedAt     time.Time `json:"mountedAt"`
+
+	// Image and ImageDigest are what the FUSE container was created from
+	Image       string `json:"image,omitempty"`
+	ImageDigest string `json:"imageDigest,omitempty"`
+}
+
+// ListMountRecords returns the records of all active FUSE container mounts
+func (m *Mounter) ListMountRecords() ([]*MountRecord, error) {
+	recordPaths, err := filepath.Glob(m.state.Path(path.Join(mountRecordsDir, "*.json")))
+	if err != nil {
+		return nil, err
+	}
+
+	var mountRecords []*MountRecord
+	for _, recordPath := range recordPaths {
+		mountRecord := MountRecord{}
+		if err := m.state.ReadJSON(path.Join(mountRecordsDir, filepath.Base(recordPath)), &mountRecord); err != nil {
+			m.logger.Warn("Failed to read mount record", "path", recordPath, "err", err.Error())
+			continue
+		}
+
+		mountRecords = append(mountRecords, &mountRecord)
+	}
+
+	return mountRecords, nil
+}
+
+// Remount recreates the FUSE container of a mount, with the current driver and configuration
+func (m *Mounter) Remount(mountRecord *MountRecord) *Response {
+	return m.runOperation("remount", mountRecord.TargetPath, func() *Response {
+		criInstance, err := m.newCRI()
+		if err != nil {
+			return NewFailResponse("Failed to create CRI", err)
+		}
+
+		defer criInstance.Close() // nolint: errcheck
+
+		m.setPhase(PhaseRemovingContainer)
+
+		if err := m.removeV3IOFUSEContainer(criInstance, mountRecord.TargetPath); err != nil {
+			return NewFailResponse("Failed to remove v3io FUSE container", err)
+		}
+
+		m.setPhase(PhaseUnmounting)
+
+		// the FUSE process is gone, so detach the mount lazily in case it's busy
+		if output, err := exec.Command("umount", "-l", mountRecord.TargetPath).CombinedOutput(); err != nil {
+			m.logger.Debug("Lazy unmount failed", "target", mountRecord.TargetPath, "output", string(output))
+		}
+
+		if err := os.MkdirAll(mountRecord.TargetPath, 0750); err != nil {
+			return NewFailResponse(fmt.Sprintf("Failed to create target %s", mountRecord.TargetPath), err)
+		}
+
+		return m.mountFUSE(&mountRecord.Spec, mountRecord.TargetPath)
+	})
+}
+
+func (m *Mounter) recordMount(spec *Spec, targetPath string) {
+	containerName, err := m.getContainerName(targetPath)
+	if err != nil {
+		return
+	}
+
+	mountRecord := MountRecord{
+		ContainerName: containerName,
+		TargetPath:    targetPath,
+		Spec:          *spec,
+		DriverVersion: version.Get().Version,
+		MountedAt:     time.Now(),
+	}
+
+	if m.operation != nil {
+		mountRecord.Image = m.operation.image
+		mountRecord.ImageDigest = m.operation.imageDigest
+	}
+
+	if err := m.state.WriteJSON(getMountRecordName(containerName), &mountRecord); err != nil {
+		m.logger.Warn("Failed to write mount record", "targetPath", targetPath, "err", err.Error())
+	}
+}
+
+func (m *Mounter) removeMountRecord(targetPath string) {
+	containerName, err := m.getContainerName(targetPath)
+	if err != nil {
+		return
+	}
+
+	if err := m.state.Remove(getMountRecordName(containerName)); err != nil {
+		m.logger.Warn("Failed to remove mount record", "targetPath", targetPath, "err", err.Error())
+	}
+}
+
+func getMountRecordName(containerName string) string {
+	return path.Join(mountRecordsDir, containerName+".json")
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/cri"
+)
+
+// MountStatus is the state of a recorded mount and its FUSE container
+type MountStatus struct {
+	TargetPath    string    `json:"targetPath"`
+	ContainerName string    `json:"containerName"`
+	MountedAt     time.Time `json:"mountedAt"`
+
+	// Mounted is whether the target path is a mount point
+	Mounted bool `json:"mounted"`
+
+	// ContainerState is the state of the FUSE container's process ("running", "paused", "stopped" or "created"),
+	// or "missing" if the container doesn't exist
+	ContainerState string    `json:"containerState"`
+	Pid            uint32    `json:"pid,omitempty"`
+	ExitCode       uint32    `json:"exitCode,omitempty"`
+	StartedAt      time.Time `json:"startedAt,omitempty"`
+
+	// Error is why the container's state couldn't be queried
+	Error string `json:"error,omitempty"`
+}
+
+// Healthy returns whether the target path is mounted and served by a running (or frozen) FUSE container
+func (s *MountStatus) Healthy() bool {
+	return s.Mounted && (s.ContainerState == "running" || s.ContainerState == "paused")
+}
+
+// ListMountStatuses returns the states of all recorded mounts
+func (m *Mounter) ListMountStatuses() ([]*MountStatus, error) {
+	mountRecords, err := m.ListMountRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	criInstance, err := m.newCRI()
+	if err != nil {
+		return nil, err
+	}
+
+	defer criInstance.Close() // nolint: errcheck
+
+	var mountStatuses []*MountStatus
+	for _, mountRecord := range mountRecords {
+		mountStatus := MountStatus{
+			TargetPath:    mountRecord.TargetPath,
+			ContainerName: mountRecord.ContainerName,
+			MountedAt:     mountRecord.MountedAt,
+			Mounted:       isMountPoint(mountRecord.TargetPath),
+		}
+
+		containerStatus, err := criInstance.GetContainerStatus(mountRecord.ContainerName)
+		if err != nil {
+			mountStatus.Error = err.Error()
+		} else {
+			setContainerStatus(&mountStatus, containerStatus)
+		}
+
+		mountStatuses = append(mountStatuses, &mountStatus)
+	}
+
+	return mountStatuses, nil
+}
+
+// getContainerStatus returns the status of a target path's FUSE container, which may not exist
+func (m *Mounter) getContainerStatus(targetPath string) (*cri.ContainerStatus, error) {
+	containerName, err := m.getContainerName(targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	criInstance, err := m.newCRI()
+	if err != nil {
+		return nil, err
+	}
+
+	defer criInstance.Close() // nolint: errcheck
+
+	return criInstance.GetContainerStatus(containerName)
+}
+
+// isStaleMount returns whether a target path's mount is served by a FUSE container whose process exited, detaching
+// the mount so that it's mounted afresh
+func (m *Mounter) isStaleMount(targetPath string) bool {
+	containerStatus, err := m.getContainerStatus(targetPath)
+	if err != nil || !containerStatus.Exists || containerStatus.State != "stopped" {
+		return false
+	}
+
+	m.logger.Warn("Remounting target path whose FUSE container exited",
+		"targetPath", targetPath,
+		"exitCode", containerStatus.ExitCode)
+
+	if output, err := exec.Command("umount", "-l", targetPath).CombinedOutput(); err != nil {
+		m.logger.Warn("Failed to unmount stale mount",
+			"targetPath", targetPath,
+			"err", err.Error(),
+			"output", strings.TrimSpace(string(output)))
+		return false
+	}
+
+	return true
+}
+
+func setContainerStatus(mountStatus *MountStatus, containerStatus *cri.ContainerStatus) {
+	if !containerStatus.Exists {
+		mountStatus.ContainerState = "missing"
+		return
+	}
+
+	mountStatus.ContainerState = containerStatus.State
+	mountStatus.Pid = containerStatus.Pid
+	mountStatus.ExitCode = containerStatus.ExitCode
+	mountStatus.StartedAt = containerStatus.StartedAt
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// names are kept short enough for every runtime (containerd IDs are limited to 76 characters) and for the log
+// and cgroup names derived from them
+const maxContainerNameLength = 63
+
+// characters runtimes don't allow in container names
+var invalidContainerNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// ContainerNameFields are the fields of the container_name_template
+type ContainerNameFields struct {
+	PodUID     string
+	VolumeName string
+
+	// Hash is a short hash of the target path
+	Hash string
+}
+
+// getContainerName returns the name of the FUSE container serving a target path. Mounts that were recorded keep
+// their name, even if the naming template changed since
+func (m *Mounter) getContainerName(targetPath string) (string, error) {
+	mountRecords, err := m.ListMountRecords()
+	if err == nil {
+		for _, mountRecord := range mountRecords {
+			if mountRecord.TargetPath == targetPath {
+				return mountRecord.ContainerName, nil
+			}
+		}
+	}
+
+	var containerName, untruncatedName string
+	if m.isSharedPath(targetPath) {
+		containerName, untruncatedName, err = getSharedContainerName(path.Base(targetPath))
+	} else {
+		containerName, untruncatedName, err = getContainerNameFromTargetPath(targetPath, m.Config.ContainerNameTemplate)
+	}
+
+	if err != nil || containerName == untruncatedName {
+		return containerName, err
+	}
+
+	// a truncated name may be shared by another target path, whose container would be taken over
+	for _, mountRecord := range mountRecords {
+		if mountRecord.ContainerName == containerName {
+			return "", fmt.Errorf("Container name %s of %s collides with the container of %s after truncation, "+
+				"set a container_name_template rendering shorter names",
+				containerName,
+				targetPath,
+				mountRecord.TargetPath)
+		}
+	}
+
+	return m.getLegacyContainerName(containerName, untruncatedName), nil
+}
+
+// getLegacyContainerName returns the untruncated name of a container created before names were truncated, if it
+// exists and no container has the truncated name
+func (m *Mounter) getLegacyContainerName(containerName string, untruncatedName string) string {
+	criInstance, err := m.newCRI()
+	if err != nil {
+		return containerName
+	}
+
+	defer criInstance.Close() // nolint: errcheck
+
+	if containerStatus, err := criInstance.GetContainerStatus(containerName); err != nil || containerStatus.Exists {
+		return containerName
+	}
+
+	if containerStatus, err := criInstance.GetContainerStatus(untruncatedName); err != nil || !containerStatus.Exists {
+		return containerName
+	}
+
+	m.logger.Debug("Using the untruncated name of an existing container",
+		"containerName", untruncatedName,
+		"truncatedName", containerName)
+
+	return untruncatedName
+}
+
+// /var/lib/kubelet/pods/0c082652-d6c7-11e9-9fd4-a4bf015abcab/volumes/v3io~fuse/v3io-fuse -> "v3io-fuse-0c082652-d6c7-11e9-9fd4-a4bf015abcab-v3io-fuse
+// /var/lib/kubelet/plugins/kubernetes.io/flexvolume/v3io/fuse/mounts/my-pv -> "v3io-fuse-device-my-pv
+// The untruncated name is returned as well, as containers were named so before names were truncated
+func getContainerNameFromTargetPath(targetPath string, nameTemplate string) (string, string, error) {
+	splitTargetPath := strings.Split(targetPath, string(filepath.Separator))
+
+	fields := ContainerNameFields{
+		VolumeName: getVolumeDirName(splitTargetPath),
+		Hash:       getTargetPathHash(targetPath),
+	}
+
+	for targetPathPartIdx, targetPathPart := range splitTargetPath {
+
+		// if we found the pods part, return the part after it - if not at the end
+		if targetPathPart == "pods" {
+			podIDIdx := targetPathPartIdx + 1
+
+			if podIDIdx >= len(splitTargetPath) {
+				return "", "", fmt.Errorf("Expected a directory after pods, found it at the end: %s", targetPath)
+
+			}
+
+			fields.PodUID = splitTargetPath[podIDIdx]
+
+			// v3io-fuse-<pod id>-<last part of path, which is the volume name> by default
+			return renderContainerName(nameTemplate, &fields)
+		}
+	}
+
+	// device mount paths are shared by pods, and named after the volume
+	if len(splitTargetPath) > 1 && splitTargetPath[len(splitTargetPath)-2] == "mounts" {
+		return renderContainerName("device-{{.VolumeName}}", &fields)
+	}
+
+	return "", "", fmt.Errorf("Could not find pod directory in path: %s", targetPath)
+}
+
+// renderContainerName renders a naming template, prefixed with ContainerNamePrefix. Names that are too long are
+// truncated and suffixed with a hash of the untruncated name, so that names differing only in their truncated part
+// remain unique, while target paths rendering the same name get the same truncated name and collide. The untruncated
+// name is returned as well
+func renderContainerName(nameTemplate string, fields *ContainerNameFields) (string, string, error) {
+	parsedTemplate, err := template.New("container_name_template").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid container_name_template: %s", err)
+	}
+
+	var renderedName bytes.Buffer
+	if err := parsedTemplate.Execute(&renderedName, fields); err != nil {
+		return "", "", fmt.Errorf("Failed to render container_name_template: %s", err)
+	}
+
+	containerName := ContainerNamePrefix + invalidContainerNameChars.ReplaceAllString(renderedName.String(), "-")
+	if len(containerName) <= maxContainerNameLength {
+		return containerName, containerName, nil
+	}
+
+	nameHash := getHash(containerName)
+	truncatedName := containerName[:maxContainerNameLength-len(nameHash)-1] + "-" + nameHash
+
+	journal.Debug("Container name is too long, truncating",
+		"containerName", containerName,
+		"truncatedName", truncatedName)
+
+	return truncatedName, containerName, nil
+}
+
+func getTargetPathHash(targetPath string) string {
+	return getHash(filepath.Clean(targetPath))
+}
+
+func getHash(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])[:10]
+}
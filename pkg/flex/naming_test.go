@@ -0,0 +1,241 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetContainerNameFromTargetPath(t *testing.T) {
+	for _, testCase := range []struct {
+		name                    string
+		targetPath              string
+		expectedName            string
+		expectedUntruncatedName string
+		expectedError           bool
+	}{
+		{
+			name:                    "pod volume",
+			targetPath:              "/var/lib/kubelet/pods/0c082652/volumes/v3io~fuse/data",
+			expectedName:            "v3io-fuse-0c082652-data",
+			expectedUntruncatedName: "v3io-fuse-0c082652-data",
+		},
+		{
+			name:                    "device mount",
+			targetPath:              "/var/lib/kubelet/plugins/kubernetes.io/flexvolume/v3io/fuse/mounts/my-pv",
+			expectedName:            "v3io-fuse-device-my-pv",
+			expectedUntruncatedName: "v3io-fuse-device-my-pv",
+		},
+		{
+			name:          "pods at the end",
+			targetPath:    "/var/lib/kubelet/pods",
+			expectedError: true,
+		},
+		{
+			name:          "no pod directory",
+			targetPath:    "/mnt/data",
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			containerName, untruncatedName, err := getContainerNameFromTargetPath(testCase.targetPath,
+				"{{.PodUID}}-{{.VolumeName}}")
+			if testCase.expectedError {
+				if err == nil {
+					t.Fatalf("Expected an error, got %s", containerName)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to get container name: %s", err)
+			}
+
+			if containerName != testCase.expectedName || untruncatedName != testCase.expectedUntruncatedName {
+				t.Errorf("Expected names %s and %s, got %s and %s",
+					testCase.expectedName,
+					testCase.expectedUntruncatedName,
+					containerName,
+					untruncatedName)
+			}
+		})
+	}
+}
+
+func TestGetContainerNameTruncated(t *testing.T) {
+	targetPath := "/var/lib/kubelet/pods/0c082652-d6c7-11e9-9fd4-a4bf015abcab/volumes/v3io~fuse/a-volume-whose-name-is-long-enough-to-be-truncated"
+	truncatedName, untruncatedName, err := getContainerNameFromTargetPath(targetPath, "{{.PodUID}}-{{.VolumeName}}")
+	if err != nil {
+		t.Fatalf("Failed to get container name: %s", err)
+	}
+
+	if len(truncatedName) != maxContainerNameLength || !strings.HasSuffix(truncatedName, getHash(untruncatedName)) {
+		t.Fatalf("Expected a truncated name suffixed with the untruncated name's hash, got %s", truncatedName)
+	}
+
+	for _, testCase := range []struct {
+		name              string
+		nameTemplate      string
+		existingNames     []string
+		recordedNames     map[string]string
+		otherTargetPath   string
+		expectedName      string
+		expectedCollision bool
+	}{
+		{
+			name:         "new mount",
+			expectedName: truncatedName,
+		},
+		{
+			name:          "container created before names were truncated",
+			existingNames: []string{untruncatedName},
+			expectedName:  untruncatedName,
+		},
+		{
+			name:          "both exist",
+			existingNames: []string{truncatedName, untruncatedName},
+			expectedName:  truncatedName,
+		},
+		{
+			name:          "recorded mount",
+			recordedNames: map[string]string{targetPath: truncatedName},
+			expectedName:  truncatedName,
+		},
+		{
+			name:              "collision with another target path",
+			recordedNames:     map[string]string{"/var/lib/kubelet/pods/other/volumes/v3io~fuse/data": truncatedName},
+			expectedCollision: true,
+		},
+		{
+			name:              "collision with the same name of another pod",
+			nameTemplate:      "{{.VolumeName}}-of-a-template-long-enough-to-be-truncated",
+			otherTargetPath:   "/var/lib/kubelet/pods/1d193763-e7d8-22fa-afe5-b5cf126bcdbc/volumes/v3io~fuse/a-volume-whose-name-is-long-enough-to-be-truncated",
+			expectedCollision: true,
+		},
+		{
+			name:            "another name differing after the truncation",
+			otherTargetPath: "/var/lib/kubelet/pods/0c082652-d6c7-11e9-9fd4-a4bf015abcab/volumes/v3io~fuse/a-volume-whose-name-is-long-enough-to-be-truncated-too",
+			expectedName:    truncatedName,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			mounter, fake := newFakeMounter(t)
+			mounter.Config.ContainerNameTemplate = "{{.PodUID}}-{{.VolumeName}}"
+			if testCase.nameTemplate != "" {
+				mounter.Config.ContainerNameTemplate = testCase.nameTemplate
+			}
+
+			// record the other target path's mount under the name it renders
+			if testCase.otherTargetPath != "" {
+				otherName, err := mounter.getContainerName(testCase.otherTargetPath)
+				if err != nil {
+					t.Fatalf("Failed to get container name: %s", err)
+				}
+
+				testCase.recordedNames = map[string]string{testCase.otherTargetPath: otherName}
+			}
+
+			for _, existingName := range testCase.existingNames {
+				if err := fake.CreateContainer("image", existingName, targetPath, nil, nil); err != nil {
+					t.Fatalf("Failed to create container: %s", err)
+				}
+			}
+
+			for recordedTargetPath, recordedName := range testCase.recordedNames {
+				if err := mounter.state.WriteJSON(getMountRecordName(recordedName), &MountRecord{
+					ContainerName: recordedName,
+					TargetPath:    recordedTargetPath,
+				}); err != nil {
+					t.Fatalf("Failed to write mount record: %s", err)
+				}
+			}
+
+			containerName, err := mounter.getContainerName(targetPath)
+			if testCase.expectedCollision {
+				if err == nil {
+					t.Fatalf("Expected a collision, got %s", containerName)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to get container name: %s", err)
+			}
+
+			if containerName != testCase.expectedName {
+				t.Errorf("Expected %s, got %s", testCase.expectedName, containerName)
+			}
+		})
+	}
+}
+
+func TestRenderContainerName(t *testing.T) {
+	podUID := "0c082652-d6c7-11e9-9fd4-a4bf015abcab"
+
+	for _, testCase := range []struct {
+		name              string
+		volumeName        string
+		expectedTruncated bool
+	}{
+		{
+			name:       "16 character volume name",
+			volumeName: "sixteen-char-vol",
+		},
+		{
+			name:              "17 character volume name",
+			volumeName:        "seventeen-char-vo",
+			expectedTruncated: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			containerName, untruncatedName, err := renderContainerName("{{.PodUID}}-{{.VolumeName}}",
+				&ContainerNameFields{PodUID: podUID, VolumeName: testCase.volumeName})
+			if err != nil {
+				t.Fatalf("Failed to render container name: %s", err)
+			}
+
+			if expectedUntruncatedName := ContainerNamePrefix + podUID + "-" + testCase.volumeName; untruncatedName != expectedUntruncatedName {
+				t.Fatalf("Expected untruncated name %s, got %s", expectedUntruncatedName, untruncatedName)
+			}
+
+			if !testCase.expectedTruncated {
+				if containerName != untruncatedName {
+					t.Fatalf("Expected %s not to be truncated, got %s", untruncatedName, containerName)
+				}
+
+				return
+			}
+
+			if len(containerName) != maxContainerNameLength ||
+				containerName != untruncatedName[:maxContainerNameLength-11]+"-"+getHash(untruncatedName) {
+				t.Fatalf("Expected %s truncated and suffixed with its hash, got %s", untruncatedName, containerName)
+			}
+
+			// the same name always truncates the same, regardless of the target path
+			if renderedAgain, _, _ := renderContainerName("{{.PodUID}}-{{.VolumeName}}",
+				&ContainerNameFields{PodUID: podUID, VolumeName: testCase.volumeName, Hash: "0123456789"}); renderedAgain != containerName {
+				t.Fatalf("Expected %s rendered again, got %s", containerName, renderedAgain)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"net"
+)
+
+// getSourceAddress returns the address the FUSE client binds its data connections to - the volume's
+// source IP or interface, falling back to the configured ones. Empty if none is set
+func (m *Mounter) getSourceAddress(spec *Spec) (string, error) {
+	switch {
+	case spec.DataSourceIP != "":
+		return spec.DataSourceIP, nil
+	case spec.DataInterface != "":
+		return getInterfaceAddress(spec.DataInterface)
+	case m.Config.DataSourceIP != "":
+		return m.Config.DataSourceIP, nil
+	case m.Config.DataInterface != "":
+		return getInterfaceAddress(m.Config.DataInterface)
+	}
+
+	return "", nil
+}
+
+// getInterfaceAddress returns the first IPv4 address of a host interface, or its first address if it has none.
+// The FUSE container shares the host's network namespace, so no routes have to be added to it
+func getInterfaceAddress(interfaceName string) (string, error) {
+	dataInterface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return "", fmt.Errorf("Failed to find data interface %s: %s", interfaceName, err)
+	}
+
+	interfaceAddresses, err := dataInterface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get addresses of data interface %s: %s", interfaceName, err)
+	}
+
+	var interfaceIPs []net.IP
+	for _, interfaceAddress := range interfaceAddresses {
+		if ipNet, ok := interfaceAddress.(*net.IPNet); ok {
+			interfaceIPs = append(interfaceIPs, ipNet.IP)
+		}
+	}
+
+	if len(interfaceIPs) == 0 {
+		return "", fmt.Errorf("Data interface %s has no addresses", interfaceName)
+	}
+
+	for _, interfaceIP := range interfaceIPs {
+		if interfaceIP.To4() != nil {
+			return interfaceIP.String(), nil
+		}
+	}
+
+	return interfaceIPs[0].String(), nil
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+
+package flex
+
+import (
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/state"
+)
+
+const (
+	PhaseValidating          = "Validating"
+	PhaseCheckingTargetPath  = "CheckingTargetPath"
+	PhaseCheckingPropagation = "CheckingPropagation"
+	PhaseCreatingContainer   = "CreatingContainer"
+	PhaseWaitingForMount     = "WaitingForMount"
+	PhaseCreatingDirs        = "CreatingDirs"
+	PhaseRemovingContainer   = "RemovingContainer"
+	PhaseUnmounting          = "Unmounting"
+	PhaseDone                = "Done"
+)
+
+// error codes reported in results of operations that failed in a given phase
+var phaseErrorCodes = map[string]string{
+	PhaseValidating:          "InvalidRequest",
+	PhaseCheckingTargetPath:  "ForeignMount",
+	PhaseCheckingPropagation: "PropagationNotShared",
+	PhaseCreatingContainer:   "ContainerCreationFailed",
+	PhaseWaitingForMount:     "MountTimeout",
+	PhaseCreatingDirs:        "DirCreationFailed",
+	PhaseRemovingContainer:   "ContainerRemovalFailed",
+	PhaseUnmounting:          "UnmountFailed",
+}
+
+const resultsDir = "results"
+
+// locks of the target paths, held by their operations
+const operationLocksDir = "locks"
+
+// Result is the outcome of an operation, written per target path for the monitor and support tooling
+type Result struct {
+	Operation       string    `json:"operation"`
+	TraceID         string    `json:"traceId"`
+	TargetPath      string    `json:"targetPath"`
+	Status          string    `json:"status"`
+	Message         string    `json:"message"`
+	Phase           string    `json:"phase"`
+	ErrorCode       string    `json:"errorCode,omitempty"`
+	StartedAt       time.Time `json:"startedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+
+	// Phases the operation went through, in order
+	Phases []PhaseDuration `json:"phases,omitempty"`
+
+	// Image and ImageDigest are what the FUSE container was created from, if the operation created one
+	Image       string `json:"image,omitempty"`
+	ImageDigest string `json:"imageDigest,omitempty"`
+}
+
+// PhaseDuration is the time an operation spent in a phase
+type PhaseDuration struct {
+	Phase           string  `json:"phase"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// operation tracks the phase and duration of a single mount/unmount invocation
+type operation struct {
+	logger         *journal.Logger
+	name           string
+	targetPath     string
+	phase          string
+	phaseStartedAt time.Time
+	phases         []PhaseDuration
+	startedAt      time.Time
+	deadline       time.Time
+	image          string
+	imageDigest    string
+	namespace      string
+	podName        string
+}
+
+func newOperation(name string, targetPath string, logger *journal.Logger) *operation {
+	now := time.Now()
+
+	return &operation{
+		logger:         logger,
+		name:           name,
+		targetPath:     targetPath,
+		phase:          PhaseValidating,
+		phaseStartedAt: now,
+		startedAt:      now,
+	}
+}
+
+func (o *operation) setPhase(phase string) {
+	o.logger.Debug("Operation phase changed", "operation", o.name, "targetPath", o.targetPath, "phase", phase)
+	o.endPhase()
+	o.phase = phase
+}
+
+// endPhase records the time spent in the current phase
+func (o *operation) endPhase() {
+	now := time.Now()
+
+	o.phases = append(o.phases, PhaseDuration{
+		Phase:           o.phase,
+		DurationSeconds: now.Sub(o.phaseStartedAt).Seconds(),
+	})
+	o.phaseStartedAt = now
+}
+
+// reportImageResolved records the image and digest the operation's FUSE container is created from
+func (m *Mounter) reportImageResolved(image string, imageDigest string) {
+	m.logger.Info("Resolved FUSE image", "image", image, "imageDigest", imageDigest)
+
+	if m.operation != nil {
+		m.operation.image = image
+		m.operation.imageDigest = imageDigest
+	}
+}
+
+func (o *operation) finish(response *Response) *Result {
+	o.endPhase()
+
+	result := Result{
+		Operation:       o.name,
+		TraceID:         o.logger.TraceID(),
+		TargetPath:      o.targetPath,
+		Status:          response.Status,
+		Message:         response.Message,
+		Phase:           o.phase,
+		StartedAt:       o.startedAt,
+		DurationSeconds: time.Since(o.startedAt).Seconds(),
+		Phases:          o.phases,
+		Image:           o.image,
+		ImageDigest:     o.imageDigest,
+	}
+
+	if response.Status == "Failure" {
+		result.ErrorCode = phaseErrorCodes[o.phase]
+	} else {
+		result.Phase = PhaseDone
+	}
+
+	return &result
+}
+
+// MarkFailed records a failure of a target path's mount detected outside of an operation (e.g. by the monitor)
+// as its last result
+func (m *Mounter) MarkFailed(targetPath string, operationName string, errorCode string, message string) error {
+	return m.state.WriteJSON(getResultName(targetPath), &Result{
+		Operation:  operationName,
+		TraceID:    m.logger.TraceID(),
+		TargetPath: targetPath,
+		Status:     "Failure",
+		Message:    message,
+		Phase:      PhaseDone,
+		ErrorCode:  errorCode,
+		StartedAt:  time.Now(),
+	})
+}
+
+// ListResults returns the last result of every target path
+func (m *Mounter) ListResults() ([]*Result, error) {
+	resultPaths, err := filepath.Glob(m.state.Path(path.Join(resultsDir, "*.json")))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	for _, resultPath := range resultPaths {
+		result := Result{}
+		if err := m.state.ReadJSON(path.Join(resultsDir, filepath.Base(resultPath)), &result); err != nil {
+			m.logger.Debug("Failed to read result", "path", resultPath, "err", err.Error())
+			continue
+		}
+
+		results = append(results, &result)
+	}
+
+	return results, nil
+}
+
+// lockTargetPath runs an operation's handler holding the target path's lock, so that operations of a target path
+// by concurrent invocations (e.g. kubelet and the monitor) don't interleave
+func (m *Mounter) lockTargetPath(targetPath string, handler func() *Response) *Response {
+	lock, err := m.state.Lock(path.Join(operationLocksDir, state.NameFromPath(targetPath)))
+	if err != nil {
+		return NewFailResponse("Failed to lock target path", err)
+	}
+
+	defer lock.Unlock() // nolint: errcheck
+
+	return handler()
+}
+
+// getResultName returns the name of the state document holding the last result for a target path
+func getResultName(targetPath string) string {
+	return path.Join(resultsDir, state.NameFromPath(targetPath)+".json")
+}
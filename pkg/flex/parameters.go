@@ -0,0 +1,194 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MountParameters are the volume options that can be set by StorageClass parameters and PV options, by their
+// option names
+var MountParameters = []string{
+	"cluster",
+	"container",
+	"subPath",
+	"dirsToCreate",
+	"mountTimeout",
+	"connectionPoolSize",
+	"dataInterface",
+	"dataSourceIP",
+	"fuseOptions",
+	"profile",
+	"image",
+	"auth",
+	"ioReadBps",
+	"ioWriteBps",
+	"ioReadIops",
+	"ioWriteIops",
+}
+
+// volumeOptionNames are the flexvolume options of mount requests other than MountParameters - the access key
+// overriding the secret's and the PVC name set by the provisioner
+var volumeOptionNames = []string{"accessKey", "pvcName"}
+
+// kubeletOptionPrefixes prefix the options kubelet adds to mount requests (the pod's details, fsType, readwrite
+// and the keys of the volume's secret), and the CSI volume context keys of translated invocations
+var kubeletOptionPrefixes = []string{"kubernetes.io/", "csi.storage.k8s.io/"}
+
+// NewSpecFromParameters converts StorageClass parameters or PV options to a spec, the mount request shared by
+// all paths. Parameters other than MountParameters are an error, unless ignored (e.g. provisioner parameters)
+func NewSpecFromParameters(parameters map[string]string, ignoredParameters ...string) (*Spec, error) {
+	mountParameters := map[string]string{}
+
+	for name, value := range parameters {
+		switch {
+		case isMountParameter(name):
+			mountParameters[name] = value
+		case contains(ignoredParameters, name):
+		default:
+			return nil, fmt.Errorf("Unknown parameter %q, expected one of %s",
+				name,
+				strings.Join(append(MountParameters, ignoredParameters...), ", "))
+		}
+	}
+
+	// the parameters are named by the spec's fields, so they're converted through it
+	encodedParameters, err := json.Marshal(mountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := Spec{}
+	if err := json.Unmarshal(encodedParameters, &spec); err != nil {
+		return nil, err
+	}
+
+	if err := spec.validateParameters(); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// validateOptionNames validates the option names of a flexvolume mount request as NewSpecFromParameters validates
+// parameter names, so that a misspelled option fails the mount rather than being ignored
+func validateOptionNames(specString string) error {
+	options := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(specString), &options); err != nil {
+		return err
+	}
+
+	for name := range options {
+		if isMountParameter(name) || contains(volumeOptionNames, name) || isKubeletOption(name) {
+			continue
+		}
+
+		return fmt.Errorf("Unknown option %q, expected one of %s",
+			name,
+			strings.Join(append(MountParameters, volumeOptionNames...), ", "))
+	}
+
+	return nil
+}
+
+func isKubeletOption(name string) bool {
+	for _, kubeletOptionPrefix := range kubeletOptionPrefixes {
+		if strings.HasPrefix(name, kubeletOptionPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parameters returns the spec's mount parameters that are set, e.g. to be passed as PV options
+func (s *Spec) Parameters() map[string]string {
+	encodedSpec, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+
+	fields := map[string]string{}
+	if err := json.Unmarshal(encodedSpec, &fields); err != nil {
+		return nil
+	}
+
+	parameters := map[string]string{}
+	for _, name := range MountParameters {
+		if fields[name] != "" {
+			parameters[name] = fields[name]
+		}
+	}
+
+	return parameters
+}
+
+// validateParameters validates the options that are independent of the pod and secret
+func (s *Spec) validateParameters() error {
+	if s.SubPath != "" && s.Container == "" {
+		return errors.New("can't have subpath without container value")
+	}
+
+	if _, err := s.GetMountTimeout(); err != nil {
+		return err
+	}
+
+	if _, err := s.GetConnectionPoolSize(); err != nil {
+		return err
+	}
+
+	if s.DataSourceIP != "" && net.ParseIP(s.DataSourceIP) == nil {
+		return fmt.Errorf("invalid dataSourceIP %q", s.DataSourceIP)
+	}
+
+	if _, err := s.GetFUSEOptions(); err != nil {
+		return err
+	}
+
+	if _, err := s.GetIOLimits(); err != nil {
+		return err
+	}
+
+	switch s.Auth {
+	case "", AuthAccessKey, AuthServiceAccountToken:
+	default:
+		return fmt.Errorf("invalid auth %q, expected %q or %q", s.Auth, AuthAccessKey, AuthServiceAccountToken)
+	}
+
+	return nil
+}
+
+func isMountParameter(name string) bool {
+	return contains(MountParameters, name)
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateOptionNames(t *testing.T) {
+	for _, testCase := range []struct {
+		name          string
+		specString    string
+		expectedError bool
+	}{
+		{
+			name: "kubelet invocation",
+			specString: `{"container": "bigdata", "subPath": "/data", "kubernetes.io/fsType": "", ` +
+				`"kubernetes.io/readwrite": "rw", "kubernetes.io/pod.name": "pod", ` +
+				`"kubernetes.io/secret/accessKey": "key", "kubernetes.io/secret/username": "user"}`,
+		},
+		{
+			name:       "provisioned volume options",
+			specString: `{"container": "pvc-0a1b", "pvcName": "data", "accessKey": "key"}`,
+		},
+		{
+			name:       "translated CSI invocation",
+			specString: `{"container": "bigdata", "csi.storage.k8s.io/ephemeral": "true"}`,
+		},
+		{
+			name:          "misspelled option",
+			specString:    `{"container": "bigdata", "subpath": "/data"}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid JSON",
+			specString:    `{"container": `,
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := validateOptionNames(testCase.specString)
+			if (err != nil) != testCase.expectedError {
+				t.Errorf("Expected error %v, got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestValidateOptionNamesOfSpec(t *testing.T) {
+
+	// a marshaled spec (e.g. by the CSI mounter or a recorded mount) is a valid mount request
+	encodedSpec, err := json.Marshal(&Spec{Container: "bigdata", PVCName: "data", OverrideAccessKey: "key"})
+	if err != nil {
+		t.Fatalf("Failed to marshal spec: %s", err)
+	}
+
+	if err := validateOptionNames(string(encodedSpec)); err != nil {
+		t.Errorf("Expected a marshaled spec to be valid, got %s", err)
+	}
+}
+
+func TestNewSpecFromParameters(t *testing.T) {
+	for _, testCase := range []struct {
+		name              string
+		parameters        map[string]string
+		ignoredParameters []string
+		expectedError     bool
+	}{
+		{
+			name:       "mount parameters",
+			parameters: map[string]string{"container": "bigdata", "mountTimeout": "30s"},
+		},
+		{
+			name:              "ignored parameter",
+			parameters:        map[string]string{"container": "bigdata", "quota": "10Gi"},
+			ignoredParameters: []string{"quota"},
+		},
+		{
+			name:          "unknown parameter",
+			parameters:    map[string]string{"container": "bigdata", "quota": "10Gi"},
+			expectedError: true,
+		},
+		{
+			name:          "invalid parameter",
+			parameters:    map[string]string{"subPath": "/data"},
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := NewSpecFromParameters(testCase.parameters, testCase.ignoredParameters...)
+			if (err != nil) != testCase.expectedError {
+				t.Errorf("Expected error %v, got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
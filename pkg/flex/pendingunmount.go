@@ -0,0 +1,160 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/state"
+)
+
+// steps of unmounting, recorded as they complete so that an interrupted or failed unmount resumes after them
+const (
+	UnmountStepContainerRemoved  = "ContainerRemoved"
+	UnmountStepUnmounted         = "Unmounted"
+	UnmountStepTargetPathRemoved = "TargetPathRemoved"
+)
+
+const pendingUnmountsDir = "unmounts"
+
+// PendingUnmount is an unmount that started but didn't complete
+type PendingUnmount struct {
+	TargetPath    string    `json:"targetPath"`
+	ContainerName string    `json:"containerName"`
+	StartedAt     time.Time `json:"startedAt"`
+
+	// Steps are the steps completed, in order
+	Steps []string `json:"steps"`
+
+	// Error is why the last attempt failed
+	Error string `json:"error,omitempty"`
+}
+
+// completed returns whether a step of the unmount completed
+func (p *PendingUnmount) completed(step string) bool {
+	return contains(p.Steps, step)
+}
+
+// ListPendingUnmounts returns the unmounts that started but didn't complete
+func (m *Mounter) ListPendingUnmounts() ([]*PendingUnmount, error) {
+	pendingUnmountPaths, err := filepath.Glob(m.state.Path(path.Join(pendingUnmountsDir, "*.json")))
+	if err != nil {
+		return nil, err
+	}
+
+	var pendingUnmounts []*PendingUnmount
+	for _, pendingUnmountPath := range pendingUnmountPaths {
+		pendingUnmount := PendingUnmount{}
+		if err := m.state.ReadJSON(path.Join(pendingUnmountsDir, filepath.Base(pendingUnmountPath)),
+			&pendingUnmount); err != nil {
+			m.logger.Debug("Failed to read pending unmount", "path", pendingUnmountPath, "err", err.Error())
+			continue
+		}
+
+		pendingUnmounts = append(pendingUnmounts, &pendingUnmount)
+	}
+
+	return pendingUnmounts, nil
+}
+
+// ResumeUnmounts completes the pending unmounts, returning the response of each by target path
+func (m *Mounter) ResumeUnmounts() (map[string]*Response, error) {
+	pendingUnmounts, err := m.ListPendingUnmounts()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := map[string]*Response{}
+	for _, pendingUnmount := range pendingUnmounts {
+		responses[pendingUnmount.TargetPath] = m.Unmount(pendingUnmount.TargetPath)
+	}
+
+	return responses, nil
+}
+
+// getPendingUnmount returns the pending unmount of a target path, or nil if it has none
+func (m *Mounter) getPendingUnmount(targetPath string) *PendingUnmount {
+	pendingUnmount := PendingUnmount{}
+	if err := m.state.ReadJSON(getPendingUnmountName(targetPath), &pendingUnmount); err != nil {
+		return nil
+	}
+
+	return &pendingUnmount
+}
+
+// startPendingUnmount records that a target path's unmount started
+func (m *Mounter) startPendingUnmount(targetPath string) (*PendingUnmount, error) {
+	containerName, err := m.getContainerName(targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get container name: %s", err)
+	}
+
+	pendingUnmount := PendingUnmount{
+		TargetPath:    targetPath,
+		ContainerName: containerName,
+		StartedAt:     time.Now(),
+	}
+
+	if err := m.state.WriteJSON(getPendingUnmountName(targetPath), &pendingUnmount); err != nil {
+		m.logger.Warn("Failed to record pending unmount", "targetPath", targetPath, "err", err.Error())
+	}
+
+	return &pendingUnmount, nil
+}
+
+// completeUnmountStep records that a step of a pending unmount completed
+func (m *Mounter) completeUnmountStep(pendingUnmount *PendingUnmount, step string) {
+	m.logger.Debug("Unmount step completed", "targetPath", pendingUnmount.TargetPath, "step", step)
+
+	pendingUnmount.Steps = append(pendingUnmount.Steps, step)
+	pendingUnmount.Error = ""
+
+	if err := m.state.WriteJSON(getPendingUnmountName(pendingUnmount.TargetPath), pendingUnmount); err != nil {
+		m.logger.Warn("Failed to record unmount step", "targetPath", pendingUnmount.TargetPath, "err", err.Error())
+	}
+}
+
+// failPendingUnmount records why a pending unmount failed, returning a failure response
+func (m *Mounter) failPendingUnmount(pendingUnmount *PendingUnmount, message string, err error) *Response {
+	pendingUnmount.Error = message
+	if err != nil {
+		pendingUnmount.Error = fmt.Sprintf("%s: %s", message, err)
+	}
+
+	if err := m.state.WriteJSON(getPendingUnmountName(pendingUnmount.TargetPath), pendingUnmount); err != nil {
+		m.logger.Warn("Failed to record unmount failure", "targetPath", pendingUnmount.TargetPath, "err", err.Error())
+	}
+
+	return NewFailResponse(message, err)
+}
+
+// removePendingUnmount removes the record of a completed unmount
+func (m *Mounter) removePendingUnmount(targetPath string) {
+	if err := m.state.Remove(getPendingUnmountName(targetPath)); err != nil {
+		m.logger.Warn("Failed to remove pending unmount", "targetPath", targetPath, "err", err.Error())
+	}
+}
+
+func getPendingUnmountName(targetPath string) string {
+	return path.Join(pendingUnmountsDir, state.NameFromPath(targetPath)+".json")
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// getPodEnv returns the environment variables identifying the pod and PVC of a mount in its FUSE container, so
+// that the data plane can be attributed to workloads
+func getPodEnv(spec *Spec, targetPath string) []string {
+	var podEnv []string
+
+	for _, envVar := range [][2]string{
+		{"V3IO_POD_NAME", spec.PodName},
+		{"V3IO_POD_NAMESPACE", spec.Namespace},
+		{"V3IO_POD_UID", getPodUID(spec, targetPath)},
+		{"V3IO_PVC_NAME", spec.PVCName},
+	} {
+		if envVar[1] != "" {
+			podEnv = append(podEnv, envVar[0]+"="+envVar[1])
+		}
+	}
+
+	return podEnv
+}
+
+// getLogPrefix returns the prefix of the FUSE container's log lines, e.g. "pod=default/my-pod pvc=data"
+func getLogPrefix(spec *Spec, targetPath string) string {
+	var logPrefix []string
+
+	if spec.PodName != "" {
+		logPrefix = append(logPrefix, fmt.Sprintf("pod=%s/%s", spec.Namespace, spec.PodName))
+	} else if podUID := getPodUID(spec, targetPath); podUID != "" {
+		logPrefix = append(logPrefix, "podUID="+podUID)
+	}
+
+	if spec.PVCName != "" {
+		logPrefix = append(logPrefix, "pvc="+spec.PVCName)
+	}
+
+	return strings.Join(logPrefix, " ")
+}
+
+// getPodUID returns the UID of a mount's pod, passed by kubelet or taken from the target path
+func getPodUID(spec *Spec, targetPath string) string {
+	if spec.PodUID != "" {
+		return spec.PodUID
+	}
+
+	return GetPodUIDFromTargetPath(targetPath)
+}
+
+// GetPodUIDFromTargetPath returns the pod UID in a pod volume's target path, or an empty string for other paths
+func GetPodUIDFromTargetPath(targetPath string) string {
+	targetPathParts := strings.Split(targetPath, "/")
+	for targetPathPartIdx, targetPathPart := range targetPathParts {
+		if targetPathPart == "pods" && targetPathPartIdx+1 < len(targetPathParts) {
+			return targetPathParts[targetPathPartIdx+1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// applyProfile sets the options of the spec's profile (from the profiles configuration) that the spec
+// doesn't set itself
+func (m *Mounter) applyProfile(spec *Spec) error {
+	if spec.Profile == "" {
+		return nil
+	}
+
+	profileParameters, found := m.Config.Profiles[spec.Profile]
+	if !found {
+		return fmt.Errorf("unknown profile %q", spec.Profile)
+	}
+
+	profileSpec, err := NewSpecFromParameters(profileParameters)
+	if err != nil {
+		return fmt.Errorf("invalid profile %q: %s", spec.Profile, err)
+	}
+
+	// all options are strings, so they're merged by their names
+	encodedSpec, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]string{}
+	if err := json.Unmarshal(encodedSpec, &fields); err != nil {
+		return err
+	}
+
+	for name, value := range profileSpec.Parameters() {
+		if fields[name] == "" {
+			fields[name] = value
+		}
+	}
+
+	encodedSpec, err = json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encodedSpec, spec)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// checkPropagation verifies that the mount holding a target path is shared, as the FUSE mount made in the
+// container only propagates back to the host (and from it to pods) through a shared mount. Otherwise pods see an
+// empty volume, with nothing failing
+func (m *Mounter) checkPropagation(targetPath string) error {
+	if m.Config.PropagationCheck == "off" {
+		return nil
+	}
+
+	mounts, err := readMountInfo()
+	if err != nil {
+		m.logger.Debug("Failed to read mounts, skipping propagation check", "err", err.Error())
+		return nil
+	}
+
+	var parentMount *mountInfo
+	for _, mount := range mounts {
+		if isPathUnder(targetPath, mount.mountPoint) &&
+			(parentMount == nil || len(mount.mountPoint) >= len(parentMount.mountPoint)) {
+			parentMount = mount
+		}
+	}
+
+	if parentMount == nil || isSharedMount(parentMount) {
+		return nil
+	}
+
+	err = fmt.Errorf("The %s mount holding %s isn't shared, so the FUSE mount won't propagate to pods "+
+		"(which would see an empty volume). Make it shared on the host with \"mount --make-rshared %s\", and make "+
+		"sure the systemd units of kubelet and the container runtime don't set MountFlags=private or slave",
+		parentMount.mountPoint,
+		targetPath,
+		parentMount.mountPoint)
+
+	if m.Config.PropagationCheck == "warn" {
+		m.logger.Warn("Mount propagation check failed", "err", err.Error())
+		return nil
+	}
+
+	return err
+}
+
+// isSharedMount returns whether a mount propagates to its peers
+func isSharedMount(mount *mountInfo) bool {
+	for _, optionalField := range mount.optionalFields {
+		if strings.HasPrefix(optionalField, "shared:") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPathUnder returns whether a path is a directory or under it
+func isPathUnder(pathToCheck string, dir string) bool {
+	return dir == "/" || pathToCheck == dir || strings.HasPrefix(pathToCheck, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// CheckMountNamespace verifies mounts made and removed by this process reach kubelet. Target paths are mounted,
+// unmounted and looked up in mountinfo in the process' mount namespace - when it isn't the host's (e.g. the daemon
+// running in a pod with hostPID), kubelet's root directory must be mounted into it with bidirectional propagation
+func CheckMountNamespace(kubeletRootDir string) error {
+	selfNamespace, err := os.Readlink("/proc/self/ns/mnt")
+	if err != nil {
+		return fmt.Errorf("Failed to read the mount namespace: %s", err)
+	}
+
+	// reading another process' namespace may be denied (e.g. without CAP_SYS_PTRACE), leaving it unverified
+	hostNamespace, err := os.Readlink("/proc/1/ns/mnt")
+	if err != nil {
+		journal.Warn("Failed to read the host's mount namespace, skipping its check", "err", err.Error())
+		return nil
+	}
+
+	if selfNamespace == hostNamespace {
+		return nil
+	}
+
+	mounts, err := readMountInfo()
+	if err != nil {
+		return fmt.Errorf("Failed to read mounts: %s", err)
+	}
+
+	for _, mount := range mounts {
+		if mount.mountPoint == kubeletRootDir && isSharedMount(mount) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Running in a mount namespace other than the host's, where %s isn't a shared mount, so "+
+		"mounts and unmounts wouldn't reach kubelet. Mount %s with Bidirectional mount propagation, "+
+		"or run on the host (e.g. with nsenter -t 1 -m)",
+		kubeletRootDir,
+		kubeletRootDir)
+}
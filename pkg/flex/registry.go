@@ -0,0 +1,118 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+
+package flex
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+)
+
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+type dockerConfigAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// getRegistryCredentials returns the credentials for an image's registry from a .dockerconfigjson
+// content, or nil if it has none. The legacy .dockercfg format, which maps registries to auths at the top
+// level, is accepted as well
+func getRegistryCredentials(dockerConfigJSONContent string, image string) (*cri.RegistryCredentials, error) {
+	dockerConfig := dockerConfigJSON{}
+	if err := json.Unmarshal([]byte(dockerConfigJSONContent), &dockerConfig); err != nil {
+		return nil, fmt.Errorf("Failed to parse docker config: %s", err)
+	}
+
+	if dockerConfig.Auths == nil {
+		if err := json.Unmarshal([]byte(dockerConfigJSONContent), &dockerConfig.Auths); err != nil {
+			return nil, fmt.Errorf("Failed to parse docker config: %s", err)
+		}
+	}
+
+	imageRegistry := cri.GetImageRegistry(image)
+
+	for registry, auth := range dockerConfig.Auths {
+		if normalizeRegistry(registry) != imageRegistry {
+			continue
+		}
+
+		if auth.Username != "" {
+			return &cri.RegistryCredentials{
+				Username: auth.Username,
+				Password: auth.Password,
+			}, nil
+		}
+
+		decodedAuth, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode auth of %s: %s", registry, err)
+		}
+
+		authParts := strings.SplitN(string(decodedAuth), ":", 2)
+		if len(authParts) != 2 {
+			return nil, fmt.Errorf("Invalid auth of %s, expected username:password", registry)
+		}
+
+		return &cri.RegistryCredentials{
+			Username: authParts[0],
+			Password: authParts[1],
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// normalizeRegistry converts docker config auth keys (e.g. https://index.docker.io/v1/) to registry hosts
+func normalizeRegistry(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	registry = strings.SplitN(registry, "/", 2)[0]
+
+	switch registry {
+	case "index.docker.io", "registry-1.docker.io":
+		return cri.DockerHubRegistry
+	}
+
+	return registry
+}
+
+// getRegistryTLS converts the configured TLS files of registries for the CRI
+func getRegistryTLS(mounterConfig *config.Config) map[string]*cri.RegistryTLS {
+	registryTLS := map[string]*cri.RegistryTLS{}
+
+	for registry, registryTLSConfig := range mounterConfig.RegistryTLS {
+		registryTLS[registry] = &cri.RegistryTLS{
+			CAFile:   registryTLSConfig.CAFile,
+			CertFile: registryTLSConfig.CertFile,
+			KeyFile:  registryTLSConfig.KeyFile,
+		}
+	}
+
+	return registryTLS
+}
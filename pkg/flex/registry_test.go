@@ -0,0 +1,169 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestGetRegistryCredentials(t *testing.T) {
+	encodedAuth := base64.StdEncoding.EncodeToString([]byte("user:pass:word"))
+
+	for _, testCase := range []struct {
+		name             string
+		dockerConfig     string
+		image            string
+		expectedUsername string
+		expectedPassword string
+		expectedNone     bool
+		expectedError    bool
+	}{
+		{
+			name:             "auths with username",
+			dockerConfig:     `{"auths": {"registry.example.com": {"username": "user", "password": "pass"}}}`,
+			image:            "registry.example.com/v3io/fuse:1.0",
+			expectedUsername: "user",
+			expectedPassword: "pass",
+		},
+		{
+			name:             "auths with encoded auth",
+			dockerConfig:     `{"auths": {"registry.example.com": {"auth": "` + encodedAuth + `"}}}`,
+			image:            "registry.example.com/v3io/fuse:1.0",
+			expectedUsername: "user",
+			expectedPassword: "pass:word",
+		},
+		{
+			name:             "legacy format",
+			dockerConfig:     `{"registry.example.com": {"auth": "` + encodedAuth + `", "email": "user@example.com"}}`,
+			image:            "registry.example.com/v3io/fuse:1.0",
+			expectedUsername: "user",
+			expectedPassword: "pass:word",
+		},
+		{
+			name:             "registry with port",
+			dockerConfig:     `{"auths": {"https://registry.example.com:5000/v2/": {"username": "user", "password": "pass"}}}`,
+			image:            "registry.example.com:5000/v3io/fuse:1.0",
+			expectedUsername: "user",
+			expectedPassword: "pass",
+		},
+		{
+			name:             "docker hub index url",
+			dockerConfig:     `{"auths": {"https://index.docker.io/v1/": {"username": "user", "password": "pass"}}}`,
+			image:            "v3io/fuse:1.0",
+			expectedUsername: "user",
+			expectedPassword: "pass",
+		},
+		{
+			name:             "docker hub registry host",
+			dockerConfig:     `{"auths": {"registry-1.docker.io": {"username": "user", "password": "pass"}}}`,
+			image:            "docker.io/v3io/fuse:1.0",
+			expectedUsername: "user",
+			expectedPassword: "pass",
+		},
+		{
+			name:         "other registry",
+			dockerConfig: `{"auths": {"registry.example.com": {"username": "user", "password": "pass"}}}`,
+			image:        "v3io/fuse:1.0",
+			expectedNone: true,
+		},
+		{
+			name:         "registry with other port",
+			dockerConfig: `{"auths": {"registry.example.com:5000": {"username": "user", "password": "pass"}}}`,
+			image:        "registry.example.com/v3io/fuse:1.0",
+			expectedNone: true,
+		},
+		{
+			name:         "empty auths",
+			dockerConfig: `{"auths": {}}`,
+			image:        "registry.example.com/v3io/fuse:1.0",
+			expectedNone: true,
+		},
+		{
+			name:          "invalid json",
+			dockerConfig:  `{"auths": `,
+			image:         "registry.example.com/v3io/fuse:1.0",
+			expectedError: true,
+		},
+		{
+			name:          "auth not base64",
+			dockerConfig:  `{"auths": {"registry.example.com": {"auth": "%%%"}}}`,
+			image:         "registry.example.com/v3io/fuse:1.0",
+			expectedError: true,
+		},
+		{
+			name: "auth without password",
+			dockerConfig: `{"auths": {"registry.example.com": {"auth": "` +
+				base64.StdEncoding.EncodeToString([]byte("user")) + `"}}}`,
+			image:         "registry.example.com/v3io/fuse:1.0",
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			credentials, err := getRegistryCredentials(testCase.dockerConfig, testCase.image)
+			if testCase.expectedError {
+				if err == nil {
+					t.Fatalf("Expected an error, got credentials %+v", credentials)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if testCase.expectedNone {
+				if credentials != nil {
+					t.Fatalf("Expected no credentials, got %+v", credentials)
+				}
+				return
+			}
+
+			if credentials == nil {
+				t.Fatal("Expected credentials, got none")
+			}
+
+			if credentials.Username != testCase.expectedUsername || credentials.Password != testCase.expectedPassword {
+				t.Fatalf("Expected %s:%s, got %s:%s",
+					testCase.expectedUsername,
+					testCase.expectedPassword,
+					credentials.Username,
+					credentials.Password)
+			}
+		})
+	}
+}
+
+func TestNormalizeRegistry(t *testing.T) {
+	for registry, expectedRegistry := range map[string]string{
+		"registry.example.com":         "registry.example.com",
+		"registry.example.com:5000":    "registry.example.com:5000",
+		"https://registry.example.com": "registry.example.com",
+		"http://localhost:5000/v2/":    "localhost:5000",
+		"https://index.docker.io/v1/":  "docker.io",
+		"index.docker.io":              "docker.io",
+		"registry-1.docker.io":         "docker.io",
+		"docker.io":                    "docker.io",
+	} {
+		if normalizedRegistry := normalizeRegistry(registry); normalizedRegistry != expectedRegistry {
+			t.Errorf("Expected %s to normalize to %s, got %s", registry, expectedRegistry, normalizedRegistry)
+		}
+	}
+}
@@ -24,12 +24,16 @@ import (
 	"fmt"
 
 	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/version"
 )
 
 type Response struct {
 	Status       string                 `json:"status"`
 	Message      string                 `json:"message"`
 	Capabilities map[string]interface{} `json:"capabilities"`
+	Device       string                 `json:"device,omitempty"`
+	Attached     bool                   `json:"attached,omitempty"`
+	Version      *version.Info          `json:"version,omitempty"`
 }
 
 func newResponse(status, message string) *Response {
@@ -45,6 +49,12 @@ func NewSuccessResponse(message string) *Response {
 	return newResponse("Success", message)
 }
 
+func NewNotSupportedResponse(message string) *Response {
+	journal.Debug("Not supported", "message", message)
+
+	return newResponse("Not supported", message)
+}
+
 func NewFailResponse(message string, err error) *Response {
 	if err != nil {
 		journal.Warn("Failed", "message", message, "err", err.Error())
@@ -0,0 +1,91 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"time"
+)
+
+// state document of the cached sessions, by service account
+const sessionsName = "sessions.json"
+
+// cachedSession is a session obtained by a token exchange, reused by mounts until it's about to expire
+type cachedSession struct {
+	SessionKey string    `json:"sessionKey"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// getSession returns a cached session of a service account, unless it expires within the renewal window, in which
+// case it's renewed with newSession. The cache is locked while renewing, so that concurrent mounts of a service
+// account share a single exchange
+func (m *Mounter) getSession(serviceAccount string, newSession func() (*cachedSession, error)) (string, error) {
+	renewBefore := time.Duration(m.Config.TokenExchange.RenewBeforeSeconds) * time.Second
+	if renewBefore < 0 {
+		session, err := newSession()
+		if err != nil {
+			return "", err
+		}
+
+		return session.SessionKey, nil
+	}
+
+	var sessionKey string
+
+	sessions := map[string]*cachedSession{}
+	err := m.state.UpdateJSON(sessionsName, &sessions, func() error {
+		now := time.Now()
+
+		for cachedServiceAccount, session := range sessions {
+			if !now.Before(session.ExpiresAt) {
+				delete(sessions, cachedServiceAccount)
+			}
+		}
+
+		if session, found := sessions[serviceAccount]; found && now.Add(renewBefore).Before(session.ExpiresAt) {
+			m.logger.Debug("Using cached session", "serviceAccount", serviceAccount, "expiresAt", session.ExpiresAt)
+			sessionKey = session.SessionKey
+			return nil
+		}
+
+		session, err := newSession()
+		if err != nil {
+			return err
+		}
+
+		sessionKey = session.SessionKey
+
+		// sessions without an expiration aren't cached, as there's no telling when to renew them
+		if !session.ExpiresAt.IsZero() {
+			sessions[serviceAccount] = session
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if sessionKey == "" {
+		return "", fmt.Errorf("No session was obtained for %s", serviceAccount)
+	}
+
+	return sessionKey, nil
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+const sharedMountsDir = "shared"
+
+// options of the spec that differ between pods mounting the same volume identically
+var podSpecificOptions = []string{
+	"kubernetes.io/pod.name",
+	"kubernetes.io/pod.uid",
+	"csi.storage.k8s.io/ephemeral",
+	"csi.storage.k8s.io/serviceAccount.tokens",
+}
+
+// options identifying the pod's service account, which differ between pods mounting the same volume identically
+// unless they authenticate with the service account's token
+var podServiceAccountOptions = []string{"kubernetes.io/pod.namespace", "kubernetes.io/serviceAccount.name"}
+
+// SharedMount is a FUSE container shared by the pods on the node mounting the same volume with the same options
+type SharedMount struct {
+	SharedPath string `json:"sharedPath"`
+
+	// TargetPaths are the pods' target paths bind mounting the shared path
+	TargetPaths []string `json:"targetPaths"`
+}
+
+// mountShared mounts the volume's shared FUSE container, if it isn't already, and bind mounts it on the target path
+func (m *Mounter) mountShared(spec *Spec, specString string, targetPath string) *Response {
+	sharedMountKey, err := getSharedMountKey(spec, specString)
+	if err != nil {
+		return NewFailResponse("Failed to get shared mount key", err)
+	}
+
+	sharedPath := path.Join(m.Config.DeviceMountRoot, sharedMountKey)
+	sharedMount := SharedMount{}
+
+	var response *Response
+	if err := m.state.UpdateJSON(getSharedMountName(sharedMountKey), &sharedMount, func() error {
+		sharedMount.SharedPath = sharedPath
+
+		// pods whose mounts were detached without an unmount (e.g. by unmount-all) no longer hold the container
+		sharedMount.TargetPaths = getMountedPaths(sharedMount.TargetPaths)
+
+		if isMountPoint(targetPath) {
+			response = NewSuccessResponse(fmt.Sprintf("Already mounted: %s", targetPath))
+		} else {
+			if err := os.MkdirAll(sharedPath, 0750); err != nil {
+				return fmt.Errorf("Failed to create shared path %s: %s", sharedPath, err)
+			}
+
+			if response = m.mountFUSE(spec, sharedPath); response.Status != "Success" {
+				return nil
+			}
+
+			m.logger.Info("Bind mounting shared mount", "sharedPath", sharedPath, "target", targetPath)
+
+			if response = bindMount(sharedPath, targetPath); response != nil {
+				return nil
+			}
+
+			response = NewSuccessResponse("Successfully mounted from shared mount")
+		}
+
+		if !contains(sharedMount.TargetPaths, targetPath) {
+			sharedMount.TargetPaths = append(sharedMount.TargetPaths, targetPath)
+		}
+
+		return nil
+	}); err != nil {
+		return NewFailResponse("Failed to update shared mount", err)
+	}
+
+	return response
+}
+
+// unmountShared unmounts the target path's bind mount, and the shared FUSE container once no pod uses it. Target
+// paths that aren't shared (e.g. mounted before sharing was enabled) are unmounted as usual
+func (m *Mounter) unmountShared(targetPath string) *Response {
+	sharedMountKey := m.findSharedMount(targetPath)
+	if sharedMountKey == "" {
+		return m.unmountFUSE(targetPath)
+	}
+
+	sharedMount := SharedMount{}
+
+	var response *Response
+	if err := m.state.UpdateJSON(getSharedMountName(sharedMountKey), &sharedMount, func() error {
+		if response = m.unmountFromDevice(targetPath); response.Status != "Success" {
+			return nil
+		}
+
+		var remainingTargetPaths []string
+		for _, sharedTargetPath := range sharedMount.TargetPaths {
+			if sharedTargetPath != targetPath {
+				remainingTargetPaths = append(remainingTargetPaths, sharedTargetPath)
+			}
+		}
+
+		sharedMount.TargetPaths = remainingTargetPaths
+
+		if len(sharedMount.TargetPaths) == 0 {
+			m.logger.Info("Last pod unmounted, unmounting shared mount", "sharedPath", sharedMount.SharedPath)
+
+			response = m.unmountFUSE(sharedMount.SharedPath)
+		}
+
+		return nil
+	}); err != nil {
+		return NewFailResponse("Failed to update shared mount", err)
+	}
+
+	return response
+}
+
+// findSharedMount returns the key of the shared mount a target path bind mounts, or "" if it doesn't
+func (m *Mounter) findSharedMount(targetPath string) string {
+	sharedMountPaths, err := filepath.Glob(m.state.Path(path.Join(sharedMountsDir, "*.json")))
+	if err != nil {
+		return ""
+	}
+
+	for _, sharedMountPath := range sharedMountPaths {
+		sharedMountName := path.Join(sharedMountsDir, filepath.Base(sharedMountPath))
+
+		sharedMount := SharedMount{}
+		if err := m.state.ReadJSON(sharedMountName, &sharedMount); err != nil {
+			m.logger.Debug("Failed to read shared mount", "path", sharedMountPath, "err", err.Error())
+			continue
+		}
+
+		if contains(sharedMount.TargetPaths, targetPath) {
+			return path.Base(sharedMount.SharedPath)
+		}
+	}
+
+	return ""
+}
+
+// getSharedMountKey returns the key of the volume's shared mount - the volume name and a hash of its options
+// without those that differ between pods, so that only pods mounting the volume identically (e.g. with the same
+// access key, or the same service account when authenticating with its token) share it
+func getSharedMountKey(spec *Spec, specString string) (string, error) {
+	options := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(specString), &options); err != nil {
+		return "", err
+	}
+
+	for _, option := range podSpecificOptions {
+		delete(options, option)
+	}
+
+	if spec.Auth != AuthServiceAccountToken {
+		for _, option := range podServiceAccountOptions {
+			delete(options, option)
+		}
+	}
+
+	// maps are marshaled with sorted keys
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(encodedOptions)
+
+	return fmt.Sprintf("%s-%s",
+		invalidContainerNameChars.ReplaceAllString(spec.Name, "-"),
+		hex.EncodeToString(hash[:])[:10]), nil
+}
+
+// getSharedContainerName returns the name of a shared mount's FUSE container, derived from its key rather than
+// its path, as the path is under the configurable device_mount_root
+func getSharedContainerName(sharedMountKey string) (string, string, error) {
+	return renderContainerName("device-{{.VolumeName}}", &ContainerNameFields{
+		VolumeName: sharedMountKey,
+		Hash:       getTargetPathHash(sharedMountKey),
+	})
+}
+
+// isSharedPath returns whether a path is the path of a shared mount
+func (m *Mounter) isSharedPath(targetPath string) bool {
+	return m.Config.ShareVolumeContainers &&
+		m.Config.DeviceMountRoot != "" &&
+		path.Dir(path.Clean(targetPath)) == path.Clean(m.Config.DeviceMountRoot)
+}
+
+func getSharedMountName(sharedMountKey string) string {
+	return path.Join(sharedMountsDir, sharedMountKey+".json")
+}
+
+func getMountedPaths(paths []string) []string {
+	var mountedPaths []string
+	for _, candidatePath := range paths {
+		if isMountPoint(candidatePath) {
+			mountedPaths = append(mountedPaths, candidatePath)
+		}
+	}
+
+	return mountedPaths
+}
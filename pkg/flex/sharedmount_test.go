@@ -0,0 +1,125 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestGetSharedMountKey(t *testing.T) {
+	const baseSpecString = `{"container": "bigdata", "kubernetes.io/pvOrVolumeName": "data", ` +
+		`"kubernetes.io/secret/accessKey": "key", "kubernetes.io/pod.name": "pod-a", "kubernetes.io/pod.uid": "uid-a", ` +
+		`"kubernetes.io/pod.namespace": "ns-a", "kubernetes.io/serviceAccount.name": "default"}`
+
+	for _, testCase := range []struct {
+		name           string
+		specString     string
+		expectedShared bool
+	}{
+		{
+			name: "another pod in another namespace",
+			specString: `{"container": "bigdata", "kubernetes.io/pvOrVolumeName": "data", ` +
+				`"kubernetes.io/secret/accessKey": "key", "kubernetes.io/pod.name": "pod-b", ` +
+				`"kubernetes.io/pod.uid": "uid-b", "kubernetes.io/pod.namespace": "ns-b", ` +
+				`"kubernetes.io/serviceAccount.name": "runner", "csi.storage.k8s.io/ephemeral": "false"}`,
+			expectedShared: true,
+		},
+		{
+			name: "another access key",
+			specString: `{"container": "bigdata", "kubernetes.io/pvOrVolumeName": "data", ` +
+				`"kubernetes.io/secret/accessKey": "other", "kubernetes.io/pod.name": "pod-b"}`,
+		},
+		{
+			name: "another subpath",
+			specString: `{"container": "bigdata", "kubernetes.io/pvOrVolumeName": "data", "subPath": "/a", ` +
+				`"kubernetes.io/secret/accessKey": "key", "kubernetes.io/pod.name": "pod-b"}`,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			baseKey := getTestSharedMountKey(t, baseSpecString)
+			key := getTestSharedMountKey(t, testCase.specString)
+
+			if (key == baseKey) != testCase.expectedShared {
+				t.Errorf("Expected shared %v, got keys %s and %s", testCase.expectedShared, baseKey, key)
+			}
+
+			if !strings.HasPrefix(key, "data-") {
+				t.Errorf("Expected the key to start with the volume name, got %s", key)
+			}
+		})
+	}
+}
+
+// pods authenticating with their service account's token share a container only with the same service account
+func TestGetSharedMountKeyServiceAccountToken(t *testing.T) {
+	getSpecString := func(namespace string, serviceAccountName string) string {
+		return `{"container": "bigdata", "kubernetes.io/pvOrVolumeName": "data", "auth": "serviceAccountToken", ` +
+			`"kubernetes.io/pod.namespace": "` + namespace + `", ` +
+			`"kubernetes.io/serviceAccount.name": "` + serviceAccountName + `"}`
+	}
+
+	key := getTestSharedMountKey(t, getSpecString("ns-a", "default"))
+
+	if otherKey := getTestSharedMountKey(t, getSpecString("ns-b", "default")); otherKey == key {
+		t.Errorf("Expected pods of another namespace's service account not to share %s", key)
+	}
+
+	if otherKey := getTestSharedMountKey(t, getSpecString("ns-a", "runner")); otherKey == key {
+		t.Errorf("Expected pods of another service account not to share %s", key)
+	}
+}
+
+func getTestSharedMountKey(t *testing.T, specString string) string {
+	spec := Spec{}
+	if err := json.Unmarshal([]byte(specString), &spec); err != nil {
+		t.Fatalf("Failed to unmarshal spec: %s", err)
+	}
+
+	key, err := getSharedMountKey(&spec, specString)
+	if err != nil {
+		t.Fatalf("Failed to get shared mount key: %s", err)
+	}
+
+	return key
+}
+
+func TestGetSharedContainerName(t *testing.T) {
+	mounter, _ := newFakeMounter(t)
+	mounter.Config.ShareVolumeContainers = true
+
+	for _, deviceMountRoot := range []string{
+		"/var/lib/kubelet/plugins/kubernetes.io/flexvolume/v3io/fuse/mounts",
+		"/data/kubelet/flex-fuse-shared",
+	} {
+		mounter.Config.DeviceMountRoot = deviceMountRoot
+
+		containerName, err := mounter.getContainerName(path.Join(deviceMountRoot, "data-0a1b2c3d4e"))
+		if err != nil {
+			t.Fatalf("%s: failed to get container name: %s", deviceMountRoot, err)
+		}
+
+		if containerName != "v3io-fuse-device-data-0a1b2c3d4e" {
+			t.Errorf("%s: expected the container to be named after the key, got %s", deviceMountRoot, containerName)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+const shutdownHookUnitName = "v3io-fuse-shutdown.service"
+
+var shutdownHookUnitPath = path.Join("/etc/systemd/system", shutdownHookUnitName)
+
+// the unit is stopped during shutdown after kubelet's graceful node shutdown stopped the pods (kubelet delays the
+// shutdown with an inhibitor lock until then), and before kubelet and the container runtime, as it's ordered after
+// them
+const shutdownHookUnitTemplate = `[Unit]
+Description=Unmount v3io FUSE mounts on node shutdown
+After=containerd.service docker.service kubelet.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=%[1]s version
+ExecStop=%[1]s shutdown-hook run
+TimeoutStopSec=%[2]d
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// InstallShutdownHook installs and starts a systemd unit flushing and unmounting all mounts on node shutdown, if
+// enabled, returning a description of the outcome. The unit runs the driver's executable
+func InstallShutdownHook(hookConfig *config.ShutdownHookConfig, executable string) (string, error) {
+	if !hookConfig.Enabled {
+		return "", nil
+	}
+
+	unit := []byte(fmt.Sprintf(shutdownHookUnitTemplate, executable, hookConfig.TimeoutSeconds))
+
+	if installedUnit, err := ioutil.ReadFile(shutdownHookUnitPath); err == nil && bytes.Equal(installedUnit, unit) {
+		return fmt.Sprintf("Shutdown hook %s is installed", shutdownHookUnitName), nil
+	}
+
+	if err := ioutil.WriteFile(shutdownHookUnitPath, unit, 0644); err != nil {
+		return "", fmt.Errorf("Failed to write %s: %s", shutdownHookUnitPath, err)
+	}
+
+	for _, systemctlArgs := range [][]string{{"daemon-reload"}, {"enable", "--now", shutdownHookUnitName}} {
+		if err := runSystemctl(systemctlArgs...); err != nil {
+			return "", err
+		}
+	}
+
+	journal.Info("Installed shutdown hook", "path", shutdownHookUnitPath)
+
+	return fmt.Sprintf("Installed shutdown hook %s", shutdownHookUnitName), nil
+}
+
+// UninstallShutdownHook stops and removes the shutdown hook's unit. Stopping it doesn't unmount, as the node isn't
+// shutting down
+func UninstallShutdownHook() error {
+	if _, err := os.Stat(shutdownHookUnitPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := runSystemctl("disable", "--now", shutdownHookUnitName); err != nil {
+		return err
+	}
+
+	if err := os.Remove(shutdownHookUnitPath); err != nil {
+		return err
+	}
+
+	return runSystemctl("daemon-reload")
+}
+
+// IsShuttingDown returns whether systemd is shutting the node down
+func IsShuttingDown() bool {
+
+	// is-system-running exits with a non zero code for states other than running
+	output, _ := exec.Command("systemctl", "is-system-running").Output()
+
+	return strings.TrimSpace(string(output)) == "stopping"
+}
+
+func runSystemctl(args ...string) error {
+	if output, err := exec.Command("systemctl", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("systemctl %s failed: %s (%s)",
+			strings.Join(args, " "),
+			err,
+			strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -22,7 +22,13 @@ package flex
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/kube"
 )
 
 type DirToCreate struct {
@@ -38,8 +44,28 @@ type Spec struct {
 	AccessKey         string `json:"kubernetes.io/secret/accessKey"`
 	PodName           string `json:"kubernetes.io/pod.name"`
 	Namespace         string `json:"kubernetes.io/pod.namespace"`
+	PodUID            string `json:"kubernetes.io/pod.uid"`
 	Name              string `json:"kubernetes.io/pvOrVolumeName"`
 	DirsToCreate      string `json:"dirsToCreate"`
+	DockerConfigJSON  string `json:"kubernetes.io/secret/.dockerconfigjson"`
+	MountTimeout      string `json:"mountTimeout"`
+	ConnectionPool    string `json:"connectionPoolSize"`
+	DataInterface     string `json:"dataInterface"`
+	DataSourceIP      string `json:"dataSourceIP"`
+	FUSEOptions       string `json:"fuseOptions"`
+	Profile           string `json:"profile"`
+	Image             string `json:"image"`
+	PVCName           string `json:"pvcName"`
+	Auth              string `json:"auth"`
+	IOReadBps         string `json:"ioReadBps"`
+	IOWriteBps        string `json:"ioWriteBps"`
+	IOReadIOPS        string `json:"ioReadIops"`
+	IOWriteIOPS       string `json:"ioWriteIops"`
+
+	ServiceAccountName string `json:"kubernetes.io/serviceAccount.name"`
+
+	// sessionKey is exchanged for the pod's service account token, and isn't recorded as it expires
+	sessionKey string
 }
 
 func (s *Spec) decodeOrDefault(value string) string {
@@ -51,24 +77,100 @@ func (s *Spec) decodeOrDefault(value string) string {
 }
 
 func (s *Spec) validate() error {
-	if s.AccessKey == "" && s.OverrideAccessKey == "" {
+	if s.AccessKey == "" && s.OverrideAccessKey == "" && s.Auth != AuthServiceAccountToken {
 		return errors.New("required access key is missing")
 	}
 
-	if s.SubPath != "" && s.Container == "" {
-		return errors.New("can't have subpath without container value")
-	}
-
-	return nil
+	return s.validateParameters()
 }
 
 func (s *Spec) GetAccessKey() string {
+	if s.sessionKey != "" {
+		return s.sessionKey
+	}
+
 	if s.OverrideAccessKey == "" {
 		return s.decodeOrDefault(s.AccessKey)
 	}
 	return s.OverrideAccessKey
 }
 
+// GetDockerConfigJSON returns the image pull secret content passed by kubelet, if any
+func (s *Spec) GetDockerConfigJSON() string {
+	return s.decodeOrDefault(s.DockerConfigJSON)
+}
+
+// GetMountTimeout returns the mount timeout option (e.g. "30s", or a number of seconds), or 0 if not set
+func (s *Spec) GetMountTimeout() (time.Duration, error) {
+	if s.MountTimeout == "" {
+		return 0, nil
+	}
+
+	if seconds, err := strconv.Atoi(s.MountTimeout); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	mountTimeout, err := time.ParseDuration(s.MountTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mountTimeout %q: %s", s.MountTimeout, err)
+	}
+
+	return mountTimeout, nil
+}
+
+// GetConnectionPoolSize returns the connectionPoolSize option, or 0 if not set
+func (s *Spec) GetConnectionPoolSize() (int, error) {
+	if s.ConnectionPool == "" {
+		return 0, nil
+	}
+
+	connectionPoolSize, err := strconv.Atoi(s.ConnectionPool)
+	if err != nil || connectionPoolSize <= 0 {
+		return 0, fmt.Errorf("invalid connectionPoolSize %q, expected a positive number", s.ConnectionPool)
+	}
+
+	return connectionPoolSize, nil
+}
+
+// GetIOLimits returns the I/O limit options - the bps as quantities (e.g. "100Mi") and the IOPS as numbers, 0 if
+// not set
+func (s *Spec) GetIOLimits() (*cri.IOLimits, error) {
+	ioLimits := cri.IOLimits{}
+
+	for _, limit := range []struct {
+		name     string
+		value    string
+		rate     *uint64
+		quantity bool
+	}{
+		{"ioReadBps", s.IOReadBps, &ioLimits.ReadBps, true},
+		{"ioWriteBps", s.IOWriteBps, &ioLimits.WriteBps, true},
+		{"ioReadIops", s.IOReadIOPS, &ioLimits.ReadIOPS, false},
+		{"ioWriteIops", s.IOWriteIOPS, &ioLimits.WriteIOPS, false},
+	} {
+		if limit.value == "" {
+			continue
+		}
+
+		var rate int64
+		var err error
+
+		if limit.quantity {
+			rate, err = kube.ParseQuantity(limit.value)
+		} else {
+			rate, err = strconv.ParseInt(limit.value, 10, 64)
+		}
+
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid %s %q, expected a positive number", limit.name, limit.value)
+		}
+
+		*limit.rate = uint64(rate)
+	}
+
+	return &ioLimits, nil
+}
+
 func (s *Spec) GetClusterName() string {
 	if s.Cluster == "" {
 		return "default"
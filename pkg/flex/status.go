@@ -0,0 +1,65 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"time"
+)
+
+const statusName = "status.json"
+
+// Status summarizes the last operations on the node, for tooling and debugging
+type Status struct {
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Operations are the last operations, oldest first
+	Operations []*Result `json:"operations"`
+}
+
+// ReadStatus returns the summary of the last operations on the node
+func (m *Mounter) ReadStatus() (*Status, error) {
+	status := Status{}
+	if err := m.state.ReadJSON(statusName, &status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// recordStatus adds the result of an operation to the node's status, keeping the last StatusOperations
+func (m *Mounter) recordStatus(result *Result) {
+	if m.Config.StatusOperations < 0 {
+		return
+	}
+
+	status := Status{}
+	if err := m.state.UpdateJSON(statusName, &status, func() error {
+		status.UpdatedAt = time.Now()
+		status.Operations = append(status.Operations, result)
+
+		if excess := len(status.Operations) - m.Config.StatusOperations; excess > 0 {
+			status.Operations = status.Operations[excess:]
+		}
+
+		return nil
+	}); err != nil {
+		m.logger.Warn("Failed to update status", "err", err.Error())
+	}
+}
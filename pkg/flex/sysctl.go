@@ -0,0 +1,64 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// procSysDir is where the host's sysctls are read and written
+var procSysDir = "/proc/sys"
+
+// applyHostSysctls sets the configured sysctls on the host. The FUSE containers share the host's network
+// namespace, where runtimes refuse to set sysctls in the container's spec, so applying them is opted into with
+// apply_host_sysctls and every changed value is logged
+func (m *Mounter) applyHostSysctls() error {
+	if !m.Config.ApplyHostSysctls {
+		return nil
+	}
+
+	for key, value := range m.Config.Sysctls {
+		sysctlPath := path.Join(procSysDir, strings.Replace(key, ".", "/", -1))
+
+		currentValue, err := ioutil.ReadFile(sysctlPath)
+		if err != nil {
+			return fmt.Errorf("Failed to read sysctl %s: %s", key, err)
+		}
+
+		// multi value sysctls (e.g. net.ipv4.tcp_rmem) are read tab separated
+		if strings.Join(strings.Fields(string(currentValue)), " ") == strings.Join(strings.Fields(value), " ") {
+			continue
+		}
+
+		if err := ioutil.WriteFile(sysctlPath, []byte(value), 0644); err != nil {
+			return fmt.Errorf("Failed to set sysctl %s: %s", key, err)
+		}
+
+		m.logger.Info("Set host sysctl",
+			"key", key,
+			"value", value,
+			"previousValue", strings.TrimSpace(string(currentValue)))
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+func TestApplyHostSysctls(t *testing.T) {
+	for _, testCase := range []struct {
+		name             string
+		sysctls          map[string]string
+		applyHostSysctls bool
+		expectedValues   map[string]string
+		expectedError    bool
+	}{
+		{
+			name:           "not opted in",
+			sysctls:        map[string]string{"net.core.rmem_max": "268435456"},
+			expectedValues: map[string]string{"net/core/rmem_max": "212992\n"},
+		},
+		{
+			name:             "changed",
+			sysctls:          map[string]string{"net.core.rmem_max": "268435456"},
+			applyHostSysctls: true,
+			expectedValues:   map[string]string{"net/core/rmem_max": "268435456"},
+		},
+		{
+			name:             "multi value already set",
+			sysctls:          map[string]string{"net.ipv4.tcp_rmem": "4096 131072 6291456"},
+			applyHostSysctls: true,
+			expectedValues:   map[string]string{"net/ipv4/tcp_rmem": "4096\t131072\t6291456\n"},
+		},
+		{
+			name:             "missing",
+			sysctls:          map[string]string{"net.core.missing": "1"},
+			applyHostSysctls: true,
+			expectedError:    true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			procSysDir = t.TempDir()
+			defer func() { procSysDir = "/proc/sys" }()
+
+			for sysctlPath, value := range map[string]string{
+				"net/core/rmem_max": "212992\n",
+				"net/ipv4/tcp_rmem": "4096\t131072\t6291456\n",
+			} {
+				if err := os.MkdirAll(path.Dir(path.Join(procSysDir, sysctlPath)), 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := ioutil.WriteFile(path.Join(procSysDir, sysctlPath), []byte(value), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mounter := &Mounter{
+				Config: &config.Config{
+					Sysctls:          testCase.sysctls,
+					ApplyHostSysctls: testCase.applyHostSysctls,
+				},
+				logger: journal.Default(),
+			}
+
+			err := mounter.applyHostSysctls()
+			if testCase.expectedError {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			for sysctlPath, expectedValue := range testCase.expectedValues {
+				value, err := ioutil.ReadFile(path.Join(procSysDir, sysctlPath))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(value) != expectedValue {
+					t.Errorf("Expected %s to be %q, got %q", sysctlPath, expectedValue, string(value))
+				}
+			}
+		})
+	}
+}
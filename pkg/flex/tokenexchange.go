@@ -0,0 +1,184 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/kube"
+)
+
+// auth modes of volumes, set by the auth option
+const (
+	AuthAccessKey           = "accessKey"
+	AuthServiceAccountToken = "serviceAccountToken"
+)
+
+// RFC8693 token exchange
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// tokenExchangeResponse is the successful response of an RFC8693 token exchange
+type tokenExchangeResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// exchangeServiceAccountToken sets the session key of a volume authenticated by its pod's service account - a
+// token of the service account bound to the pod is requested from the API server and exchanged for a v3io session
+// at the token exchange endpoint, so that no access key is kept in the PV
+func (m *Mounter) exchangeServiceAccountToken(spec *Spec) error {
+	if spec.Auth != AuthServiceAccountToken {
+		return nil
+	}
+
+	exchangeConfig := m.Config.TokenExchange
+	if exchangeConfig.URL == "" {
+		return fmt.Errorf("Volume is authenticated by service account token, but token_exchange url isn't configured")
+	}
+
+	if spec.Namespace == "" || spec.PodName == "" || spec.ServiceAccountName == "" {
+		return fmt.Errorf("Volume is authenticated by service account token, but kubelet didn't pass its pod's " +
+			"service account")
+	}
+
+	sessionKey, err := m.getSession(spec.Namespace+"/"+spec.ServiceAccountName, func() (*cachedSession, error) {
+		return m.newServiceAccountSession(spec)
+	})
+	if err != nil {
+		return err
+	}
+
+	spec.sessionKey = sessionKey
+
+	return nil
+}
+
+// newServiceAccountSession exchanges a token of a pod's service account for a session
+func (m *Mounter) newServiceAccountSession(spec *Spec) (*cachedSession, error) {
+	exchangeConfig := m.Config.TokenExchange
+
+	kubeClient, err := m.newKubeClient(exchangeConfig.APIServer, exchangeConfig.CredentialsDir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Kubernetes client: %s", err)
+	}
+
+	tokenStatus, err := kubeClient.CreateServiceAccountToken(spec.Namespace, spec.ServiceAccountName, kube.TokenRequestSpec{
+		Audiences:         []string{exchangeConfig.Audience},
+		ExpirationSeconds: int64(exchangeConfig.ExpirationSeconds),
+		BoundObjectRef: &kube.BoundObjectRef{
+			Kind:       "Pod",
+			APIVersion: "v1",
+			Name:       spec.PodName,
+			UID:        spec.PodUID,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request a token of service account %s/%s: %s",
+			spec.Namespace,
+			spec.ServiceAccountName,
+			err)
+	}
+
+	exchangedAt := time.Now()
+
+	exchangeResponse, err := exchangeToken(exchangeConfig.URL,
+		tokenStatus.Token,
+		time.Duration(exchangeConfig.TimeoutSeconds)*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to exchange the token of service account %s/%s: %s",
+			spec.Namespace,
+			spec.ServiceAccountName,
+			err)
+	}
+
+	m.logger.Info("Exchanged service account token for a session",
+		"namespace", spec.Namespace,
+		"serviceAccount", spec.ServiceAccountName,
+		"expiresIn", exchangeResponse.ExpiresIn)
+
+	session := cachedSession{
+		SessionKey: exchangeResponse.AccessToken,
+	}
+
+	if exchangeResponse.ExpiresIn > 0 {
+		session.ExpiresAt = exchangedAt.Add(time.Duration(exchangeResponse.ExpiresIn) * time.Second)
+	}
+
+	return &session, nil
+}
+
+// newKubeClient creates a client authenticated by a service account's credentials directory if set, or the
+// in-cluster client
+func (m *Mounter) newKubeClient(apiServer string, credentialsDir string) (*kube.Client, error) {
+	if credentialsDir != "" {
+		return kube.NewClient(apiServer, credentialsDir)
+	}
+
+	return kube.NewInClusterClient()
+}
+
+// exchangeToken exchanges a service account token for a session at an RFC8693 token exchange endpoint
+func exchangeToken(exchangeURL string, token string, timeout time.Duration) (*tokenExchangeResponse, error) {
+	httpClient := http.Client{
+		Timeout: timeout,
+	}
+
+	response, err := httpClient.PostForm(exchangeURL, url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {token},
+		"subject_token_type": {jwtTokenType},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Token exchange responded with %s: %s",
+			response.Status,
+			strings.TrimSpace(string(responseBody)))
+	}
+
+	exchangeResponse := tokenExchangeResponse{}
+	if err := json.Unmarshal(responseBody, &exchangeResponse); err != nil {
+		return nil, fmt.Errorf("Failed to parse token exchange response: %s", err)
+	}
+
+	if exchangeResponse.AccessToken == "" {
+		return nil, fmt.Errorf("Token exchange response has no access_token")
+	}
+
+	return &exchangeResponse, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package flex
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// kubelet's directory of the driver's volumes in a pod's volumes directory
+const podVolumesDirName = "/volumes/v3io~fuse/"
+
+// UnmountOutcome is the outcome of unmounting one of the mounts in UnmountAll
+type UnmountOutcome struct {
+	TargetPath string
+	Status     string
+	Message    string
+}
+
+// ListOwnedMounts returns the target paths of the driver's mounts - those with a mount record and any mount
+// point in the driver's pod volume or device mount directories
+func (m *Mounter) ListOwnedMounts() ([]string, error) {
+	targetPaths := map[string]bool{}
+
+	mountRecords, err := m.ListMountRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mountRecord := range mountRecords {
+		targetPaths[mountRecord.TargetPath] = true
+	}
+
+	mountPoints, err := getMountPoints()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mountPoint := range mountPoints {
+		if strings.Contains(mountPoint, podVolumesDirName) || m.isDeviceMountPath(mountPoint) {
+			targetPaths[mountPoint] = true
+		}
+	}
+
+	var ownedMounts []string
+	for targetPath := range targetPaths {
+		ownedMounts = append(ownedMounts, targetPath)
+	}
+
+	sort.Strings(ownedMounts)
+
+	return ownedMounts, nil
+}
+
+// UnmountAll unmounts all of the driver's mounts. Pod mounts are unmounted before the device mounts they may
+// bind, which are skipped if any pod mount remains. When forced, mounts that fail to unmount are lazily detached
+// and forgotten
+func (m *Mounter) UnmountAll(force bool) ([]*UnmountOutcome, error) {
+	ownedMounts, err := m.ListOwnedMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var podMounts, deviceMounts []string
+	for _, targetPath := range ownedMounts {
+		if m.isDeviceMountPath(targetPath) {
+			deviceMounts = append(deviceMounts, targetPath)
+		} else {
+			podMounts = append(podMounts, targetPath)
+		}
+	}
+
+	// nested mounts first
+	sort.Slice(podMounts, func(i, j int) bool {
+		return strings.Count(podMounts[i], "/") > strings.Count(podMounts[j], "/")
+	})
+
+	var outcomes []*UnmountOutcome
+	remainingPodMounts := 0
+
+	for _, targetPath := range podMounts {
+		outcome := m.unmountOwnedMount(targetPath, m.Unmount, force)
+		if outcome.Status == "Failure" {
+			remainingPodMounts++
+		}
+
+		outcomes = append(outcomes, outcome)
+	}
+
+	for _, deviceMountPath := range deviceMounts {
+		if remainingPodMounts > 0 && !force {
+			outcomes = append(outcomes, &UnmountOutcome{
+				TargetPath: deviceMountPath,
+				Status:     "Skipped",
+				Message:    fmt.Sprintf("%d pod mounts remain", remainingPodMounts),
+			})
+
+			continue
+		}
+
+		outcomes = append(outcomes, m.unmountOwnedMount(deviceMountPath, m.UnmountDevice, force))
+	}
+
+	return outcomes, nil
+}
+
+func (m *Mounter) unmountOwnedMount(targetPath string, unmount func(string) *Response, force bool) *UnmountOutcome {
+	response := unmount(targetPath)
+	if response.Status != "Failure" || !force {
+		return &UnmountOutcome{
+			TargetPath: targetPath,
+			Status:     response.Status,
+			Message:    response.Message,
+		}
+	}
+
+	m.logger.Warn("Unmount failed, detaching lazily", "targetPath", targetPath, "message", response.Message)
+
+	if output, err := exec.Command("umount", "-l", targetPath).CombinedOutput(); err != nil && isMountPoint(targetPath) {
+		return &UnmountOutcome{
+			TargetPath: targetPath,
+			Status:     "Failure",
+			Message:    fmt.Sprintf("%s, and lazy unmount failed: %s", response.Message, strings.TrimSpace(string(output))),
+		}
+	}
+
+	m.removeMountRecord(targetPath)
+
+	return &UnmountOutcome{
+		TargetPath: targetPath,
+		Status:     "Forced",
+		Message:    fmt.Sprintf("Detached lazily after: %s", response.Message),
+	}
+}
+
+func (m *Mounter) isDeviceMountPath(targetPath string) bool {
+	return strings.HasPrefix(targetPath, strings.TrimSuffix(m.Config.DeviceMountRoot, "/")+"/")
+}
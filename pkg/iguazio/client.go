@@ -0,0 +1,157 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package iguazio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client is a minimal client of the Iguazio management API
+type Client struct {
+	apiURL     string
+	accessKey  string
+	httpClient *http.Client
+}
+
+type Container struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type containerResource struct {
+	Type       string              `json:"type"`
+	ID         int                 `json:"id,omitempty"`
+	Attributes containerAttributes `json:"attributes"`
+}
+
+type containerAttributes struct {
+	Name string `json:"name"`
+
+	// QuotaBytes limits the size of the container, if set
+	QuotaBytes int64 `json:"quota_bytes,omitempty"`
+}
+
+func NewClient(apiURL string, accessKey string) *Client {
+	return &Client{
+		apiURL:    strings.TrimSuffix(apiURL, "/"),
+		accessKey: accessKey,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// ListContainers returns the data containers
+func (c *Client) ListContainers() ([]Container, error) {
+	response := struct {
+		Data []containerResource `json:"data"`
+	}{}
+
+	if err := c.do("GET", "/api/containers", nil, &response); err != nil {
+		return nil, err
+	}
+
+	var containers []Container
+	for _, resource := range response.Data {
+		containers = append(containers, Container{
+			ID:   resource.ID,
+			Name: resource.Attributes.Name,
+		})
+	}
+
+	return containers, nil
+}
+
+// CreateContainer creates a data container, limited to a quota if it's not 0
+func (c *Client) CreateContainer(name string, quotaBytes int64) error {
+	request := map[string]interface{}{
+		"data": containerResource{
+			Type: "container",
+			Attributes: containerAttributes{
+				Name:       name,
+				QuotaBytes: quotaBytes,
+			},
+		},
+	}
+
+	return c.do("POST", "/api/containers", request, nil)
+}
+
+// DeleteContainer deletes a data container by name, if it exists
+func (c *Client) DeleteContainer(name string) error {
+	containers, err := c.ListContainers()
+	if err != nil {
+		return err
+	}
+
+	for _, container := range containers {
+		if container.Name == name {
+			return c.do("DELETE", fmt.Sprintf("/api/containers/%d", container.ID), nil, nil)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) do(method string, path string, body interface{}, result interface{}) error {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		if bodyBytes, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequest(method, c.apiURL+path, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Cookie", fmt.Sprintf(`session=j:{"sid": "%s"}`, c.accessKey))
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, response.StatusCode, string(responseBody))
+	}
+
+	if result != nil {
+		return json.Unmarshal(responseBody, result)
+	}
+
+	return nil
+}
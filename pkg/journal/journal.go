@@ -22,11 +22,58 @@ package journal
 import (
 	"context"
 	"fmt"
+	"io"
+	"sync"
+	"time"
+
 	"github.com/coreos/go-systemd/journal"
 	"github.com/nuclio/logger"
 )
 
-var j = Logger{}
+// j is the process' logger, which the package functions log with
+var j = &Logger{}
+
+var (
+	outputLock sync.Mutex
+	sink       Sink
+)
+
+// SetTraceID sets the ID identifying the current invocation in all subsequent messages
+func SetTraceID(id string) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	j.traceID = id
+}
+
+// TraceID returns the ID identifying the current invocation
+func TraceID() string {
+	return j.TraceID()
+}
+
+// SetFileOutput writes all subsequent messages to a file as well as the journal
+func SetFileOutput(writer io.Writer) {
+	j.SetFileOutput(writer)
+}
+
+// SetSink sends all subsequent messages to a sink instead of the systemd journal. nil restores the journal
+func SetSink(newSink Sink) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	sink = newSink
+}
+
+// Default returns the process' logger, which the package functions log with
+func Default() *Logger {
+	return j
+}
+
+// NewLogger returns a logger identifying its messages with their own trace ID, for operations handled concurrently
+// in one process (e.g. by the daemon), which can't share the process' trace ID and file output
+func NewLogger(traceID string) *Logger {
+	return &Logger{traceID: traceID}
+}
 
 func Error(message interface{}, vars ...interface{}) {
 	j.Error(message, vars...)
@@ -44,17 +91,89 @@ func Debug(message interface{}, vars ...interface{}) {
 	j.Debug(message, vars...)
 }
 
+// Logger sends messages with a trace ID, to the journal (or sink) and optionally a file. A nil logger is the
+// process' logger
 type Logger struct {
+	traceID    string
+	fileOutput io.Writer
+}
+
+// TraceID returns the ID identifying the logger's messages
+func (j *Logger) TraceID() string {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	return j.get().traceID
+}
+
+// SetFileOutput writes all of the logger's subsequent messages to a file as well as the journal
+func (j *Logger) SetFileOutput(writer io.Writer) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	j.get().fileOutput = writer
+}
+
+// get returns the logger, or the process' logger if nil
+func (j *Logger) get() *Logger {
+	if j == nil {
+		return Default()
+	}
+
+	return j
 }
 
 func (j *Logger) journal(priority journal.Priority, message interface{}, vars ...interface{}) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	if !enabled(priority) {
+		return
+	}
+
+	if !allowed(priority, fmt.Sprint(message)) {
+		return
+	}
+
 	format := ""
 	if len(vars) > 0 {
 		format = fmt.Sprintf("%s: %s", message, vars)
 	} else {
 		format = fmt.Sprint(message)
 	}
-	journal.Send(format, priority, nil) // nolint: errcheck
+
+	j.get().send(priority, format)
+}
+
+// send writes a message to the outputs. Must be called with the output lock held
+func (j *Logger) send(priority journal.Priority, format string) {
+	timestamp := formatTimestamp(time.Now())
+
+	journalVars := map[string]string{"TIMESTAMP": timestamp}
+	if j.traceID != "" {
+		journalVars["TRACE_ID"] = j.traceID
+	}
+
+	if sink != nil {
+		sink.Send(priorityNames[priority], format, journalVars)
+	} else {
+		journal.Send(format, priority, journalVars) // nolint: errcheck
+	}
+
+	if j.fileOutput != nil {
+		fmt.Fprintf(j.fileOutput, "%s %s [%s] %s\n", // nolint: errcheck
+			timestamp,
+			priorityNames[priority],
+			j.traceID,
+			format)
+	}
+}
+
+var priorityNames = map[journal.Priority]string{
+	journal.PriErr:     "ERROR",
+	journal.PriWarning: "WARN",
+	journal.PriInfo:    "INFO",
+	journal.PriDebug:   "DEBUG",
 }
 
 func (j *Logger) Error(message interface{}, vars ...interface{}) {
@@ -130,6 +249,7 @@ func (j *Logger) DebugWithCtx(ctx context.Context, format interface{}, vars ...i
 }
 
 func (j *Logger) Flush() {
+	Flush()
 }
 
 func (j *Logger) GetChild(name string) logger.Logger {
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package journal
+
+import (
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/coreos/go-systemd/journal"
+)
+
+var levelPriorities = map[string]journal.Priority{
+	"error": journal.PriErr,
+	"warn":  journal.PriWarning,
+	"info":  journal.PriInfo,
+	"debug": journal.PriDebug,
+}
+
+var (
+	defaultLevel   = journal.PriDebug
+	modulePriority map[string]journal.Priority
+)
+
+// ValidLevel returns whether a log level name is valid - "error", "warn", "info" or "debug"
+func ValidLevel(level string) bool {
+	_, found := levelPriorities[level]
+	return found
+}
+
+// SetLevels sets the level of messages sent from now on, and overrides it for modules (packages, e.g. "cri").
+// Invalid levels are ignored
+func SetLevels(level string, moduleLevels map[string]string) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	if priority, found := levelPriorities[level]; found {
+		defaultLevel = priority
+	}
+
+	modulePriority = map[string]journal.Priority{}
+	for module, moduleLevel := range moduleLevels {
+		if priority, found := levelPriorities[moduleLevel]; found {
+			modulePriority[module] = priority
+		}
+	}
+}
+
+// enabled returns whether a message of a given priority is sent. Must be called with the output lock held
+func enabled(priority journal.Priority) bool {
+	threshold := defaultLevel
+
+	// looking up the caller is only needed when levels differ between modules
+	if len(modulePriority) > 0 {
+		if moduleThreshold, found := modulePriority[getCallerModule()]; found {
+			threshold = moduleThreshold
+		}
+	}
+
+	return priority <= threshold
+}
+
+// getCallerModule returns the name of the package that called into the journal
+func getCallerModule() string {
+	callers := make([]uintptr, 16)
+	frames := runtime.CallersFrames(callers[:runtime.Callers(2, callers)])
+
+	for {
+		frame, more := frames.Next()
+
+		// functions are named <package path>.<function>, and the package path may contain dots
+		packagePath := frame.Function
+		if lastSlashIdx := strings.LastIndex(packagePath, "/"); lastSlashIdx >= 0 {
+			if dotIdx := strings.Index(packagePath[lastSlashIdx:], "."); dotIdx >= 0 {
+				packagePath = packagePath[:lastSlashIdx+dotIdx]
+			}
+		} else if dotIdx := strings.Index(packagePath, "."); dotIdx >= 0 {
+			packagePath = packagePath[:dotIdx]
+		}
+
+		if module := path.Base(packagePath); module != "journal" || !more {
+			return module
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package journal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// NewTraceID returns a random ID to identify an invocation
+func NewTraceID() string {
+	traceIDBytes := make([]byte, 8)
+	if _, err := rand.Read(traceIDBytes); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(traceIDBytes)
+}
+
+// OpenOperationLog writes all subsequent messages to <dir>/<trace ID>.log as well, after removing the oldest
+// operation logs exceeding maxFiles or maxTotalBytes. The returned function closes the log
+func OpenOperationLog(dir string, maxFiles int, maxTotalBytes int64) (func(), error) {
+	return j.OpenOperationLog(dir, maxFiles, maxTotalBytes)
+}
+
+// OpenOperationLog writes all of the logger's subsequent messages to <dir>/<trace ID>.log as well, after removing
+// the oldest operation logs exceeding maxFiles or maxTotalBytes. The returned function closes the log
+func (j *Logger) OpenOperationLog(dir string, maxFiles int, maxTotalBytes int64) (func(), error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	if err := pruneOperationLogs(dir, maxFiles-1, maxTotalBytes); err != nil {
+		j.Warn("Failed to prune operation logs", "dir", dir, "err", err.Error())
+	}
+
+	logFile, err := os.OpenFile(path.Join(dir, fmt.Sprintf("%s.log", j.TraceID())),
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0640)
+	if err != nil {
+		return nil, err
+	}
+
+	j.SetFileOutput(logFile)
+
+	return func() {
+		j.SetFileOutput(nil)
+		logFile.Close() // nolint: errcheck
+	}, nil
+}
+
+// pruneOperationLogs removes the oldest logs until at most maxFiles remain, totaling at most maxTotalBytes
+func pruneOperationLogs(dir string, maxFiles int, maxTotalBytes int64) error {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var logFileInfos []os.FileInfo
+	var totalBytes int64
+
+	for _, fileInfo := range fileInfos {
+		if fileInfo.Mode().IsRegular() && strings.HasSuffix(fileInfo.Name(), ".log") {
+			logFileInfos = append(logFileInfos, fileInfo)
+			totalBytes += fileInfo.Size()
+		}
+	}
+
+	// oldest first
+	sort.Slice(logFileInfos, func(i, j int) bool {
+		return logFileInfos[i].ModTime().Before(logFileInfos[j].ModTime())
+	})
+
+	for len(logFileInfos) > 0 && (len(logFileInfos) > maxFiles || totalBytes > maxTotalBytes) {
+		if err := os.Remove(path.Join(dir, logFileInfos[0].Name())); err != nil {
+			return err
+		}
+
+		totalBytes -= logFileInfos[0].Size()
+		logFileInfos = logFileInfos[1:]
+	}
+
+	return nil
+}
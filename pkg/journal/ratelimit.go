@@ -0,0 +1,111 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package journal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/go-systemd/journal"
+)
+
+// messageWindow counts the messages sent with the same text and priority in the current window
+type messageWindow struct {
+	startedAt  time.Time
+	sent       int
+	suppressed int
+	priority   journal.Priority
+	message    string
+}
+
+var (
+	rateLimitBurst    = 5
+	rateLimitInterval = 10 * time.Second
+	messageWindows    = map[string]*messageWindow{}
+)
+
+// SetRateLimit limits the messages sent with the same text (regardless of their variables) and priority to
+// burst per interval. Suppressed messages are summarized once the interval passes. A burst of 0 or less
+// disables rate limiting
+func SetRateLimit(burst int, interval time.Duration) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	rateLimitBurst = burst
+	rateLimitInterval = interval
+}
+
+// Flush sends the summaries of suppressed messages
+func Flush() {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	for _, window := range messageWindows {
+		summarizeSuppressed(window)
+	}
+
+	messageWindows = map[string]*messageWindow{}
+}
+
+// allowed returns whether a message may be sent, summarizing messages suppressed in the previous window of its
+// text. Must be called with the output lock held
+func allowed(priority journal.Priority, message string) bool {
+	if rateLimitBurst <= 0 {
+		return true
+	}
+
+	key := fmt.Sprintf("%d:%s", priority, message)
+	now := time.Now()
+
+	window, found := messageWindows[key]
+	if !found || now.Sub(window.startedAt) >= rateLimitInterval {
+		if found {
+			summarizeSuppressed(window)
+		}
+
+		// drop windows that passed, so the map doesn't grow with every message ever sent
+		for otherKey, otherWindow := range messageWindows {
+			if now.Sub(otherWindow.startedAt) >= rateLimitInterval {
+				summarizeSuppressed(otherWindow)
+				delete(messageWindows, otherKey)
+			}
+		}
+
+		window = &messageWindow{startedAt: now, priority: priority, message: message}
+		messageWindows[key] = window
+	}
+
+	if window.sent >= rateLimitBurst {
+		window.suppressed++
+		return false
+	}
+
+	window.sent++
+	return true
+}
+
+func summarizeSuppressed(window *messageWindow) {
+	if window.suppressed == 0 {
+		return
+	}
+
+	j.send(window.priority, fmt.Sprintf("Last message repeated %d times: %s", window.suppressed, window.message))
+	window.suppressed = 0
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package journal
+
+import (
+	"strings"
+	"sync"
+)
+
+// Sink receives messages instead of the systemd journal, e.g. to inspect them without systemd
+type Sink interface {
+	Send(level string, message string, vars map[string]string)
+}
+
+// Entry is a message received by a MemorySink
+type Entry struct {
+	Level   string
+	Message string
+	Vars    map[string]string
+}
+
+// MemorySink keeps the messages it receives
+type MemorySink struct {
+	lock    sync.Mutex
+	entries []Entry
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Send(level string, message string, vars map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.entries = append(s.entries, Entry{
+		Level:   level,
+		Message: message,
+		Vars:    vars,
+	})
+}
+
+// Entries returns the messages received so far
+func (s *MemorySink) Entries() []Entry {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Entry{}, s.entries...)
+}
+
+// Contains returns whether a message of a level containing a substring was received
+func (s *MemorySink) Contains(level string, substring string) bool {
+	for _, entry := range s.Entries() {
+		if entry.Level == level && strings.Contains(entry.Message, substring) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package journal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslogTimestampFormat is RFC5424's timestamp, which allows up to microseconds
+const syslogTimestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+var syslogSeverities = map[string]int{
+	"ERROR": 3,
+	"WARN":  4,
+	"INFO":  6,
+	"DEBUG": 7,
+}
+
+// ValidSyslogFacility returns whether a syslog facility name is valid, e.g. "daemon" or "local0"
+func ValidSyslogFacility(facility string) bool {
+	_, found := syslogFacilities[facility]
+	return found
+}
+
+// SyslogSink sends messages to a syslog server in the RFC5424 format
+type SyslogSink struct {
+	lock     sync.Mutex
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	conn     net.Conn
+}
+
+// NewSyslogSink connects to a syslog server over "udp", "tcp" or "unix" (datagram or stream socket, e.g. /dev/log)
+func NewSyslogSink(network string, address string, facility string, appName string) (*SyslogSink, error) {
+	facilityCode, found := syslogFacilities[facility]
+	if !found {
+		return nil, fmt.Errorf("Unknown syslog facility %q", facility)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	s := &SyslogSink{
+		network:  network,
+		address:  address,
+		facility: facilityCode,
+		appName:  appName,
+		hostname: hostname,
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *SyslogSink) Send(level string, message string, vars map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry := s.format(level, message, vars)
+
+	// reconnect once, e.g. after the server restarted. Messages failing after that are dropped, as there's nowhere
+	// to report them
+	if err := s.write(entry); err != nil {
+		if s.connect() == nil {
+			s.write(entry) // nolint: errcheck
+		}
+	}
+}
+
+// Close closes the connection to the syslog server
+func (s *SyslogSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	return s.conn.Close()
+}
+
+func (s *SyslogSink) connect() error {
+	if s.conn != nil {
+		s.conn.Close() // nolint: errcheck
+		s.conn = nil
+	}
+
+	networks := []string{s.network}
+	if s.network == "unix" {
+		networks = []string{"unixgram", "unix"}
+	}
+
+	var err error
+	for _, network := range networks {
+		if s.conn, err = net.DialTimeout(network, s.address, 5*time.Second); err == nil {
+			s.network = network
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Failed to connect to syslog at %s://%s: %s", s.network, s.address, err)
+}
+
+func (s *SyslogSink) write(entry string) error {
+	if s.conn == nil {
+		return fmt.Errorf("Not connected")
+	}
+
+	// stream transports need framing (RFC6587 octet counting), datagrams carry a message each
+	if s.network == "tcp" || s.network == "unix" {
+		entry = fmt.Sprintf("%d %s", len(entry), entry)
+	}
+
+	_, err := s.conn.Write([]byte(entry))
+	return err
+}
+
+// format returns a message in the RFC5424 format. The trace ID is the message ID
+func (s *SyslogSink) format(level string, message string, vars map[string]string) string {
+	severity, found := syslogSeverities[level]
+	if !found {
+		severity = syslogSeverities["INFO"]
+	}
+
+	messageID := vars["TRACE_ID"]
+	if messageID == "" {
+		messageID = "-"
+	}
+
+	// sinks are called with the output lock held
+	timestamp := time.Now()
+	if timestampUTC {
+		timestamp = timestamp.UTC()
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		s.facility*8+severity,
+		timestamp.Format(syslogTimestampFormat),
+		s.hostname,
+		s.appName,
+		os.Getpid(),
+		messageID,
+		strings.TrimRight(message, "\n"))
+}
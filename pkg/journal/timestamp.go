@@ -0,0 +1,68 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package journal
+
+import (
+	"strconv"
+	"time"
+)
+
+var timestampFormats = map[string]func(time.Time) string{
+	"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+	"rfc3339nano": func(t time.Time) string {
+		return t.Format(time.RFC3339Nano)
+	},
+	"epoch-millis": func(t time.Time) string {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	},
+}
+
+var (
+	timestampFormat = "rfc3339nano"
+	timestampUTC    bool
+)
+
+// ValidTimestampFormat returns whether a timestamp format name is valid - "rfc3339", "rfc3339nano" or "epoch-millis"
+func ValidTimestampFormat(format string) bool {
+	_, found := timestampFormats[format]
+	return found
+}
+
+// SetTimestampFormat sets the format of the timestamps of messages sent from now on, and whether they're in UTC
+// rather than the node's timezone. Invalid formats are ignored
+func SetTimestampFormat(format string, utc bool) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+
+	if _, found := timestampFormats[format]; found {
+		timestampFormat = format
+	}
+
+	timestampUTC = utc
+}
+
+// formatTimestamp returns the timestamp of a message. Must be called with the output lock held
+func formatTimestamp(t time.Time) string {
+	if timestampUTC {
+		t = t.UTC()
+	}
+
+	return timestampFormats[timestampFormat](t)
+}
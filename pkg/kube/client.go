@@ -0,0 +1,146 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package kube
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// ErrNotFound is returned when the requested resource doesn't exist
+var ErrNotFound = fmt.Errorf("Not found")
+
+// ErrConflict is returned when a resource was modified since it was read, or already exists
+var ErrConflict = fmt.Errorf("Conflict")
+
+// Client is a minimal Kubernetes API client, for the few resources the driver works with
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewInClusterClient creates a client from the pod's service account
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("Not running in a cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+
+	return NewClient("https://"+net.JoinHostPort(host, port), serviceAccountDir)
+}
+
+// NewClient creates a client for an API server URL, authenticating with a service account's "token" and
+// "ca.crt" in a directory
+func NewClient(server string, credentialsDir string) (*Client, error) {
+	token, err := ioutil.ReadFile(filepath.Join(credentialsDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+
+	caCert, err := ioutil.ReadFile(filepath.Join(credentialsDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("Failed to parse cluster CA certificate")
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(server, "/"),
+		token:   string(bytes.TrimSpace(token)),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: caCertPool},
+			},
+		},
+	}, nil
+}
+
+// Do sends a request with an optional JSON body, decoding the JSON response into result (if not nil)
+func (c *Client) Do(method string, path string, contentType string, body interface{}, result interface{}) error {
+	var bodyReader *bytes.Reader
+
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		bodyReader = bytes.NewReader(bodyBytes)
+	} else {
+		bodyReader = bytes.NewReader(nil)
+	}
+
+	request, err := http.NewRequest(method, c.baseURL+path, bodyReader)
+	if err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	request.Header.Set("Authorization", "Bearer "+c.token)
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close() // nolint: errcheck
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case response.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case response.StatusCode >= 300:
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, response.StatusCode, string(responseBody))
+	}
+
+	if result != nil {
+		return json.Unmarshal(responseBody, result)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package kube
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// component reported as the source of events
+const eventSourceComponent = "v3io-fuse"
+
+type EventSource struct {
+	Component string `json:"component,omitempty"`
+	Host      string `json:"host,omitempty"`
+}
+
+type Event struct {
+	Metadata       ObjectMeta      `json:"metadata"`
+	InvolvedObject ObjectReference `json:"involvedObject"`
+	Reason         string          `json:"reason"`
+	Message        string          `json:"message"`
+	Type           string          `json:"type"`
+	Source         EventSource     `json:"source"`
+	FirstTimestamp string          `json:"firstTimestamp"`
+	LastTimestamp  string          `json:"lastTimestamp"`
+	Count          int32           `json:"count"`
+}
+
+// RecordEvent creates an event (of type "Normal" or "Warning") about an object. Events of cluster scoped
+// objects (e.g. nodes) are created in the default namespace
+func (c *Client) RecordEvent(involvedObject ObjectReference, eventType string, reason string, message string) error {
+	namespace := involvedObject.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	event := Event{
+		Metadata: ObjectMeta{
+			GenerateName: involvedObject.Name + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject: involvedObject,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source: EventSource{
+			Component: eventSourceComponent,
+			Host:      os.Getenv("NODE_NAME"),
+		},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	return c.Do("POST", fmt.Sprintf("/api/v1/namespaces/%s/events", namespace), "", &event, nil)
+}
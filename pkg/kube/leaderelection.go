@@ -0,0 +1,157 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package kube
+
+import (
+	"context"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+// LeaderElector elects a single leader among instances sharing a lease
+type LeaderElector struct {
+	client        *Client
+	namespace     string
+	name          string
+	identity      string
+	leaseDuration time.Duration
+	retryPeriod   time.Duration
+}
+
+func NewLeaderElector(client *Client,
+	namespace string,
+	name string,
+	identity string,
+	leaseDuration time.Duration) *LeaderElector {
+
+	return &LeaderElector{
+		client:        client,
+		namespace:     namespace,
+		name:          name,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		retryPeriod:   leaseDuration / 3,
+	}
+}
+
+// Run tries to acquire the lease until the context is done. While leading, lead is invoked with a context
+// that is cancelled once leadership is lost
+func (le *LeaderElector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	for ctx.Err() == nil {
+		if le.tryAcquireOrRenew() {
+			journal.Info("Acquired leadership", "lease", le.name, "identity", le.identity)
+
+			leadingCtx, cancelLeading := context.WithCancel(ctx)
+			leadingDone := make(chan struct{})
+
+			go func() {
+				defer close(leadingDone)
+				lead(leadingCtx)
+			}()
+
+			le.renewWhileLeading(leadingCtx)
+			cancelLeading()
+			<-leadingDone
+
+			journal.Warn("Lost leadership", "lease", le.name, "identity", le.identity)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(le.retryPeriod):
+		}
+	}
+}
+
+func (le *LeaderElector) renewWhileLeading(ctx context.Context) {
+	lastRenewal := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(le.retryPeriod):
+		}
+
+		if le.tryAcquireOrRenew() {
+			lastRenewal = time.Now()
+			continue
+		}
+
+		// step down before others may consider the lease expired
+		if time.Since(lastRenewal) > le.leaseDuration-le.retryPeriod {
+			return
+		}
+	}
+}
+
+// tryAcquireOrRenew returns whether this instance holds the lease after the attempt
+func (le *LeaderElector) tryAcquireOrRenew() bool {
+	now := time.Now()
+
+	lease, err := le.client.GetLease(le.namespace, le.name)
+	if err == ErrNotFound {
+		_, err = le.client.CreateLease(&Lease{
+			Metadata: ObjectMeta{
+				Name:      le.name,
+				Namespace: le.namespace,
+			},
+			Spec: LeaseSpec{
+				HolderIdentity:       le.identity,
+				LeaseDurationSeconds: int(le.leaseDuration.Seconds()),
+				AcquireTime:          formatMicroTime(now),
+				RenewTime:            formatMicroTime(now),
+			},
+		})
+
+		if err != nil {
+			journal.Debug("Failed to create lease", "lease", le.name, "err", err.Error())
+			return false
+		}
+
+		return true
+	}
+
+	if err != nil {
+		journal.Debug("Failed to get lease", "lease", le.name, "err", err.Error())
+		return false
+	}
+
+	if lease.Spec.HolderIdentity != le.identity {
+		if lease.Spec.HolderIdentity != "" && !lease.Expired(now) {
+			return false
+		}
+
+		lease.Spec.HolderIdentity = le.identity
+		lease.Spec.AcquireTime = formatMicroTime(now)
+		lease.Spec.LeaseTransitions++
+	}
+
+	lease.Spec.LeaseDurationSeconds = int(le.leaseDuration.Seconds())
+	lease.Spec.RenewTime = formatMicroTime(now)
+
+	if _, err := le.client.UpdateLease(lease); err != nil {
+		journal.Debug("Failed to update lease", "lease", le.name, "err", err.Error())
+		return false
+	}
+
+	return true
+}
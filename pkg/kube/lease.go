@@ -0,0 +1,115 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package kube
+
+import (
+	"fmt"
+	"time"
+)
+
+// microTimeLayout is the serialization format of metav1.MicroTime
+const microTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+type ObjectMeta struct {
+	Name            string            `json:"name,omitempty"`
+	GenerateName    string            `json:"generateName,omitempty"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             string            `json:"uid,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+type Lease struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       LeaseSpec  `json:"spec"`
+}
+
+type LeaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// RenewedAt returns when the lease was last renewed
+func (l *Lease) RenewedAt() time.Time {
+	renewTime, err := time.Parse(microTimeLayout, l.Spec.RenewTime)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return renewTime
+}
+
+// Expired returns whether the holder failed to renew the lease in time
+func (l *Lease) Expired(now time.Time) bool {
+	return now.After(l.RenewedAt().Add(time.Duration(l.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+func (c *Client) GetLease(namespace string, name string) (*Lease, error) {
+	lease := Lease{}
+	if err := c.Do("GET", getLeasePath(namespace, name), "", nil, &lease); err != nil {
+		return nil, err
+	}
+
+	return &lease, nil
+}
+
+func (c *Client) CreateLease(lease *Lease) (*Lease, error) {
+	lease.APIVersion = "coordination.k8s.io/v1"
+	lease.Kind = "Lease"
+
+	createdLease := Lease{}
+	if err := c.Do("POST",
+		fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", lease.Metadata.Namespace),
+		"",
+		lease,
+		&createdLease); err != nil {
+		return nil, err
+	}
+
+	return &createdLease, nil
+}
+
+// UpdateLease replaces a lease, failing with ErrConflict if it was modified since it was read
+func (c *Client) UpdateLease(lease *Lease) (*Lease, error) {
+	updatedLease := Lease{}
+	if err := c.Do("PUT",
+		getLeasePath(lease.Metadata.Namespace, lease.Metadata.Name),
+		"",
+		lease,
+		&updatedLease); err != nil {
+		return nil, err
+	}
+
+	return &updatedLease, nil
+}
+
+func getLeasePath(namespace string, name string) string {
+	return fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases/%s", namespace, name)
+}
+
+func formatMicroTime(t time.Time) string {
+	return t.UTC().Format(microTimeLayout)
+}
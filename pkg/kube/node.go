@@ -0,0 +1,142 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package kube
+
+import (
+	"fmt"
+)
+
+type Node struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     NodeSpec   `json:"spec"`
+}
+
+type NodeSpec struct {
+	Taints []Taint `json:"taints,omitempty"`
+}
+
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+// GetNode returns a node
+func (c *Client) GetNode(nodeName string) (*Node, error) {
+	node := Node{}
+	if err := c.Do("GET", fmt.Sprintf("/api/v1/nodes/%s", nodeName), "", nil, &node); err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
+// PatchNodeLabels sets the given labels on a node, leaving its other labels as is
+func (c *Client) PatchNodeLabels(nodeName string, labels map[string]string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": labels,
+		},
+	}
+
+	return c.Do("PATCH",
+		fmt.Sprintf("/api/v1/nodes/%s", nodeName),
+		"application/merge-patch+json",
+		patch,
+		nil)
+}
+
+// SetNodeTaint adds a taint to a node, replacing a taint with the same key and effect
+func (c *Client) SetNodeTaint(nodeName string, taint Taint) error {
+	return c.updateNodeTaints(nodeName, func(taints []Taint) []Taint {
+		return append(removeTaint(taints, taint.Key, taint.Effect), taint)
+	})
+}
+
+// RemoveNodeTaint removes a taint from a node, if it exists
+func (c *Client) RemoveNodeTaint(nodeName string, key string, effect string) error {
+	return c.updateNodeTaints(nodeName, func(taints []Taint) []Taint {
+		return removeTaint(taints, key, effect)
+	})
+}
+
+func (c *Client) updateNodeTaints(nodeName string, update func([]Taint) []Taint) error {
+	node, err := c.GetNode(nodeName)
+	if err != nil {
+		return err
+	}
+
+	taints := update(node.Spec.Taints)
+	if taints == nil {
+		taints = []Taint{}
+	}
+
+	// the resource version makes the update fail with a conflict if the taints changed meanwhile
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": node.Metadata.ResourceVersion,
+		},
+		"spec": map[string]interface{}{
+			"taints": taints,
+		},
+	}
+
+	return c.Do("PATCH",
+		fmt.Sprintf("/api/v1/nodes/%s", nodeName),
+		"application/merge-patch+json",
+		patch,
+		nil)
+}
+
+func removeTaint(taints []Taint, key string, effect string) []Taint {
+	var remainingTaints []Taint
+	for _, taint := range taints {
+		if taint.Key != key || taint.Effect != effect {
+			remainingTaints = append(remainingTaints, taint)
+		}
+	}
+
+	return remainingTaints
+}
+
+type NodeCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastHeartbeatTime  string `json:"lastHeartbeatTime,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// SetNodeCondition adds or replaces a condition (by type) in a node's status
+func (c *Client) SetNodeCondition(nodeName string, condition NodeCondition) error {
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []NodeCondition{condition},
+		},
+	}
+
+	// a strategic merge patch merges conditions by type, rather than replacing the list
+	return c.Do("PATCH",
+		fmt.Sprintf("/api/v1/nodes/%s/status", nodeName),
+		"application/strategic-merge-patch+json",
+		patch,
+		nil)
+}
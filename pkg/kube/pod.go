@@ -17,9 +17,36 @@ illegal under applicable law, and the grant of the foregoing license
 under the Apache 2.0 license is conditioned upon your compliance with
 such restriction.
 */
-package flex
+package kube
 
-type ClusterConfig struct {
-	Name     string   `json:"name"`
-	DataUrls []string `json:"data_urls"`
+import (
+	"fmt"
+	"net/url"
+)
+
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     PodSpec    `json:"spec"`
+}
+
+type PodSpec struct {
+	NodeName string `json:"nodeName"`
+}
+
+type podList struct {
+	Items []Pod `json:"items"`
+}
+
+// ListNodePods returns the pods scheduled to a node, across all namespaces
+func (c *Client) ListNodePods(nodeName string) ([]Pod, error) {
+	pods := podList{}
+	if err := c.Do("GET",
+		fmt.Sprintf("/api/v1/pods?fieldSelector=%s", url.QueryEscape("spec.nodeName="+nodeName)),
+		"",
+		nil,
+		&pods); err != nil {
+		return nil, err
+	}
+
+	return pods.Items, nil
 }
@@ -0,0 +1,142 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package kube
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type PersistentVolume struct {
+	APIVersion string                 `json:"apiVersion,omitempty"`
+	Kind       string                 `json:"kind,omitempty"`
+	Metadata   ObjectMeta             `json:"metadata"`
+	Spec       PersistentVolumeSpec   `json:"spec"`
+	Status     PersistentVolumeStatus `json:"status,omitempty"`
+}
+
+type PersistentVolumeSpec struct {
+	Capacity                      map[string]string           `json:"capacity,omitempty"`
+	AccessModes                   []string                    `json:"accessModes,omitempty"`
+	PersistentVolumeReclaimPolicy string                      `json:"persistentVolumeReclaimPolicy,omitempty"`
+	StorageClassName              string                      `json:"storageClassName,omitempty"`
+	ClaimRef                      *ObjectReference            `json:"claimRef,omitempty"`
+	FlexVolume                    *FlexPersistentVolumeSource `json:"flexVolume,omitempty"`
+}
+
+type ObjectReference struct {
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	UID       string `json:"uid,omitempty"`
+}
+
+type SecretReference struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type FlexPersistentVolumeSource struct {
+	Driver    string            `json:"driver"`
+	SecretRef *SecretReference  `json:"secretRef,omitempty"`
+	Options   map[string]string `json:"options,omitempty"`
+}
+
+type PersistentVolumeStatus struct {
+	Phase string `json:"phase,omitempty"`
+}
+
+type persistentVolumeList struct {
+	Items []PersistentVolume `json:"items"`
+}
+
+type StorageClass struct {
+	Metadata      ObjectMeta        `json:"metadata"`
+	Provisioner   string            `json:"provisioner"`
+	Parameters    map[string]string `json:"parameters,omitempty"`
+	ReclaimPolicy string            `json:"reclaimPolicy,omitempty"`
+}
+
+type storageClassList struct {
+	Items []StorageClass `json:"items"`
+}
+
+// ListPersistentVolumes returns all PVs
+func (c *Client) ListPersistentVolumes() ([]PersistentVolume, error) {
+	persistentVolumes := persistentVolumeList{}
+	if err := c.Do("GET", "/api/v1/persistentvolumes", "", nil, &persistentVolumes); err != nil {
+		return nil, err
+	}
+
+	return persistentVolumes.Items, nil
+}
+
+// CreatePersistentVolume creates a PV
+func (c *Client) CreatePersistentVolume(persistentVolume *PersistentVolume) error {
+	persistentVolume.APIVersion = "v1"
+	persistentVolume.Kind = "PersistentVolume"
+
+	return c.Do("POST", "/api/v1/persistentvolumes", "", persistentVolume, nil)
+}
+
+// DeletePersistentVolume deletes a PV
+func (c *Client) DeletePersistentVolume(name string) error {
+	return c.Do("DELETE", fmt.Sprintf("/api/v1/persistentvolumes/%s", name), "", nil, nil)
+}
+
+// ListStorageClasses returns all storage classes
+func (c *Client) ListStorageClasses() ([]StorageClass, error) {
+	storageClasses := storageClassList{}
+	if err := c.Do("GET", "/apis/storage.k8s.io/v1/storageclasses", "", nil, &storageClasses); err != nil {
+		return nil, err
+	}
+
+	return storageClasses.Items, nil
+}
+
+// ParseQuantity converts a resource quantity (e.g. 10Gi, 500M) to a number of bytes
+func ParseQuantity(quantity string) (int64, error) {
+	suffixes := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50},
+		{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15},
+	}
+
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(quantity, suffix.suffix) {
+			value, err := strconv.ParseFloat(strings.TrimSuffix(quantity, suffix.suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("Invalid quantity %q: %s", quantity, err)
+			}
+
+			return int64(value * float64(suffix.multiplier)), nil
+		}
+	}
+
+	value, err := strconv.ParseInt(quantity, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid quantity %q: %s", quantity, err)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package kube
+
+import (
+	"fmt"
+)
+
+type PersistentVolumeClaim struct {
+	Metadata ObjectMeta                  `json:"metadata"`
+	Spec     PersistentVolumeClaimSpec   `json:"spec"`
+	Status   PersistentVolumeClaimStatus `json:"status"`
+}
+
+type PersistentVolumeClaimSpec struct {
+	AccessModes      []string             `json:"accessModes,omitempty"`
+	StorageClassName string               `json:"storageClassName,omitempty"`
+	VolumeName       string               `json:"volumeName,omitempty"`
+	Resources        ResourceRequirements `json:"resources"`
+}
+
+type ResourceRequirements struct {
+	Requests map[string]string `json:"requests,omitempty"`
+}
+
+type PersistentVolumeClaimStatus struct {
+	Phase string `json:"phase,omitempty"`
+}
+
+type persistentVolumeClaimList struct {
+	Items []PersistentVolumeClaim `json:"items"`
+}
+
+// ListPersistentVolumeClaims returns the PVCs of all namespaces
+func (c *Client) ListPersistentVolumeClaims() ([]PersistentVolumeClaim, error) {
+	persistentVolumeClaims := persistentVolumeClaimList{}
+	if err := c.Do("GET", "/api/v1/persistentvolumeclaims", "", nil, &persistentVolumeClaims); err != nil {
+		return nil, err
+	}
+
+	return persistentVolumeClaims.Items, nil
+}
+
+// PatchPersistentVolumeClaimAnnotations sets the given annotations on a PVC, leaving its other annotations as is
+func (c *Client) PatchPersistentVolumeClaimAnnotations(namespace string, name string, annotations map[string]string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	}
+
+	return c.Do("PATCH",
+		fmt.Sprintf("/api/v1/namespaces/%s/persistentvolumeclaims/%s", namespace, name),
+		"application/merge-patch+json",
+		patch,
+		nil)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package kube
+
+import (
+	"fmt"
+	"time"
+)
+
+// TokenRequest requests a token of a service account
+type TokenRequest struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Spec       TokenRequestSpec   `json:"spec"`
+	Status     TokenRequestStatus `json:"status,omitempty"`
+}
+
+type TokenRequestSpec struct {
+	Audiences         []string        `json:"audiences,omitempty"`
+	ExpirationSeconds int64           `json:"expirationSeconds,omitempty"`
+	BoundObjectRef    *BoundObjectRef `json:"boundObjectRef,omitempty"`
+}
+
+// BoundObjectRef is the object a token is bound to, invalidating the token when the object is deleted
+type BoundObjectRef struct {
+	Kind       string `json:"kind"`
+	APIVersion string `json:"apiVersion"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+}
+
+type TokenRequestStatus struct {
+	Token               string    `json:"token"`
+	ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+}
+
+// CreateServiceAccountToken requests a token of a service account. This requires create on
+// serviceaccounts/token
+func (c *Client) CreateServiceAccountToken(namespace string, name string, spec TokenRequestSpec) (*TokenRequestStatus, error) {
+	tokenRequest := TokenRequest{
+		APIVersion: "authentication.k8s.io/v1",
+		Kind:       "TokenRequest",
+		Spec:       spec,
+	}
+
+	if err := c.Do("POST",
+		fmt.Sprintf("/api/v1/namespaces/%s/serviceaccounts/%s/token", namespace, name),
+		"",
+		&tokenRequest,
+		&tokenRequest); err != nil {
+		return nil, err
+	}
+
+	return &tokenRequest.Status, nil
+}
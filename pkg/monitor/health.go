@@ -0,0 +1,123 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// name of the service reported by the health service, in addition to the overall ("") status
+const healthServiceName = "flex-fuse"
+
+const readinessCheckInterval = 10 * time.Second
+
+// runHealthServer serves the grpc.health.v1 Health service, reporting serving while the container runtime
+// is reachable, until the context is done
+func (m *Monitor) runHealthServer(ctx context.Context) error {
+	listener, err := listen(m.config.HealthListenAddress)
+	if err != nil {
+		return err
+	}
+
+	grpcServer := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(grpcServer, m.healthServer)
+
+	go func() {
+		<-ctx.Done()
+		m.healthServer.Shutdown()
+		grpcServer.GracefulStop()
+	}()
+
+	go m.checkReadiness(ctx)
+
+	journal.Info("Serving health", "address", m.config.HealthListenAddress)
+
+	return grpcServer.Serve(listener)
+}
+
+func (m *Monitor) checkReadiness(ctx context.Context) {
+	for {
+		servingStatus := grpc_health_v1.HealthCheckResponse_SERVING
+
+		_, runtimeErr := m.criInstance.ListContainers("")
+		if runtimeErr != nil {
+			journal.Warn("Container runtime is unreachable", "err", runtimeErr.Error())
+			servingStatus = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+
+		m.reportNodeHealth(runtimeErr)
+
+		m.healthServer.SetServingStatus("", servingStatus)
+		m.healthServer.SetServingStatus(healthServiceName, servingStatus)
+		atomic.StoreInt32(&m.ready, boolToInt32(servingStatus == grpc_health_v1.HealthCheckResponse_SERVING))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(readinessCheckInterval):
+		}
+	}
+}
+
+func (m *Monitor) handleHealthz(responseWriter http.ResponseWriter, request *http.Request) {
+	responseWriter.WriteHeader(http.StatusOK)
+}
+
+func (m *Monitor) handleReadyz(responseWriter http.ResponseWriter, request *http.Request) {
+	if atomic.LoadInt32(&m.ready) == 0 {
+		responseWriter.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusOK)
+}
+
+// listen listens on a TCP address or unix://<socket path>
+func listen(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, "unix://") {
+		socketPath := strings.TrimPrefix(address, "unix://")
+
+		// remove a socket left by a previous run
+		os.Remove(socketPath) // nolint: errcheck
+
+		return net.Listen("unix", socketPath)
+	}
+
+	return net.Listen("tcp", address)
+}
+
+func boolToInt32(value bool) int32 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+const (
+	containerLogsPrefix = "flex-fuse-"
+	logPruneInterval    = time.Minute
+)
+
+// logFile is a file in the log directory of a FUSE container. The file currently written is named "current",
+// and rotated files are named @<timestamp>.s
+type logFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// runLogPruning enforces the total size caps of the FUSE container logs until the context is done
+func (m *Monitor) runLogPruning(ctx context.Context) {
+	logConfig := m.config.ContainerLogs
+	if logConfig.MaxMountSizeMB == 0 && logConfig.MaxNodeSizeMB == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(logPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		m.pruneLogs()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pruneLogs removes the oldest rotated files of mounts whose logs exceed the mount cap, and then of all mounts
+// while the node's logs exceed the node cap
+func (m *Monitor) pruneLogs() {
+	mountLogFiles, err := listMountLogFiles(m.config.HostPaths.ContainerLogsDir)
+	if err != nil {
+		journal.Warn("Failed to list container logs", "err", err.Error())
+		return
+	}
+
+	var nodeLogFiles []logFile
+
+	for mountName, logFiles := range mountLogFiles {
+		if m.config.ContainerLogs.MaxMountSizeMB > 0 {
+			logFiles = pruneLogFiles(logFiles, int64(m.config.ContainerLogs.MaxMountSizeMB)*1024*1024)
+			journal.Debug("Pruned mount logs", "mountName", mountName, "files", len(logFiles))
+		}
+
+		nodeLogFiles = append(nodeLogFiles, logFiles...)
+	}
+
+	if m.config.ContainerLogs.MaxNodeSizeMB > 0 {
+		pruneLogFiles(nodeLogFiles, int64(m.config.ContainerLogs.MaxNodeSizeMB)*1024*1024)
+	}
+}
+
+// pruneLogFiles removes the oldest rotated files until the total size is within the cap, returning the
+// remaining files. Files currently written aren't removed
+func pruneLogFiles(logFiles []logFile, maxBytes int64) []logFile {
+	sort.Slice(logFiles, func(i, j int) bool {
+		return logFiles[i].modTime.Before(logFiles[j].modTime)
+	})
+
+	totalBytes := getTotalLogBytes(logFiles)
+
+	var remainingLogFiles []logFile
+	for _, file := range logFiles {
+		if totalBytes > maxBytes && filepath.Base(file.path) != "current" {
+			if err := os.Remove(file.path); err == nil {
+				journal.Info("Removed log file over the size cap", "path", file.path, "size", file.size)
+				totalBytes -= file.size
+				continue
+			}
+		}
+
+		remainingLogFiles = append(remainingLogFiles, file)
+	}
+
+	return remainingLogFiles
+}
+
+// listMountLogFiles returns the log files of every mount, by the name its log directories share
+// (flex-fuse-<name>.<random suffix per container start>)
+func listMountLogFiles(containerLogsDir string) (map[string][]logFile, error) {
+	logDirs, err := filepath.Glob(filepath.Join(containerLogsDir, containerLogsPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	mountLogFiles := map[string][]logFile{}
+
+	for _, logDir := range logDirs {
+		mountName := getLogMountName(logDir)
+
+		logFiles, err := listLogFiles(logDir)
+		if err != nil {
+			continue
+		}
+
+		mountLogFiles[mountName] = append(mountLogFiles[mountName], logFiles...)
+	}
+
+	return mountLogFiles, nil
+}
+
+// getLogMountName returns the name a mount's log directories share, given one of them
+func getLogMountName(logDir string) string {
+	mountName := strings.TrimPrefix(filepath.Base(logDir), containerLogsPrefix)
+	if suffixIdx := strings.LastIndex(mountName, "."); suffixIdx > 0 {
+		mountName = mountName[:suffixIdx]
+	}
+
+	return mountName
+}
+
+func listLogFiles(logDir string) ([]logFile, error) {
+	fileInfos, err := ioutil.ReadDir(logDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var logFiles []logFile
+	for _, info := range fileInfos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		logFiles = append(logFiles, logFile{
+			path:    filepath.Join(logDir, info.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	return logFiles, nil
+}
+
+func getTotalLogBytes(logFiles []logFile) int64 {
+	var totalBytes int64
+	for _, file := range logFiles {
+		totalBytes += file.size
+	}
+
+	return totalBytes
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListMountLogFiles(t *testing.T) {
+	containerLogsDir := t.TempDir()
+
+	// two starts of the same mount's container, and another mount
+	logDirs := []string{
+		filepath.Join(containerLogsDir, "flex-fuse-0123abcd.00000001"),
+		filepath.Join(containerLogsDir, "flex-fuse-0123abcd.00000002"),
+		filepath.Join(containerLogsDir, "flex-fuse-4567ef01.00000003"),
+	}
+
+	for _, logDir := range logDirs {
+		if err := os.Mkdir(logDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(logDir, "current"), []byte("reconnect\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mountLogFiles, err := listMountLogFiles(containerLogsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mountLogFiles) != 2 {
+		t.Fatalf("Expected log files of 2 mounts, got %d", len(mountLogFiles))
+	}
+
+	for _, testCase := range []struct {
+		logDir             string
+		expectedBytes      int64
+		expectedReconnects int
+	}{
+		{logDir: logDirs[0], expectedBytes: 20, expectedReconnects: 2},
+		{logDir: logDirs[2], expectedBytes: 10, expectedReconnects: 1},
+	} {
+		logMountName := getLogMountName(testCase.logDir)
+
+		if logBytes := getTotalLogBytes(mountLogFiles[logMountName]); logBytes != testCase.expectedBytes {
+			t.Errorf("%s: expected %d log bytes, got %d", testCase.logDir, testCase.expectedBytes, logBytes)
+		}
+
+		if reconnects := countReconnects(containerLogsDir, logMountName); reconnects != testCase.expectedReconnects {
+			t.Errorf("%s: expected %d reconnects, got %d", testCase.logDir, testCase.expectedReconnects, reconnects)
+		}
+	}
+
+	if reconnects := countReconnects(containerLogsDir, ""); reconnects != 0 {
+		t.Errorf("Expected no reconnects without a log directory, got %d", reconnects)
+	}
+}
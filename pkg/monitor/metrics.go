@@ -0,0 +1,226 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/cgroup"
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/journal"
+)
+
+type mountMetrics struct {
+	containerName string
+	stats         *cgroup.Stats
+	reconnects    int
+	logBytes      int64
+	startedAt     time.Time
+}
+
+// handleMetrics writes per mount metrics in the prometheus text format
+func (m *Monitor) handleMetrics(responseWriter http.ResponseWriter, request *http.Request) {
+	containerNames, err := m.criInstance.ListOwned()
+	if err != nil {
+		journal.Warn("Failed to list containers", "err", err.Error())
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var allMountMetrics []mountMetrics
+	var nodeLogBytes int64
+
+	mountLogBytes := map[string]int64{}
+	if mountLogFiles, err := listMountLogFiles(m.config.HostPaths.ContainerLogsDir); err == nil {
+		for mountName, logFiles := range mountLogFiles {
+			mountLogBytes[mountName] = getTotalLogBytes(logFiles)
+			nodeLogBytes += mountLogBytes[mountName]
+		}
+	}
+
+	for _, containerName := range containerNames {
+		containerStatus, err := m.criInstance.GetContainerStatus(containerName)
+		if err != nil {
+			journal.Debug("Failed to get container status", "containerName", containerName, "err", err.Error())
+			continue
+		}
+
+		if containerStatus.State != "running" && containerStatus.State != "paused" {
+			continue
+		}
+
+		stats, err := m.getContainerStats(containerName, containerStatus.Pid)
+		if err != nil {
+			journal.Debug("Failed to get container stats", "containerName", containerName, "err", err.Error())
+			continue
+		}
+
+		logMountName := m.getLogMountName(containerName)
+
+		allMountMetrics = append(allMountMetrics, mountMetrics{
+			containerName: containerName,
+			stats:         stats,
+			reconnects:    countReconnects(m.config.HostPaths.ContainerLogsDir, logMountName),
+			logBytes:      mountLogBytes[logMountName],
+			startedAt:     containerStatus.StartedAt,
+		})
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	writeMetric(responseWriter,
+		"flex_fuse_mount_memory_rss_bytes",
+		"gauge",
+		"Resident memory of the FUSE container",
+		allMountMetrics,
+		func(metrics *mountMetrics) interface{} { return metrics.stats.MemoryRSSBytes })
+
+	writeMetric(responseWriter,
+		"flex_fuse_mount_cpu_seconds_total",
+		"counter",
+		"CPU time consumed by the FUSE container",
+		allMountMetrics,
+		func(metrics *mountMetrics) interface{} { return float64(metrics.stats.CPUUsageNanoseconds) / 1e9 })
+
+	writeMetric(responseWriter,
+		"flex_fuse_mount_open_files",
+		"gauge",
+		"Open file descriptors of the FUSE container processes",
+		allMountMetrics,
+		func(metrics *mountMetrics) interface{} { return metrics.stats.OpenFiles })
+
+	writeMetric(responseWriter,
+		"flex_fuse_mount_reconnects",
+		"gauge",
+		"Reconnects logged by the FUSE client in its current log files, reset as they're rotated",
+		allMountMetrics,
+		func(metrics *mountMetrics) interface{} { return metrics.reconnects })
+
+	writeMetric(responseWriter,
+		"flex_fuse_mount_start_time_seconds",
+		"gauge",
+		"Start time of the FUSE container's process since the epoch, 0 if unknown",
+		allMountMetrics,
+		func(metrics *mountMetrics) interface{} {
+			if metrics.startedAt.IsZero() {
+				return 0
+			}
+
+			return metrics.startedAt.Unix()
+		})
+
+	writeMetric(responseWriter,
+		"flex_fuse_mount_log_bytes",
+		"gauge",
+		"Size of the FUSE container's logs, including rotated files",
+		allMountMetrics,
+		func(metrics *mountMetrics) interface{} { return metrics.logBytes })
+
+	fmt.Fprintf(responseWriter, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n",
+		"flex_fuse_node_log_bytes",
+		"Size of the logs of all FUSE containers on the node",
+		"flex_fuse_node_log_bytes",
+		"flex_fuse_node_log_bytes",
+		nodeLogBytes)
+}
+
+// getContainerStats returns the resource usage of a container as reported by the runtime, or read from its
+// process' cgroup if the runtime doesn't report it
+func (m *Monitor) getContainerStats(containerName string, pid uint32) (*cgroup.Stats, error) {
+	if containerStatsGetter, ok := m.criInstance.(cri.ContainerStatsGetter); ok {
+		return containerStatsGetter.GetContainerStats(containerName)
+	}
+
+	return cgroup.GetStats(pid)
+}
+
+// getLogMountName returns the name the log directories of a container's mount share, as keyed by
+// listMountLogFiles, or an empty string if the runtime doesn't report the container's log directory
+func (m *Monitor) getLogMountName(containerName string) string {
+	containerLogDirGetter, ok := m.criInstance.(cri.ContainerLogDirGetter)
+	if !ok {
+		return ""
+	}
+
+	logDir, err := containerLogDirGetter.GetContainerLogDir(containerName)
+	if err != nil {
+		journal.Debug("Failed to get container log directory", "containerName", containerName, "err", err.Error())
+		return ""
+	}
+
+	return getLogMountName(logDir)
+}
+
+func writeMetric(writer io.Writer,
+	name string,
+	metricType string,
+	help string,
+	allMountMetrics []mountMetrics,
+	getValue func(*mountMetrics) interface{}) {
+
+	fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+
+	for mountMetricsIdx := range allMountMetrics {
+		fmt.Fprintf(writer, "%s{container=%q} %v\n",
+			name,
+			allMountMetrics[mountMetricsIdx].containerName,
+			getValue(&allMountMetrics[mountMetricsIdx]))
+	}
+}
+
+// countReconnects counts reconnect lines in the current logs of a mount's FUSE containers. Rotated files aren't
+// counted, so the count drops as the logs rotate and isn't a counter
+func countReconnects(containerLogsDir string, logMountName string) int {
+	if logMountName == "" {
+		return 0
+	}
+
+	logFilePaths, err := filepath.Glob(filepath.Join(containerLogsDir,
+		fmt.Sprintf("%s%s.*/current", containerLogsPrefix, logMountName)))
+	if err != nil {
+		return 0
+	}
+
+	reconnects := 0
+	for _, logFilePath := range logFilePaths {
+		logFile, err := os.Open(logFilePath)
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(logFile)
+		for scanner.Scan() {
+			if strings.Contains(strings.ToLower(scanner.Text()), "reconnect") {
+				reconnects++
+			}
+		}
+
+		logFile.Close() // nolint: errcheck
+	}
+
+	return reconnects
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/journal"
+
+	"google.golang.org/grpc/health"
+)
+
+// time to let an unmount in progress remove the container before reacting to its exit
+const taskExitGracePeriod = 5 * time.Second
+
+// Monitor is a long running process observing the FUSE containers of a node
+type Monitor struct {
+	config       *config.Config
+	criInstance  cri.CRI
+	server       *http.Server
+	healthServer *health.Server
+	ready        int32
+
+	// the node health last reported, see reportNodeHealth
+	reportedHealth       *nodeHealth
+	healthReportedAt     time.Time
+	healthTransitionedAt time.Time
+
+	// restarts of exited containers, see scheduleRestart
+	restarts     map[string]*restartState
+	restartsLock sync.Mutex
+}
+
+func NewMonitor(monitorConfig *config.Config) (*Monitor, error) {
+	criInstance, err := cri.New(monitorConfig.RuntimeBackend, monitorConfig.RuntimeEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	newMonitor := Monitor{
+		config:       monitorConfig,
+		criInstance:  criInstance,
+		healthServer: health.NewServer(),
+		restarts:     map[string]*restartState{},
+	}
+
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("/metrics", newMonitor.handleMetrics)
+	serveMux.HandleFunc("/healthz", newMonitor.handleHealthz)
+	serveMux.HandleFunc("/readyz", newMonitor.handleReadyz)
+
+	newMonitor.server = &http.Server{
+		Addr:    monitorConfig.MetricsListenAddress,
+		Handler: serveMux,
+	}
+
+	return &newMonitor, nil
+}
+
+// Run serves until the context is done
+func (m *Monitor) Run(ctx context.Context) error {
+	defer m.criInstance.Close() // nolint: errcheck
+
+	m.reportTopology()
+
+	serverErrChan := make(chan error, 2)
+
+	go func() {
+		journal.Info("Serving metrics", "address", m.server.Addr)
+		serverErrChan <- m.server.ListenAndServe()
+	}()
+
+	go func() {
+		serverErrChan <- m.runHealthServer(ctx)
+	}()
+
+	go m.runStaleMountProbes(ctx)
+
+	go m.runLogPruning(ctx)
+
+	if taskExitWatcher, ok := m.criInstance.(cri.TaskExitWatcher); ok {
+		go func() {
+			journal.Info("Watching task exits", "restartPolicy", m.config.RestartPolicy)
+
+			if err := taskExitWatcher.WatchTaskExits(ctx, m.handleTaskExit); err != nil {
+				journal.Error("Stopped watching task exits", "err", err.Error())
+			}
+		}()
+	}
+
+	select {
+	case err := <-serverErrChan:
+		return err
+	case <-ctx.Done():
+		return m.server.Shutdown(context.Background())
+	}
+}
+
+// handleTaskExit reacts to the exit of a FUSE container's process according to the restart policy
+func (m *Monitor) handleTaskExit(containerName string, exitStatus uint32) {
+	if !strings.HasPrefix(containerName, flex.ContainerNamePrefix) {
+		return
+	}
+
+	journal.Warn("FUSE container exited",
+		"containerName", containerName,
+		"exitStatus", exitStatus,
+		"restartPolicy", m.config.RestartPolicy)
+
+	if m.config.RestartPolicy == "none" {
+		return
+	}
+
+	// exits are expected while unmounting, in which case the container is removed shortly after. The exit is
+	// handled once the grace period passes rather than blocking the handling of other containers' exits
+	time.AfterFunc(taskExitGracePeriod, func() {
+		m.handleExitedContainer(containerName)
+	})
+}
+
+// handleExitedContainer removes or restarts a container whose process exited, unless it was removed
+func (m *Monitor) handleExitedContainer(containerName string) {
+	if !m.containerExists(containerName) {
+		journal.Debug("Container was removed, ignoring exit", "containerName", containerName)
+		return
+	}
+
+	switch m.config.RestartPolicy {
+	case "remove":
+		if err := m.criInstance.RemoveContainer(containerName); err != nil {
+			journal.Error("Failed to remove exited container", "containerName", containerName, "err", err.Error())
+		}
+	case "restart":
+		m.scheduleRestart(containerName)
+	}
+}
+
+// containerExists returns whether a container created by the driver exists
+func (m *Monitor) containerExists(containerName string) bool {
+	containerNames, err := m.criInstance.ListOwned()
+	if err != nil {
+		return false
+	}
+
+	for _, existingContainerName := range containerNames {
+		if existingContainerName == containerName {
+			return true
+		}
+	}
+
+	return false
+}
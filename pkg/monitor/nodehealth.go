@@ -0,0 +1,159 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/kube"
+)
+
+const (
+	unhealthyTaintKey       = "v3io.io/fuse-unhealthy"
+	unhealthyConditionType  = "V3ioFuseUnhealthy"
+	nodeHealthHeartbeatTime = 5 * time.Minute
+)
+
+type nodeHealth struct {
+	reason  string
+	message string
+}
+
+// reportNodeHealth sets the unhealthy taint and condition of the node according to the driver's health,
+// whenever it changes
+func (m *Monitor) reportNodeHealth(runtimeErr error) {
+	nodeHealthConfig := &m.config.NodeHealth
+	if !nodeHealthConfig.Taint && !nodeHealthConfig.Condition {
+		return
+	}
+
+	currentHealth := m.getNodeHealth(runtimeErr)
+
+	// report changes, and periodically refresh the condition's heartbeat
+	if m.reportedHealth != nil &&
+		*m.reportedHealth == currentHealth &&
+		time.Since(m.healthReportedAt) < nodeHealthHeartbeatTime {
+		return
+	}
+
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		journal.Warn("Can't report node health, NODE_NAME is not set")
+		return
+	}
+
+	kubeClient, err := kube.NewInClusterClient()
+	if err != nil {
+		journal.Warn("Can't report node health", "err", err.Error())
+		return
+	}
+
+	unhealthy := currentHealth.reason != ""
+
+	if nodeHealthConfig.Taint {
+		if unhealthy {
+			err = kubeClient.SetNodeTaint(nodeName, kube.Taint{Key: unhealthyTaintKey, Effect: "NoSchedule"})
+		} else {
+			err = kubeClient.RemoveNodeTaint(nodeName, unhealthyTaintKey, "NoSchedule")
+		}
+
+		if err != nil {
+			journal.Warn("Failed to update node taint", "nodeName", nodeName, "err", err.Error())
+			return
+		}
+	}
+
+	if nodeHealthConfig.Condition {
+		if err := kubeClient.SetNodeCondition(nodeName, m.getUnhealthyCondition(&currentHealth)); err != nil {
+			journal.Warn("Failed to update node condition", "nodeName", nodeName, "err", err.Error())
+			return
+		}
+	}
+
+	if m.reportedHealth == nil || *m.reportedHealth != currentHealth {
+		journal.Info("Reported node health", "nodeName", nodeName, "unhealthy", unhealthy, "reason", currentHealth.reason)
+		m.healthTransitionedAt = time.Now()
+	}
+
+	m.reportedHealth = &currentHealth
+	m.healthReportedAt = time.Now()
+}
+
+func (m *Monitor) getNodeHealth(runtimeErr error) nodeHealth {
+	if runtimeErr != nil {
+		return nodeHealth{
+			reason:  "RuntimeUnreachable",
+			message: fmt.Sprintf("Container runtime is unreachable: %s", runtimeErr),
+		}
+	}
+
+	results, err := flex.NewMounterFromConfig(m.config).ListResults()
+	if err != nil {
+		journal.Warn("Failed to list operation results", "err", err.Error())
+		return nodeHealth{}
+	}
+
+	window := time.Duration(m.config.NodeHealth.MountFailureWindowSeconds) * time.Second
+	mountFailures := 0
+
+	for _, result := range results {
+		if (result.Operation == "mount" || result.Operation == "mountdevice" || result.Operation == "restart") &&
+			result.Status == "Failure" &&
+			time.Since(result.StartedAt) < window {
+			mountFailures++
+		}
+	}
+
+	if mountFailures >= m.config.NodeHealth.MountFailureThreshold {
+		return nodeHealth{
+			reason:  "MountFailures",
+			message: fmt.Sprintf("Mounts of %d volumes failed in the last %s", mountFailures, window),
+		}
+	}
+
+	return nodeHealth{}
+}
+
+func (m *Monitor) getUnhealthyCondition(currentHealth *nodeHealth) kube.NodeCondition {
+	condition := kube.NodeCondition{
+		Type:               unhealthyConditionType,
+		Status:             "False",
+		Reason:             "Healthy",
+		Message:            "The v3io FUSE driver is healthy",
+		LastHeartbeatTime:  time.Now().UTC().Format(time.RFC3339),
+		LastTransitionTime: m.healthTransitionedAt.UTC().Format(time.RFC3339),
+	}
+
+	if m.reportedHealth == nil || *m.reportedHealth != *currentHealth {
+		condition.LastTransitionTime = condition.LastHeartbeatTime
+	}
+
+	if currentHealth.reason != "" {
+		condition.Status = "True"
+		condition.Reason = currentHealth.reason
+		condition.Message = currentHealth.message
+	}
+
+	return condition
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/kube"
+
+	"golang.org/x/sys/unix"
+)
+
+// taint set on the node by the taint-node stale mount action, while any mount is stale
+const staleMountTaintKey = "v3io.io/fuse-stale-mount"
+
+type probeState struct {
+	failures int
+
+	// a probe that hasn't returned yet, e.g. blocked on a hung FUSE process
+	inFlight chan error
+}
+
+// runStaleMountProbes periodically statfs's every mount, acting on consecutive failures according to the
+// configured thresholds, until the context is done
+func (m *Monitor) runStaleMountProbes(ctx context.Context) {
+	probeConfig := &m.config.StaleMountProbe
+	if probeConfig.IntervalSeconds < 0 {
+		return
+	}
+
+	journal.Info("Probing mounts", "intervalSeconds", probeConfig.IntervalSeconds)
+
+	mounter := flex.NewMounterFromConfig(m.config)
+	probeStates := map[string]*probeState{}
+
+	// with a taint-node action, assume a previous run may have left the taint, so it's removed if no mount is stale
+	nodeTainted := false
+	for _, threshold := range probeConfig.Thresholds {
+		if threshold.Action == "taint-node" {
+			nodeTainted = true
+		}
+	}
+
+	for {
+		mountRecords, err := mounter.ListMountRecords()
+		if err != nil {
+			journal.Warn("Failed to list mounts", "err", err.Error())
+		}
+
+		currentProbeStates := map[string]*probeState{}
+		anyStale := false
+
+		for _, mountRecord := range mountRecords {
+			mountProbeState := probeStates[mountRecord.TargetPath]
+			if mountProbeState == nil {
+				mountProbeState = &probeState{}
+			}
+			currentProbeStates[mountRecord.TargetPath] = mountProbeState
+
+			// I/O through a paused FUSE container blocks until it's resumed, so it would fail the probes
+			if m.isPaused(mounter, mountRecord) {
+				journal.Debug("Not probing paused mount", "targetPath", mountRecord.TargetPath)
+				continue
+			}
+
+			if err := probeMount(mountRecord.TargetPath,
+				mountProbeState,
+				time.Duration(probeConfig.TimeoutSeconds)*time.Second); err != nil {
+				mountProbeState.failures++
+
+				m.handleProbeFailure(mounter, mountRecord, mountProbeState.failures, err)
+			} else {
+				if mountProbeState.failures > 0 {
+					journal.Info("Mount recovered", "targetPath", mountRecord.TargetPath, "failures", mountProbeState.failures)
+				}
+
+				mountProbeState.failures = 0
+			}
+
+			if m.isStale(mountProbeState.failures, "taint-node") {
+				anyStale = true
+			}
+		}
+
+		probeStates = currentProbeStates
+
+		// untaint the node once no mount is stale anymore
+		if nodeTainted && !anyStale {
+			nodeTainted = !m.setStaleMountTaint(false)
+		} else if anyStale && !nodeTainted {
+			nodeTainted = m.setStaleMountTaint(true)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(probeConfig.IntervalSeconds) * time.Second):
+		}
+	}
+}
+
+// isPaused returns whether a mount's FUSE container was frozen or is paused
+func (m *Monitor) isPaused(mounter *flex.Mounter, mountRecord *flex.MountRecord) bool {
+	if mounter.IsFrozen(mountRecord.ContainerName) {
+		return true
+	}
+
+	containerStatus, err := m.criInstance.GetContainerStatus(mountRecord.ContainerName)
+
+	return err == nil && containerStatus.State == "paused"
+}
+
+func (m *Monitor) handleProbeFailure(mounter *flex.Mounter, mountRecord *flex.MountRecord, failures int, err error) {
+	for _, threshold := range m.config.StaleMountProbe.Thresholds {
+		if failures != threshold.Failures {
+			continue
+		}
+
+		journal.Warn("Mount failed consecutive probes",
+			"targetPath", mountRecord.TargetPath,
+			"containerName", mountRecord.ContainerName,
+			"failures", failures,
+			"action", threshold.Action,
+			"err", err.Error())
+
+		if threshold.Action == "remount" {
+			response := mounter.Remount(mountRecord)
+			journal.Info("Remounted stale mount",
+				"targetPath", mountRecord.TargetPath,
+				"status", response.Status,
+				"message", response.Message)
+		}
+	}
+}
+
+// isStale returns whether a number of consecutive failures reached the threshold of an action
+func (m *Monitor) isStale(failures int, action string) bool {
+	for _, threshold := range m.config.StaleMountProbe.Thresholds {
+		if threshold.Action == action && failures >= threshold.Failures {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setStaleMountTaint taints or untaints the node, returning whether it succeeded
+func (m *Monitor) setStaleMountTaint(tainted bool) bool {
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		journal.Warn("Can't taint node, NODE_NAME is not set")
+		return false
+	}
+
+	kubeClient, err := kube.NewInClusterClient()
+	if err != nil {
+		journal.Warn("Can't taint node", "err", err.Error())
+		return false
+	}
+
+	if tainted {
+		err = kubeClient.SetNodeTaint(nodeName, kube.Taint{Key: staleMountTaintKey, Effect: "NoSchedule"})
+	} else {
+		err = kubeClient.RemoveNodeTaint(nodeName, staleMountTaintKey, "NoSchedule")
+	}
+
+	if err != nil {
+		journal.Warn("Failed to update node taint", "nodeName", nodeName, "tainted", tainted, "err", err.Error())
+		return false
+	}
+
+	journal.Info("Updated node taint", "nodeName", nodeName, "key", staleMountTaintKey, "tainted", tainted)
+
+	return true
+}
+
+// probeMount statfs's a mount within a timeout. A probe that times out is left running, and the next probes of
+// the mount fail until it returns
+func probeMount(targetPath string, mountProbeState *probeState, timeout time.Duration) error {
+	if mountProbeState.inFlight == nil {
+		inFlight := make(chan error, 1)
+		mountProbeState.inFlight = inFlight
+
+		go func() {
+			var statfs unix.Statfs_t
+			inFlight <- unix.Statfs(targetPath, &statfs)
+		}()
+	}
+
+	select {
+	case err := <-mountProbeState.inFlight:
+		mountProbeState.inFlight = nil
+		return err
+	case <-time.After(timeout):
+		return context.DeadlineExceeded
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/kube"
+)
+
+// error code of the result recorded for mounts whose FUSE container is crash looping
+const crashLoopErrorCode = "CrashLoop"
+
+// restartState tracks the restarts of an exited FUSE container
+type restartState struct {
+	restartedAt []time.Time
+	backoff     time.Duration
+	crashLoop   bool
+
+	// when the state was created, see isRemounted
+	createdAt time.Time
+}
+
+// scheduleRestart restarts an exited container after a backoff, doubled on every restart. A container restarted
+// too often within the crash loop window is left exited and its mount is marked as failed
+func (m *Monitor) scheduleRestart(containerName string) {
+	backoffConfig := m.config.RestartBackoff
+	window := time.Duration(backoffConfig.CrashLoopWindowSeconds) * time.Second
+
+	m.restartsLock.Lock()
+
+	state, found := m.restarts[containerName]
+	if found && m.isRemounted(containerName, state.createdAt) {
+		journal.Info("Container was mounted again, resetting its restarts", "containerName", containerName)
+		found = false
+	}
+
+	if !found {
+		state = &restartState{createdAt: time.Now()}
+		m.restarts[containerName] = state
+	}
+
+	if state.crashLoop {
+		m.restartsLock.Unlock()
+		journal.Debug("Container is crash looping, not restarting", "containerName", containerName)
+		return
+	}
+
+	// forget restarts outside the window - a container that ran long enough starts over
+	var recentRestarts []time.Time
+	for _, restartedAt := range state.restartedAt {
+		if time.Since(restartedAt) < window {
+			recentRestarts = append(recentRestarts, restartedAt)
+		}
+	}
+
+	state.restartedAt = recentRestarts
+	if len(state.restartedAt) == 0 {
+		state.backoff = time.Duration(backoffConfig.InitialSeconds) * time.Second
+	}
+
+	if len(state.restartedAt) >= backoffConfig.CrashLoopRestarts {
+		state.crashLoop = true
+		m.restartsLock.Unlock()
+
+		m.reportCrashLoop(containerName, len(recentRestarts), window)
+		return
+	}
+
+	delay := state.backoff
+	state.backoff *= 2
+	if maxBackoff := time.Duration(backoffConfig.MaxSeconds) * time.Second; state.backoff > maxBackoff {
+		state.backoff = maxBackoff
+	}
+
+	state.restartedAt = append(state.restartedAt, time.Now().Add(delay))
+	restarts := len(state.restartedAt)
+
+	m.restartsLock.Unlock()
+
+	journal.Info("Restarting exited container",
+		"containerName", containerName,
+		"delay", delay.String(),
+		"recentRestarts", len(recentRestarts))
+
+	time.AfterFunc(delay, func() {
+		if !m.containerExists(containerName) {
+			journal.Debug("Container was removed, not restarting", "containerName", containerName)
+			m.forgetRestarts(containerName)
+			return
+		}
+
+		if err := m.criInstance.RestartContainer(containerName); err != nil {
+			journal.Error("Failed to restart exited container", "containerName", containerName, "err", err.Error())
+			return
+		}
+
+		time.AfterFunc(window, func() {
+			m.forgetRestartsIfRunning(containerName, state, restarts)
+		})
+	})
+}
+
+// isRemounted returns whether a container was mounted again (e.g. by kubelet after being marked as crash
+// looping) since a time, so that its past restarts no longer apply
+func (m *Monitor) isRemounted(containerName string, since time.Time) bool {
+	mountRecords, err := flex.NewMounterFromConfig(m.config).ListMountRecords()
+	if err != nil {
+		journal.Warn("Failed to list mounts", "err", err.Error())
+	}
+
+	for _, mountRecord := range mountRecords {
+		if mountRecord.ContainerName == containerName && mountRecord.MountedAt.After(since) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forgetRestartsIfRunning drops the restart state of a container that ran through the crash loop window since it
+// was restarted, unless it was restarted again meanwhile
+func (m *Monitor) forgetRestartsIfRunning(containerName string, state *restartState, restarts int) {
+	containerStatus, err := m.criInstance.GetContainerStatus(containerName)
+	if err != nil || containerStatus.State != "running" {
+		return
+	}
+
+	m.restartsLock.Lock()
+	defer m.restartsLock.Unlock()
+
+	if m.restarts[containerName] == state && len(state.restartedAt) == restarts {
+		journal.Debug("Restarted container is running, resetting its restarts", "containerName", containerName)
+		delete(m.restarts, containerName)
+	}
+}
+
+// forgetRestarts drops the restart state of a container that was removed
+func (m *Monitor) forgetRestarts(containerName string) {
+	m.restartsLock.Lock()
+	defer m.restartsLock.Unlock()
+
+	delete(m.restarts, containerName)
+}
+
+// reportCrashLoop marks the mount of a crash looping container as failed and emits an event about it
+func (m *Monitor) reportCrashLoop(containerName string, restarts int, window time.Duration) {
+	message := fmt.Sprintf("FUSE container %s exited after %d restarts in %s, no longer restarting",
+		containerName,
+		restarts,
+		window)
+
+	journal.Error("FUSE container is crash looping", "containerName", containerName, "restarts", restarts)
+
+	mounter := flex.NewMounterFromConfig(m.config)
+
+	mountRecords, err := mounter.ListMountRecords()
+	if err != nil {
+		journal.Warn("Failed to list mounts", "err", err.Error())
+	}
+
+	var crashLoopRecord *flex.MountRecord
+	for _, mountRecord := range mountRecords {
+		if mountRecord.ContainerName == containerName {
+			crashLoopRecord = mountRecord
+			break
+		}
+	}
+
+	if crashLoopRecord != nil {
+		if err := mounter.MarkFailed(crashLoopRecord.TargetPath, "restart", crashLoopErrorCode, message); err != nil {
+			journal.Warn("Failed to mark mount as failed", "targetPath", crashLoopRecord.TargetPath, "err", err.Error())
+		}
+	}
+
+	alert := flex.Alert{
+		Event:     flex.AlertCrashLoop,
+		ErrorCode: crashLoopErrorCode,
+		Message:   message,
+	}
+
+	if crashLoopRecord != nil {
+		alert.TargetPath = crashLoopRecord.TargetPath
+		alert.Namespace = crashLoopRecord.Spec.Namespace
+		alert.Pod = crashLoopRecord.Spec.PodName
+	}
+
+	mounter.SendAlert(&alert)
+
+	kubeClient, err := kube.NewInClusterClient()
+	if err != nil {
+		journal.Warn("Can't emit crash loop event", "err", err.Error())
+		return
+	}
+
+	// the event is about the pod using the mount, or the node for shared device mounts
+	involvedObject := kube.ObjectReference{
+		Kind: "Node",
+		Name: os.Getenv("NODE_NAME"),
+	}
+
+	if crashLoopRecord != nil && crashLoopRecord.Spec.PodName != "" {
+		involvedObject = kube.ObjectReference{
+			Kind:      "Pod",
+			Namespace: crashLoopRecord.Spec.Namespace,
+			Name:      crashLoopRecord.Spec.PodName,
+		}
+	}
+
+	if involvedObject.Name == "" {
+		journal.Warn("Can't emit crash loop event, NODE_NAME is not set")
+		return
+	}
+
+	if err := kubeClient.RecordEvent(involvedObject, "Warning", "FUSECrashLoop", message); err != nil {
+		journal.Warn("Failed to emit crash loop event", "err", err.Error())
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/flex"
+	"github.com/v3io/flex-fuse/pkg/state"
+)
+
+const restartTestContainerName = "v3io-fuse-pod-data"
+
+func newRestartTestMonitor(t *testing.T) (*Monitor, *cri.Fake) {
+	tempDir := t.TempDir()
+	configPath := path.Join(tempDir, "config.json")
+
+	if err := ioutil.WriteFile(configPath, []byte(fmt.Sprintf(`{
+		"clusters": [{"name": "default", "data_urls": ["tcp://127.0.0.1:1234"]}],
+		"state_dir": "%s",
+		"restart_policy": "restart",
+		"restart_backoff": {"initial_seconds": 60, "max_seconds": 60, "crash_loop_restarts": 3,
+			"crash_loop_window_seconds": 600}
+	}`, path.Join(tempDir, "state"))), 0644); err != nil {
+		t.Fatalf("Failed to write configuration: %s", err)
+	}
+
+	monitorConfig, err := config.NewFromFile(configPath, true)
+	if err != nil {
+		t.Fatalf("Failed to read configuration: %s", err)
+	}
+
+	fake := cri.NewFake()
+	if err := fake.CreateContainer("image", restartTestContainerName, "/target", nil, nil); err != nil {
+		t.Fatalf("Failed to create container: %s", err)
+	}
+
+	return &Monitor{
+		config:      monitorConfig,
+		criInstance: fake,
+		restarts:    map[string]*restartState{},
+	}, fake
+}
+
+func TestScheduleRestartResetsCrashLoopOnRemount(t *testing.T) {
+	monitor, _ := newRestartTestMonitor(t)
+	monitor.restarts[restartTestContainerName] = &restartState{
+		restartedAt: []time.Time{time.Now(), time.Now(), time.Now()},
+		crashLoop:   true,
+		createdAt:   time.Now().Add(-time.Minute),
+	}
+
+	// crash looping containers aren't restarted
+	monitor.scheduleRestart(restartTestContainerName)
+
+	if !monitor.restarts[restartTestContainerName].crashLoop {
+		t.Fatalf("Expected the container to remain crash looping")
+	}
+
+	// until mounted again
+	if err := state.New(monitor.config.StateDir).WriteJSON(
+		path.Join("mounts", restartTestContainerName+".json"),
+		&flex.MountRecord{
+			ContainerName: restartTestContainerName,
+			TargetPath:    "/target",
+			MountedAt:     time.Now(),
+		}); err != nil {
+		t.Fatalf("Failed to write mount record: %s", err)
+	}
+
+	monitor.scheduleRestart(restartTestContainerName)
+
+	restartState := monitor.restarts[restartTestContainerName]
+	if restartState.crashLoop || len(restartState.restartedAt) != 1 {
+		t.Errorf("Expected the restarts to start over, got crash loop %v and %d restarts",
+			restartState.crashLoop,
+			len(restartState.restartedAt))
+	}
+}
+
+func TestForgetRestartsIfRunning(t *testing.T) {
+	monitor, fake := newRestartTestMonitor(t)
+
+	restartState := &restartState{restartedAt: []time.Time{time.Now(), time.Now()}}
+	monitor.restarts[restartTestContainerName] = restartState
+
+	// restarted again meanwhile
+	monitor.forgetRestartsIfRunning(restartTestContainerName, restartState, 1)
+	if monitor.restarts[restartTestContainerName] == nil {
+		t.Fatalf("Expected the restarts of a container restarted again to be kept")
+	}
+
+	// exited
+	if err := fake.SimulateExit(restartTestContainerName, 1); err != nil {
+		t.Fatalf("Failed to simulate exit: %s", err)
+	}
+
+	monitor.forgetRestartsIfRunning(restartTestContainerName, restartState, 2)
+	if monitor.restarts[restartTestContainerName] == nil {
+		t.Fatalf("Expected the restarts of an exited container to be kept")
+	}
+
+	// survived the window
+	if err := fake.RestartContainer(restartTestContainerName); err != nil {
+		t.Fatalf("Failed to restart container: %s", err)
+	}
+
+	monitor.forgetRestartsIfRunning(restartTestContainerName, restartState, 2)
+	if monitor.restarts[restartTestContainerName] != nil {
+		t.Errorf("Expected the restarts of a running container to be reset")
+	}
+}
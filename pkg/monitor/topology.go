@@ -0,0 +1,84 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package monitor
+
+import (
+	"os"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/kube"
+	"github.com/v3io/flex-fuse/pkg/state"
+)
+
+// name of the node info file in the state dir, the flex equivalent of CSI NodeGetInfo
+const nodeInfoName = "node-info.json"
+
+// label marking nodes with connectivity to a data cluster, followed by the cluster name
+const clusterLabelPrefix = "cluster.v3io.io/"
+
+type NodeInfo struct {
+	NodeName string            `json:"nodeName"`
+	Topology map[string]string `json:"topology"`
+}
+
+// reportTopology writes the node info file and, when running in a cluster, labels the node with its
+// topology so that pods using the driver can be scheduled with node affinity
+func (m *Monitor) reportTopology() {
+	nodeInfo := NodeInfo{
+		NodeName: os.Getenv("NODE_NAME"),
+		Topology: m.getTopology(),
+	}
+
+	if err := state.New(m.config.StateDir).WriteJSON(nodeInfoName, &nodeInfo); err != nil {
+		journal.Warn("Failed to write node info", "err", err.Error())
+	}
+
+	if nodeInfo.NodeName == "" || len(nodeInfo.Topology) == 0 {
+		return
+	}
+
+	kubeClient, err := kube.NewInClusterClient()
+	if err != nil {
+		journal.Debug("Not labeling node with topology", "reason", err.Error())
+		return
+	}
+
+	if err := kubeClient.PatchNodeLabels(nodeInfo.NodeName, nodeInfo.Topology); err != nil {
+		journal.Warn("Failed to label node with topology", "nodeName", nodeInfo.NodeName, "err", err.Error())
+		return
+	}
+
+	journal.Info("Labeled node with topology", "nodeName", nodeInfo.NodeName, "topology", nodeInfo.Topology)
+}
+
+// getTopology returns the configured topology labels along with a label per data cluster
+func (m *Monitor) getTopology() map[string]string {
+	topology := map[string]string{}
+
+	for key, value := range m.config.Topology {
+		topology[key] = value
+	}
+
+	for _, clusterConfig := range m.config.Clusters {
+		topology[clusterLabelPrefix+clusterConfig.Name] = "true"
+	}
+
+	return topology
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+
+// Package mounter mounts v3io data containers through FUSE containers, as the flexvolume driver does, for Go
+// programs that manage mounts themselves (e.g. node agents)
+package mounter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+// DirToCreate is a directory created in the mount once it's ready
+type DirToCreate struct {
+	Name        string
+	Permissions os.FileMode
+}
+
+// MountRequest describes a mount. Only TargetPath and AccessKey are required
+type MountRequest struct {
+	TargetPath string
+	AccessKey  string
+
+	// Cluster is the data cluster from the configuration's clusters ("default" if empty), and Container and
+	// SubPath are what's mounted from it - all containers if empty
+	Cluster   string
+	Container string
+	SubPath   string
+
+	// PodName, Namespace and VolumeName identify the mount's consumer in logs and naming
+	PodName    string
+	Namespace  string
+	VolumeName string
+
+	DirsToCreate []DirToCreate
+
+	// ConnectionPoolSize, DataInterface, DataSourceIP, FUSEOptions and Profile are the volume options of the
+	// same names, using the configuration's defaults if empty
+	ConnectionPoolSize int
+	DataInterface      string
+	DataSourceIP       string
+	FUSEOptions        []string
+	Profile            string
+
+	// Image overrides the configured FUSE image, if allowed by the configuration's allowed_images
+	Image string
+
+	// DockerConfigJSON holds credentials for pulling the FUSE image, in the .dockerconfigjson format
+	DockerConfigJSON string
+}
+
+// Mounter mounts and unmounts. It's safe for concurrent use on different target paths
+type Mounter struct {
+	config *config.Config
+}
+
+// New creates a mounter with a configuration
+func New(mounterConfig *config.Config) *Mounter {
+	return &Mounter{
+		config: mounterConfig,
+	}
+}
+
+// NewFromDefaultConfig creates a mounter with the driver's configuration (/etc/v3io/fuse/v3io.conf, or
+// V3IO_FUSE_CONFIG)
+func NewFromDefaultConfig() (*Mounter, error) {
+	mounterConfig, err := config.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return New(mounterConfig), nil
+}
+
+// Mount mounts a request's target path, returning once the mount is ready. The context's deadline bounds the
+// mount, unless the configured mount timeout is shorter. Cancellation is only honoured before the mount starts -
+// once started, it runs until it's ready, fails or its deadline passes, as abandoning it midway would leave a FUSE
+// container serving nothing behind
+func (m *Mounter) Mount(ctx context.Context, request *MountRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	spec, err := m.getSpec(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	encodedSpec, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	return getResponseError(flex.NewMounterFromConfig(m.config).Mount(request.TargetPath, string(encodedSpec)))
+}
+
+// Unmount unmounts a target path, removing its FUSE container. As with Mount, cancellation is only honoured before
+// the unmount starts, and it isn't bounded by the context's deadline
+func (m *Mounter) Unmount(ctx context.Context, targetPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return getResponseError(flex.NewMounterFromConfig(m.config).Unmount(targetPath))
+}
+
+func (m *Mounter) getSpec(ctx context.Context, request *MountRequest) (*flex.Spec, error) {
+	if request.TargetPath == "" {
+		return nil, errors.New("Target path is required")
+	}
+
+	spec := flex.Spec{
+		OverrideAccessKey: request.AccessKey,
+		Cluster:           request.Cluster,
+		Container:         request.Container,
+		SubPath:           request.SubPath,
+		PodName:           request.PodName,
+		Namespace:         request.Namespace,
+		Name:              request.VolumeName,
+		DataInterface:     request.DataInterface,
+		DataSourceIP:      request.DataSourceIP,
+		FUSEOptions:       strings.Join(request.FUSEOptions, ","),
+		Profile:           request.Profile,
+		Image:             request.Image,
+		DockerConfigJSON:  request.DockerConfigJSON,
+	}
+
+	if request.ConnectionPoolSize != 0 {
+		spec.ConnectionPool = strconv.Itoa(request.ConnectionPoolSize)
+	}
+
+	if deadline, found := ctx.Deadline(); found {
+		mountTimeout := time.Until(deadline)
+		if mountTimeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+
+		configuredMountTimeout := time.Duration(m.config.MountTimeoutSeconds) * time.Second
+		if configuredMountTimeout == 0 || mountTimeout < configuredMountTimeout {
+			spec.MountTimeout = mountTimeout.String()
+		}
+	}
+
+	if len(request.DirsToCreate) > 0 {
+		var dirsToCreate []flex.DirToCreate
+		for _, dirToCreate := range request.DirsToCreate {
+			dirsToCreate = append(dirsToCreate, flex.DirToCreate{
+				Name:        dirToCreate.Name,
+				Permissions: dirToCreate.Permissions,
+			})
+		}
+
+		encodedDirsToCreate, err := json.Marshal(dirsToCreate)
+		if err != nil {
+			return nil, err
+		}
+
+		spec.DirsToCreate = string(encodedDirsToCreate)
+	}
+
+	return &spec, nil
+}
+
+func getResponseError(response *flex.Response) error {
+	if response.Status == "Failure" {
+		return fmt.Errorf("%s", response.Message)
+	}
+
+	return nil
+}
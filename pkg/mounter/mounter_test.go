@@ -0,0 +1,290 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package mounter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/config"
+	"github.com/v3io/flex-fuse/pkg/cri"
+	"github.com/v3io/flex-fuse/pkg/flex"
+)
+
+// newFakeMounter returns a mounter whose runtime is a fake CRI
+func newFakeMounter(t *testing.T) (*Mounter, *cri.Fake) {
+	tempDir := t.TempDir()
+	configPath := path.Join(tempDir, "config.json")
+
+	if err := ioutil.WriteFile(configPath, []byte(fmt.Sprintf(`{
+		"clusters": [{"name": "default", "data_urls": ["tcp://127.0.0.1:1234"]}],
+		"runtime_endpoint": "fake://%s",
+		"state_dir": "%s",
+		"propagation_check": "off",
+		"operation_log_dir": "-",
+		"mount_events": {"delay_seconds": -1}
+	}`, t.Name(), path.Join(tempDir, "state"))), 0644); err != nil {
+		t.Fatalf("Failed to write configuration: %s", err)
+	}
+
+	mounterConfig, err := config.NewFromFile(configPath, true)
+	if err != nil {
+		t.Fatalf("Failed to read configuration: %s", err)
+	}
+
+	fake := cri.NewFake()
+	cri.RegisterFake(t.Name(), fake)
+
+	return New(mounterConfig), fake
+}
+
+// getFakeTargetPath returns a kubelet like target path under a temporary directory
+func getFakeTargetPath(t *testing.T) string {
+	targetPath := path.Join(t.TempDir(), "pods/0a1b2c3d-pod/volumes/v3io~fuse/data")
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		t.Fatalf("Failed to create target path: %s", err)
+	}
+
+	return targetPath
+}
+
+func TestGetSpec(t *testing.T) {
+	for _, testCase := range []struct {
+		name                 string
+		request              MountRequest
+		timeout              time.Duration
+		mountTimeoutSeconds  int
+		expectedMountTimeout bool
+		expectedError        bool
+	}{
+		{
+			name:    "no deadline",
+			request: MountRequest{TargetPath: "/mnt/data"},
+		},
+		{
+			name:                 "deadline",
+			request:              MountRequest{TargetPath: "/mnt/data"},
+			timeout:              time.Minute,
+			expectedMountTimeout: true,
+		},
+		{
+			name:                 "deadline before the configured mount timeout",
+			request:              MountRequest{TargetPath: "/mnt/data"},
+			timeout:              time.Minute,
+			mountTimeoutSeconds:  120,
+			expectedMountTimeout: true,
+		},
+		{
+			name:                "deadline after the configured mount timeout",
+			request:             MountRequest{TargetPath: "/mnt/data"},
+			timeout:             time.Minute,
+			mountTimeoutSeconds: 30,
+		},
+		{
+			name:          "deadline passed",
+			request:       MountRequest{TargetPath: "/mnt/data"},
+			timeout:       -time.Second,
+			expectedError: true,
+		},
+		{
+			name:          "no target path",
+			expectedError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			fuseMounter := New(&config.Config{MountTimeoutSeconds: testCase.mountTimeoutSeconds})
+
+			ctx := context.Background()
+			if testCase.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, testCase.timeout)
+				defer cancel()
+			}
+
+			spec, err := fuseMounter.getSpec(ctx, &testCase.request)
+			if testCase.expectedError {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to get spec: %s", err)
+			}
+
+			if (spec.MountTimeout != "") != testCase.expectedMountTimeout {
+				t.Fatalf("Expected a mount timeout: %t, got %q", testCase.expectedMountTimeout, spec.MountTimeout)
+			}
+
+			if spec.MountTimeout == "" {
+				return
+			}
+
+			if mountTimeout, err := time.ParseDuration(spec.MountTimeout); err != nil || mountTimeout > testCase.timeout {
+				t.Fatalf("Expected a mount timeout of at most %s, got %s", testCase.timeout, spec.MountTimeout)
+			}
+		})
+	}
+}
+
+func TestGetSpecOptions(t *testing.T) {
+	fuseMounter := New(&config.Config{})
+
+	spec, err := fuseMounter.getSpec(context.Background(), &MountRequest{
+		TargetPath:         "/mnt/data",
+		AccessKey:          "key",
+		Container:          "bigdata",
+		ConnectionPoolSize: 8,
+		FUSEOptions:        []string{"allow_other", "ro"},
+		DirsToCreate:       []DirToCreate{{Name: "logs", Permissions: 0750}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get spec: %s", err)
+	}
+
+	if spec.OverrideAccessKey != "key" || spec.Container != "bigdata" {
+		t.Errorf("Expected the access key and container of the request, got %q and %q",
+			spec.OverrideAccessKey,
+			spec.Container)
+	}
+
+	if spec.ConnectionPool != "8" || spec.FUSEOptions != "allow_other,ro" {
+		t.Errorf("Expected the request's options, got %q and %q", spec.ConnectionPool, spec.FUSEOptions)
+	}
+
+	var dirsToCreate []flex.DirToCreate
+	if err := json.Unmarshal([]byte(spec.DirsToCreate), &dirsToCreate); err != nil {
+		t.Fatalf("Failed to parse dirsToCreate %q: %s", spec.DirsToCreate, err)
+	}
+
+	if len(dirsToCreate) != 1 || dirsToCreate[0].Name != "logs" || dirsToCreate[0].Permissions != 0750 {
+		t.Errorf("Expected the request's dirs to create, got %+v", dirsToCreate)
+	}
+}
+
+func TestMountCancelled(t *testing.T) {
+	fuseMounter, fake := newFakeMounter(t)
+	targetPath := getFakeTargetPath(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := fuseMounter.Mount(ctx, &MountRequest{TargetPath: targetPath, AccessKey: "key"}); err != context.Canceled {
+		t.Fatalf("Expected the mount to be cancelled, got %v", err)
+	}
+
+	if err := fuseMounter.Unmount(ctx, targetPath); err != context.Canceled {
+		t.Fatalf("Expected the unmount to be cancelled, got %v", err)
+	}
+
+	if containerNames, _ := fake.ListOwned(); len(containerNames) != 0 {
+		t.Errorf("Expected no containers, got %v", containerNames)
+	}
+}
+
+func TestMountFailure(t *testing.T) {
+	fuseMounter, fake := newFakeMounter(t)
+	targetPath := getFakeTargetPath(t)
+
+	err := fuseMounter.Mount(context.Background(), &MountRequest{
+		TargetPath: targetPath,
+		AccessKey:  "key",
+		Image:      "registry.example.com/v3io-fuse:latest",
+	})
+	if err == nil || !strings.Contains(err.Error(), "allowed_images") {
+		t.Fatalf("Expected the mount to fail as the image isn't allowed, got %v", err)
+	}
+
+	if containerNames, _ := fake.ListOwned(); len(containerNames) != 0 {
+		t.Errorf("Expected no containers, got %v", containerNames)
+	}
+}
+
+func TestUnmountNotMounted(t *testing.T) {
+	fuseMounter, _ := newFakeMounter(t)
+
+	if err := fuseMounter.Unmount(context.Background(), getFakeTargetPath(t)); err != nil {
+		t.Fatalf("Expected unmounting a target path that isn't mounted to succeed, got %s", err)
+	}
+}
+
+func TestMountAndUnmount(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Mounting a tmpfs in place of the FUSE mount requires root")
+	}
+
+	if filesystems, err := ioutil.ReadFile("/proc/filesystems"); err != nil ||
+		!strings.Contains(string(filesystems), "\tfuse\n") {
+		t.Skip("FUSE is unavailable")
+	}
+
+	fuseMounter, fake := newFakeMounter(t)
+	targetPath := getFakeTargetPath(t)
+
+	// the FUSE process would mount the target path
+	fake.OnCreate = func(container *cri.FakeContainer) error {
+		output, err := exec.Command("mount", "-t", "tmpfs", "fake-fuse", container.TargetPath).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Failed to mount a tmpfs: %s (%s)", err, output)
+		}
+
+		return nil
+	}
+
+	defer exec.Command("umount", targetPath).Run() // nolint: errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := fuseMounter.Mount(ctx, &MountRequest{
+		TargetPath:   targetPath,
+		AccessKey:    "key",
+		Container:    "bigdata",
+		DirsToCreate: []DirToCreate{{Name: "logs", Permissions: 0750}},
+	}); err != nil {
+		t.Fatalf("Expected the mount to succeed, got %s", err)
+	}
+
+	if containerNames, _ := fake.ListOwned(); len(containerNames) != 1 {
+		t.Fatalf("Expected a container, got %v", containerNames)
+	}
+
+	if _, err := os.Stat(path.Join(targetPath, "logs")); err != nil {
+		t.Errorf("Expected the dirs to create in the mount: %s", err)
+	}
+
+	if err := fuseMounter.Unmount(ctx, targetPath); err != nil {
+		t.Fatalf("Expected the unmount to succeed, got %s", err)
+	}
+
+	if containerNames, _ := fake.ListOwned(); len(containerNames) != 0 {
+		t.Errorf("Expected no containers, got %v", containerNames)
+	}
+}
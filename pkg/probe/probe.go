@@ -0,0 +1,91 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package probe
+
+import (
+	"encoding/json"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"github.com/v3io/flex-fuse/pkg/state"
+)
+
+const probesDir = "probes"
+
+var (
+	cacheLock  sync.Mutex
+	cacheState *state.State
+	cacheTTL   time.Duration
+)
+
+type cachedProbe struct {
+	ProbedAt time.Time       `json:"probedAt"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// SetCache caches probe results in a state directory for a TTL, so that driver invocations don't re-probe the
+// environment. Without a cache (or with a TTL of 0), probes run every time
+func SetCache(probeState *state.State, ttl time.Duration) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	cacheState = probeState
+	cacheTTL = ttl
+}
+
+// Cached fills a value by running a probe, unless a result of it was cached within the TTL
+func Cached(name string, value interface{}, probe func() error) error {
+	cacheLock.Lock()
+	probeState, ttl := cacheState, cacheTTL
+	cacheLock.Unlock()
+
+	if probeState == nil || ttl <= 0 {
+		return probe()
+	}
+
+	documentName := path.Join(probesDir, name+".json")
+
+	cached := cachedProbe{}
+	if err := probeState.ReadJSON(documentName, &cached); err == nil && time.Since(cached.ProbedAt) < ttl {
+		if err := json.Unmarshal(cached.Value, value); err == nil {
+			return nil
+		}
+	}
+
+	if err := probe(); err != nil {
+		return err
+	}
+
+	encodedValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if err := probeState.WriteJSON(documentName, &cachedProbe{
+		ProbedAt: time.Now(),
+		Value:    encodedValue,
+	}); err != nil {
+		journal.Debug("Failed to cache probe", "name", name, "err", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/v3io/flex-fuse/pkg/journal"
+	"golang.org/x/sys/unix"
+)
+
+// interval of retrying a lock held by another process
+const lockRetryInterval = 100 * time.Millisecond
+
+// Lock is an exclusive lock of a document, held until unlocked or the holding process exits
+type Lock struct {
+	file *os.File
+}
+
+// lockHolder identifies the process holding a lock, recorded in the lock file for troubleshooting
+type lockHolder struct {
+	PID int `json:"pid"`
+}
+
+// Lock acquires an exclusive lock of a document, waiting while another process holds it. The lock is a flock of
+// the lock file, which the kernel releases when the holder exits - even if it crashed - as the file isn't
+// inherited by the processes it starts (Go opens files close-on-exec). A lock is therefore never left held by a
+// dead process, and is never broken
+func (s *State) Lock(name string) (*Lock, error) {
+	lockPath := s.Path(name) + ".lock"
+
+	if err := os.MkdirAll(path.Dir(lockPath), 0700); err != nil {
+		return nil, err
+	}
+
+	lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	waitingSince := time.Time{}
+
+	for {
+		err = unix.Flock(int(lockFile.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+
+		if err != unix.EWOULDBLOCK {
+			lockFile.Close() // nolint: errcheck
+			return nil, err
+		}
+
+		if waitingSince.IsZero() {
+			waitingSince = time.Now()
+			journal.Debug("Waiting for lock", "path", lockPath, "holderPID", readLockHolderPID(lockPath))
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+
+	if !waitingSince.IsZero() {
+		journal.Debug("Acquired lock", "path", lockPath, "waited", time.Since(waitingSince).String())
+	}
+
+	lock := Lock{file: lockFile}
+	lock.recordHolder()
+
+	return &lock, nil
+}
+
+// Unlock releases the lock, clearing its holder first so that waiters don't report a released holder
+func (l *Lock) Unlock() error {
+	if err := l.file.Truncate(0); err != nil {
+		journal.Debug("Failed to clear lock holder", "path", l.file.Name(), "err", err.Error())
+	}
+
+	return l.file.Close()
+}
+
+func (l *Lock) recordHolder() {
+	encodedHolder, err := json.Marshal(&lockHolder{PID: os.Getpid()})
+	if err != nil {
+		return
+	}
+
+	if err := l.file.Truncate(0); err != nil {
+		journal.Debug("Failed to record lock holder", "path", l.file.Name(), "err", err.Error())
+		return
+	}
+
+	if _, err := l.file.WriteAt(encodedHolder, 0); err != nil {
+		journal.Debug("Failed to record lock holder", "path", l.file.Name(), "err", err.Error())
+	}
+}
+
+// readLockHolderPID returns the pid recorded by a lock's holder, or 0 if it's not recorded (yet)
+func readLockHolderPID(lockPath string) int {
+	content, err := ioutil.ReadFile(lockPath)
+	if err != nil || len(content) == 0 {
+		return 0
+	}
+
+	holder := lockHolder{}
+	if err := json.Unmarshal(content, &holder); err != nil {
+		return 0
+	}
+
+	return holder.PID
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package state
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+const testLockName = "locks/target"
+
+func TestLockContention(t *testing.T) {
+	state := New(t.TempDir())
+
+	lock, err := state.Lock(testLockName)
+	if err != nil {
+		t.Fatalf("Failed to lock: %s", err)
+	}
+
+	if holderPID := readLockHolderPID(state.Path(testLockName) + ".lock"); holderPID != os.Getpid() {
+		t.Errorf("Expected the holder pid to be %d, got %d", os.Getpid(), holderPID)
+	}
+
+	acquired := make(chan *Lock)
+	go func() {
+		secondLock, err := state.Lock(testLockName)
+		if err != nil {
+			t.Errorf("Failed to lock: %s", err)
+		}
+
+		acquired <- secondLock
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected the lock to be held exclusively")
+	case <-time.After(3 * lockRetryInterval):
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Failed to unlock: %s", err)
+	}
+
+	select {
+	case secondLock := <-acquired:
+		secondLock.Unlock() // nolint: errcheck
+	case <-time.After(10 * lockRetryInterval):
+		t.Fatal("Expected the lock to be acquired once released")
+	}
+}
+
+func TestLockRelease(t *testing.T) {
+	state := New(t.TempDir())
+
+	for attempt := 0; attempt < 2; attempt++ {
+		lock, err := state.Lock(testLockName)
+		if err != nil {
+			t.Fatalf("Failed to lock: %s", err)
+		}
+
+		if err := lock.Unlock(); err != nil {
+			t.Fatalf("Failed to unlock: %s", err)
+		}
+
+		if holderPID := readLockHolderPID(state.Path(testLockName) + ".lock"); holderPID != 0 {
+			t.Errorf("Expected the holder to be cleared on unlock, got %d", holderPID)
+		}
+	}
+}
+
+// TestLockCrashedHolderProcess is run by TestLockCrashedHolder in a process of its own, which exits holding the lock
+func TestLockCrashedHolderProcess(t *testing.T) {
+	stateDir := os.Getenv("FLEX_FUSE_TEST_LOCK_STATE_DIR")
+	if stateDir == "" {
+		t.Skip("Run by TestLockCrashedHolder")
+	}
+
+	if _, err := New(stateDir).Lock(testLockName); err != nil {
+		os.Exit(2)
+	}
+
+	// a child process outliving the holder doesn't keep the lock
+	if err := exec.Command("sleep", "5").Start(); err != nil {
+		os.Exit(2)
+	}
+
+	os.Exit(1)
+}
+
+func TestLockCrashedHolder(t *testing.T) {
+	stateDir := t.TempDir()
+
+	command := exec.Command(os.Args[0], "-test.run=^TestLockCrashedHolderProcess$")
+	command.Env = append(os.Environ(), "FLEX_FUSE_TEST_LOCK_STATE_DIR="+stateDir)
+	if err := command.Run(); err == nil || command.ProcessState.ExitCode() != 1 {
+		t.Fatalf("Expected the holder to exit holding the lock, got %v", err)
+	}
+
+	acquired := make(chan *Lock)
+	go func() {
+		lock, err := New(stateDir).Lock(testLockName)
+		if err != nil {
+			t.Errorf("Failed to lock: %s", err)
+		}
+
+		acquired <- lock
+	}()
+
+	select {
+	case lock := <-acquired:
+		lock.Unlock() // nolint: errcheck
+	case <-time.After(10 * lockRetryInterval):
+		t.Fatal("Expected the lock of a crashed holder to be released")
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// State persists JSON documents under a node local directory, for the driver invocations
+// and tooling to share
+type State struct {
+	dir string
+}
+
+func New(dir string) *State {
+	return &State{
+		dir: dir,
+	}
+}
+
+// Dir returns the path of the state directory
+func (s *State) Dir() string {
+	return s.dir
+}
+
+// Path returns the path of a document in the state directory
+func (s *State) Path(name string) string {
+	return path.Join(s.dir, name)
+}
+
+// WriteJSON atomically writes a value as a JSON document
+func (s *State) WriteJSON(name string, value interface{}) error {
+	documentPath := s.Path(name)
+
+	if err := os.MkdirAll(path.Dir(documentPath), 0700); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename it, so readers never see a partial document
+	temporaryFile, err := ioutil.TempFile(path.Dir(documentPath), path.Base(documentPath)+".")
+	if err != nil {
+		return err
+	}
+
+	if _, err := temporaryFile.Write(content); err != nil {
+		temporaryFile.Close()           // nolint: errcheck
+		os.Remove(temporaryFile.Name()) // nolint: errcheck
+		return err
+	}
+
+	if err := temporaryFile.Close(); err != nil {
+		os.Remove(temporaryFile.Name()) // nolint: errcheck
+		return err
+	}
+
+	return os.Rename(temporaryFile.Name(), documentPath)
+}
+
+// ReadJSON reads a JSON document into a value
+func (s *State) ReadJSON(name string, value interface{}) error {
+	content, err := ioutil.ReadFile(s.Path(name))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, value)
+}
+
+// UpdateJSON reads a JSON document into a value (left as is if the document doesn't exist), updates it and
+// writes it back, holding an exclusive lock so that concurrent invocations don't lose each other's updates
+func (s *State) UpdateJSON(name string, value interface{}, update func() error) error {
+	lock, err := s.Lock(name)
+	if err != nil {
+		return err
+	}
+
+	defer lock.Unlock() // nolint: errcheck
+
+	if err := s.ReadJSON(name, value); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := update(); err != nil {
+		return err
+	}
+
+	return s.WriteJSON(name, value)
+}
+
+// Remove removes a document, if it exists
+func (s *State) Remove(name string) error {
+	if err := os.Remove(s.Path(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// NameFromPath converts a path (e.g. a target path) to a document name
+func NameFromPath(pathToConvert string) string {
+	return strings.Replace(strings.Trim(pathToConvert, "/"), "/", "-", -1)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2018 Iguazio Systems Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License") with
+an addition restriction as set forth herein. You may not use this
+file except in compliance with the License. You may obtain a copy of
+the License at http://www.apache.org/licenses/LICENSE-2.0.
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing
+permissions and limitations under the License.
+
+In addition, you may not use the software for any purposes that are
+illegal under applicable law, and the grant of the foregoing license
+under the Apache 2.0 license is conditioned upon your compliance with
+such restriction.
+*/
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// populated at link time, e.g. -ldflags "-X github.com/v3io/flex-fuse/pkg/version.Version=1.2.3"
+var (
+	Version   = "unstable"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+func Get() *Info {
+	return &Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// Labels returns the version info as container labels
+func (i *Info) Labels() map[string]string {
+	return map[string]string{
+		"io.iguazio.flex-fuse/version":    i.Version,
+		"io.iguazio.flex-fuse/commit":     i.Commit,
+		"io.iguazio.flex-fuse/build-date": i.BuildDate,
+	}
+}
+
+func (i *Info) String() string {
+	return fmt.Sprintf("Version[Version=%s, Commit=%s, BuildDate=%s, GoVersion=%s]",
+		i.Version,
+		i.Commit,
+		i.BuildDate,
+		i.GoVersion)
+}